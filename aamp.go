@@ -0,0 +1,236 @@
+package matrixprofile
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/matrix-profile-foundation/go-matrixprofile/util"
+	"gonum.org/v1/gonum/floats"
+	"gonum.org/v1/gonum/stat"
+)
+
+// nonNormalized returns whether the matrix profile compares subsequences with the plain
+// euclidean distance rather than the z-normalized distance, so distance profiles used
+// after the computation, such as for motifs and streaming updates, agree with it.
+func (mp MatrixProfile) nonNormalized() bool {
+	return mp.Opts != nil && (mp.Opts.Algorithm == AlgoAAMP || mp.Opts.Algorithm == AlgoACAMP)
+}
+
+// meanCentered returns whether non-normalized distances are taken between mean
+// centered subsequences, which ignores differences in level but keeps differences in
+// amplitude. The squared distance is then the plain one minus w*(muA-muB)^2.
+func (mp MatrixProfile) meanCentered() bool {
+	return mp.Normalization == NormMean && mp.nonNormalized()
+}
+
+// validateNormalization checks that the normalization of the matrix profile is
+// supported by the options.
+func (mp MatrixProfile) validateNormalization(o *MPOpts) error {
+	switch mp.Normalization {
+	case "", NormZ:
+		return nil
+	case NormMean:
+		if o.Algorithm != AlgoAAMP && o.Algorithm != AlgoACAMP {
+			return fmt.Errorf("mean normalization is only supported by algorithms %s and %s, got %s", AlgoAAMP, AlgoACAMP, o.Algorithm)
+		}
+		if o.Manhattan {
+			return errors.New("mean normalization doesn't support manhattan distances")
+		}
+		return nil
+	}
+	return fmt.Errorf("unsupported normalization, %s", mp.Normalization)
+}
+
+// aamp computes the matrix profile with AAMP, using the plain euclidean distance
+// between subsequences without z-normalizing them so differences in level and amplitude
+// are kept, or only amplitude when mean centered. Each batch of rows of the distance
+// matrix starts from a sliding dot product and updates it for the following rows with
+// the stomp recurrence.
+func (mp *MatrixProfile) aamp() error {
+	if err := mp.initCaches(); err != nil {
+		return err
+	}
+
+	mp.MP = make([]float64, mp.N-mp.W+1)
+	mp.Idx = make([]int, mp.N-mp.W+1)
+	for i := 0; i < len(mp.MP); i++ {
+		mp.MP[i] = math.Inf(1)
+		mp.Idx[i] = math.MaxInt64
+	}
+
+	aSq := movSumSq(mp.A, mp.W)
+	bSq := movSumSq(mp.B, mp.W)
+	batchSize := (len(mp.A)-mp.W+1)/mp.Opts.NJobs + 1
+	return mp.runBatches(func(batch int) *mpResult {
+		return mp.aampBatch(batch*batchSize, batchSize, aSq, bSq)
+	})
+}
+
+// aampBatch processes size rows of the distance matrix starting at row start.
+func (mp MatrixProfile) aampBatch(start, size int, aSq, bSq []float64) *mpResult {
+	n := len(mp.A) - mp.W + 1
+	if start >= n {
+		// got an index larger than mp.A so ignore
+		return &mpResult{}
+	}
+	end := start + size
+	if end > n {
+		end = n
+	}
+
+	dot, err := mp.crossCorrelate(mp.A[start:start+mp.W], mp.newFFT())
+	if err != nil {
+		return &mpResult{nil, nil, nil, nil, err}
+	}
+
+	result := newBatchResult(len(dot))
+	profile := make([]float64, len(dot))
+	for i := start; i < end; i++ {
+		if i > start {
+			for j := len(dot) - 1; j > 0; j-- {
+				dot[j] = dot[j-1] - mp.B[j-1]*mp.A[i-1] + mp.B[j+mp.W-1]*mp.A[i+mp.W-1]
+			}
+			// the first dot product is not covered by the update above
+			dot[0] = floats.Dot(mp.A[i:i+mp.W], mp.B[:mp.W])
+		}
+		mp.rawDistances(dot, aSq[i], mp.AMean[i], bSq, profile)
+		if mp.SelfJoin {
+			mp.applyExclusionZone(profile, i, 0, mp.exclusionZone(mp.W/2))
+		}
+
+		for j := range profile {
+			if isBetterMatch(profile[j], i, result.MP[j], result.Idx[j], true) {
+				result.MP[j] = profile[j]
+				result.Idx[j] = i
+			}
+		}
+	}
+	return result
+}
+
+// rawMass writes the plain euclidean distance between the query and every subsequence
+// of b to profile.
+func (mp MatrixProfile) rawMass(q []float64, profile []float64, fft *fftCache) error {
+	dot, err := mp.crossCorrelate(q, fft)
+	if err != nil {
+		return err
+	}
+	mp.rawDistances(dot, floats.Dot(q, q), stat.Mean(q, nil), movSumSq(mp.B, len(q)), profile[:len(dot)])
+	return nil
+}
+
+// rawDistances converts the sliding dot products of a query with a sum of squares of
+// qSq and a mean of qMean into plain euclidean distances, |q|^2 + |b|^2 - 2*dot, given
+// the sliding sums of squares of b. Mean centered distances also subtract
+// w*(qMean-bMean)^2 using the sliding mean of b.
+func (mp MatrixProfile) rawDistances(dot []float64, qSq, qMean float64, bSq, profile []float64) {
+	limit, squared := mp.squaredLimit(), mp.squared()
+	centered := mp.meanCentered()
+	w := float64(mp.W)
+	for j, d := range dot {
+		sq := qSq + bSq[j] - 2*d
+		if centered {
+			diff := qMean - mp.BMean[j]
+			sq -= w * diff * diff
+		}
+		// rounding can take the distance of identical subsequences below 0
+		profile[j] = finishDistance(math.Max(sq, 0), limit, squared)
+	}
+}
+
+// movSumSq returns the sum of squares of each sliding window of length w.
+func movSumSq(ts []float64, w int) []float64 {
+	out := make([]float64, len(ts)-w+1)
+	var sum float64
+	for i, v := range ts {
+		sum += v * v
+		if i >= w {
+			sum -= ts[i-w] * ts[i-w]
+		}
+		if i >= w-1 {
+			out[i-w+1] = sum
+		}
+	}
+	return out
+}
+
+// acamp computes the same plain euclidean matrix profile as AAMP without any fourier
+// transforms. Each diagonal of the distance matrix starts from one direct distance and
+// keeps a running sum of squared differences, adding the pair of points entering the
+// subsequences and removing the pair leaving them, so the join takes O(n^2) time. Mean
+// centered distances also keep a running sum of the differences, since the squared
+// distance of mean centered subsequences is the variance of their differences times w.
+func (mp *MatrixProfile) acamp() error {
+	lenA := len(mp.A) - mp.W + 1
+	lenB := len(mp.B) - mp.W + 1
+	mp.MP = make([]float64, lenB)
+	mp.Idx = make([]int, lenB)
+	for i := 0; i < lenB; i++ {
+		mp.MP[i] = math.Inf(1)
+		mp.Idx[i] = math.MaxInt64
+	}
+
+	// a self join only visits the diagonals above the main one, which hold each pair
+	// once, while an AB join visits every diagonal from the last subsequence of a
+	// against the first of b to the first of a against the last of b
+	first, count := 1, lenA-1
+	if !mp.SelfJoin {
+		first, count = 1-lenA, lenA+lenB-1
+	}
+	batchScheme := util.DiagBatchingScheme(count, mp.Opts.NJobs)
+	return mp.runBatches(func(batch int) *mpResult {
+		b := batchScheme[batch]
+		end := b.Idx + b.Size
+		if end > count {
+			end = count
+		}
+		return mp.acampBatch(first+b.Idx, first+end, lenB)
+	})
+}
+
+// acampBatch processes the diagonals from start up to end, where diagonal k compares
+// the subsequence of a at i with the subsequence of b at i+k.
+func (mp MatrixProfile) acampBatch(start, end, lenB int) *mpResult {
+	lenA := len(mp.A) - mp.W + 1
+	result := newBatchResult(lenB)
+	zone := mp.exclusionZone(mp.W / 2)
+	limit, squared := mp.squaredLimit(), mp.squared()
+	centered, w := mp.meanCentered(), float64(mp.W)
+
+	for k := start; k < end; k++ {
+		i0, j0 := 0, k
+		if k < 0 {
+			i0, j0 = -k, 0
+		}
+
+		var sq, sum float64
+		for t := 0; t < mp.W; t++ {
+			d := mp.A[i0+t] - mp.B[j0+t]
+			sq += d * d
+			sum += d
+		}
+		for i, j := i0, j0; i < lenA && j < lenB; i, j = i+1, j+1 {
+			if i > i0 {
+				enter := mp.A[i+mp.W-1] - mp.B[j+mp.W-1]
+				leave := mp.A[i-1] - mp.B[j-1]
+				sq += enter*enter - leave*leave
+				sum += enter - leave
+			}
+
+			dsq := sq
+			if centered {
+				dsq -= sum * sum / w
+			}
+			// rounding can take the distance of identical subsequences below 0
+			dist := finishDistance(math.Max(dsq, 0), limit, squared)
+			if mp.SelfJoin {
+				mp.updatePair(result, dist, i, j, zone)
+			} else if isBetterMatch(dist, i, result.MP[j], result.Idx[j], true) {
+				result.MP[j] = dist
+				result.Idx[j] = i
+			}
+		}
+	}
+	return result
+}
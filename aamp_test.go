@@ -0,0 +1,301 @@
+package matrixprofile
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"gonum.org/v1/gonum/stat"
+)
+
+// bruteRawProfile computes the plain euclidean matrix profile of every subsequence of
+// b against the subsequences of a, excluding trivial matches of self joins. Centered
+// subtracts the mean of each subsequence first.
+func bruteRawProfile(a, b []float64, w int, selfJoin, squared, centered bool) []float64 {
+	zone := w / 2
+	out := make([]float64, len(b)-w+1)
+	for j := range out {
+		out[j] = math.Inf(1)
+		for i := 0; i+w <= len(a); i++ {
+			if selfJoin && j >= i-zone && j < i+zone {
+				continue
+			}
+			var ma, mb float64
+			if centered {
+				ma, mb = stat.Mean(a[i:i+w], nil), stat.Mean(b[j:j+w], nil)
+			}
+			var d float64
+			for k := 0; k < w; k++ {
+				diff := (a[i+k] - ma) - (b[j+k] - mb)
+				d += diff * diff
+			}
+			if !squared {
+				d = math.Sqrt(d)
+			}
+			out[j] = math.Min(out[j], d)
+		}
+	}
+	return out
+}
+
+func TestComputeAamp(t *testing.T) {
+	r := rand.New(rand.NewSource(5))
+	a := make([]float64, 150)
+	b := make([]float64, 90)
+	for i := range a {
+		a[i] = r.NormFloat64() + float64(i/50)*10
+	}
+	for i := range b {
+		b[i] = r.NormFloat64()
+	}
+
+	testdata := []struct {
+		b       []float64
+		njobs   int
+		squared bool
+	}{
+		{nil, 1, false},
+		{nil, 3, false},
+		{nil, 2, true},
+		{b, 1, false},
+		{b, 4, true},
+	}
+
+	for _, algo := range []Algo{AlgoAAMP, AlgoACAMP} {
+		for i, d := range testdata {
+			mp, err := New(a, d.b, 12)
+			if err != nil {
+				t.Fatal(err)
+			}
+			o := NewMPOpts()
+			o.Algorithm = algo
+			o.NJobs = d.njobs
+			o.Squared = d.squared
+			if err = mp.Compute(o); err != nil {
+				t.Fatal(err)
+			}
+
+			expected := bruteRawProfile(a, mp.B, 12, mp.SelfJoin, d.squared, false)
+			if len(mp.MP) != len(expected) {
+				t.Fatalf("Expected %d elements, but got %d for case %d with %s", len(expected), len(mp.MP), i, algo)
+			}
+			for j := range expected {
+				if math.Abs(mp.MP[j]-expected[j]) > 1e-6 {
+					t.Errorf("Expected %.6f at %d, but got %.6f for case %d with %s", expected[j], j, mp.MP[j], i, algo)
+					break
+				}
+			}
+		}
+	}
+}
+
+func TestComputeAcampCircular(t *testing.T) {
+	r := rand.New(rand.NewSource(2))
+	a := make([]float64, 120)
+	for i := range a {
+		a[i] = r.NormFloat64()
+	}
+
+	var profiles [][]float64
+	for _, algo := range []Algo{AlgoAAMP, AlgoACAMP} {
+		mp, err := New(a, nil, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		o := NewMPOpts()
+		o.Algorithm = algo
+		o.NJobs = 3
+		o.Circular = true
+		if err = mp.Compute(o); err != nil {
+			t.Fatal(err)
+		}
+		profiles = append(profiles, mp.MP)
+	}
+
+	for j := range profiles[0] {
+		if math.Abs(profiles[0][j]-profiles[1][j]) > 1e-6 {
+			t.Errorf("Expected %.6f at %d, but got %.6f", profiles[0][j], j, profiles[1][j])
+			break
+		}
+	}
+}
+
+func TestComputeAampAmplitude(t *testing.T) {
+	// the same shape repeats at twice the amplitude, which z-normalization hides
+	shape := []float64{0, 1, 3, 1, 0, -2, 0, 1}
+	var sig []float64
+	for _, scale := range []float64{1, 2} {
+		for _, v := range shape {
+			sig = append(sig, v*scale)
+		}
+		sig = append(sig, 5, 5, 5, 5)
+	}
+
+	for _, algo := range []Algo{AlgoSTOMP, AlgoAAMP, AlgoACAMP} {
+		mp, err := New(sig, nil, len(shape))
+		if err != nil {
+			t.Fatal(err)
+		}
+		o := NewMPOpts()
+		o.Algorithm = algo
+		if err = mp.Compute(o); err != nil {
+			t.Fatal(err)
+		}
+
+		normalized := algo == AlgoSTOMP
+		if got := mp.MP[0] < 1e-6; got != normalized {
+			t.Errorf("Expected a zero distance between the scaled shapes to be %t with %s, but got %.6f", normalized, algo, mp.MP[0])
+		}
+	}
+}
+
+func TestComputeAampMeanCentered(t *testing.T) {
+	r := rand.New(rand.NewSource(7))
+	a := make([]float64, 150)
+	b := make([]float64, 90)
+	for i := range a {
+		a[i] = r.NormFloat64() + float64(i/50)*10
+	}
+	for i := range b {
+		b[i] = r.NormFloat64() - 20
+	}
+
+	for _, algo := range []Algo{AlgoAAMP, AlgoACAMP} {
+		for _, other := range [][]float64{nil, b} {
+			mp, err := NewWithOpts(a, WithWindow(12), WithNormalization(NormMean))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if other != nil {
+				if mp, err = NewWithOpts(a, WithWindow(12), WithBSeries(other), WithNormalization(NormMean)); err != nil {
+					t.Fatal(err)
+				}
+			}
+			o := NewMPOpts()
+			o.Algorithm = algo
+			if err = mp.Compute(o); err != nil {
+				t.Fatal(err)
+			}
+
+			expected := bruteRawProfile(a, mp.B, 12, mp.SelfJoin, false, true)
+			if len(mp.MP) != len(expected) {
+				t.Fatalf("Expected %d elements, but got %d with %s", len(expected), len(mp.MP), algo)
+			}
+			for j := range expected {
+				if math.Abs(mp.MP[j]-expected[j]) > 1e-6 {
+					t.Errorf("Expected %.6f at %d, but got %.6f with %s for a self join %t", expected[j], j, mp.MP[j], algo, mp.SelfJoin)
+					break
+				}
+			}
+		}
+	}
+
+	// the same shape at another level matches exactly, but not at another amplitude
+	shape := []float64{0, 1, 3, 1, 0, -2, 0, 1}
+	var sig []float64
+	for _, s := range [][2]float64{{1, 0}, {1, 10}, {2, 0}} {
+		for _, v := range shape {
+			sig = append(sig, v*s[0]+s[1])
+		}
+		sig = append(sig, 5, 4, 6, 5)
+	}
+	mp, err := NewWithOpts(sig, WithWindow(len(shape)), WithNormalization(NormMean))
+	if err != nil {
+		t.Fatal(err)
+	}
+	o := NewMPOpts()
+	o.Algorithm = AlgoAAMP
+	if err = mp.Compute(o); err != nil {
+		t.Fatal(err)
+	}
+	if mp.MP[0] > 1e-6 || mp.Idx[0] != 12 {
+		t.Errorf("Expected the shifted shape at 12 as an exact match, but got %d at %.6f", mp.Idx[0], mp.MP[0])
+	}
+	if mp.MP[24] < 1 {
+		t.Errorf("Expected the scaled shape to have no close match, but got %.6f", mp.MP[24])
+	}
+
+	// streaming updates and distance profiles agree with the computation
+	mp, err = NewWithOpts(a[:120], WithWindow(12), WithNormalization(NormMean))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = mp.Compute(o); err != nil {
+		t.Fatal(err)
+	}
+	if err = mp.Update(a[120:]); err != nil {
+		t.Fatal(err)
+	}
+	expected := bruteRawProfile(a, a, 12, true, false, true)
+	for j := range expected {
+		if math.Abs(mp.MP[j]-expected[j]) > 1e-6 {
+			t.Errorf("Expected %.6f at %d after streaming, but got %.6f", expected[j], j, mp.MP[j])
+			break
+		}
+	}
+	prof, err := mp.DistanceProfile(a[30:42])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if math.Abs(prof[mp.Idx[30]]-mp.MP[30]) > 1e-6 {
+		t.Errorf("Expected a distance of %.6f to the nearest neighbor, but got %.6f", mp.MP[30], prof[mp.Idx[30]])
+	}
+
+	for _, o := range []*MPOpts{NewMPOpts(), {Algorithm: AlgoAAMP, NJobs: 1, Euclidean: true, Manhattan: true}} {
+		if err = mp.Compute(o); err == nil {
+			t.Errorf("Expected an error for mean normalization with %s and manhattan %t", o.Algorithm, o.Manhattan)
+		}
+	}
+}
+
+func TestComputeAampUnsampled(t *testing.T) {
+	r := rand.New(rand.NewSource(9))
+	a := make([]float64, 200)
+	for i := range a {
+		a[i] = r.NormFloat64() * float64(1+i/40)
+	}
+	expected := bruteRawProfile(a, a, 16, true, false, false)
+
+	mp, err := New(a, nil, 16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// only STAMP and SCRIMP sample, so AAMP rejects their options
+	o := NewMPOpts()
+	o.Algorithm = AlgoAAMP
+	o.STAMP = &STAMPOpts{SamplePct: 0.3}
+	if err = mp.Compute(o); err == nil {
+		t.Errorf("Expected an error for sampling with %s", o.Algorithm)
+	}
+
+	o.STAMP = nil
+	if err = mp.Compute(o); err != nil {
+		t.Fatal(err)
+	}
+	for j := range expected {
+		if math.Abs(mp.MP[j]-expected[j]) > 1e-6 {
+			t.Errorf("Expected %.6f at %d, but got %.6f", expected[j], j, mp.MP[j])
+			break
+		}
+	}
+
+	// distance profiles used after the computation, such as by motif discovery and
+	// streaming updates, use the same distances as the profile
+	prof := make([]float64, len(mp.MP))
+	if err = mp.distanceProfile(20, prof, mp.newFFT()); err != nil {
+		t.Fatal(err)
+	}
+	for j := range prof {
+		if math.IsInf(prof[j], 1) {
+			continue
+		}
+		var d float64
+		for k := 0; k < 16; k++ {
+			d += (a[20+k] - a[j+k]) * (a[20+k] - a[j+k])
+		}
+		if math.Abs(prof[j]-math.Sqrt(d)) > 1e-6 {
+			t.Errorf("Expected a plain euclidean distance of %.6f at %d, but got %.6f", math.Sqrt(d), j, prof[j])
+			break
+		}
+	}
+}
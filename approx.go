@@ -0,0 +1,141 @@
+package matrixprofile
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/matrix-profile-foundation/go-matrixprofile/util"
+)
+
+// ProfileModel predicts matrix profile values from subsequences, in the manner of
+// LAMP, such as a regression model trained on the matrix profile of reference data
+// and run through an ONNX runtime.
+type ProfileModel interface {
+	// Predict returns the predicted matrix profile value of each z-normalized
+	// subsequence, in the same units as the matrix profile being approximated.
+	Predict(subsequences [][]float64) ([]float64, error)
+}
+
+// ApproxOpts are parameters to vary the approximate matrix profile computed with a
+// learned model.
+type ApproxOpts struct {
+	Model     ProfileModel // model predicting the matrix profile value of each subsequence
+	Verify    int          // number of subsequences with the highest predicted values computed exactly
+	BatchSize int          // number of subsequences passed to the model at once
+}
+
+// NewApproxOpts returns a default ApproxOpts for the model.
+func NewApproxOpts(model ProfileModel) *ApproxOpts {
+	return &ApproxOpts{
+		Model:     model,
+		Verify:    10,
+		BatchSize: 1024,
+	}
+}
+
+// ComputeApprox computes an approximate matrix profile, indexed by the subsequences of
+// a, from the predictions of a learned model. The predictions triage the subsequences
+// for discords, so the ones with the highest predicted values are computed exactly with
+// MASS against b using the given options, which must use euclidean distances. Only
+// verified subsequences have a matrix profile index, and the rest keep their predicted
+// value with an index of math.MaxInt64. Flat subsequences are never verified.
+func (mp *MatrixProfile) ComputeApprox(o *MPOpts, ao *ApproxOpts) error {
+	if o == nil {
+		o = NewMPOpts()
+	}
+	if ao == nil || ao.Model == nil {
+		return errors.New("must provide a model to compute an approximate matrix profile")
+	}
+	if ao.Verify < 0 {
+		return fmt.Errorf("number of subsequences to verify, %d, must be at least 0", ao.Verify)
+	}
+	if ao.BatchSize < 1 {
+		return fmt.Errorf("batch size, %d, must be at least 1", ao.BatchSize)
+	}
+	mp.Opts = o
+	mp.mpxStream = nil
+	mp.stampi = nil
+
+	if err := o.Validate(); err != nil {
+		return err
+	}
+	if err := mp.validateNormalization(o); err != nil {
+		return err
+	}
+	if !o.Euclidean {
+		return errors.New("approximate matrix profiles only support euclidean distances")
+	}
+	if o.recomputesFlat() {
+		return fmt.Errorf("approximate matrix profiles don't support flat mode %s", o.Flat)
+	}
+	if o.Period > 0 {
+		return errors.New("approximate matrix profiles don't support periodic exclusion zones")
+	}
+	if err := mp.applyTransform(o); err != nil {
+		return err
+	}
+	if err := mp.initCaches(); err != nil {
+		return err
+	}
+
+	n := len(mp.A) - mp.W + 1
+	mp.MP = make([]float64, 0, n)
+	mp.Idx = make([]int, n)
+	mp.MPB, mp.IdxB = nil, nil
+	flat := make([]bool, n)
+	batch := make([][]float64, 0, ao.BatchSize)
+	for i := 0; i < n; i++ {
+		// flat subsequences have no shape, so they are passed to the model as all zeros
+		sub, err := util.ZNormalize(mp.A[i : i+mp.W])
+		if err != nil {
+			sub = make([]float64, mp.W)
+			flat[i] = true
+		}
+		batch = append(batch, sub)
+		mp.Idx[i] = math.MaxInt64
+
+		if len(batch) == ao.BatchSize || i == n-1 {
+			pred, err := ao.Model.Predict(batch)
+			if err != nil {
+				return err
+			}
+			if len(pred) != len(batch) {
+				return fmt.Errorf("model predicted %d values for %d subsequences", len(pred), len(batch))
+			}
+			mp.MP = append(mp.MP, pred...)
+			batch = batch[:0]
+		}
+	}
+
+	order := make([]int, 0, n)
+	for i := 0; i < n; i++ {
+		if !flat[i] {
+			order = append(order, i)
+		}
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return mp.MP[order[i]] > mp.MP[order[j]]
+	})
+	if ao.Verify < len(order) {
+		order = order[:ao.Verify]
+	}
+
+	fft := mp.newFFT()
+	profile := make([]float64, len(mp.B)-mp.W+1)
+	for _, i := range order {
+		if err := mp.distanceProfile(i, profile, fft); err != nil {
+			return err
+		}
+		mp.MP[i], mp.Idx[i] = math.Inf(1), math.MaxInt64
+		for j, d := range profile {
+			if isBetterMatch(d, j, mp.MP[i], mp.Idx[i], true) {
+				mp.MP[i], mp.Idx[i] = d, j
+			}
+		}
+	}
+	mp.applyMaxDistance()
+
+	return mp.maskFlat(o)
+}
@@ -0,0 +1,115 @@
+package matrixprofile
+
+import (
+	"errors"
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/matrix-profile-foundation/go-matrixprofile/siggen"
+)
+
+// noisyModel predicts the exact matrix profile value of each subsequence, looked up by
+// its starting index, scaled by a random error.
+type noisyModel struct {
+	exact []float64
+	rng   *rand.Rand
+	next  int
+	err   error
+	short bool
+}
+
+func (m *noisyModel) Predict(subsequences [][]float64) ([]float64, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	pred := make([]float64, len(subsequences))
+	for i := range pred {
+		pred[i] = m.exact[m.next] * (1 + 0.1*(m.rng.Float64()-0.5))
+		m.next++
+	}
+	if m.short {
+		return pred[1:], nil
+	}
+	return pred, nil
+}
+
+func TestComputeApprox(t *testing.T) {
+	sig := siggen.Add(siggen.Sin(1, 4, 0, 0, 100, 4), siggen.Noise(0.05, 400))
+	for i := 0; i < 20; i++ {
+		sig[250+i] += 0.5 * math.Sin(math.Pi*float64(i)/20)
+	}
+	w := 25
+
+	exact, err := New(sig, nil, w)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// STOMP uses the same exclusion zone as the distance profiles that verify
+	// subsequences
+	eo := NewMPOpts()
+	eo.Algorithm = AlgoSTOMP
+	if err = exact.Compute(eo); err != nil {
+		t.Fatal(err)
+	}
+
+	mp, err := New(sig, nil, w)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, ao := range []*ApproxOpts{
+		nil,
+		{Model: &noisyModel{exact: exact.MP}, Verify: -1, BatchSize: 10},
+		{Model: &noisyModel{exact: exact.MP}, Verify: 1, BatchSize: 0},
+		NewApproxOpts(&noisyModel{err: errors.New("model failed")}),
+		NewApproxOpts(&noisyModel{exact: exact.MP, rng: rand.New(rand.NewSource(1)), short: true}),
+	} {
+		if err = mp.ComputeApprox(nil, ao); err == nil {
+			t.Errorf("Expected an error for %+v", ao)
+		}
+	}
+	o := NewMPOpts()
+	o.Euclidean = false
+	if err = mp.ComputeApprox(o, NewApproxOpts(&noisyModel{exact: exact.MP})); err == nil {
+		t.Errorf("Expected an error for pearson correlations")
+	}
+
+	ao := NewApproxOpts(&noisyModel{exact: exact.MP, rng: rand.New(rand.NewSource(1))})
+	ao.Verify = 20
+	ao.BatchSize = 64
+	if err = mp.ComputeApprox(nil, ao); err != nil {
+		t.Fatal(err)
+	}
+	if len(mp.MP) != len(exact.MP) {
+		t.Fatalf("Expected a profile length of %d, but got %d", len(exact.MP), len(mp.MP))
+	}
+
+	verified := 0
+	for i := range mp.MP {
+		if mp.Idx[i] == math.MaxInt64 {
+			if math.Abs(mp.MP[i]-exact.MP[i]) > 0.05*exact.MP[i]+1e-9 {
+				t.Errorf("Expected a prediction within 5%% of %.6f at %d, but got %.6f", exact.MP[i], i, mp.MP[i])
+			}
+			continue
+		}
+		verified++
+		if math.Abs(mp.MP[i]-exact.MP[i]) > 1e-6 || mp.Idx[i] != exact.Idx[i] {
+			t.Errorf("Expected %.6f with index %d at %d, but got %.6f with index %d", exact.MP[i], exact.Idx[i], i, mp.MP[i], mp.Idx[i])
+		}
+	}
+	if verified != ao.Verify {
+		t.Errorf("Expected %d verified subsequences, but got %d", ao.Verify, verified)
+	}
+
+	discords, err := mp.DiscoverDiscords(1, w/2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected, err := exact.DiscoverDiscords(1, w/2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if discords[0] != expected[0] || mp.Idx[discords[0]] == math.MaxInt64 {
+		t.Errorf("Expected the verified top discord at %d, but got %d", expected[0], discords[0])
+	}
+}
@@ -2,7 +2,9 @@
 package av
 
 import (
+	"encoding/json"
 	"fmt"
+	"io/ioutil"
 	"math"
 
 	"gonum.org/v1/gonum/floats"
@@ -121,3 +123,88 @@ func makeClipping(d []float64, m int) []float64 {
 
 	return av
 }
+
+// Op is a way of combining several annotation vectors into one.
+type Op string
+
+const (
+	Multiply Op = "multiply" // Multiply keeps a subsequence's importance only as far as every annotation vector does
+	Min      Op = "min"      // Min uses the lowest importance given by any annotation vector
+	Max      Op = "max"      // Max uses the highest importance given by any annotation vector
+)
+
+// Compose combines annotation vectors of the same length into one, so suppression
+// rules such as known events and complexity can be built up separately and reused.
+func Compose(op Op, avs ...[]float64) ([]float64, error) {
+	if len(avs) == 0 {
+		return nil, fmt.Errorf("must provide at least one annotation vector")
+	}
+	for i, avec := range avs {
+		if len(avec) != len(avs[0]) {
+			return nil, fmt.Errorf("annotation vector %d has length %d, but expected %d", i, len(avec), len(avs[0]))
+		}
+		if err := Validate(avec); err != nil {
+			return nil, err
+		}
+	}
+
+	out := make([]float64, len(avs[0]))
+	copy(out, avs[0])
+	for _, avec := range avs[1:] {
+		for i, val := range avec {
+			switch op {
+			case Multiply:
+				out[i] *= val
+			case Min:
+				out[i] = math.Min(out[i], val)
+			case Max:
+				out[i] = math.Max(out[i], val)
+			default:
+				return nil, fmt.Errorf("invalid annotation vector operation, %s", op)
+			}
+		}
+	}
+	return out, nil
+}
+
+// Validate checks that every value of an annotation vector is between 0 and 1.
+func Validate(avec []float64) error {
+	for idx, val := range avec {
+		if !(val >= 0 && val <= 1) {
+			return fmt.Errorf("got an annotation vector value of %.3f at index %d. must be between 0 and 1", val, idx)
+		}
+	}
+	return nil
+}
+
+// Save will save an annotation vector to disk so it can be reused across runs
+func Save(avec []float64, filepath, format string) error {
+	switch format {
+	case "json":
+		out, err := json.Marshal(avec)
+		if err != nil {
+			return err
+		}
+		return ioutil.WriteFile(filepath, out, 0644)
+	default:
+		return fmt.Errorf("invalid save format, %s", format)
+	}
+}
+
+// Load will attempt to load an annotation vector from a file
+func Load(filepath, format string) ([]float64, error) {
+	switch format {
+	case "json":
+		b, err := ioutil.ReadFile(filepath)
+		if err != nil {
+			return nil, err
+		}
+		var avec []float64
+		if err = json.Unmarshal(b, &avec); err != nil {
+			return nil, err
+		}
+		return avec, Validate(avec)
+	default:
+		return nil, fmt.Errorf("invalid load format, %s", format)
+	}
+}
@@ -1,7 +1,10 @@
 package av
 
 import (
+	"io/ioutil"
 	"math"
+	"os"
+	"path/filepath"
 	"testing"
 )
 
@@ -106,3 +109,71 @@ func TestMakeClipping(t *testing.T) {
 		}
 	}
 }
+
+func TestCompose(t *testing.T) {
+	testdata := []struct {
+		op          Op
+		avs         [][]float64
+		expected    []float64
+		expectedErr bool
+	}{
+		{Multiply, [][]float64{{1, 0.5, 0}, {0.5, 0.5, 1}}, []float64{0.5, 0.25, 0}, false},
+		{Min, [][]float64{{1, 0.5, 0}, {0.5, 0.5, 1}, {1, 0.2, 1}}, []float64{0.5, 0.2, 0}, false},
+		{Max, [][]float64{{1, 0.5, 0}, {0.5, 0.5, 1}}, []float64{1, 0.5, 1}, false},
+		{Max, [][]float64{{1, 0.5}}, []float64{1, 0.5}, false},
+		{Max, nil, nil, true},
+		{Min, [][]float64{{1, 0.5}, {1}}, nil, true},
+		{Min, [][]float64{{1, 1.5}, {1, 1}}, nil, true},
+		{"sum", [][]float64{{1, 1}, {1, 1}}, nil, true},
+	}
+
+	for _, d := range testdata {
+		out, err := Compose(d.op, d.avs...)
+		if d.expectedErr {
+			if err == nil {
+				t.Errorf("Expected an error, but got none for %v", d)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Did not expect an error, but got %v for %v", err, d)
+			continue
+		}
+		for i, val := range out {
+			if math.Abs(val-d.expected[i]) > 1e-7 {
+				t.Errorf("Expected %v, but got %v for %v", d.expected, out, d)
+				break
+			}
+		}
+	}
+}
+
+func TestSaveLoad(t *testing.T) {
+	dir, err := ioutil.TempDir("", "av")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	fn := filepath.Join(dir, "av.json")
+	avec := []float64{1, 0.5, 0, 0.25}
+	if err = Save(avec, fn, "csv"); err == nil {
+		t.Errorf("Expected an error for an invalid save format")
+	}
+	if err = Save(avec, fn, "json"); err != nil {
+		t.Fatal(err)
+	}
+	out, err := Load(fn, "json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out) != len(avec) {
+		t.Fatalf("Expected %d values, but got %d", len(avec), len(out))
+	}
+	for i := range avec {
+		if out[i] != avec[i] {
+			t.Errorf("Expected %v, but got %v", avec, out)
+			break
+		}
+	}
+}
@@ -0,0 +1,213 @@
+package matrixprofile
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+
+	"github.com/matrix-profile-foundation/go-matrixprofile/util"
+	"gonum.org/v1/gonum/floats"
+)
+
+// Chain is a time series chain, a sequence of subsequences where each link is the
+// nearest neighbor of the previous one, along with metrics describing its quality.
+type Chain struct {
+	Idx             []int   // start index of each link in time order
+	Length          int     // number of links
+	EffectiveLength float64 // span of the chain in subsequence lengths, capped at Length, which discounts heavily overlapping links
+	LinkCorr        float64 // mean pearson correlation between consecutive links
+	Divergence      float64 // z-normalized euclidean distance between the first and last link
+	Score           float64 // EffectiveLength times LinkCorr
+}
+
+// ScoreChain computes the quality metrics of a chain given the start index of each of
+// its links in time order. A meaningful chain is long, its consecutive links are highly
+// correlated, and its first and last links diverge as the pattern drifts. The score
+// favors long chains of tightly linked subsequences, while the divergence is reported
+// separately since both a stable and a drifting pattern can be of interest.
+func (mp MatrixProfile) ScoreChain(idx []int) (Chain, error) {
+	if len(idx) == 0 {
+		return Chain{}, fmt.Errorf("chain does not have any links")
+	}
+
+	links := make([][]float64, len(idx))
+	for i, start := range idx {
+		if start < 0 || start > len(mp.A)-mp.W {
+			return Chain{}, fmt.Errorf("link %d starting at %d is outside of the %d subsequences of a", i, start, len(mp.A)-mp.W+1)
+		}
+		if i > 0 && start <= idx[i-1] {
+			return Chain{}, fmt.Errorf("link %d starting at %d is not after the previous link at %d", i, start, idx[i-1])
+		}
+		var err error
+		if links[i], err = util.ZNormalize(mp.A[start : start+mp.W]); err != nil {
+			return Chain{}, err
+		}
+	}
+
+	c := Chain{
+		Idx:    idx,
+		Length: len(idx),
+	}
+
+	c.EffectiveLength = math.Min(float64(idx[len(idx)-1]-idx[0])/float64(mp.W)+1, float64(len(idx)))
+
+	if len(links) > 1 {
+		for i := 1; i < len(links); i++ {
+			c.LinkCorr += floats.Dot(links[i-1], links[i]) / float64(mp.W)
+		}
+		c.LinkCorr /= float64(len(links) - 1)
+	} else {
+		c.LinkCorr = 1
+	}
+
+	c.Divergence = floats.Distance(links[0], links[len(links)-1], 2)
+	c.Score = c.EffectiveLength * c.LinkCorr
+	return c, nil
+}
+
+// RankChains scores each chain and returns them ordered from the highest to the lowest
+// score, breaking ties by the larger divergence.
+func (mp MatrixProfile) RankChains(chains [][]int) ([]Chain, error) {
+	ranked := make([]Chain, len(chains))
+	for i, idx := range chains {
+		var err error
+		if ranked[i], err = mp.ScoreChain(idx); err != nil {
+			return nil, err
+		}
+	}
+
+	sort.SliceStable(ranked, func(i, j int) bool {
+		if ranked[i].Score != ranked[j].Score {
+			return ranked[i].Score > ranked[j].Score
+		}
+		return ranked[i].Divergence > ranked[j].Divergence
+	})
+	return ranked, nil
+}
+
+// Chains discovers the all-chain set of a self join with ALLC. Each subsequence is
+// linked to its right nearest neighbor, the closest subsequence after it, when that
+// neighbor's left nearest neighbor, the closest subsequence before it, points back.
+// Following the links from every subsequence that isn't linked to from the left gives
+// each chain, which captures a pattern that drifts over time as every link is only
+// close to the previous one. Chains of at least two links are returned ranked with
+// RankChains, so the first is the top unanchored chain. Distances are z-normalized
+// and trivial matches are excluded as in the matrix profile. The search is split
+// across the NJobs of the options.
+func (mp MatrixProfile) Chains() ([]Chain, error) {
+	if !mp.SelfJoin {
+		return nil, errors.New("can only find chains if a self join is performed")
+	}
+
+	left, right, err := mp.leftRightIdx()
+	if err != nil {
+		return nil, err
+	}
+
+	linked := make([]bool, len(left))
+	var chains [][]int
+	for i := range left {
+		if linked[i] {
+			continue
+		}
+		chain := []int{i}
+		for j := i; right[j] >= 0 && left[right[j]] == j; {
+			j = right[j]
+			linked[j] = true
+			chain = append(chain, j)
+		}
+		if len(chain) > 1 {
+			chains = append(chains, chain)
+		}
+	}
+	return mp.RankChains(chains)
+}
+
+// leftRightIdx returns for each subsequence of a the index of its nearest neighbor
+// before it and after it, or -1 if it has none. Every diagonal of the distance matrix
+// above the exclusion zone is walked once with the stomp recurrence, updating the
+// right neighbor of the earlier subsequence and the left neighbor of the later one.
+func (mp MatrixProfile) leftRightIdx() ([]int, []int, error) {
+	jobs := 1
+	if mp.Opts != nil && mp.Opts.NJobs > 1 {
+		jobs = mp.Opts.NJobs
+	}
+
+	// chains are defined on the plain z-normalized profile regardless of the options
+	c := mp
+	c.Opts = nil
+	var err error
+	if c.AMean, c.AStd, err = util.MovMeanStd(c.A, c.W); err != nil {
+		return nil, nil, err
+	}
+	c.BMean, c.BStd = c.AMean, c.AStd
+
+	n := len(c.A) - c.W + 1
+	zone := c.exclusionZone(c.W / 2)
+	first := zone
+	if first < 1 {
+		first = 1
+	}
+
+	newSide := func() ([]float64, []int) {
+		d := make([]float64, n)
+		idx := make([]int, n)
+		for i := range d {
+			d[i] = math.Inf(1)
+			idx[i] = math.MaxInt64
+		}
+		return d, idx
+	}
+
+	// each job walks every jobs-th diagonal and keeps its own neighbors, which are
+	// merged afterwards
+	leftD, leftIdx := make([][]float64, jobs), make([][]int, jobs)
+	rightD, rightIdx := make([][]float64, jobs), make([][]int, jobs)
+	var wg sync.WaitGroup
+	wg.Add(jobs)
+	for job := 0; job < jobs; job++ {
+		leftD[job], leftIdx[job] = newSide()
+		rightD[job], rightIdx[job] = newSide()
+		go func(job int) {
+			defer wg.Done()
+			lD, lIdx, rD, rIdx := leftD[job], leftIdx[job], rightD[job], rightIdx[job]
+			buf := make([]float64, 1)
+			for k := first + job; k < n; k += jobs {
+				dot := floats.Dot(c.A[:c.W], c.A[k:k+c.W])
+				for i := 0; i+k < n; i++ {
+					j := i + k
+					if i > 0 {
+						dot += c.A[i+c.W-1]*c.A[j+c.W-1] - c.A[i-1]*c.A[j-1]
+					}
+					d := c.pairDistance(dot, i, j, buf)
+					if !c.excluded(i, j, zone) && isBetterMatch(d, j, rD[i], rIdx[i], true) {
+						rD[i], rIdx[i] = d, j
+					}
+					if !c.excluded(j, i, zone) && isBetterMatch(d, i, lD[j], lIdx[j], true) {
+						lD[j], lIdx[j] = d, i
+					}
+				}
+			}
+		}(job)
+	}
+	wg.Wait()
+
+	merge := func(d [][]float64, idx [][]int) []int {
+		for job := 1; job < jobs; job++ {
+			for i := range d[0] {
+				if isBetterMatch(d[job][i], idx[job][i], d[0][i], idx[0][i], true) {
+					d[0][i], idx[0][i] = d[job][i], idx[job][i]
+				}
+			}
+		}
+		for i := range idx[0] {
+			if math.IsInf(d[0][i], 1) || idx[0][i] == math.MaxInt64 {
+				idx[0][i] = -1
+			}
+		}
+		return idx[0]
+	}
+	return merge(leftD, leftIdx), merge(rightD, rightIdx), nil
+}
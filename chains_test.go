@@ -0,0 +1,190 @@
+package matrixprofile
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/matrix-profile-foundation/go-matrixprofile/siggen"
+	"github.com/matrix-profile-foundation/go-matrixprofile/util"
+	"gonum.org/v1/gonum/floats"
+)
+
+func TestScoreChain(t *testing.T) {
+	// a sine wave followed by noise so chains over the sine are tightly linked
+	a := append(siggen.Sin(1, 4, 0, 0, 100, 1), siggen.Noise(1, 100)...)
+	mp, err := New(a, nil, 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testdata := []struct {
+		idx             []int
+		expectedErr     bool
+		effectiveLength float64
+		highCorr        bool
+	}{
+		{[]int{}, true, 0, false},
+		{[]int{0, 25, 300}, true, 0, false},
+		{[]int{25, 0}, true, 0, false},
+		{[]int{0}, false, 1, true},
+		{[]int{0, 25, 50, 75}, false, 4, true},
+		{[]int{0, 1, 2}, false, 1.1, true},
+		{[]int{100, 130, 160}, false, 3, false},
+	}
+
+	for _, d := range testdata {
+		c, err := mp.ScoreChain(d.idx)
+		if d.expectedErr {
+			if err == nil {
+				t.Errorf("Expected an error for %v", d.idx)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("Did not expect an error, %v, for %v", err, d.idx)
+		}
+		if c.Length != len(d.idx) || math.Abs(c.EffectiveLength-d.effectiveLength) > 1e-9 {
+			t.Errorf("Expected length %d and effective length %.2f, but got %d and %.2f for %v", len(d.idx), d.effectiveLength, c.Length, c.EffectiveLength, d.idx)
+		}
+		if d.highCorr != (c.LinkCorr > 0.9) {
+			t.Errorf("Expected high link correlation to be %t, but got %.3f for %v", d.highCorr, c.LinkCorr, d.idx)
+		}
+		if math.Abs(c.Score-c.EffectiveLength*c.LinkCorr) > 1e-9 {
+			t.Errorf("Expected score %.3f, but got %.3f for %v", c.EffectiveLength*c.LinkCorr, c.Score, d.idx)
+		}
+	}
+}
+
+func TestRankChains(t *testing.T) {
+	a := append(siggen.Sin(1, 4, 0, 0, 100, 1), siggen.Noise(1, 100)...)
+	mp, err := New(a, nil, 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	chains := [][]int{{100, 130, 160}, {0, 25}, {0, 25, 50, 75}}
+	ranked, err := mp.RankChains(chains)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []int{2, 1, 0}
+	for i, c := range ranked {
+		if c.Idx[0] != chains[expected[i]][0] || c.Length != len(chains[expected[i]]) {
+			t.Errorf("Expected chain %v at rank %d, but got %v", chains[expected[i]], i, c.Idx)
+		}
+	}
+
+	if _, err = mp.RankChains([][]int{{0}, {}}); err == nil {
+		t.Errorf("Expected an error for an empty chain")
+	}
+}
+
+func TestLeftRightIdx(t *testing.T) {
+	r := rand.New(rand.NewSource(4))
+	a := make([]float64, 90)
+	for i := range a {
+		a[i] = r.NormFloat64()
+	}
+	mp, err := New(a, nil, 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// brute force the nearest z-normalized neighbor on each side outside the exclusion zone
+	n := len(a) - mp.W + 1
+	zone := mp.W / 2
+	dist := func(i, j int) float64 {
+		x, _ := util.ZNormalize(a[i : i+mp.W])
+		y, _ := util.ZNormalize(a[j : j+mp.W])
+		return floats.Distance(x, y, 2)
+	}
+	expectedLeft, expectedRight := make([]int, n), make([]int, n)
+	for i := 0; i < n; i++ {
+		expectedLeft[i], expectedRight[i] = -1, -1
+		for j := 0; j < n; j++ {
+			if j < i-zone && (expectedLeft[i] < 0 || dist(i, j) < dist(i, expectedLeft[i])) {
+				expectedLeft[i] = j
+			}
+			if j >= i+zone && (expectedRight[i] < 0 || dist(i, j) < dist(i, expectedRight[i])) {
+				expectedRight[i] = j
+			}
+		}
+	}
+
+	for _, njobs := range []int{0, 1, 3} {
+		mp.Opts = nil
+		if njobs > 0 {
+			mp.Opts = NewMPOpts()
+			mp.Opts.NJobs = njobs
+		}
+		left, right, err := mp.leftRightIdx()
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < n; i++ {
+			if left[i] != expectedLeft[i] || right[i] != expectedRight[i] {
+				t.Errorf("Expected left and right neighbors %d and %d of %d, but got %d and %d with %d jobs", expectedLeft[i], expectedRight[i], i, left[i], right[i], njobs)
+				break
+			}
+		}
+	}
+}
+
+func TestChains(t *testing.T) {
+	// a pattern that gradually morphs from a sine bump into a sawtooth, placed among
+	// noise, forms a chain where each occurrence is closest to the next
+	r := rand.New(rand.NewSource(8))
+	const w = 20
+	sig := make([]float64, 600)
+	for i := range sig {
+		sig[i] = 0.1 * r.NormFloat64()
+	}
+	var starts []int
+	for k := 0; k < 6; k++ {
+		start := 40 + 100*k
+		mix := float64(k) / 5
+		for i := 0; i < w; i++ {
+			bump := math.Sin(math.Pi * float64(i) / float64(w-1))
+			saw := float64(i) / float64(w-1)
+			sig[start+i] = 3 * ((1-mix)*bump + mix*saw)
+		}
+		starts = append(starts, start)
+	}
+
+	mp, err := New(sig, nil, w)
+	if err != nil {
+		t.Fatal(err)
+	}
+	chains, err := mp.Chains()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(chains) == 0 {
+		t.Fatal("Expected at least one chain")
+	}
+
+	// the chain may continue into the noise after the last occurrence
+	top := chains[0]
+	if top.Length < len(starts) {
+		t.Fatalf("Expected a top chain of at least %d links, but got %v", len(starts), top.Idx)
+	}
+	for i, idx := range top.Idx[:len(starts)] {
+		if idx < starts[i]-3 || idx > starts[i]+3 {
+			t.Errorf("Expected link %d near %d, but got %v", i, starts[i], top.Idx)
+			break
+		}
+	}
+	for i := 1; i < len(chains); i++ {
+		if chains[i].Score > chains[i-1].Score {
+			t.Errorf("Expected chains ranked by score, but got %.3f after %.3f", chains[i].Score, chains[i-1].Score)
+		}
+	}
+
+	ab, err := New(sig, sig, w)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = ab.Chains(); err == nil {
+		t.Errorf("Expected an error for an AB join")
+	}
+}
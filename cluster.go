@@ -0,0 +1,314 @@
+package matrixprofile
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+)
+
+// Cluster is a group of subsequences linked together through the matrix profile
+// index. Exemplar is the member chosen as nearest neighbor by the most other
+// members, which makes it the most representative subsequence of the group.
+type Cluster struct {
+	Exemplar int
+	Members  []int
+}
+
+// Clusters groups subsequences into the connected components of the nearest
+// neighbor graph described by the matrix profile index, only keeping the links
+// whose matrix profile distance is at most radius. Clusters with a single member
+// are dropped and the remaining clusters are sorted by descending size. Only
+// applies to self joins.
+func (mp MatrixProfile) Clusters(radius float64) ([]Cluster, error) {
+	if !mp.SelfJoin {
+		return nil, errors.New("can only cluster subsequences if a self join is performed")
+	}
+	if mp.MP == nil || mp.Idx == nil {
+		return nil, errors.New("matrix profile has not been computed")
+	}
+
+	dist := mp.euclideanMP()
+	n := len(dist)
+
+	parent := make([]int, n)
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(int) int
+	find = func(i int) int {
+		for parent[i] != i {
+			parent[i] = parent[parent[i]]
+			i = parent[i]
+		}
+		return i
+	}
+
+	inDegree := make([]int, n)
+	for i, j := range mp.Idx {
+		if j < 0 || j >= n || math.IsInf(dist[i], 1) || dist[i] > radius {
+			continue
+		}
+		inDegree[j]++
+		if ri, rj := find(i), find(j); ri != rj {
+			parent[ri] = rj
+		}
+	}
+
+	groups := make(map[int][]int)
+	for i := 0; i < n; i++ {
+		r := find(i)
+		groups[r] = append(groups[r], i)
+	}
+
+	var clusters []Cluster
+	for _, members := range groups {
+		if len(members) < 2 {
+			continue
+		}
+		exemplar := members[0]
+		for _, m := range members[1:] {
+			if inDegree[m] > inDegree[exemplar] || (inDegree[m] == inDegree[exemplar] && dist[m] < dist[exemplar]) {
+				exemplar = m
+			}
+		}
+		clusters = append(clusters, Cluster{Exemplar: exemplar, Members: members})
+	}
+
+	sort.Slice(clusters, func(i, j int) bool {
+		if len(clusters[i].Members) != len(clusters[j].Members) {
+			return len(clusters[i].Members) > len(clusters[j].Members)
+		}
+		return clusters[i].Members[0] < clusters[j].Members[0]
+	})
+
+	return clusters, nil
+}
+
+// Merge joins two clusters of a Dendrogram. Leaves are numbered from 0 in the order of
+// the dendrogram's indices, and the cluster formed by the k-th merge is numbered after
+// the leaves, as the number of leaves plus k.
+type Merge struct {
+	Left  int     // first cluster joined
+	Right int     // second cluster joined
+	Dist  float64 // average distance between the members of the two clusters
+	Size  int     // number of leaves in the joined cluster
+}
+
+// Dendrogram is the hierarchy built by average linkage clustering of subsequences.
+// Merges are in the order they were made, which is by non-decreasing distance.
+// Clusters at an infinite distance from each other are never merged, so the hierarchy
+// can have several roots.
+type Dendrogram struct {
+	Idx    []int   // starting index of the subsequence of each leaf, in ascending order
+	Merges []Merge // merges in the order they were made
+}
+
+// Cut returns the clusters formed by every merge within radius, as the starting
+// indices of their subsequences in ascending order. Clusters are ordered by their first
+// index, and subsequences that were never merged within radius form clusters of one.
+func (d Dendrogram) Cut(radius float64) [][]int {
+	n := len(d.Idx)
+	members := make([][]int, n, n+len(d.Merges))
+	for i, idx := range d.Idx {
+		members[i] = []int{idx}
+	}
+	for _, m := range d.Merges {
+		if m.Dist > radius {
+			break
+		}
+		joined := append(append([]int{}, members[m.Left]...), members[m.Right]...)
+		members[m.Left], members[m.Right] = nil, nil
+		members = append(members, joined)
+	}
+
+	var clusters [][]int
+	for _, c := range members {
+		if c != nil {
+			sort.Ints(c)
+			clusters = append(clusters, c)
+		}
+	}
+	sort.Slice(clusters, func(i, j int) bool {
+		return clusters[i][0] < clusters[j][0]
+	})
+	return clusters
+}
+
+// ClusterMotifs hierarchically clusters every occurrence of the motifs with average
+// linkage, merging the motif groups whose occurrences fall within radius of each other
+// on average. This folds near duplicate motifs, such as the same shape found from two
+// seed pairs, into one group. The distances between occurrences are the ones of the
+// matrix profile without an exclusion zone, so shifted copies of an occurrence can
+// merge. Each merged group keeps the smallest minimum distance of the motifs seeded in
+// it and measures its distances and radius from the seed of that motif. Occurrences
+// that split off from their motifs are seeded by their closest pair, and lone
+// occurrences are dropped. Merged groups are returned in ascending order of their
+// minimum distance along with the dendrogram of all occurrences. Only applies to self
+// joins.
+func (mp *MatrixProfile) ClusterMotifs(motifs []MotifGroup, radius float64) ([]MotifGroup, *Dendrogram, error) {
+	if !mp.SelfJoin {
+		return nil, nil, errors.New("can only cluster motifs if a self join is performed")
+	}
+	if radius < 0 {
+		return nil, nil, fmt.Errorf("radius, %.3f, must not be negative", radius)
+	}
+
+	// every occurrence is a leaf once, even when it belongs to several motifs
+	leaf := make(map[int]int)
+	var idx []int
+	for _, g := range motifs {
+		for _, i := range g.Idx {
+			if i < 0 || i > len(mp.A)-mp.W {
+				return nil, nil, fmt.Errorf("motif index, %d, is out of range of the %d subsequences", i, len(mp.A)-mp.W+1)
+			}
+			if _, ok := leaf[i]; !ok {
+				leaf[i] = 0
+				idx = append(idx, i)
+			}
+		}
+	}
+	sort.Ints(idx)
+	for l, i := range idx {
+		leaf[i] = l
+	}
+
+	n := len(idx)
+	dist := make([][]float64, n)
+	for l, i := range idx {
+		profile, err := mp.DistanceProfile(mp.A[i : i+mp.W])
+		if err != nil {
+			return nil, nil, err
+		}
+		dist[l] = make([]float64, n)
+		for m, j := range idx {
+			dist[l][m] = profile[j]
+		}
+	}
+	dendrogram := averageLinkage(idx, dist)
+
+	// each cluster of the cut becomes one group, led by the best motif seeded in it
+	clusters := dendrogram.Cut(radius)
+	groupOf := make(map[int]int)
+	for c, members := range clusters {
+		for _, i := range members {
+			groupOf[i] = c
+		}
+	}
+	best := make([]int, len(clusters))
+	for c := range best {
+		best[c] = -1
+	}
+	for m, g := range motifs {
+		if len(g.Idx) == 0 {
+			continue
+		}
+		c := groupOf[motifSeed(g)]
+		if best[c] < 0 || g.MinDist < motifs[best[c]].MinDist {
+			best[c] = m
+		}
+	}
+
+	var merged []MotifGroup
+	for c, members := range clusters {
+		if len(members) < 2 {
+			continue
+		}
+		var g MotifGroup
+		var seed int
+		if best[c] >= 0 {
+			seed, g.MinDist = motifSeed(motifs[best[c]]), motifs[best[c]].MinDist
+		} else {
+			// the occurrences split off from their motifs, so the closest pair seeds them
+			g.MinDist = math.Inf(1)
+			for _, i := range members {
+				for _, j := range members {
+					if i < j && dist[leaf[i]][leaf[j]] < g.MinDist {
+						seed, g.MinDist = i, dist[leaf[i]][leaf[j]]
+					}
+				}
+			}
+		}
+
+		g.Idx = members
+		g.Dists = make([]float64, len(members))
+		for k, i := range members {
+			if i != seed {
+				g.Dists[k] = dist[leaf[seed]][leaf[i]]
+			}
+			g.Radius = math.Max(g.Radius, g.Dists[k])
+		}
+		merged = append(merged, g)
+	}
+	sort.SliceStable(merged, func(i, j int) bool {
+		return merged[i].MinDist < merged[j].MinDist
+	})
+	return merged, dendrogram, nil
+}
+
+// motifSeed returns the starting index of the seed of a motif group, the member at a
+// distance of 0, or its first member when its distances are unknown.
+func motifSeed(g MotifGroup) int {
+	for k, d := range g.Dists {
+		if d == 0 && k < len(g.Idx) {
+			return g.Idx[k]
+		}
+	}
+	return g.Idx[0]
+}
+
+// averageLinkage builds the dendrogram of the subsequences starting at idx from their
+// pairwise distances, repeatedly merging the two closest clusters where the distance
+// between clusters is the average distance between their members.
+func averageLinkage(idx []int, dist [][]float64) *Dendrogram {
+	n := len(idx)
+	d := &Dendrogram{Idx: idx}
+
+	// the distances between live clusters, indexed by cluster number
+	between := make(map[[2]int]float64)
+	size := make(map[int]int)
+	for l := 0; l < n; l++ {
+		size[l] = 1
+		for m := l + 1; m < n; m++ {
+			between[[2]int{l, m}] = dist[l][m]
+		}
+	}
+
+	for next := n; len(size) > 1; next++ {
+		left, right, closest := -1, -1, math.Inf(1)
+		for pair, v := range between {
+			// ties go to the pair of the oldest clusters so the result is reproducible
+			if v < closest || (v == closest && left >= 0 && (pair[0] < left || (pair[0] == left && pair[1] < right))) {
+				left, right, closest = pair[0], pair[1], v
+			}
+		}
+		if left < 0 {
+			break
+		}
+
+		merged := size[left] + size[right]
+		d.Merges = append(d.Merges, Merge{Left: left, Right: right, Dist: closest, Size: merged})
+		for c := range size {
+			if c == left || c == right {
+				continue
+			}
+			v := (float64(size[left])*between[clusterPair(c, left)] + float64(size[right])*between[clusterPair(c, right)]) / float64(merged)
+			delete(between, clusterPair(c, left))
+			delete(between, clusterPair(c, right))
+			between[[2]int{c, next}] = v
+		}
+		delete(between, [2]int{left, right})
+		delete(size, left)
+		delete(size, right)
+		size[next] = merged
+	}
+	return d
+}
+
+// clusterPair returns the key of the distance between two clusters.
+func clusterPair(a, b int) [2]int {
+	if a > b {
+		a, b = b, a
+	}
+	return [2]int{a, b}
+}
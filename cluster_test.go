@@ -0,0 +1,187 @@
+package matrixprofile
+
+import (
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/matrix-profile-foundation/go-matrixprofile/siggen"
+)
+
+func TestClusters(t *testing.T) {
+	testdata := []struct {
+		mp               []float64
+		idx              []int
+		radius           float64
+		expectedClusters []Cluster
+	}{
+		{
+			[]float64{0.1, 0.2, 5, 0.1, 0.3, 0.2},
+			[]int{3, 3, 0, 0, 5, 4},
+			1,
+			[]Cluster{{3, []int{0, 1, 3}}, {5, []int{4, 5}}},
+		},
+		{
+			[]float64{0.1, 0.2, 5, 0.1, 0.3, 0.2},
+			[]int{3, 3, 0, 0, 5, 4},
+			10,
+			[]Cluster{{0, []int{0, 1, 2, 3}}, {5, []int{4, 5}}},
+		},
+		{
+			[]float64{0.1, 0.2, 5, 0.1, 0.3, 0.2},
+			[]int{3, 3, 0, 0, 5, 4},
+			0.05,
+			nil,
+		},
+	}
+
+	for _, d := range testdata {
+		mp := MatrixProfile{MP: d.mp, Idx: d.idx, SelfJoin: true, Opts: NewMPOpts()}
+		clusters, err := mp.Clusters(d.radius)
+		if err != nil {
+			t.Errorf("Did not expect an error, %v, for %v", err, d)
+			continue
+		}
+		if len(clusters) != len(d.expectedClusters) {
+			t.Errorf("Expected %d clusters, but got %d, %v", len(d.expectedClusters), len(clusters), clusters)
+			continue
+		}
+		for i, c := range clusters {
+			ec := d.expectedClusters[i]
+			if c.Exemplar != ec.Exemplar {
+				t.Errorf("Expected exemplar %d for cluster %d, but got %d", ec.Exemplar, i, c.Exemplar)
+			}
+			if len(c.Members) != len(ec.Members) {
+				t.Errorf("Expected members %v for cluster %d, but got %v", ec.Members, i, c.Members)
+				continue
+			}
+			for j := range c.Members {
+				if c.Members[j] != ec.Members[j] {
+					t.Errorf("Expected members %v for cluster %d, but got %v", ec.Members, i, c.Members)
+					break
+				}
+			}
+		}
+	}
+
+	mp := MatrixProfile{MP: []float64{1}, Idx: []int{0}, SelfJoin: false}
+	if _, err := mp.Clusters(1); err == nil {
+		t.Errorf("Expected an error for an AB join")
+	}
+}
+
+func TestClustersComputed(t *testing.T) {
+	sig := []float64{0, 0.99, 1, 0, 0, 0.98, 1, 0, 0, 0.96, 1, 0}
+	mp, err := New(sig, nil, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = mp.Compute(nil); err != nil {
+		t.Fatal(err)
+	}
+
+	clusters, err := mp.Clusters(0.1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var total int
+	for _, c := range clusters {
+		total += len(c.Members)
+	}
+	if total != len(mp.MP) {
+		t.Errorf("Expected all %d subsequences to be clustered, but got %d, %v", len(mp.MP), total, clusters)
+	}
+}
+
+func TestDendrogramCut(t *testing.T) {
+	inf := math.Inf(1)
+	dist := [][]float64{
+		{0, 1, 5, 6, inf},
+		{1, 0, 7, 8, inf},
+		{5, 7, 0, 2, inf},
+		{6, 8, 2, 0, inf},
+		{inf, inf, inf, inf, 0},
+	}
+	d := averageLinkage([]int{0, 10, 20, 30, 40}, dist)
+
+	expectedMerges := []Merge{{0, 1, 1, 2}, {2, 3, 2, 2}, {5, 6, 6.5, 4}}
+	if !reflect.DeepEqual(d.Merges, expectedMerges) {
+		t.Errorf("Expected merges %v, but got %v", expectedMerges, d.Merges)
+	}
+
+	testdata := []struct {
+		radius   float64
+		expected [][]int
+	}{
+		{0.5, [][]int{{0}, {10}, {20}, {30}, {40}}},
+		{1.5, [][]int{{0, 10}, {20}, {30}, {40}}},
+		{3, [][]int{{0, 10}, {20, 30}, {40}}},
+		{10, [][]int{{0, 10, 20, 30}, {40}}},
+	}
+	for _, td := range testdata {
+		if out := d.Cut(td.radius); !reflect.DeepEqual(out, td.expected) {
+			t.Errorf("Expected %v for a radius of %.1f, but got %v", td.expected, td.radius, out)
+		}
+	}
+}
+
+func TestClusterMotifs(t *testing.T) {
+	w := 20
+	sig := siggen.Noise(0.1, 600)
+	for _, start := range []int{50, 190, 330, 470} {
+		for i := 0; i < w; i++ {
+			sig[start+i] += math.Sin(2 * math.Pi * float64(i) / float64(w))
+		}
+	}
+	mp, err := New(sig, nil, w)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the same shape reported twice, along with a pair of unrelated noise
+	motifs := []MotifGroup{
+		{Idx: []int{50, 190}, MinDist: 0.3},
+		{Idx: []int{330, 470}, MinDist: 0.2},
+		{Idx: []int{10, 560}, MinDist: 0.4},
+	}
+	merged, dendrogram, err := mp.ClusterMotifs(motifs, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dendrogram.Idx) != 6 {
+		t.Errorf("Expected 6 occurrences in the dendrogram, but got %v", dendrogram.Idx)
+	}
+	if len(merged) != 1 || !reflect.DeepEqual(merged[0].Idx, []int{50, 190, 330, 470}) {
+		t.Fatalf("Expected one merged motif of the sine occurrences, but got %+v", merged)
+	}
+
+	g := merged[0]
+	if g.MinDist != 0.2 {
+		t.Errorf("Expected the minimum distance of the best motif, 0.2, but got %.3f", g.MinDist)
+	}
+	profile, err := mp.DistanceProfile(mp.A[330 : 330+w])
+	if err != nil {
+		t.Fatal(err)
+	}
+	var radius float64
+	for k, i := range g.Idx {
+		if math.Abs(g.Dists[k]-profile[i]) > 1e-6 && i != 330 {
+			t.Errorf("Expected a distance of %.4f to the seed for %d, but got %.4f", profile[i], i, g.Dists[k])
+		}
+		radius = math.Max(radius, g.Dists[k])
+	}
+	if g.Dists[2] != 0 || g.Radius != radius {
+		t.Errorf("Expected the seed at 330 and a radius of %.4f, but got %+v", radius, g)
+	}
+
+	if _, _, err = mp.ClusterMotifs(motifs, -1); err == nil {
+		t.Errorf("Expected an error for a negative radius")
+	}
+	ab, err := New(sig, sig, w)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err = ab.ClusterMotifs(motifs, 2); err == nil {
+		t.Errorf("Expected an error for an AB join")
+	}
+}
@@ -0,0 +1,190 @@
+package matrixprofile
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/matrix-profile-foundation/go-matrixprofile/util"
+)
+
+// CoarseOpts are parameters to vary the coarse to fine approximate matrix profile.
+type CoarseOpts struct {
+	Factor int // number of points averaged into each point of the downsampled timeseries
+	Refine int // number of candidate motif regions, and as many discord regions, refined at full resolution
+}
+
+// NewCoarseOpts returns a default CoarseOpts.
+func NewCoarseOpts() *CoarseOpts {
+	return &CoarseOpts{
+		Factor: 8,
+		Refine: 10,
+	}
+}
+
+// ComputeCoarse computes an approximate self join matrix profile in two stages for
+// timeseries too long for an exact join. The matrix profile of the timeseries
+// downsampled with PAA finds candidate regions, then only the regions around the
+// lowest and highest downsampled values, the likely motifs and discords, are refined
+// with exact distance profiles at full resolution, along with any region estimated
+// below the best refined value so the top motif is exact in practice. Every other
+// subsequence keeps the downsampled matrix profile value scaled to the full
+// subsequence length and the nearest neighbor of its block, which are estimates. The
+// options must use euclidean distances and are only applied in full to the refined
+// subsequences.
+func (mp *MatrixProfile) ComputeCoarse(o *MPOpts, co *CoarseOpts) error {
+	if o == nil {
+		o = NewMPOpts()
+	}
+	if co == nil {
+		co = NewCoarseOpts()
+	}
+	if !mp.SelfJoin {
+		return errors.New("coarse to fine matrix profiles only support self joins")
+	}
+	if co.Factor < 2 {
+		return fmt.Errorf("downsampling factor, %d, must be at least 2", co.Factor)
+	}
+	if co.Refine < 0 {
+		return fmt.Errorf("number of regions to refine, %d, must not be negative", co.Refine)
+	}
+	if mp.W/co.Factor < 4 {
+		return fmt.Errorf("subsequence length, %d, must be at least 4 times the downsampling factor, %d", mp.W, co.Factor)
+	}
+	mp.Opts = o
+	mp.mpxStream = nil
+	mp.stampi = nil
+
+	if err := o.Validate(); err != nil {
+		return err
+	}
+	if err := mp.validateNormalization(o); err != nil {
+		return err
+	}
+	if !o.Euclidean || o.Manhattan {
+		return errors.New("coarse to fine matrix profiles only support euclidean distances")
+	}
+	if o.recomputesFlat() {
+		return fmt.Errorf("coarse to fine matrix profiles don't support flat mode %s", o.Flat)
+	}
+	if o.Period > 0 {
+		return errors.New("coarse to fine matrix profiles don't support periodic exclusion zones")
+	}
+	if err := mp.applyTransform(o); err != nil {
+		return err
+	}
+	if err := mp.initCaches(); err != nil {
+		return err
+	}
+
+	paa, err := util.PAA(mp.A, co.Factor)
+	if err != nil {
+		return err
+	}
+	coarse, err := New(paa, nil, mp.W/co.Factor)
+	if err != nil {
+		return err
+	}
+	// the timeseries was already transformed, averaging removes most of the noise, and
+	// distance limits only apply at full resolution
+	copts := *o
+	copts.Transform, copts.Circular, copts.NoiseStd = TransformNone, false, 0
+	copts.Squared, copts.MaxDistance, copts.FlatThreshold, copts.Flat = false, 0, 0, FlatNone
+	if err = coarse.Compute(&copts); err != nil {
+		return err
+	}
+
+	// each point of the downsampled timeseries stands for factor points, so distances
+	// scale with the square root of the factor
+	n := len(mp.A) - mp.W + 1
+	scale := math.Sqrt(float64(co.Factor))
+	mp.MP = make([]float64, n)
+	mp.Idx = make([]int, n)
+	mp.MPB, mp.IdxB = nil, nil
+	for i := range mp.MP {
+		c := i / co.Factor
+		if c >= len(coarse.MP) {
+			c = len(coarse.MP) - 1
+		}
+		mp.MP[i] = coarse.MP[c] * scale
+		if o.Squared {
+			mp.MP[i] *= mp.MP[i]
+		}
+		mp.Idx[i] = math.MaxInt64
+		if coarse.Idx[c] != math.MaxInt64 {
+			mp.Idx[i] = coarse.Idx[c] * co.Factor
+		}
+	}
+
+	// discord candidates are the highest finite values, found as the lowest negated ones
+	discords := make([]float64, len(coarse.MP))
+	for i, d := range coarse.MP {
+		discords[i] = math.Inf(1)
+		if !math.IsInf(d, 0) && !math.IsNaN(d) {
+			discords[i] = -d
+		}
+	}
+	zone := coarse.W / 2
+	candidates := append(topKMatches(coarse.MP, co.Refine, zone), topKMatches(discords, co.Refine, zone)...)
+
+	// a region spans the subsequences of the candidate's block and the blocks on either
+	// side, as the best alignment at full resolution can fall between blocks
+	refined := make([]bool, n)
+	profile := make([]float64, n)
+	fft := mp.newFFT()
+	best := math.Inf(1)
+	refine := func(c int) error {
+		for i := (c - 1) * co.Factor; i < (c+2)*co.Factor && i < n; i++ {
+			if i < 0 || refined[i] {
+				continue
+			}
+			refined[i] = true
+			if _, std := windowMeanStd(mp.A[i : i+mp.W]); std == 0 {
+				continue
+			}
+			if err := mp.distanceProfile(i, profile, fft); err != nil {
+				return err
+			}
+			mp.MP[i], mp.Idx[i] = math.Inf(1), math.MaxInt64
+			for j, d := range profile {
+				if isBetterMatch(d, j, mp.MP[i], mp.Idx[i], true) {
+					mp.MP[i], mp.Idx[i] = d, j
+				}
+			}
+			best = math.Min(best, mp.MP[i])
+		}
+		return nil
+	}
+	for _, c := range candidates {
+		if err = refine(c.Idx); err != nil {
+			return err
+		}
+	}
+
+	// the downsampled distances are close to a lower bound of the full ones, so any
+	// block estimated below the best refined value may hold a closer motif and is
+	// refined as well
+	order := make([]int, len(coarse.MP))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return coarse.MP[order[i]] < coarse.MP[order[j]]
+	})
+	for _, c := range order {
+		est := coarse.MP[c] * scale
+		if o.Squared {
+			est *= est
+		}
+		if !(est < best) {
+			break
+		}
+		if err = refine(c); err != nil {
+			return err
+		}
+	}
+	mp.applyMaxDistance()
+
+	return mp.maskFlat(o)
+}
@@ -0,0 +1,74 @@
+package matrixprofile
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"gonum.org/v1/gonum/floats"
+)
+
+func TestComputeCoarse(t *testing.T) {
+	// a noisy periodic signal with a single pulse
+	r := rand.New(rand.NewSource(11))
+	sig := make([]float64, 3000)
+	for i := range sig {
+		sig[i] = math.Sin(float64(i)*2*math.Pi/200) + 0.05*r.NormFloat64()
+	}
+	for i := 1400; i < 1440; i++ {
+		sig[i] += 1.5
+	}
+
+	exact, err := New(sig, nil, 120)
+	if err != nil {
+		t.Fatal(err)
+	}
+	o := NewMPOpts()
+	o.Algorithm = AlgoSTOMP
+	if err = exact.Compute(o); err != nil {
+		t.Fatal(err)
+	}
+
+	mp, err := New(sig, nil, 120)
+	if err != nil {
+		t.Fatal(err)
+	}
+	co := NewCoarseOpts()
+	co.Refine = 3
+	if err = mp.ComputeCoarse(o, co); err != nil {
+		t.Fatal(err)
+	}
+	if len(mp.MP) != len(exact.MP) {
+		t.Fatalf("Expected %d elements, but got %d", len(exact.MP), len(mp.MP))
+	}
+
+	// the top motif and discord are refined to their exact values
+	motif, best := floats.MinIdx(exact.MP), floats.MinIdx(mp.MP)
+	if math.Abs(mp.MP[best]-exact.MP[motif]) > 1e-6 || math.Abs(exact.MP[best]-exact.MP[motif]) > 1e-6 {
+		t.Errorf("Expected the lowest value to be the exact motif distance, %.6f, but got %.6f at %d", exact.MP[motif], mp.MP[best], best)
+	}
+	discord, worst := floats.MaxIdx(exact.MP), floats.MaxIdx(mp.MP)
+	if worst != discord || math.Abs(mp.MP[worst]-exact.MP[discord]) > 1e-6 || mp.Idx[worst] != exact.Idx[discord] {
+		t.Errorf("Expected the discord (%.6f, %d) at %d, but got (%.6f, %d) at %d", exact.MP[discord], exact.Idx[discord], discord, mp.MP[worst], mp.Idx[worst], worst)
+	}
+
+	testdata := []struct {
+		b  []float64
+		co *CoarseOpts
+		w  int
+	}{
+		{sig, nil, 120},
+		{nil, &CoarseOpts{Factor: 1, Refine: 3}, 120},
+		{nil, &CoarseOpts{Factor: 8, Refine: -1}, 120},
+		{nil, &CoarseOpts{Factor: 8, Refine: 3}, 24},
+	}
+	for _, d := range testdata {
+		mp, err := New(sig, d.b, d.w)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = mp.ComputeCoarse(nil, d.co); err == nil {
+			t.Errorf("Expected an error for %+v", d.co)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package matrixprofile
+
+import (
+	"fmt"
+	"math"
+)
+
+// ContrastProfile computes the contrast profile of the subsequences of length w of the
+// positive timeseries, which is high for a subsequence that has a close match elsewhere
+// in pos but none in neg, such as a pattern only seen before failures. It is the
+// matrix profile of pos joined with neg minus the self join matrix profile of pos, with
+// both clipped to sqrt(2*w), the distance of uncorrelated subsequences, and divided by
+// it so values range from 0 to 1. Only the MPX algorithm is supported since its AB
+// join is indexed by the subsequences of pos.
+func ContrastProfile(pos, neg []float64, w int, o *MPOpts) ([]float64, error) {
+	if o == nil {
+		o = NewMPOpts()
+	}
+	if o.Algorithm != AlgoMPX {
+		return nil, fmt.Errorf("ContrastProfile only supports the %s algorithm, got %s", AlgoMPX, o.Algorithm)
+	}
+
+	self, err := New(pos, nil, w)
+	if err != nil {
+		return nil, err
+	}
+	if err = self.Compute(o); err != nil {
+		return nil, err
+	}
+
+	join, err := New(pos, neg, w)
+	if err != nil {
+		return nil, err
+	}
+	if err = join.Compute(o); err != nil {
+		return nil, err
+	}
+
+	limit := math.Sqrt(2 * float64(w))
+	selfMP, joinMP := self.euclideanMP(), join.euclideanMP()
+	cp := make([]float64, len(selfMP))
+	for i := range cp {
+		// NaN comparisons are false, so an undefined distance counts as uncorrelated
+		s, j := limit, limit
+		if selfMP[i] < limit {
+			s = selfMP[i]
+		}
+		if joinMP[i] < limit {
+			j = joinMP[i]
+		}
+		cp[i] = math.Max(j-s, 0) / limit
+	}
+	return cp, nil
+}
@@ -0,0 +1,71 @@
+package matrixprofile
+
+import (
+	"math"
+	"testing"
+
+	"github.com/matrix-profile-foundation/go-matrixprofile/siggen"
+)
+
+func TestContrastProfile(t *testing.T) {
+	sine := siggen.Sin(1, 5, 0, 0, 100, 0.4)
+	saw := siggen.Sawtooth(1, 5, 0, 0, 100, 0.4)
+
+	// the sawtooth recurs in both classes while the sine only recurs in the positive one
+	pos := siggen.Noise(0.3, 1000)
+	neg := siggen.Noise(0.3, 1000)
+	for _, i := range []int{100, 500} {
+		for j := range sine {
+			pos[i+j] += sine[j]
+		}
+	}
+	for _, i := range []int{300, 700} {
+		for j := range saw {
+			pos[i+j] += saw[j]
+			neg[i+j] += saw[j]
+		}
+	}
+	w := 40
+
+	o := NewMPOpts()
+	o.Algorithm = AlgoSTOMP
+	if _, err := ContrastProfile(pos, neg, w, o); err == nil {
+		t.Errorf("Expected an error for the %s algorithm", AlgoSTOMP)
+	}
+	if _, err := ContrastProfile(pos, neg[:30], w, nil); err == nil {
+		t.Errorf("Expected an error for a negative timeseries shorter than the subsequence length")
+	}
+
+	cp, err := ContrastProfile(pos, neg, w, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cp) != len(pos)-w+1 {
+		t.Fatalf("Expected a length of %d, but got %d", len(pos)-w+1, len(cp))
+	}
+
+	best := 0
+	for i, v := range cp {
+		if v < 0 || v > 1 || math.IsNaN(v) {
+			t.Fatalf("Expected values between 0 and 1, but got %.3f at %d", v, i)
+		}
+		if v > cp[best] {
+			best = i
+		}
+	}
+	// shifts of a periodic pattern match each other, so any overlap with a sine counts
+	overlapsSine := false
+	for _, i := range []int{100, 500} {
+		if best+w > i && best < i+len(sine) {
+			overlapsSine = true
+		}
+	}
+	if !overlapsSine {
+		t.Errorf("Expected the highest contrast to overlap a sine at 100 or 500, but got %d", best)
+	}
+	for _, i := range []int{300, 700} {
+		if cp[i] >= cp[best]/2 {
+			t.Errorf("Expected a low contrast for the shared sawtooth at %d, but got %.3f against %.3f", i, cp[i], cp[best])
+		}
+	}
+}
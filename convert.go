@@ -0,0 +1,138 @@
+package matrixprofile
+
+import (
+	"reflect"
+
+	"github.com/matrix-profile-foundation/go-matrixprofile/util"
+	"gonum.org/v1/gonum/mat"
+)
+
+// NewFloat32 creates a matrix profile struct from float32 timeseries. This is a
+// convenience wrapper around New that allocates a full float64 copy of each
+// timeseries, so it does not save the memory of converting the data up front. If b
+// is nil, then the matrix profile assumes a self join on the first timeseries.
+func NewFloat32(a, b []float32, w int) (*MatrixProfile, error) {
+	return New(util.Float32To64(a), util.Float32To64(b), w)
+}
+
+// UpdateFloat32 updates a matrix profile and matrix profile index in place with
+// a batch of float32 values. This is a convenience wrapper around Update that
+// allocates a float64 copy of the batch. See Update for more details.
+func (mp *MatrixProfile) UpdateFloat32(newValues []float32) error {
+	return mp.Update(util.Float32To64(newValues))
+}
+
+// MPFloat32 returns a float32 copy of the matrix profile.
+func (mp MatrixProfile) MPFloat32() []float32 {
+	return util.Float64To32(mp.MP)
+}
+
+// NewKMPFloat32 creates a k dimensional matrix profile struct from a set of
+// float32 timeseries. This is a convenience wrapper around NewKMP that allocates a
+// full float64 copy of every dimension. See NewKMP for more details.
+func NewKMPFloat32(t [][]float32, w int) (*KMP, error) {
+	if t == nil {
+		return NewKMP(nil, w)
+	}
+	t64 := make([][]float64, len(t))
+	for d := range t {
+		t64[d] = util.Float32To64(t[d])
+	}
+	return NewKMP(t64, w)
+}
+
+// NewFromVectors creates a matrix profile struct from gonum vectors. If b is nil,
+// then the matrix profile assumes a self join on the first timeseries. Dense
+// vectors with a unit increment are used without copying the underlying data. A nil
+// pointer held in a vector, such as a (*mat.VecDense)(nil), is treated as nil.
+func NewFromVectors(a, b mat.Vector, w int) (*MatrixProfile, error) {
+	if isNil(a) {
+		return New(nil, nil, w)
+	}
+	var bs []float64
+	if !isNil(b) {
+		bs = vecSlice(b)
+	}
+	return New(vecSlice(a), bs, w)
+}
+
+// NewKMPFromMatrix creates a k dimensional matrix profile struct from a gonum
+// matrix where each row represents a separate dimension and each column a
+// point in time. A nil pointer held in the matrix is treated as nil.
+func NewKMPFromMatrix(t mat.Matrix, w int) (*KMP, error) {
+	if isNil(t) {
+		return NewKMP(nil, w)
+	}
+	r, c := t.Dims()
+	ts := make([][]float64, r)
+	if rm, ok := t.(mat.RawMatrixer); ok {
+		raw := rm.RawMatrix()
+		for i := 0; i < r; i++ {
+			ts[i] = raw.Data[i*raw.Stride : i*raw.Stride+c : i*raw.Stride+c]
+		}
+	} else {
+		for i := 0; i < r; i++ {
+			ts[i] = mat.Row(nil, i, t)
+		}
+	}
+	return NewKMP(ts, w)
+}
+
+// MPVec returns the matrix profile wrapped as a gonum vector. The vector shares
+// its data with the matrix profile.
+func (mp MatrixProfile) MPVec() *mat.VecDense {
+	if len(mp.MP) == 0 {
+		return nil
+	}
+	return mat.NewVecDense(len(mp.MP), mp.MP)
+}
+
+// MPBVec returns the matrix profile of the BA join wrapped as a gonum vector.
+// The vector shares its data with the matrix profile.
+func (mp MatrixProfile) MPBVec() *mat.VecDense {
+	if len(mp.MPB) == 0 {
+		return nil
+	}
+	return mat.NewVecDense(len(mp.MPB), mp.MPB)
+}
+
+// MPMatrix returns the k dimensional matrix profile as a gonum matrix where each
+// row holds the matrix profile for that number of dimensions.
+func (k KMP) MPMatrix() *mat.Dense {
+	if len(k.MP) == 0 || len(k.MP[0]) == 0 {
+		return nil
+	}
+	out := mat.NewDense(len(k.MP), len(k.MP[0]), nil)
+	for d := range k.MP {
+		out.SetRow(d, k.MP[d])
+	}
+	return out
+}
+
+// vecSlice returns the contents of a gonum vector as a slice of floats. Dense
+// vectors with a unit increment are returned without a copy, capped so that
+// appending to the result never writes into the vector's backing data.
+func vecSlice(v mat.Vector) []float64 {
+	n := v.Len()
+	if rv, ok := v.(mat.RawVectorer); ok {
+		raw := rv.RawVector()
+		if raw.Inc == 1 {
+			return raw.Data[:n:n]
+		}
+	}
+	out := make([]float64, n)
+	for i := 0; i < n; i++ {
+		out[i] = v.AtVec(i)
+	}
+	return out
+}
+
+// isNil reports whether v is nil or holds a nil pointer, which would otherwise pass
+// a nil check on the interface and panic on its first method call.
+func isNil(v interface{}) bool {
+	if v == nil {
+		return true
+	}
+	rv := reflect.ValueOf(v)
+	return rv.Kind() == reflect.Ptr && rv.IsNil()
+}
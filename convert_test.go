@@ -0,0 +1,225 @@
+package matrixprofile
+
+import (
+	"math"
+	"testing"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+func TestNewFloat32(t *testing.T) {
+	testdata := []struct {
+		a           []float32
+		b           []float32
+		m           int
+		expectedErr bool
+	}{
+		{[]float32{}, []float32{}, 2, true},
+		{nil, nil, 2, true},
+		{[]float32{1, 1, 1, 1, 1}, []float32{}, 2, true},
+		{[]float32{1, 1, 1, 1, 1}, nil, 2, false},
+		{[]float32{1, 1, 1, 1, 1}, nil, 6, true},
+		{[]float32{1, 2, 3, 4, 5}, []float32{1, 1, 1, 1, 1}, 2, false},
+	}
+
+	for _, d := range testdata {
+		mp, err := NewFloat32(d.a, d.b, d.m)
+		if d.expectedErr && err == nil {
+			t.Errorf("Expected an error, but got none for %v", d)
+			return
+		}
+		if !d.expectedErr && err != nil {
+			t.Errorf("Expected no error, but got %v for %v", err, d)
+			return
+		}
+		if err != nil {
+			continue
+		}
+		if d.b == nil && !mp.SelfJoin {
+			t.Errorf("Expected a self join for %v", d)
+		}
+	}
+}
+
+func TestFloat32Compute(t *testing.T) {
+	sig := []float64{0, 0.99, 1, 0, 0, 0.98, 1, 0, 0, 0.96, 1, 0}
+	sig32 := []float32{0, 0.99, 1, 0, 0, 0.98, 1, 0, 0, 0.96, 1, 0}
+
+	mp, err := New(sig, nil, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = mp.Compute(nil); err != nil {
+		t.Fatal(err)
+	}
+
+	mp32, err := NewFloat32(sig32, nil, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = mp32.Compute(nil); err != nil {
+		t.Fatal(err)
+	}
+
+	out := mp32.MPFloat32()
+	if len(out) != len(mp.MP) {
+		t.Fatalf("Expected %d elements, but got %d", len(mp.MP), len(out))
+	}
+	for i := range out {
+		if math.Abs(float64(out[i])-mp.MP[i]) > 1e-3 {
+			t.Errorf("Expected\n%.4f, but got\n%.4f", mp.MP, out)
+			break
+		}
+		if mp32.Idx[i] != mp.Idx[i] {
+			t.Errorf("Expected %v,\nbut got\n%v", mp.Idx, mp32.Idx)
+			break
+		}
+	}
+
+	if err = mp32.UpdateFloat32([]float32{0.5, 0.9}); err != nil {
+		t.Fatal(err)
+	}
+	if len(mp32.A) != len(sig32)+2 {
+		t.Errorf("Expected timeseries length of %d, but got %d", len(sig32)+2, len(mp32.A))
+	}
+}
+
+func TestNewKMPFloat32(t *testing.T) {
+	testdata := []struct {
+		t           [][]float32
+		w           int
+		expectedErr bool
+	}{
+		{nil, 2, true},
+		{[][]float32{}, 2, true},
+		{[][]float32{{1, 1, 1, 1, 1}}, 2, false},
+		{[][]float32{{1, 1, 1, 1, 1}, {1, 1, 1}}, 2, true},
+	}
+
+	for _, d := range testdata {
+		_, err := NewKMPFloat32(d.t, d.w)
+		if d.expectedErr && err == nil {
+			t.Errorf("Expected an error, but got none for %v", d)
+		}
+		if !d.expectedErr && err != nil {
+			t.Errorf("Expected no error, but got %v for %v", err, d)
+		}
+	}
+}
+
+func TestNewFromVectors(t *testing.T) {
+	sig := []float64{0, 0.99, 1, 0, 0, 0.98, 1, 0, 0, 0.96, 1, 0}
+
+	testdata := []struct {
+		a           mat.Vector
+		b           mat.Vector
+		m           int
+		expectedErr bool
+	}{
+		{nil, nil, 4, true},
+		{(*mat.VecDense)(nil), nil, 4, true},
+		{mat.NewVecDense(len(sig), sig), nil, 4, false},
+		{mat.NewVecDense(len(sig), sig), (*mat.VecDense)(nil), 4, false},
+		{mat.NewVecDense(len(sig), sig), mat.NewVecDense(len(sig), sig), 4, false},
+		{mat.NewVecDense(len(sig), sig).SliceVec(0, 6), nil, 7, true},
+		{mat.NewDense(len(sig), 1, sig).ColView(0), nil, 4, false},
+		{mat.NewDense(2, 6, sig).RowView(1), nil, 3, false},
+	}
+
+	for _, d := range testdata {
+		mp, err := NewFromVectors(d.a, d.b, d.m)
+		if d.expectedErr && err == nil {
+			t.Errorf("Expected an error, but got none for %v", d)
+			continue
+		}
+		if !d.expectedErr && err != nil {
+			t.Errorf("Expected no error, but got %v for %v", err, d)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if len(mp.A) != d.a.Len() {
+			t.Errorf("Expected length %d, but got %d", d.a.Len(), len(mp.A))
+		}
+		for i := range mp.A {
+			if mp.A[i] != d.a.AtVec(i) {
+				t.Errorf("Expected %v at index %d, but got %v", d.a.AtVec(i), i, mp.A[i])
+				break
+			}
+		}
+		if err = mp.Compute(nil); err != nil {
+			t.Errorf("Did not expect error, %v", err)
+		}
+		v := mp.MPVec()
+		if v.Len() != len(mp.MP) {
+			t.Errorf("Expected vector length %d, but got %d", len(mp.MP), v.Len())
+		}
+	}
+}
+
+func TestNewFromVectorsNoClobber(t *testing.T) {
+	data := []float64{0, 0.99, 1, 0, 0, 0.98, 1, 0, 0, 0.96, 1, 0, 42}
+	v := mat.NewVecDense(len(data), data).SliceVec(0, 12)
+
+	mp, err := NewFromVectors(v, nil, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = mp.Compute(nil); err != nil {
+		t.Fatal(err)
+	}
+	if err = mp.Update([]float64{0.5}); err != nil {
+		t.Fatal(err)
+	}
+	if data[12] != 42 {
+		t.Errorf("Expected the backing data to be untouched, but got %v", data[12])
+	}
+}
+
+func TestNewKMPFromMatrix(t *testing.T) {
+	raw := []float64{
+		0, 0, 1, 0, 0, 1, 0, 0, 1, 0,
+		1, 0, 0, 1, 0, 0, 1, 0, 0, 1,
+	}
+
+	testdata := []struct {
+		t           mat.Matrix
+		w           int
+		expectedErr bool
+	}{
+		{nil, 3, true},
+		{(*mat.Dense)(nil), 3, true},
+		{mat.NewDense(2, 10, raw), 3, false},
+		{mat.NewDense(2, 10, raw).T(), 3, true},
+		{mat.NewDense(10, 2, raw).T(), 3, false},
+		{mat.NewDense(2, 10, raw), 1, true},
+	}
+
+	for _, d := range testdata {
+		k, err := NewKMPFromMatrix(d.t, d.w)
+		if d.expectedErr && err == nil {
+			t.Errorf("Expected an error, but got none for %v", d)
+			continue
+		}
+		if !d.expectedErr && err != nil {
+			t.Errorf("Expected no error, but got %v for %v", err, d)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		r, c := d.t.Dims()
+		if len(k.T) != r || len(k.T[0]) != c {
+			t.Errorf("Expected %dx%d timeseries, but got %dx%d", r, c, len(k.T), len(k.T[0]))
+		}
+		if err = k.Compute(); err != nil {
+			t.Errorf("Did not expect error, %v", err)
+			continue
+		}
+		out := k.MPMatrix()
+		if mr, mc := out.Dims(); mr != len(k.MP) || mc != len(k.MP[0]) {
+			t.Errorf("Expected %dx%d matrix, but got %dx%d", len(k.MP), len(k.MP[0]), mr, mc)
+		}
+	}
+}
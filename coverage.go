@@ -0,0 +1,101 @@
+package matrixprofile
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// Interval is a range of points of a timeseries, [Start, End).
+type Interval struct {
+	Start int `json:"start"` // first point of the interval
+	End   int `json:"end"`   // point after the last point of the interval
+}
+
+// GroupCoverage is the part of a timeseries covered by the occurrences of one motif.
+type GroupCoverage struct {
+	Fraction  float64    `json:"fraction"`  // fraction of the points of the timeseries covered by the motif
+	Intervals []Interval `json:"intervals"` // disjoint intervals covered by the motif, in order
+}
+
+// MotifCoverage is the part of a timeseries covered by a set of motifs. A signal made
+// of repeating patterns is mostly covered by its top motifs, while a noisy one is
+// barely covered, and the points outside of the intervals are what a motif based
+// summary of the signal would have to keep as is.
+type MotifCoverage struct {
+	Fraction  float64         `json:"fraction"`  // fraction of the points of the timeseries covered by any motif
+	Intervals []Interval      `json:"intervals"` // disjoint intervals covered by any motif, in order
+	Motifs    []GroupCoverage `json:"motifs"`    // coverage of each motif, in the order given
+}
+
+// MotifCoverage measures how much of the timeseries is covered by the occurrences of
+// the motifs, such as the top k groups returned by DiscoverMotifs. Overlapping
+// occurrences count once. Intervals are points of the timeseries before the options
+// transform, see RawSpan, and occurrences wrapping around the end of a circular self
+// join cover both ends.
+func (mp MatrixProfile) MotifCoverage(motifs []MotifGroup) (*MotifCoverage, error) {
+	if mp.A == nil {
+		return nil, errors.New("matrix profile has no timeseries")
+	}
+	n := len(mp.A)
+	if mp.RawA != nil {
+		n = len(mp.RawA)
+	}
+	subs := len(mp.A) - mp.W + 1
+
+	var all []Interval
+	cov := &MotifCoverage{Motifs: make([]GroupCoverage, len(motifs))}
+	for g, motif := range motifs {
+		var spans []Interval
+		for _, idx := range motif.Idx {
+			if idx < 0 || idx >= subs {
+				return nil, fmt.Errorf("motif index, %d, is out of range of the %d subsequences", idx, subs)
+			}
+			start, end := mp.RawSpan(idx)
+			if end > n {
+				spans = append(spans, Interval{Start: 0, End: end - n})
+				end = n
+			}
+			spans = append(spans, Interval{Start: start, End: end})
+		}
+		all = append(all, spans...)
+
+		merged := mergeIntervals(spans)
+		cov.Motifs[g] = GroupCoverage{
+			Fraction:  float64(intervalPoints(merged)) / float64(n),
+			Intervals: merged,
+		}
+	}
+	cov.Intervals = mergeIntervals(all)
+	cov.Fraction = float64(intervalPoints(cov.Intervals)) / float64(n)
+	return cov, nil
+}
+
+// mergeIntervals sorts intervals and merges the ones that overlap or touch.
+func mergeIntervals(intervals []Interval) []Interval {
+	sorted := append([]Interval(nil), intervals...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Start < sorted[j].Start
+	})
+
+	merged := make([]Interval, 0, len(sorted))
+	for _, iv := range sorted {
+		if last := len(merged) - 1; last >= 0 && iv.Start <= merged[last].End {
+			if iv.End > merged[last].End {
+				merged[last].End = iv.End
+			}
+			continue
+		}
+		merged = append(merged, iv)
+	}
+	return merged
+}
+
+// intervalPoints returns the number of points covered by disjoint intervals.
+func intervalPoints(intervals []Interval) int {
+	var points int
+	for _, iv := range intervals {
+		points += iv.End - iv.Start
+	}
+	return points
+}
@@ -0,0 +1,108 @@
+package matrixprofile
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestMotifCoverage(t *testing.T) {
+	a := make([]float64, 20)
+	for i := range a {
+		a[i] = math.Sin(float64(i))
+	}
+
+	testdata := []struct {
+		name      string
+		opts      *MPOpts
+		motifs    []MotifGroup
+		expected  *MotifCoverage
+		expectErr bool
+	}{
+		{
+			name:   "disjoint groups",
+			motifs: []MotifGroup{{Idx: []int{0, 10}}, {Idx: []int{15}}},
+			expected: &MotifCoverage{
+				Fraction:  0.6,
+				Intervals: []Interval{{0, 4}, {10, 14}, {15, 19}},
+				Motifs: []GroupCoverage{
+					{Fraction: 0.4, Intervals: []Interval{{0, 4}, {10, 14}}},
+					{Fraction: 0.2, Intervals: []Interval{{15, 19}}},
+				},
+			},
+		},
+		{
+			name:   "overlapping occurrences count once",
+			motifs: []MotifGroup{{Idx: []int{2, 4}}, {Idx: []int{6}}},
+			expected: &MotifCoverage{
+				Fraction:  0.4,
+				Intervals: []Interval{{2, 10}},
+				Motifs: []GroupCoverage{
+					{Fraction: 0.3, Intervals: []Interval{{2, 8}}},
+					{Fraction: 0.2, Intervals: []Interval{{6, 10}}},
+				},
+			},
+		},
+		{
+			name:   "no motifs",
+			motifs: nil,
+			expected: &MotifCoverage{
+				Intervals: []Interval{},
+				Motifs:    []GroupCoverage{},
+			},
+		},
+		{
+			name:   "differenced series",
+			opts:   &MPOpts{Algorithm: AlgoSTOMP, Euclidean: true, Transform: TransformDiff},
+			motifs: []MotifGroup{{Idx: []int{0, 14}}},
+			expected: &MotifCoverage{
+				Fraction:  0.5,
+				Intervals: []Interval{{0, 5}, {14, 19}},
+				Motifs:    []GroupCoverage{{Fraction: 0.5, Intervals: []Interval{{0, 5}, {14, 19}}}},
+			},
+		},
+		{
+			name:   "circular wrap around",
+			opts:   &MPOpts{Algorithm: AlgoSTOMP, Euclidean: true, Circular: true},
+			motifs: []MotifGroup{{Idx: []int{18}}},
+			expected: &MotifCoverage{
+				Fraction:  0.2,
+				Intervals: []Interval{{0, 2}, {18, 20}},
+				Motifs:    []GroupCoverage{{Fraction: 0.2, Intervals: []Interval{{0, 2}, {18, 20}}}},
+			},
+		},
+		{
+			name:      "out of range",
+			motifs:    []MotifGroup{{Idx: []int{17}}},
+			expectErr: true,
+		},
+	}
+
+	for _, d := range testdata {
+		mp, err := New(a, nil, 4)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if d.opts != nil {
+			if err = mp.applyTransform(d.opts); err != nil {
+				t.Fatal(err)
+			}
+			mp.Opts = d.opts
+		}
+
+		cov, err := mp.MotifCoverage(d.motifs)
+		if d.expectErr {
+			if err == nil {
+				t.Errorf("%s: expected an error", d.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", d.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(cov, d.expected) {
+			t.Errorf("%s: expected %+v, but got %+v", d.name, d.expected, cov)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package matrixprofile
+
+import (
+	"errors"
+	"math"
+)
+
+// CrossMatch is a pair of similar subsequences from the two timeseries of an AB join.
+type CrossMatch struct {
+	A      int     // starting index of the subsequence of a
+	B      int     // starting index of the subsequence of b
+	Dist   float64 // euclidean distance between the two subsequences
+	Mutual bool    // each subsequence is the nearest neighbor of the other
+}
+
+// CrossMatches returns the top k pairs of subsequences shared by the two timeseries of
+// an AB join in both directions. The first slice pairs subsequences of a with their
+// nearest neighbor in b and the second pairs subsequences of b with their nearest
+// neighbor in a, each ordered by distance. A pattern found in both recordings shows up
+// as a mutual pair in both, while a pattern of a that only resembles something in b
+// only shows up in the first. An exclusion zone around each pick, on the side of the
+// timeseries being ranked, keeps shifted copies of the same pair out of the results.
+// Joins computed with MPX keep both directions and are read as is. Other algorithms
+// keep only one, so both directions are recomputed with MASS.
+func (mp MatrixProfile) CrossMatches(k, exclusionZone int) ([]CrossMatch, []CrossMatch, error) {
+	if mp.SelfJoin {
+		return nil, nil, errors.New("can only find cross matches if an AB join is performed")
+	}
+	if mp.Opts == nil {
+		return nil, nil, errors.New("matrix profile must be computed before finding cross matches")
+	}
+
+	ab, abIdx, ba, baIdx, err := mp.bothDirections()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	pairs := func(profile []float64, idx, otherIdx []int, fromA bool) []CrossMatch {
+		matches := topKMatches(profile, k, exclusionZone)
+		out := make([]CrossMatch, len(matches))
+		for n, m := range matches {
+			j := idx[m.Idx]
+			out[n] = CrossMatch{A: m.Idx, B: j, Dist: m.Dist}
+			if !fromA {
+				out[n].A, out[n].B = j, m.Idx
+			}
+			out[n].Mutual = j >= 0 && j < len(otherIdx) && otherIdx[j] == m.Idx
+		}
+		return out
+	}
+	return pairs(ab, abIdx, baIdx, true), pairs(ba, baIdx, abIdx, false), nil
+}
+
+// bothDirections returns the euclidean matrix profile of a against b and of b against
+// a with their indices. Joins computed with MPX keep both directions and are read as
+// is, otherwise both are recomputed with MASS.
+func (mp MatrixProfile) bothDirections() ([]float64, []int, []float64, []int, error) {
+	if mp.MPB != nil {
+		ba := MatrixProfile{MP: mp.MPB, W: mp.W, Opts: mp.Opts}.euclideanMP()
+		return mp.euclideanMP(), mp.Idx, ba, mp.IdxB, nil
+	}
+	if mp.Opts.Manhattan {
+		return nil, nil, nil, nil, errors.New("both directions of manhattan distances need an AB join computed with MPX")
+	}
+	return mp.crossProfiles()
+}
+
+// crossProfiles computes the euclidean matrix profile of a against b and of b against
+// a in one pass over the distance profiles of the subsequences of a.
+func (mp MatrixProfile) crossProfiles() ([]float64, []int, []float64, []int, error) {
+	nA := len(mp.A) - mp.W + 1
+	nB := len(mp.B) - mp.W + 1
+	ab, abIdx := make([]float64, nA), make([]int, nA)
+	ba, baIdx := make([]float64, nB), make([]int, nB)
+	for j := range ba {
+		ba[j], baIdx[j] = math.Inf(1), math.MaxInt64
+	}
+
+	squared := mp.squared()
+	err := mp.joinSubsequences(0, nA, true, func(i int, profile []float64, bestVal float64, bestIdx int) {
+		if squared {
+			for j, d := range profile {
+				profile[j] = math.Sqrt(d)
+			}
+			bestVal = math.Sqrt(bestVal)
+		}
+		ab[i], abIdx[i] = bestVal, bestIdx
+		if math.IsInf(bestVal, 1) {
+			abIdx[i] = math.MaxInt64
+		}
+		for j, d := range profile {
+			if !math.IsInf(d, 1) && isBetterMatch(d, i, ba[j], baIdx[j], true) {
+				ba[j], baIdx[j] = d, i
+			}
+		}
+	})
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+	return ab, abIdx, ba, baIdx, nil
+}
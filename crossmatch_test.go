@@ -0,0 +1,83 @@
+package matrixprofile
+
+import (
+	"math"
+	"testing"
+
+	"github.com/matrix-profile-foundation/go-matrixprofile/siggen"
+)
+
+func TestCrossMatches(t *testing.T) {
+	sine := siggen.Sin(1, 5, 0, 0, 100, 0.4)
+	saw := siggen.Sawtooth(1, 5, 0, 0, 100, 0.4)
+	w := 40
+
+	// the sine is shared by both recordings while the sawtooth only appears in a
+	a := seededNoise(1, 0.2, 800)
+	b := seededNoise(2, 0.2, 600)
+	for j := range sine {
+		a[100+j] += sine[j]
+		b[400+j] += sine[j]
+		a[500+j] += saw[j]
+	}
+
+	self, err := New(a, nil, w)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = self.Compute(NewMPOpts()); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err = self.CrossMatches(3, w/2); err == nil {
+		t.Errorf("Expected an error for a self join")
+	}
+
+	var expectedAB, expectedBA []CrossMatch
+	for _, algo := range []Algo{AlgoMPX, AlgoSTOMP} {
+		mp, err := New(a, b, w)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, _, err = mp.CrossMatches(3, w/2); err == nil {
+			t.Errorf("Expected an error before the join is computed")
+		}
+		o := NewMPOpts()
+		o.Algorithm = algo
+		if err = mp.Compute(o); err != nil {
+			t.Fatal(err)
+		}
+
+		ab, ba, err := mp.CrossMatches(3, w/2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(ab) != 3 || len(ba) != 3 {
+			t.Fatalf("%s: expected 3 matches in each direction, but got %v and %v", algo, ab, ba)
+		}
+		for _, matches := range [][]CrossMatch{ab, ba} {
+			// the sine is shifted by 300 points from a to b wherever the match lands on it
+			if matches[0].B-matches[0].A != 300 || math.Abs(float64(matches[0].A-100)) > float64(w/2) || !matches[0].Mutual {
+				t.Errorf("%s: expected a mutual match of the shared sine, but got %+v", algo, matches[0])
+			}
+			for i := 1; i < len(matches); i++ {
+				if matches[i].Dist < matches[i-1].Dist {
+					t.Errorf("%s: expected matches ordered by distance, but got %v", algo, matches)
+				}
+			}
+		}
+
+		// both directions are the same whichever algorithm kept them
+		if expectedAB == nil {
+			expectedAB, expectedBA = ab, ba
+			continue
+		}
+		for i := range ab {
+			for _, pair := range [][2]CrossMatch{{ab[i], expectedAB[i]}, {ba[i], expectedBA[i]}} {
+				got, want := pair[0], pair[1]
+				if got.A != want.A || got.B != want.B || got.Mutual != want.Mutual || math.Abs(got.Dist-want.Dist) > 1e-6 {
+					t.Errorf("%s: expected %+v, but got %+v", algo, want, got)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package matrixprofile
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/matrix-profile-foundation/go-matrixprofile/util"
+)
+
+// DAMP detects discords online with Discord Aware Matrix Profile, scoring each new
+// subsequence of a stream by its distance to its nearest neighbor in the past as soon
+// as its last point arrives. The past is searched backwards in chunks that double in
+// size, and the search is abandoned as soon as a neighbor closer than the best discord
+// found so far turns up, since the subsequence can no longer be the top discord. Most
+// subsequences are abandoned within the first chunk, so the amortized cost of each
+// point doesn't grow with the length of the stream. Every point is kept, as any part of
+// the past may hold a subsequence's nearest neighbor.
+type DAMP struct {
+	W          int     // subsequence length
+	Discord    int     // starting index of the top discord so far, -1 before any was scored
+	DiscordVal float64 // distance of the top discord so far to its nearest neighbor in the past
+
+	ts   []float64
+	std  []float64 // standard deviation of each complete subsequence of ts
+	ffts map[int]*fftCache
+}
+
+// NewDAMP creates a DAMP detector of subsequence length w from the history of the
+// stream, which must hold at least 2*w points so the first new subsequence has a
+// neighbor outside of its exclusion zone. Subsequences of the history are not scored.
+func NewDAMP(history []float64, w int) (*DAMP, error) {
+	if w < 2 {
+		return nil, fmt.Errorf("subsequence length must be at least 2")
+	}
+	if len(history) < 2*w {
+		return nil, fmt.Errorf("history length, %d, must be at least twice the subsequence length, %d", len(history), w)
+	}
+
+	d := &DAMP{
+		W:       w,
+		Discord: -1,
+		ts:      make([]float64, 0, 2*len(history)),
+		ffts:    make(map[int]*fftCache),
+	}
+	for _, v := range history {
+		d.push(v)
+	}
+	return d, nil
+}
+
+// push appends a point to the stream, recording the standard deviation of the
+// subsequence it completes.
+func (d *DAMP) push(v float64) {
+	d.ts = append(d.ts, v)
+	if len(d.ts) >= d.W {
+		_, std := windowMeanStd(d.ts[len(d.ts)-d.W:])
+		d.std = append(d.std, std)
+	}
+}
+
+// Len returns the number of points received, including the history.
+func (d DAMP) Len() int {
+	return len(d.ts)
+}
+
+// Update appends a point to the stream and returns the score of the subsequence it
+// completes, starting at Len()-W. A score at least as large as DiscordVal before the
+// update is the exact distance to the subsequence's nearest neighbor in the past, and
+// the subsequence becomes the top discord. A lower score is the distance to the
+// closest neighbor found before the search was abandoned, an upper bound of the exact
+// distance. A flat subsequence scores 0, as the z-normalized distance is undefined.
+func (d *DAMP) Update(v float64) (float64, error) {
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return 0, fmt.Errorf("value, %.3f, must be a finite number", v)
+	}
+	d.push(v)
+
+	i := len(d.ts) - d.W
+	q, err := util.ZNormalize(d.ts[i:])
+	if err != nil {
+		return 0, nil
+	}
+
+	// the first chunk holds the subsequences ending right before the query, and each
+	// following chunk the subsequences in the next stretch of the past, twice as long
+	chunk := 1
+	for chunk < 8*d.W {
+		chunk *= 2
+	}
+	best := math.Inf(1)
+	end := i
+	for {
+		start := i - chunk
+		if start < 0 {
+			start = 0
+		}
+		best = math.Min(best, d.chunkMin(q, start, end))
+		if best < d.DiscordVal {
+			return best, nil
+		}
+		if start == 0 {
+			break
+		}
+		end = start + d.W - 1
+		chunk *= 2
+	}
+
+	if !math.IsInf(best, 1) {
+		d.Discord, d.DiscordVal = i, best
+	}
+	return best, nil
+}
+
+// chunkMin returns the smallest z-normalized distance between the normalized query
+// and the subsequences within the points from start up to end, using MASS. Flat
+// subsequences have a distance of +Inf.
+func (d *DAMP) chunkMin(q []float64, start, end int) float64 {
+	n := util.FFTLen(end - start)
+	fft, ok := d.ffts[n]
+	if !ok {
+		fft = newFFTCache(n)
+		d.ffts[n] = fft
+	}
+	dot := fft.correlate(q, fft.coefficients(d.ts[start:end]))
+
+	best := math.Inf(1)
+	for j := 0; j < end-start-d.W+1; j++ {
+		std := d.std[start+j]
+		if std == 0 {
+			continue
+		}
+		// the query sums to 0, so its dot product with the raw subsequence equals the
+		// one with the mean removed
+		best = math.Min(best, math.Sqrt(math.Abs(2*(float64(d.W)-dot[j]/std))))
+	}
+	return best
+}
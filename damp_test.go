@@ -0,0 +1,79 @@
+package matrixprofile
+
+import (
+	"math"
+	"testing"
+
+	"github.com/matrix-profile-foundation/go-matrixprofile/siggen"
+	"gonum.org/v1/gonum/floats"
+)
+
+func TestNewDAMP(t *testing.T) {
+	testdata := []struct {
+		history     []float64
+		w           int
+		expectedErr bool
+	}{
+		{siggen.Noise(1, 100), 1, true},
+		{siggen.Noise(1, 19), 10, true},
+		{siggen.Noise(1, 20), 10, false},
+	}
+
+	for _, d := range testdata {
+		_, err := NewDAMP(d.history, d.w)
+		if d.expectedErr && err == nil {
+			t.Errorf("Expected an error, but got none for a history of %d and w of %d", len(d.history), d.w)
+		}
+		if !d.expectedErr && err != nil {
+			t.Errorf("Expected no error, but got %v for a history of %d and w of %d", err, len(d.history), d.w)
+		}
+	}
+}
+
+func TestDAMP(t *testing.T) {
+	sig := siggen.Add(siggen.Sin(1, 4, 0, 0, 100, 20), siggen.Noise(0.05, 2000))
+	for i := 0; i < 30; i++ {
+		sig[1500+i] += 0.8 * math.Sin(math.Pi*float64(i)/30)
+	}
+	w, start := 25, 300
+
+	d, err := NewDAMP(sig[:start], w)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = d.Update(math.NaN()); err == nil {
+		t.Errorf("Expected an error for a NaN value")
+	}
+	if d.Len() != start {
+		t.Fatalf("Expected %d points after a rejected value, but got %d", start, d.Len())
+	}
+
+	bestIdx, bestVal := -1, 0.0
+	for _, v := range sig[start:] {
+		prev := d.DiscordVal
+		score, err := d.Update(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// the exact distance to the nearest neighbor outside of the exclusion zone
+		i := d.Len() - w
+		left := floats.Min(bruteDistanceProfile(sig[i:i+w], sig[:i]))
+		if score < left-1e-6 {
+			t.Fatalf("Expected a score of at least %.6f at %d, but got %.6f", left, i, score)
+		}
+		if score >= prev && math.Abs(score-left) > 1e-6 {
+			t.Fatalf("Expected an exact score of %.6f at %d, but got %.6f", left, i, score)
+		}
+		if left > bestVal {
+			bestIdx, bestVal = i, left
+		}
+	}
+
+	if d.Discord != bestIdx || math.Abs(d.DiscordVal-bestVal) > 1e-6 {
+		t.Errorf("Expected the top discord at %d with %.6f, but got %d with %.6f", bestIdx, bestVal, d.Discord, d.DiscordVal)
+	}
+	if d.Discord+w < 1500 || d.Discord > 1530 {
+		t.Errorf("Expected the top discord to overlap the anomaly at 1500, but got %d", d.Discord)
+	}
+}
@@ -0,0 +1,188 @@
+package matrixprofile
+
+import (
+	"errors"
+	"math"
+)
+
+// Device is the hardware a matrix profile is computed on.
+type Device string
+
+const (
+	DeviceCPU    Device = ""       // the matrix profile is computed on the CPU
+	DeviceCUDA   Device = "cuda"   // an NVIDIA GPU through CUDA, when the package is built with the cuda tag and a device is present
+	DeviceOpenCL Device = "opencl" // an AMD, Intel, or integrated GPU through OpenCL, when the package is built with the opencl tag and a device is present
+)
+
+// errDeviceUnsupported is returned by a device backend that has no kernel for the
+// requested computation so it runs on the CPU instead.
+var errDeviceUnsupported = errors.New("computation is not supported on the device")
+
+// deviceBackend computes matrix profiles on an accelerator.
+type deviceBackend interface {
+	// available reports whether a device is present.
+	available() bool
+	// compute computes the matrix profile with the given algorithm, returning
+	// errDeviceUnsupported if the backend has no kernel for it.
+	compute(mp *MatrixProfile, algo Algo) error
+}
+
+// deviceBackends holds the backends compiled into the package. Each backend registers
+// itself from a file behind its own build tag, device_cuda.go with the cuda tag and
+// device_opencl.go with the opencl tag, so a default build has none and always
+// computes on the CPU.
+var deviceBackends = map[Device]deviceBackend{}
+
+// computeOnDevice computes the matrix profile on the device chosen in the options and
+// reports whether it did. It falls back to the CPU when the package was built without
+// a backend for the device, no device is present, or the backend does not support the
+// algorithm and options.
+func (mp *MatrixProfile) computeOnDevice(algo Algo) (bool, error) {
+	if mp.Opts.Device == DeviceCPU {
+		return false, nil
+	}
+	backend, ok := deviceBackends[mp.Opts.Device]
+	if !ok || !backend.available() {
+		return false, nil
+	}
+
+	err := backend.compute(mp, algo)
+	if err == errDeviceUnsupported {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// diagonalJob holds the inputs of the diagonal kernel the device backends share. Each
+// diagonal k of the distance matrix pairs the subsequences i and i+k, and walking it
+// updates the mean centered dot product of each pair from the previous one with the
+// MPX recurrence. The best match of each subsequence is written to best as a packed
+// match, see packMatch, merged with an atomic maximum.
+type diagonalJob struct {
+	ts     []float64 // self join timeseries
+	mu     []float64 // sliding mean of each subsequence
+	sig    []float64 // inverse norm of each mean centered subsequence
+	df     []float64 // MPX recurrence terms, see mpxStats
+	dg     []float64
+	w      int
+	minLag int // first diagonal walked
+	symLag int // diagonals below it only match the later subsequence to the earlier one
+	maxLag int // diagonals from it on are not walked
+}
+
+// deviceSupported reports whether the diagonal kernel computes the same matrix profile
+// as the CPU for the algorithm and options. Only plain z-normalized self joins are run
+// on a device, every other option is left to the CPU.
+func (mp MatrixProfile) deviceSupported(algo Algo) bool {
+	o := mp.Opts
+	if !mp.SelfJoin || o.Manhattan || o.NoiseStd > 0 || o.RemapNegCorr || o.MaxDistance > 0 {
+		return false
+	}
+	if algo == AlgoSTOMP && mp.circular() {
+		return false
+	}
+	// the index of each match is packed into 32 bits
+	return (algo == AlgoMPX || algo == AlgoSTOMP) && uint64(len(mp.A)-mp.W+1) < math.MaxUint32
+}
+
+// computeDiagonals computes a self join matrix profile with a device's diagonal kernel
+// run by the given function. The exclusion zone follows the algorithm on the CPU. The
+// kernel only picks the nearest neighbor of each subsequence, its distance is then
+// recomputed directly in double precision since the packed matches only keep single
+// precision correlations. Near ties within single precision can therefore pick a
+// different neighbor than the CPU does.
+func (mp *MatrixProfile) computeDiagonals(algo Algo, run func(job *diagonalJob, best []uint64) error) error {
+	if !mp.deviceSupported(algo) {
+		return errDeviceUnsupported
+	}
+
+	n := len(mp.A) - mp.W + 1
+	st := newMPXStats(mp.A, mp.W)
+	job := &diagonalJob{
+		ts:     mp.A,
+		mu:     st.mu,
+		sig:    st.sig,
+		df:     st.df,
+		dg:     st.dg,
+		w:      mp.W,
+		maxLag: n,
+	}
+	if algo == AlgoMPX {
+		job.minLag = mp.mpxExclusionZone()
+		job.symLag = job.minLag
+		if mp.circular() {
+			job.maxLag -= job.minLag - 1
+		}
+	} else {
+		// a distance profile excludes [i-zone, i+zone), so a subsequence zone points
+		// later only matches the earlier one
+		zone := mp.exclusionZone(mp.W / 2)
+		job.minLag = zone
+		job.symLag = zone + 1
+	}
+	if job.minLag < 1 {
+		job.minLag = 1
+	}
+	if job.symLag < job.minLag {
+		job.symLag = job.minLag
+	}
+
+	best := make([]uint64, n)
+	if err := run(job, best); err != nil {
+		return err
+	}
+
+	// only MPX keeps pearson correlations
+	euclidean := mp.Opts.Euclidean || algo != AlgoMPX
+	mp.MP = make([]float64, n)
+	mp.Idx = make([]int, n)
+	for i, b := range best {
+		j, ok := unpackMatch(b)
+		if !ok {
+			continue
+		}
+		var c float64
+		for t := 0; t < mp.W; t++ {
+			c += (mp.A[i+t] - st.mu[i]) * (mp.A[j+t] - st.mu[j])
+		}
+		mp.MP[i], mp.Idx[i] = c*st.sig[i]*st.sig[j], j
+	}
+	if euclidean {
+		mp.p2e(mp.MP)
+	}
+	for i, b := range best {
+		if _, ok := unpackMatch(b); !ok {
+			mp.MP[i], mp.Idx[i] = math.Inf(1), math.MaxInt64
+			if !euclidean {
+				mp.MP[i] = math.Inf(-1)
+			}
+		}
+	}
+	if algo == AlgoMPX {
+		mp.mpxStats = st
+	}
+	return nil
+}
+
+// packMatch packs a pearson correlation and the index of the match into a key whose
+// unsigned order is the order of better matches. The correlation is kept in single
+// precision in the high half with its bits ordered like the values, and the
+// complement of the index in the low half so ties favor the lower index, as in
+// isBetterMatch. A key of 0 is below every match and means no match.
+func packMatch(corr float64, idx int) uint64 {
+	bits := math.Float32bits(float32(corr))
+	if bits&(1<<31) != 0 {
+		bits = ^bits
+	} else {
+		bits |= 1 << 31
+	}
+	return uint64(bits)<<32 | uint64(^uint32(idx))
+}
+
+// unpackMatch returns the index of a packed match and whether there is one.
+func unpackMatch(key uint64) (int, bool) {
+	if key == 0 {
+		return 0, false
+	}
+	return int(^uint32(key)), true
+}
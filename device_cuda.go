@@ -0,0 +1,206 @@
+//go:build cuda
+// +build cuda
+
+package matrixprofile
+
+/*
+#cgo LDFLAGS: -lcuda -lnvrtc
+#include <stdio.h>
+#include <stdlib.h>
+#include <cuda.h>
+#include <nvrtc.h>
+
+// mpx_diagonals walks one diagonal of the distance matrix per thread with the MPX
+// recurrence, merging the best match of each subsequence as a packed match with a
+// 64 bit atomic maximum. It mirrors runDiagonals in device_test.go.
+static const char *mpxDiagonalsSource =
+	"extern \"C\" __global__ void mpx_diagonals(const double *ts, const double *mu,\n"
+	"    const double *sig, const double *df, const double *dg, int n, int w,\n"
+	"    int minLag, int symLag, int maxLag, unsigned long long *best)\n"
+	"{\n"
+	"  int k = minLag + blockIdx.x * blockDim.x + threadIdx.x;\n"
+	"  if (k >= maxLag) return;\n"
+	"  double c = 0;\n"
+	"  for (int t = 0; t < w; t++) c += (ts[k + t] - mu[k]) * (ts[t] - mu[0]);\n"
+	"  for (int i = 0; i + k < n; i++) {\n"
+	"    int j = i + k;\n"
+	"    if (i > 0) c += df[i] * dg[j] + df[j] * dg[i];\n"
+	"    double corr = c * sig[i] * sig[j];\n"
+	"    if (corr != corr) continue;\n"
+	"    unsigned int bits = __float_as_uint((float)corr);\n"
+	"    bits = (bits & 0x80000000u) ? ~bits : (bits | 0x80000000u);\n"
+	"    unsigned long long key = (unsigned long long)bits << 32;\n"
+	"    atomicMax(&best[j], key | (unsigned int)~(unsigned int)i);\n"
+	"    if (k >= symLag) atomicMax(&best[i], key | (unsigned int)~(unsigned int)j);\n"
+	"  }\n"
+	"}\n";
+
+// mpCudaAvailable reports whether a CUDA device with 64 bit atomics is present.
+static int mpCudaAvailable(void) {
+	CUdevice dev;
+	int count = 0, major = 0;
+	if (cuInit(0) != CUDA_SUCCESS || cuDeviceGetCount(&count) != CUDA_SUCCESS || count == 0) {
+		return 0;
+	}
+	if (cuDeviceGet(&dev, 0) != CUDA_SUCCESS ||
+		cuDeviceGetAttribute(&major, CU_DEVICE_ATTRIBUTE_COMPUTE_CAPABILITY_MAJOR, dev) != CUDA_SUCCESS) {
+		return 0;
+	}
+	// atomicMax on 64 bit integers needs compute capability 3.5, which every device
+	// supported by current drivers has
+	return major >= 3;
+}
+
+// mpCudaDiagonals compiles the kernel for the first device and runs it over the
+// diagonals minLag to maxLag. On failure it writes the reason to msg and returns a
+// nonzero value.
+static int mpCudaDiagonals(const double *ts, const double *mu, const double *sig,
+	const double *df, const double *dg, int n, int w, int minLag, int symLag, int maxLag,
+	unsigned long long *best, char *msg, size_t msgLen) {
+	CUdevice dev;
+	CUcontext ctx = NULL;
+	CUmodule mod = NULL;
+	CUfunction fn;
+	CUdeviceptr dts = 0, dmu = 0, dsig = 0, ddf = 0, ddg = 0, dbest = 0;
+	nvrtcProgram prog = NULL;
+	char *ptx = NULL;
+	const char *name = NULL;
+	int major = 0, minor = 0, rc = 1;
+	size_t lenTs = (size_t)(n + w - 1) * sizeof(double), lenN = (size_t)n * sizeof(double);
+	CUresult res;
+	nvrtcResult nres;
+
+#define MP_CU(call) do { res = (call); if (res != CUDA_SUCCESS) { \
+	cuGetErrorName(res, &name); \
+	snprintf(msg, msgLen, "%s failed: %s", #call, name ? name : "unknown error"); \
+	goto done; } } while (0)
+#define MP_NVRTC(call) do { nres = (call); if (nres != NVRTC_SUCCESS) { \
+	snprintf(msg, msgLen, "%s failed: %s", #call, nvrtcGetErrorString(nres)); \
+	goto done; } } while (0)
+
+	MP_CU(cuInit(0));
+	MP_CU(cuDeviceGet(&dev, 0));
+	MP_CU(cuDeviceGetAttribute(&major, CU_DEVICE_ATTRIBUTE_COMPUTE_CAPABILITY_MAJOR, dev));
+	MP_CU(cuDeviceGetAttribute(&minor, CU_DEVICE_ATTRIBUTE_COMPUTE_CAPABILITY_MINOR, dev));
+	MP_CU(cuDevicePrimaryCtxRetain(&ctx, dev));
+	MP_CU(cuCtxSetCurrent(ctx));
+
+	{
+		char arch[64];
+		const char *opts[1];
+		size_t ptxLen = 0;
+		snprintf(arch, sizeof(arch), "--gpu-architecture=compute_%d%d", major, minor);
+		opts[0] = arch;
+		MP_NVRTC(nvrtcCreateProgram(&prog, mpxDiagonalsSource, "mpx_diagonals.cu", 0, NULL, NULL));
+		nres = nvrtcCompileProgram(prog, 1, opts);
+		if (nres != NVRTC_SUCCESS) {
+			size_t logLen = 0;
+			nvrtcGetProgramLogSize(prog, &logLen);
+			char *log = (char *)malloc(logLen + 1);
+			if (log != NULL) {
+				log[0] = 0;
+				nvrtcGetProgramLog(prog, log);
+				snprintf(msg, msgLen, "compiling the kernel failed: %s", log);
+				free(log);
+			}
+			goto done;
+		}
+		MP_NVRTC(nvrtcGetPTXSize(prog, &ptxLen));
+		ptx = (char *)malloc(ptxLen);
+		if (ptx == NULL) {
+			snprintf(msg, msgLen, "out of memory for the kernel");
+			goto done;
+		}
+		MP_NVRTC(nvrtcGetPTX(prog, ptx));
+	}
+	MP_CU(cuModuleLoadData(&mod, ptx));
+	MP_CU(cuModuleGetFunction(&fn, mod, "mpx_diagonals"));
+
+	MP_CU(cuMemAlloc(&dts, lenTs));
+	MP_CU(cuMemAlloc(&dmu, lenN));
+	MP_CU(cuMemAlloc(&dsig, lenN));
+	MP_CU(cuMemAlloc(&ddf, lenN));
+	MP_CU(cuMemAlloc(&ddg, lenN));
+	MP_CU(cuMemAlloc(&dbest, (size_t)n * sizeof(unsigned long long)));
+	MP_CU(cuMemcpyHtoD(dts, ts, lenTs));
+	MP_CU(cuMemcpyHtoD(dmu, mu, lenN));
+	MP_CU(cuMemcpyHtoD(dsig, sig, lenN));
+	MP_CU(cuMemcpyHtoD(ddf, df, lenN));
+	MP_CU(cuMemcpyHtoD(ddg, dg, lenN));
+	MP_CU(cuMemsetD8(dbest, 0, (size_t)n * sizeof(unsigned long long)));
+
+	{
+		unsigned int block = 256;
+		unsigned int grid = (unsigned int)((maxLag - minLag + block - 1) / block);
+		void *args[] = {&dts, &dmu, &dsig, &ddf, &ddg, &n, &w, &minLag, &symLag, &maxLag, &dbest};
+		if (grid > 0) {
+			MP_CU(cuLaunchKernel(fn, grid, 1, 1, block, 1, 1, 0, NULL, args, NULL));
+			MP_CU(cuCtxSynchronize());
+		}
+	}
+	MP_CU(cuMemcpyDtoH(best, dbest, (size_t)n * sizeof(unsigned long long)));
+	rc = 0;
+
+done:
+	if (dbest) cuMemFree(dbest);
+	if (ddg) cuMemFree(ddg);
+	if (ddf) cuMemFree(ddf);
+	if (dsig) cuMemFree(dsig);
+	if (dmu) cuMemFree(dmu);
+	if (dts) cuMemFree(dts);
+	if (mod) cuModuleUnload(mod);
+	if (ctx) cuDevicePrimaryCtxRelease(dev);
+	if (prog) nvrtcDestroyProgram(&prog);
+	free(ptx);
+	return rc;
+#undef MP_CU
+#undef MP_NVRTC
+}
+*/
+import "C"
+
+import (
+	"errors"
+	"math"
+	"unsafe"
+)
+
+func init() {
+	deviceBackends[DeviceCUDA] = cudaBackend{}
+}
+
+// cudaBackend computes MPX and STOMP self joins on the first CUDA device with the
+// diagonal kernel, compiled for the device at run time with NVRTC. Building with the
+// cuda tag needs the CUDA toolkit headers and links against libcuda and libnvrtc.
+type cudaBackend struct{}
+
+func (cudaBackend) available() bool {
+	return C.mpCudaAvailable() != 0
+}
+
+func (cudaBackend) compute(mp *MatrixProfile, algo Algo) error {
+	return mp.computeDiagonals(algo, func(job *diagonalJob, best []uint64) error {
+		n := len(job.mu)
+		if n > math.MaxInt32 {
+			return errDeviceUnsupported
+		}
+		if n == 0 {
+			return nil
+		}
+		msg := make([]byte, 1024)
+		rc := C.mpCudaDiagonals(
+			(*C.double)(unsafe.Pointer(&job.ts[0])),
+			(*C.double)(unsafe.Pointer(&job.mu[0])),
+			(*C.double)(unsafe.Pointer(&job.sig[0])),
+			(*C.double)(unsafe.Pointer(&job.df[0])),
+			(*C.double)(unsafe.Pointer(&job.dg[0])),
+			C.int(n), C.int(job.w), C.int(job.minLag), C.int(job.symLag), C.int(job.maxLag),
+			(*C.ulonglong)(unsafe.Pointer(&best[0])),
+			(*C.char)(unsafe.Pointer(&msg[0])), C.size_t(len(msg)),
+		)
+		if rc != 0 {
+			return errors.New("cuda: " + C.GoString((*C.char)(unsafe.Pointer(&msg[0]))))
+		}
+		return nil
+	})
+}
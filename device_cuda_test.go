@@ -0,0 +1,47 @@
+//go:build cuda
+// +build cuda
+
+package matrixprofile
+
+import (
+	"math"
+	"testing"
+
+	"github.com/matrix-profile-foundation/go-matrixprofile/siggen"
+)
+
+func TestCUDABackend(t *testing.T) {
+	backend := cudaBackend{}
+	if !backend.available() {
+		t.Skip("no cuda device is present")
+	}
+	sig := siggen.Add(siggen.Sin(1, 4, 0, 0, 100, 20), seededNoise(1, 0.2, 2000))
+
+	for _, algo := range []Algo{AlgoMPX, AlgoSTOMP} {
+		o := NewMPOpts()
+		o.Algorithm = algo
+		expected, err := New(sig, nil, 32)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = expected.Compute(o); err != nil {
+			t.Fatal(err)
+		}
+
+		mp, err := New(sig, nil, 32)
+		if err != nil {
+			t.Fatal(err)
+		}
+		mp.Opts = o
+		if err = backend.compute(mp, algo); err != nil {
+			t.Fatalf("%s: did not expect an error, %v", algo, err)
+		}
+		// near ties in single precision may pick another neighbor at the same distance
+		for i := range expected.MP {
+			if math.Abs(mp.MP[i]-expected.MP[i]) > 1e-4 {
+				t.Errorf("%s: expected %.6f at %d, but got %.6f", algo, expected.MP[i], i, mp.MP[i])
+				break
+			}
+		}
+	}
+}
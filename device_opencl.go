@@ -0,0 +1,222 @@
+//go:build opencl
+// +build opencl
+
+package matrixprofile
+
+/*
+#cgo !darwin LDFLAGS: -lOpenCL
+#cgo darwin LDFLAGS: -framework OpenCL
+#define CL_TARGET_OPENCL_VERSION 120
+#define CL_USE_DEPRECATED_OPENCL_1_2_APIS
+#include <stdio.h>
+#include <stdlib.h>
+#include <string.h>
+#ifdef __APPLE__
+#include <OpenCL/opencl.h>
+#else
+#include <CL/cl.h>
+#endif
+
+// mpx_diagonals walks one diagonal of the distance matrix per work item with the MPX
+// recurrence, merging the best match of each subsequence as a packed match with a
+// 64 bit atomic maximum. It mirrors runDiagonals in device_test.go.
+static const char *mpxDiagonalsSource =
+	"#pragma OPENCL EXTENSION cl_khr_fp64 : enable\n"
+	"#pragma OPENCL EXTENSION cl_khr_int64_extended_atomics : enable\n"
+	"__kernel void mpx_diagonals(__global const double *ts, __global const double *mu,\n"
+	"    __global const double *sig, __global const double *df, __global const double *dg,\n"
+	"    const int n, const int w, const int minLag, const int symLag, const int maxLag,\n"
+	"    __global ulong *best)\n"
+	"{\n"
+	"  int k = minLag + (int)get_global_id(0);\n"
+	"  if (k >= maxLag) return;\n"
+	"  double c = 0;\n"
+	"  for (int t = 0; t < w; t++) c += (ts[k + t] - mu[k]) * (ts[t] - mu[0]);\n"
+	"  for (int i = 0; i + k < n; i++) {\n"
+	"    int j = i + k;\n"
+	"    if (i > 0) c += df[i] * dg[j] + df[j] * dg[i];\n"
+	"    double corr = c * sig[i] * sig[j];\n"
+	"    if (isnan(corr)) continue;\n"
+	"    uint bits = as_uint((float)corr);\n"
+	"    bits = (bits & 0x80000000u) ? ~bits : (bits | 0x80000000u);\n"
+	"    ulong key = (ulong)bits << 32;\n"
+	"    atom_max(&best[j], key | (ulong)(~(uint)i));\n"
+	"    if (k >= symLag) atom_max(&best[i], key | (ulong)(~(uint)j));\n"
+	"  }\n"
+	"}\n";
+
+// mpOpenCLDevice finds the first device, preferring GPUs, with double precision and
+// 64 bit atomics. It returns 0 when there is none.
+static int mpOpenCLDevice(cl_device_id *out) {
+	cl_platform_id platforms[16];
+	cl_uint nplatforms = 0, p, d;
+	cl_device_type types[2] = {CL_DEVICE_TYPE_GPU, CL_DEVICE_TYPE_ALL};
+	int t;
+	if (clGetPlatformIDs(16, platforms, &nplatforms) != CL_SUCCESS) {
+		return 0;
+	}
+	if (nplatforms > 16) {
+		nplatforms = 16;
+	}
+	for (t = 0; t < 2; t++) {
+		for (p = 0; p < nplatforms; p++) {
+			cl_device_id devices[16];
+			cl_uint ndevices = 0;
+			if (clGetDeviceIDs(platforms[p], types[t], 16, devices, &ndevices) != CL_SUCCESS) {
+				continue;
+			}
+			if (ndevices > 16) {
+				ndevices = 16;
+			}
+			for (d = 0; d < ndevices; d++) {
+				char ext[8192];
+				size_t len = 0;
+				if (clGetDeviceInfo(devices[d], CL_DEVICE_EXTENSIONS, sizeof(ext) - 1, ext, &len) != CL_SUCCESS) {
+					continue;
+				}
+				ext[len < sizeof(ext) ? len : sizeof(ext) - 1] = 0;
+				if (strstr(ext, "cl_khr_fp64") && strstr(ext, "cl_khr_int64_extended_atomics")) {
+					*out = devices[d];
+					return 1;
+				}
+			}
+		}
+	}
+	return 0;
+}
+
+static int mpOpenCLAvailable(void) {
+	cl_device_id dev;
+	return mpOpenCLDevice(&dev);
+}
+
+// mpOpenCLDiagonals builds the kernel for the chosen device and runs it over the
+// diagonals minLag to maxLag. best holds zeros on entry. On failure it writes the
+// reason to msg and returns a nonzero value.
+static int mpOpenCLDiagonals(double *ts, double *mu, double *sig, double *df, double *dg,
+	int n, int w, int minLag, int symLag, int maxLag, cl_ulong *best, char *msg, size_t msgLen) {
+	cl_device_id dev;
+	cl_context ctx = NULL;
+	cl_command_queue queue = NULL;
+	cl_program prog = NULL;
+	cl_kernel kernel = NULL;
+	cl_mem bufs[6] = {NULL, NULL, NULL, NULL, NULL, NULL};
+	size_t lenTs = (size_t)(n + w - 1) * sizeof(double), lenN = (size_t)n * sizeof(double);
+	size_t global = (size_t)(maxLag - minLag);
+	cl_int err = CL_SUCCESS;
+	int i, rc = 1;
+
+#define MP_CL(call) do { err = (call); if (err != CL_SUCCESS) { \
+	snprintf(msg, msgLen, "%s failed with error %d", #call, (int)err); \
+	goto done; } } while (0)
+#define MP_CL_CREATE(v, call) do { v = (call); if (err != CL_SUCCESS) { \
+	snprintf(msg, msgLen, "%s failed with error %d", #call, (int)err); \
+	goto done; } } while (0)
+
+	if (!mpOpenCLDevice(&dev)) {
+		snprintf(msg, msgLen, "no device with double precision and 64 bit atomics");
+		goto done;
+	}
+	MP_CL_CREATE(ctx, clCreateContext(NULL, 1, &dev, NULL, NULL, &err));
+	MP_CL_CREATE(queue, clCreateCommandQueue(ctx, dev, 0, &err));
+	MP_CL_CREATE(prog, clCreateProgramWithSource(ctx, 1, &mpxDiagonalsSource, NULL, &err));
+	err = clBuildProgram(prog, 1, &dev, NULL, NULL, NULL);
+	if (err != CL_SUCCESS) {
+		size_t logLen = 0;
+		clGetProgramBuildInfo(prog, dev, CL_PROGRAM_BUILD_LOG, 0, NULL, &logLen);
+		char *log = (char *)malloc(logLen + 1);
+		if (log != NULL) {
+			log[0] = 0;
+			clGetProgramBuildInfo(prog, dev, CL_PROGRAM_BUILD_LOG, logLen, log, NULL);
+			log[logLen] = 0;
+			snprintf(msg, msgLen, "building the kernel failed: %s", log);
+			free(log);
+		}
+		goto done;
+	}
+	MP_CL_CREATE(kernel, clCreateKernel(prog, "mpx_diagonals", &err));
+
+	MP_CL_CREATE(bufs[0], clCreateBuffer(ctx, CL_MEM_READ_ONLY | CL_MEM_COPY_HOST_PTR, lenTs, ts, &err));
+	MP_CL_CREATE(bufs[1], clCreateBuffer(ctx, CL_MEM_READ_ONLY | CL_MEM_COPY_HOST_PTR, lenN, mu, &err));
+	MP_CL_CREATE(bufs[2], clCreateBuffer(ctx, CL_MEM_READ_ONLY | CL_MEM_COPY_HOST_PTR, lenN, sig, &err));
+	MP_CL_CREATE(bufs[3], clCreateBuffer(ctx, CL_MEM_READ_ONLY | CL_MEM_COPY_HOST_PTR, lenN, df, &err));
+	MP_CL_CREATE(bufs[4], clCreateBuffer(ctx, CL_MEM_READ_ONLY | CL_MEM_COPY_HOST_PTR, lenN, dg, &err));
+	MP_CL_CREATE(bufs[5], clCreateBuffer(ctx, CL_MEM_READ_WRITE | CL_MEM_COPY_HOST_PTR, (size_t)n * sizeof(cl_ulong), best, &err));
+
+	for (i = 0; i < 5; i++) {
+		MP_CL(clSetKernelArg(kernel, i, sizeof(cl_mem), &bufs[i]));
+	}
+	MP_CL(clSetKernelArg(kernel, 5, sizeof(int), &n));
+	MP_CL(clSetKernelArg(kernel, 6, sizeof(int), &w));
+	MP_CL(clSetKernelArg(kernel, 7, sizeof(int), &minLag));
+	MP_CL(clSetKernelArg(kernel, 8, sizeof(int), &symLag));
+	MP_CL(clSetKernelArg(kernel, 9, sizeof(int), &maxLag));
+	MP_CL(clSetKernelArg(kernel, 10, sizeof(cl_mem), &bufs[5]));
+
+	if (global > 0) {
+		MP_CL(clEnqueueNDRangeKernel(queue, kernel, 1, NULL, &global, NULL, 0, NULL, NULL));
+	}
+	MP_CL(clEnqueueReadBuffer(queue, bufs[5], CL_TRUE, 0, (size_t)n * sizeof(cl_ulong), best, 0, NULL, NULL));
+	rc = 0;
+
+done:
+	for (i = 0; i < 6; i++) {
+		if (bufs[i]) clReleaseMemObject(bufs[i]);
+	}
+	if (kernel) clReleaseKernel(kernel);
+	if (prog) clReleaseProgram(prog);
+	if (queue) clReleaseCommandQueue(queue);
+	if (ctx) clReleaseContext(ctx);
+	return rc;
+#undef MP_CL
+#undef MP_CL_CREATE
+}
+*/
+import "C"
+
+import (
+	"errors"
+	"math"
+	"unsafe"
+)
+
+func init() {
+	deviceBackends[DeviceOpenCL] = openCLBackend{}
+}
+
+// openCLBackend computes MPX and STOMP self joins with the diagonal kernel on the
+// first OpenCL device, preferring GPUs, that supports double precision and 64 bit
+// atomics. The kernel is built for the device at run time. Building with the opencl
+// tag needs the OpenCL headers and links against the OpenCL loader.
+type openCLBackend struct{}
+
+func (openCLBackend) available() bool {
+	return C.mpOpenCLAvailable() != 0
+}
+
+func (openCLBackend) compute(mp *MatrixProfile, algo Algo) error {
+	return mp.computeDiagonals(algo, func(job *diagonalJob, best []uint64) error {
+		n := len(job.mu)
+		if n > math.MaxInt32 {
+			return errDeviceUnsupported
+		}
+		if n == 0 {
+			return nil
+		}
+		msg := make([]byte, 1024)
+		rc := C.mpOpenCLDiagonals(
+			(*C.double)(unsafe.Pointer(&job.ts[0])),
+			(*C.double)(unsafe.Pointer(&job.mu[0])),
+			(*C.double)(unsafe.Pointer(&job.sig[0])),
+			(*C.double)(unsafe.Pointer(&job.df[0])),
+			(*C.double)(unsafe.Pointer(&job.dg[0])),
+			C.int(n), C.int(job.w), C.int(job.minLag), C.int(job.symLag), C.int(job.maxLag),
+			(*C.cl_ulong)(unsafe.Pointer(&best[0])),
+			(*C.char)(unsafe.Pointer(&msg[0])), C.size_t(len(msg)),
+		)
+		if rc != 0 {
+			return errors.New("opencl: " + C.GoString((*C.char)(unsafe.Pointer(&msg[0]))))
+		}
+		return nil
+	})
+}
@@ -0,0 +1,233 @@
+package matrixprofile
+
+import (
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/matrix-profile-foundation/go-matrixprofile/siggen"
+)
+
+// fakeBackend computes on the CPU while recording that it was used.
+type fakeBackend struct {
+	present bool
+	algos   []Algo
+	err     error
+	calls   int
+}
+
+func (f *fakeBackend) available() bool {
+	return f.present
+}
+
+func (f *fakeBackend) compute(mp *MatrixProfile, algo Algo) error {
+	for _, a := range f.algos {
+		if a == algo {
+			f.calls++
+			if f.err != nil {
+				return f.err
+			}
+			return mp.stomp()
+		}
+	}
+	return errDeviceUnsupported
+}
+
+// diagonalBackend runs the diagonal kernel of the device backends on the CPU, one
+// diagonal at a time, so the packing and merging of matches can be checked without a
+// device.
+type diagonalBackend struct{}
+
+func (diagonalBackend) available() bool {
+	return true
+}
+
+func (diagonalBackend) compute(mp *MatrixProfile, algo Algo) error {
+	return mp.computeDiagonals(algo, runDiagonals)
+}
+
+// runDiagonals mirrors the mpx_diagonals kernels of device_cuda.go and device_opencl.go.
+func runDiagonals(job *diagonalJob, best []uint64) error {
+	n := len(job.mu)
+	for k := job.minLag; k < job.maxLag; k++ {
+		var c float64
+		for t := 0; t < job.w; t++ {
+			c += (job.ts[k+t] - job.mu[k]) * (job.ts[t] - job.mu[0])
+		}
+		for i := 0; i+k < n; i++ {
+			j := i + k
+			if i > 0 {
+				c += job.df[i]*job.dg[j] + job.df[j]*job.dg[i]
+			}
+			corr := c * job.sig[i] * job.sig[j]
+			if math.IsNaN(corr) {
+				continue
+			}
+			if key := packMatch(corr, i); key > best[j] {
+				best[j] = key
+			}
+			if k >= job.symLag {
+				if key := packMatch(corr, j); key > best[i] {
+					best[i] = key
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func TestComputeOnDevice(t *testing.T) {
+	sig := siggen.Add(siggen.Sin(1, 4, 0, 0, 100, 2), siggen.Noise(0.1, 200))
+	exact, err := New(sig, nil, 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = exact.Compute(NewMPOpts()); err != nil {
+		t.Fatal(err)
+	}
+
+	testdata := []struct {
+		device        Device
+		backend       *fakeBackend
+		algo          Algo
+		expectedCalls int
+		expectedErr   bool
+	}{
+		{DeviceCUDA, nil, AlgoMPX, 0, false},
+		{DeviceCUDA, &fakeBackend{present: false, algos: []Algo{AlgoMPX}}, AlgoMPX, 0, false},
+		{DeviceCUDA, &fakeBackend{present: true, algos: []Algo{AlgoMPX}}, AlgoMPX, 1, false},
+		{DeviceCUDA, &fakeBackend{present: true, algos: []Algo{AlgoSTOMP}}, AlgoMPX, 0, false},
+		{DeviceCUDA, &fakeBackend{present: true, algos: []Algo{AlgoMPX}, err: errors.New("out of memory")}, AlgoMPX, 1, true},
+		{DeviceOpenCL, nil, AlgoMPX, 0, false},
+		{DeviceOpenCL, &fakeBackend{present: false, algos: []Algo{AlgoMPX}}, AlgoMPX, 0, false},
+		{DeviceOpenCL, &fakeBackend{present: true, algos: []Algo{AlgoMPX}}, AlgoMPX, 1, false},
+		{DeviceOpenCL, &fakeBackend{present: true, algos: []Algo{AlgoMPX}}, AlgoSTOMP, 0, false},
+	}
+
+	defer delete(deviceBackends, DeviceCUDA)
+	defer delete(deviceBackends, DeviceOpenCL)
+	for i, d := range testdata {
+		delete(deviceBackends, DeviceCUDA)
+		delete(deviceBackends, DeviceOpenCL)
+		if d.backend != nil {
+			deviceBackends[d.device] = d.backend
+		}
+
+		mp, err := New(sig, nil, 20)
+		if err != nil {
+			t.Fatal(err)
+		}
+		o := NewMPOpts()
+		o.Algorithm = d.algo
+		o.Device = d.device
+		err = mp.Compute(o)
+		if d.expectedErr {
+			if err == nil {
+				t.Errorf("Expected an error for case %d", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Did not expect an error, %v, for case %d", err, i)
+			continue
+		}
+
+		if d.backend != nil && d.backend.calls != d.expectedCalls {
+			t.Errorf("Expected %d device computations, but got %d for case %d", d.expectedCalls, d.backend.calls, i)
+		}
+		for j := range exact.MP {
+			if math.Abs(mp.MP[j]-exact.MP[j]) > 1e-6 {
+				t.Errorf("Expected %.6f at %d, but got %.6f for case %d", exact.MP[j], j, mp.MP[j], i)
+				break
+			}
+		}
+	}
+}
+
+func TestComputeDiagonals(t *testing.T) {
+	sig := siggen.Add(siggen.Sin(1, 4, 0, 0, 100, 3), seededNoise(1, 0.2, 300))
+
+	testdata := []struct {
+		algo      Algo
+		euclidean bool
+		squared   bool
+		circular  bool
+		zone      int
+		device    bool
+	}{
+		{AlgoMPX, true, false, false, 0, true},
+		{AlgoMPX, false, false, false, 0, true},
+		{AlgoMPX, true, true, false, 0, true},
+		{AlgoMPX, true, false, true, 0, true},
+		{AlgoMPX, true, false, false, 7, true},
+		{AlgoSTOMP, true, false, false, 0, true},
+		{AlgoSTOMP, false, false, false, 0, true},
+		{AlgoSTOMP, true, true, false, 0, true},
+		{AlgoSTOMP, true, false, false, 3, true},
+		{AlgoSTOMP, true, false, true, 0, false},
+		{AlgoSTAMP, true, false, false, 0, false},
+	}
+
+	defer delete(deviceBackends, DeviceCUDA)
+	deviceBackends[DeviceCUDA] = diagonalBackend{}
+	for _, d := range testdata {
+		o := NewMPOpts()
+		o.Algorithm = d.algo
+		o.Euclidean = d.euclidean
+		o.Squared = d.squared
+		o.Circular = d.circular
+
+		expected, err := New(sig, nil, 20)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected.ExclusionZone = d.zone
+		if err = expected.Compute(o); err != nil {
+			t.Fatal(err)
+		}
+
+		mp, err := New(sig, nil, 20)
+		if err != nil {
+			t.Fatal(err)
+		}
+		mp.ExclusionZone = d.zone
+		mp.Opts = o
+		if d.device != (mp.applyTransform(o) == nil && mp.deviceSupported(d.algo)) {
+			t.Errorf("Expected device support %t for %+v", d.device, d)
+		}
+		o.Device = DeviceCUDA
+		if err = mp.Compute(o); err != nil {
+			t.Fatal(err)
+		}
+
+		for i := range expected.MP {
+			if math.Abs(mp.MP[i]-expected.MP[i]) > 1e-6 || mp.Idx[i] != expected.Idx[i] {
+				t.Errorf("Expected (%.6f, %d) at %d, but got (%.6f, %d) for %+v", expected.MP[i], expected.Idx[i], i, mp.MP[i], mp.Idx[i], d)
+				break
+			}
+		}
+	}
+}
+
+func TestPackMatch(t *testing.T) {
+	corrs := []float64{-1, -0.5, -1e-9, 0, 1e-9, 0.25, 0.999, 1}
+	for i := 1; i < len(corrs); i++ {
+		if packMatch(corrs[i], 10) <= packMatch(corrs[i-1], 10) {
+			t.Errorf("Expected %.3g to pack above %.3g", corrs[i], corrs[i-1])
+		}
+	}
+	if packMatch(0.5, 3) <= packMatch(0.5, 4) {
+		t.Errorf("Expected ties to favor the lower index")
+	}
+	if packMatch(math.Inf(-1), 0) == 0 {
+		t.Errorf("Expected every match to pack above no match")
+	}
+	for _, idx := range []int{0, 7, 1 << 30} {
+		if j, ok := unpackMatch(packMatch(0.5, idx)); !ok || j != idx {
+			t.Errorf("Expected to unpack index %d, but got %d", idx, j)
+		}
+	}
+	if _, ok := unpackMatch(0); ok {
+		t.Errorf("Expected no match from a key of 0")
+	}
+}
@@ -1,14 +1,23 @@
 package matrixprofile
 
 import (
+	"errors"
+	"fmt"
 	"math"
+	"sort"
+
+	"gonum.org/v1/gonum/stat"
 )
 
 // MotifGroup stores a list of indices representing a similar motif along
-// with the minimum distance that this set of motif composes of.
+// with the minimum distance that this set of motif composes of. Dists holds the
+// distance of each member in Idx to the first subsequence of the seed pair, which is 0
+// for the seed itself, and Radius is the largest of them.
 type MotifGroup struct {
 	Idx     []int
 	MinDist float64
+	Dists   []float64
+	Radius  float64
 }
 
 // arcCurve computes the arc curve (histogram) which is uncorrected for.
@@ -35,9 +44,458 @@ func arcCurve(mpIdx []int) []float64 {
 	return histo
 }
 
+// correctedArcCurve computes the arc curve of a matrix profile index divided by the
+// idealized arc curve, capped at 1. The edges are always set to 1.
+func correctedArcCurve(mpIdx []int) []float64 {
+	histo := arcCurve(mpIdx)
+	for i := 0; i < len(histo); i++ {
+		if i == 0 || i == len(histo)-1 {
+			histo[i] = math.Min(1.0, float64(len(histo)))
+		} else {
+			histo[i] = math.Min(1.0, histo[i]/iac(float64(i), len(histo)))
+		}
+	}
+	return histo
+}
+
 // iac represents the ideal arc curve with a maximum of n/2 and 0 values
 // at 0 and n-1. The derived equation to ensure the requirements is
 // -(sqrt(2/n)*(x-n/2))^2 + n/2 = y
 func iac(x float64, n int) float64 {
 	return -math.Pow(math.Sqrt(2/float64(n))*(x-float64(n)/2.0), 2.0) + float64(n)/2.0
 }
+
+// LagCount is the number of subsequences whose nearest neighbor lag falls within a
+// bin starting at Lag.
+type LagCount struct {
+	Lag   int
+	Count int
+}
+
+// Lags returns the lag, Idx[i]-i, between each subsequence and its nearest neighbor.
+// Positive lags point forward in time and negative lags point backward. Subsequences
+// without a nearest neighbor get a lag of 0. For AB joins this is the offset between
+// a subsequence and its match at the same position in the other timeseries.
+func (mp MatrixProfile) Lags() ([]int, error) {
+	if mp.Idx == nil {
+		return nil, errors.New("matrix profile index has not been computed")
+	}
+
+	lags := make([]int, len(mp.Idx))
+	for i, idx := range mp.Idx {
+		if idx == math.MaxInt64 {
+			continue
+		}
+		lags[i] = idx - i
+	}
+	return lags, nil
+}
+
+// LagDistribution returns the distribution of nearest neighbor lags as counts in bins
+// of binWidth sorted by lag. Peaks at a lag and its multiples point to periodic
+// structure, a drifting peak points to phase drift, and a peak at the edge of the
+// exclusion zone points to trivial self join matches.
+func (mp MatrixProfile) LagDistribution(binWidth int) ([]LagCount, error) {
+	if binWidth < 1 {
+		return nil, errors.New("bin width must be at least 1")
+	}
+
+	lags, err := mp.Lags()
+	if err != nil {
+		return nil, err
+	}
+
+	counts := make(map[int]int)
+	for i, lag := range lags {
+		if mp.Idx[i] == math.MaxInt64 {
+			continue
+		}
+		// floors negative lags so each bin covers [Lag, Lag+binWidth)
+		bin := lag / binWidth
+		if lag%binWidth != 0 && lag < 0 {
+			bin--
+		}
+		counts[bin*binWidth]++
+	}
+
+	dist := make([]LagCount, 0, len(counts))
+	for lag, count := range counts {
+		dist = append(dist, LagCount{Lag: lag, Count: count})
+	}
+	sort.Slice(dist, func(i, j int) bool {
+		return dist[i].Lag < dist[j].Lag
+	})
+	return dist, nil
+}
+
+// ReverseIdx returns the reverse of the matrix profile index, listing for each
+// subsequence the subsequences that chose it as their nearest neighbor in ascending
+// order. Subsequences chosen by many others are hubs or exemplars of a recurring
+// pattern and make good motif seeds, while ones chosen by none are candidates for
+// anomalies. The result has one entry per subsequence of the timeseries the index
+// points into, so for AB joins it maps those subsequences back to the other
+// timeseries.
+func (mp MatrixProfile) ReverseIdx() ([][]int, error) {
+	if mp.Idx == nil {
+		return nil, errors.New("matrix profile index has not been computed")
+	}
+
+	// MPX keeps both directions of an AB join with the index of a pointing into b, while
+	// the other algorithms keep one row per subsequence of b pointing into a
+	n := len(mp.B) - mp.W + 1
+	if !mp.SelfJoin && mp.MPB == nil && len(mp.Idx) == n {
+		n = len(mp.A) - mp.W + 1
+	}
+
+	rev := make([][]int, n)
+	for i, idx := range mp.Idx {
+		if idx < 0 || idx >= len(rev) {
+			continue
+		}
+		rev[idx] = append(rev[idx], i)
+	}
+	return rev, nil
+}
+
+// DiscordOpts are parameters to vary how subsequences are scored when discovering
+// discords.
+type DiscordOpts struct {
+	Neighbor int  `json:"neighbor"`  // rank of the nearest neighbor whose distance scores a subsequence. Defaults to 1 which uses the matrix profile
+	LeftOnly bool `json:"left_only"` // only neighbors starting before a subsequence are considered, so discords are novel relative to the past. Only applies to self joins
+}
+
+// NewDiscordOpts returns a default DiscordOpts
+func NewDiscordOpts() *DiscordOpts {
+	return &DiscordOpts{
+		Neighbor: 1,
+	}
+}
+
+// DiscordProfile scores each subsequence by the distance to its nearest neighbor of the
+// rank given in the options, optionally only looking at neighbors in the past. Scoring
+// by a later neighbor finds "twin" anomalies that occur a few times and so have a close
+// first neighbor in each other. Neighbors are distinct occurrences separated by the
+// exclusion zone. Subsequences without enough neighbors are given +Inf so they are
+// skipped by discord discovery.
+func (mp *MatrixProfile) DiscordProfile(o *DiscordOpts) ([]float64, error) {
+	if o == nil {
+		o = NewDiscordOpts()
+	}
+	if o.Neighbor < 1 {
+		return nil, fmt.Errorf("neighbor rank, %d, must be at least 1", o.Neighbor)
+	}
+
+	knn, err := mp.nearestNeighbors(o.Neighbor, o.LeftOnly)
+	if err != nil {
+		return nil, err
+	}
+
+	profile := make([]float64, len(knn.Dist))
+	for i, dists := range knn.Dist {
+		profile[i] = math.Inf(1)
+		if len(dists) == o.Neighbor {
+			profile[i] = dists[o.Neighbor-1]
+		}
+	}
+	return profile, nil
+}
+
+// DiscoverDiscordsWithOpts finds the top k time series discords like DiscoverDiscords,
+// but scores subsequences with the discord definition given in the options rather than
+// the matrix profile. The scores are scaled by the annotation vector as in
+// DiscoverDiscords and the mask is applied to them.
+func (mp *MatrixProfile) DiscoverDiscordsWithOpts(k, exclusionZone int, o *DiscordOpts) ([]int, error) {
+	if o == nil || (o.Neighbor == 1 && !o.LeftOnly) {
+		return mp.DiscoverDiscords(k, exclusionZone)
+	}
+
+	profile, err := mp.DiscordProfile(o)
+	if err != nil {
+		return nil, err
+	}
+	if profile, err = applyDiscordAV(profile, mp.A, mp.W, mp.AV, mp.CustomAV); err != nil {
+		return nil, err
+	}
+	mp.applyMask(profile)
+
+	mp.Discords = topDiscords(profile, k, exclusionZone)
+	return mp.Discords, nil
+}
+
+// PatternProbability converts the matrix profile into a per point score between 0 and 1
+// of how likely each point is part of a repeated pattern. The profile distances are
+// calibrated against their own distribution by splitting them into bins of equal
+// frequency, so a subsequence in the closest bin scores 1 and one in the farthest bin
+// scores 0. Each point takes the highest score of the subsequences covering it, since a
+// point belongs to a pattern if any subsequence containing it repeats. Masked and
+// undefined profile values score 0. The returned series has one value per point of the
+// timeseries the profile is indexed by.
+func (mp MatrixProfile) PatternProbability(bins int) ([]float64, error) {
+	if mp.MP == nil {
+		return nil, errors.New("matrix profile has not been computed")
+	}
+	if bins < 2 {
+		return nil, fmt.Errorf("number of bins, %d, must be at least 2", bins)
+	}
+
+	dist := mp.euclideanMP()
+	mp.applyMask(dist)
+
+	var finite []float64
+	for _, d := range dist {
+		if !math.IsInf(d, 0) && !math.IsNaN(d) {
+			finite = append(finite, d)
+		}
+	}
+	sort.Float64s(finite)
+
+	// the upper edge of every bin but the last, at equally spaced quantiles
+	edges := make([]float64, bins-1)
+	for b := range edges {
+		if len(finite) > 0 {
+			edges[b] = finite[(b+1)*(len(finite)-1)/bins]
+		}
+	}
+
+	prob := make([]float64, len(dist)+mp.W-1)
+	for i, d := range dist {
+		if math.IsInf(d, 0) || math.IsNaN(d) {
+			continue
+		}
+		score := 1 - float64(sort.SearchFloat64s(edges, d))/float64(bins-1)
+		for p := i; p < i+mp.W; p++ {
+			if score > prob[p] {
+				prob[p] = score
+			}
+		}
+	}
+	return prob, nil
+}
+
+// AnomalyEvent is a run of consecutive or overlapping anomalous subsequences merged
+// into a single event.
+type AnomalyEvent struct {
+	Start     int     // first point of the event
+	End       int     // point after the last point of the event
+	Peak      int     // start index of the subsequence with the highest score
+	PeakScore float64 // highest euclidean matrix profile value within the event
+	Duration  int     // number of points covered by the event
+}
+
+// EventOpts are parameters used to group anomalous subsequences into events.
+type EventOpts struct {
+	Threshold float64 // euclidean profile value at or above which a subsequence is anomalous. Defaults to 0 which uses the mean plus 3 standard deviations of the profile
+	MaxGap    int     // number of normal points allowed between two anomalous subsequences of the same event. Defaults to 0
+}
+
+// NewEventOpts returns a default EventOpts
+func NewEventOpts() *EventOpts {
+	return &EventOpts{}
+}
+
+// AnomalyEvents groups the subsequences whose matrix profile values are at or above a
+// threshold into discrete events, which is the form alerting systems expect rather
+// than a ranked list of overlapping discords. Each anomalous subsequence covers its
+// whole span of points, and spans that overlap or are within the maximum gap of each
+// other are merged. The profile is scaled by the annotation vector as in
+// DiscoverDiscords and masked first. Events are ordered by their start.
+func (mp MatrixProfile) AnomalyEvents(o *EventOpts) ([]AnomalyEvent, error) {
+	if mp.MP == nil {
+		return nil, errors.New("matrix profile has not been computed")
+	}
+	if o == nil {
+		o = NewEventOpts()
+	}
+	if o.Threshold < 0 {
+		return nil, fmt.Errorf("threshold, %.3f, must not be negative", o.Threshold)
+	}
+	if o.MaxGap < 0 {
+		return nil, fmt.Errorf("maximum gap, %d, must not be negative", o.MaxGap)
+	}
+
+	profile, err := mp.discordMP()
+	if err != nil {
+		return nil, err
+	}
+
+	threshold := o.Threshold
+	if threshold == 0 {
+		var finite []float64
+		for _, d := range profile {
+			if !math.IsInf(d, 0) && !math.IsNaN(d) {
+				finite = append(finite, d)
+			}
+		}
+		if len(finite) == 0 {
+			return nil, nil
+		}
+		mean, std := stat.MeanStdDev(finite, nil)
+		threshold = mean + 3*std
+	}
+
+	var anomalous []int
+	for i, d := range profile {
+		if math.IsInf(d, 0) || math.IsNaN(d) || d < threshold {
+			continue
+		}
+		anomalous = append(anomalous, i)
+	}
+	return mergeEvents(anomalous, profile, mp.W, o.MaxGap), nil
+}
+
+// DiscordEvents merges the top k discords into events, for alerting on intervals
+// without picking a threshold. Discords are taken as in DiscoverDiscords, with an
+// exclusion zone of a single subsequence so that the overlapping subsequences of one
+// incident can all rank, and the spans of those within maxGap points of each other are
+// merged as in AnomalyEvents. Events are ordered by decreasing peak score.
+func (mp *MatrixProfile) DiscordEvents(k, maxGap int) ([]AnomalyEvent, error) {
+	if k < 1 {
+		return nil, fmt.Errorf("number of discords, %d, must be at least 1", k)
+	}
+	if maxGap < 0 {
+		return nil, fmt.Errorf("maximum gap, %d, must not be negative", maxGap)
+	}
+	profile, err := mp.discordMP()
+	if err != nil {
+		return nil, err
+	}
+
+	ranked := make([]float64, len(profile))
+	copy(ranked, profile)
+	discords := topDiscords(ranked, k, 1)
+	sort.Ints(discords)
+
+	events := mergeEvents(discords, profile, mp.W, maxGap)
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].PeakScore > events[j].PeakScore
+	})
+	return events, nil
+}
+
+// mergeEvents merges the spans of the subsequences at the ascending indices into
+// events, joining spans that overlap or are within maxGap points of each other. The
+// peak of each event is its subsequence with the highest score.
+func mergeEvents(indices []int, scores []float64, w, maxGap int) []AnomalyEvent {
+	var events []AnomalyEvent
+	for _, i := range indices {
+		d := scores[i]
+		if n := len(events); n > 0 && i <= events[n-1].End+maxGap {
+			e := &events[n-1]
+			e.End = i + w
+			if d > e.PeakScore {
+				e.Peak = i
+				e.PeakScore = d
+			}
+			continue
+		}
+		events = append(events, AnomalyEvent{
+			Start:     i,
+			End:       i + w,
+			Peak:      i,
+			PeakScore: d,
+		})
+	}
+	for i := range events {
+		events[i].Duration = events[i].End - events[i].Start
+	}
+	return events
+}
+
+// Segment is a node of a hierarchical segmentation of a timeseries into regimes.
+type Segment struct {
+	Start    int        // first subsequence of the segment
+	End      int        // subsequence after the last one of the segment
+	Split    int        // subsequence where the segment splits into its two children, -1 for a leaf
+	Score    float64    // corrected arc curve value at the split, where lower is a stronger regime change
+	Children []*Segment // segments before and after the split, empty for a leaf
+}
+
+// SegmentOpts are parameters used to build a hierarchical segmentation.
+type SegmentOpts struct {
+	MinLength int     // minimum number of subsequences in a segment. Defaults to 0 which uses 5 subsequence lengths
+	MaxScore  float64 // segments are only split where the corrected arc curve is below this score
+	MaxDepth  int     // maximum depth of the tree below the root. Defaults to 0 which is unlimited
+}
+
+// NewSegmentOpts returns a default SegmentOpts
+func NewSegmentOpts() *SegmentOpts {
+	return &SegmentOpts{
+		MaxScore: 0.5,
+	}
+}
+
+// SegmentTree recursively splits the timeseries into a tree of regimes. Each segment
+// computes its own corrected arc curve from only the nearest neighbor arcs starting
+// and ending within it, and splits at its minimum if that is below the maximum score
+// and both children are at least the minimum length. This exposes regime changes at
+// several granularities rather than a single split as in DiscoverSegments.
+func (mp MatrixProfile) SegmentTree(o *SegmentOpts) (*Segment, error) {
+	if mp.Idx == nil {
+		return nil, errors.New("matrix profile index has not been computed")
+	}
+	if o == nil {
+		o = NewSegmentOpts()
+	}
+	if o.MinLength < 0 {
+		return nil, fmt.Errorf("minimum segment length, %d, must not be negative", o.MinLength)
+	}
+	if o.MaxDepth < 0 {
+		return nil, fmt.Errorf("maximum depth, %d, must not be negative", o.MaxDepth)
+	}
+
+	minLen := o.MinLength
+	if minLen == 0 {
+		minLen = 5 * mp.W
+	}
+	if minLen < 2 {
+		// the corrected arc curve is only defined away from the edges of a segment
+		minLen = 2
+	}
+
+	root := &Segment{Start: 0, End: len(mp.Idx)}
+	mp.splitSegment(root, minLen, o.MaxScore, o.MaxDepth, 0)
+	return root, nil
+}
+
+// splitSegment splits a segment at the minimum of its own corrected arc curve and
+// recurses into both children.
+func (mp MatrixProfile) splitSegment(s *Segment, minLen int, maxScore float64, maxDepth, depth int) {
+	s.Split = -1
+	if (maxDepth > 0 && depth >= maxDepth) || s.End-s.Start < 2*minLen {
+		return
+	}
+
+	// only keep arcs within the segment, relative to its start
+	idx := make([]int, s.End-s.Start)
+	for i := range idx {
+		j := mp.Idx[s.Start+i]
+		if j < s.Start || j >= s.End {
+			idx[i] = math.MaxInt64
+			continue
+		}
+		idx[i] = j - s.Start
+	}
+	cac := correctedArcCurve(idx)
+
+	split := -1
+	score := math.Inf(1)
+	for i := minLen; i <= len(cac)-minLen; i++ {
+		if cac[i] < score {
+			split = i
+			score = cac[i]
+		}
+	}
+	if split < 0 || score >= maxScore {
+		return
+	}
+
+	s.Split = s.Start + split
+	s.Score = score
+	s.Children = []*Segment{
+		{Start: s.Start, End: s.Split},
+		{Start: s.Split, End: s.End},
+	}
+	for _, c := range s.Children {
+		mp.splitSegment(c, minLen, maxScore, maxDepth, depth+1)
+	}
+}
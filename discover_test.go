@@ -3,6 +3,8 @@ package matrixprofile
 import (
 	"math"
 	"testing"
+
+	"github.com/matrix-profile-foundation/go-matrixprofile/siggen"
 )
 
 func TestArcCurve(t *testing.T) {
@@ -52,3 +54,520 @@ func TestIac(t *testing.T) {
 		}
 	}
 }
+
+func TestLagDistribution(t *testing.T) {
+	testdata := []struct {
+		idx          []int
+		binWidth     int
+		expectedLags []int
+		expectedDist []LagCount
+	}{
+		{nil, 1, nil, nil},
+		{[]int{1, 2}, 0, []int{1, 1}, nil},
+		{[]int{3, 4, 0, 1, math.MaxInt64}, 1, []int{3, 3, -2, -2, 0}, []LagCount{{-2, 2}, {3, 2}}},
+		{[]int{3, 4, 0, 1, math.MaxInt64}, 2, []int{3, 3, -2, -2, 0}, []LagCount{{-2, 2}, {2, 2}}},
+		{[]int{5, 0, 0, 0}, 4, []int{5, -1, -2, -3}, []LagCount{{-4, 3}, {4, 1}}},
+	}
+
+	for _, d := range testdata {
+		mp := MatrixProfile{Idx: d.idx}
+		lags, err := mp.Lags()
+		if d.expectedLags == nil {
+			if err == nil {
+				t.Errorf("Expected an error for %+v", d)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("Did not expect an error, %v", err)
+		}
+		for i := range lags {
+			if lags[i] != d.expectedLags[i] {
+				t.Errorf("Expected %v, but got %v", d.expectedLags, lags)
+				break
+			}
+		}
+
+		dist, err := mp.LagDistribution(d.binWidth)
+		if d.expectedDist == nil {
+			if err == nil {
+				t.Errorf("Expected an error for bin width %d", d.binWidth)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("Did not expect an error, %v", err)
+		}
+		if len(dist) != len(d.expectedDist) {
+			t.Errorf("Expected %v, but got %v", d.expectedDist, dist)
+			continue
+		}
+		for i := range dist {
+			if dist[i] != d.expectedDist[i] {
+				t.Errorf("Expected %v, but got %v", d.expectedDist, dist)
+				break
+			}
+		}
+	}
+}
+
+func TestReverseIdx(t *testing.T) {
+	testdata := []struct {
+		idx      []int
+		b        []float64
+		expected [][]int
+	}{
+		{nil, make([]float64, 6), nil},
+		{[]int{2, 2, 0, 1, math.MaxInt64}, make([]float64, 6), [][]int{{2}, {3}, {0, 1}, nil, nil}},
+		{[]int{0, 0, 3}, make([]float64, 4), [][]int{{0, 1}, nil, nil}},
+	}
+
+	for _, d := range testdata {
+		mp := MatrixProfile{A: d.b, B: d.b, W: 2, Idx: d.idx, SelfJoin: true}
+		rev, err := mp.ReverseIdx()
+		if d.expected == nil {
+			if err == nil {
+				t.Errorf("Expected an error for %v", d.idx)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Did not expect an error, %v, for %v", err, d.idx)
+			continue
+		}
+		if len(rev) != len(d.expected) {
+			t.Errorf("Expected %v, but got %v", d.expected, rev)
+			continue
+		}
+		for i := range rev {
+			if len(rev[i]) != len(d.expected[i]) {
+				t.Errorf("Expected %v, but got %v", d.expected, rev)
+				break
+			}
+			for j := range rev[i] {
+				if rev[i][j] != d.expected[i][j] {
+					t.Errorf("Expected %v, but got %v", d.expected, rev)
+					break
+				}
+			}
+		}
+	}
+}
+
+func TestReverseIdxABJoin(t *testing.T) {
+	a := siggen.Add(siggen.Sin(1, 4, 0, 0, 100, 3), seededNoise(1, 0.1, 300))
+	b := siggen.Add(siggen.Sin(1, 4, 0, 0, 100, 2), seededNoise(2, 0.1, 200))
+	w := 20
+
+	testdata := []struct {
+		a, b []float64
+		algo Algo
+	}{
+		{a, b, AlgoSTOMP},
+		{b, a, AlgoSTOMP},
+		{a, b, AlgoMPX},
+		{b, a, AlgoMPX},
+	}
+
+	for _, d := range testdata {
+		mp, err := New(d.a, d.b, w)
+		if err != nil {
+			t.Fatal(err)
+		}
+		o := NewMPOpts()
+		o.Algorithm = d.algo
+		if err = mp.Compute(o); err != nil {
+			t.Fatal(err)
+		}
+		rev, err := mp.ReverseIdx()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// the mpx index of a points into b, the stomp index of b points into a
+		expectedLen := len(d.a) - w + 1
+		if d.algo == AlgoMPX {
+			expectedLen = len(d.b) - w + 1
+		}
+		if len(rev) != expectedLen {
+			t.Errorf("%s: expected %d entries for lengths %d and %d, but got %d", d.algo, expectedLen, len(d.a), len(d.b), len(rev))
+			continue
+		}
+		var count int
+		for j, chosen := range rev {
+			for _, i := range chosen {
+				if mp.Idx[i] != j {
+					t.Errorf("%s: expected %d to have chosen %d, but got %d", d.algo, i, j, mp.Idx[i])
+				}
+			}
+			count += len(chosen)
+		}
+		if count != len(mp.Idx) {
+			t.Errorf("%s: expected all %d subsequences in the reverse index, but got %d", d.algo, len(mp.Idx), count)
+		}
+	}
+}
+
+func TestDiscoverDiscordsWithOpts(t *testing.T) {
+	// the same anomaly occurs twice so each occurrence is the other's nearest neighbor,
+	// while a milder anomaly occurs only once
+	sig := siggen.Add(siggen.Sin(1, 4, 0, 0, 100, 4), siggen.Noise(0.05, 400))
+	for _, start := range []int{100, 300} {
+		for i := 0; i < 10; i++ {
+			sig[start+i] += 2
+		}
+	}
+	for i := 0; i < 10; i++ {
+		sig[200+i] += 0.6
+	}
+	near := func(idx int) bool {
+		return math.Abs(float64(idx-100)) < 20 || math.Abs(float64(idx-300)) < 20
+	}
+
+	testdata := []struct {
+		opts         *DiscordOpts
+		expectedTwin bool
+		expectedErr  bool
+	}{
+		{nil, false, false},
+		{&DiscordOpts{Neighbor: 0}, false, true},
+		{&DiscordOpts{Neighbor: 1}, false, false},
+		{&DiscordOpts{Neighbor: 2}, true, false},
+	}
+
+	for _, d := range testdata {
+		mp, err := New(sig, nil, 20)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = mp.Compute(NewMPOpts()); err != nil {
+			t.Fatal(err)
+		}
+
+		discords, err := mp.DiscoverDiscordsWithOpts(1, mp.W/2, d.opts)
+		if d.expectedErr {
+			if err == nil {
+				t.Errorf("Expected an error for %+v", d.opts)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("Did not expect an error, %v, for %+v", err, d.opts)
+		}
+		if len(discords) != 1 || near(discords[0]) != d.expectedTwin {
+			t.Errorf("Expected the discord to be near the twin anomalies to be %t, but got %v for %+v", d.expectedTwin, discords, d.opts)
+		}
+	}
+}
+
+func TestDiscordProfileLeftOnly(t *testing.T) {
+	sig := siggen.Add(siggen.Sin(1, 4, 0, 0, 100, 2), siggen.Noise(0.1, 200))
+	mp, err := New(sig, nil, 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = mp.Compute(NewMPOpts()); err != nil {
+		t.Fatal(err)
+	}
+
+	profile, err := mp.DiscordProfile(&DiscordOpts{Neighbor: 1, LeftOnly: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = mp.initCaches(); err != nil {
+		t.Fatal(err)
+	}
+	dist := make([]float64, len(profile))
+	for i := range profile {
+		if err = mp.distanceProfile(i, dist, mp.newFFT()); err != nil {
+			t.Fatal(err)
+		}
+		expected := math.Inf(1)
+		for j := 0; j < i; j++ {
+			expected = math.Min(expected, dist[j])
+		}
+		if math.Abs(profile[i]-expected) > 1e-6 && !(math.IsInf(expected, 1) && math.IsInf(profile[i], 1)) {
+			t.Errorf("Expected left distance %.6f at %d, but got %.6f", expected, i, profile[i])
+			break
+		}
+	}
+
+	// only the first of two identical anomalies is novel relative to the past
+	twins := make([]float64, len(sig))
+	copy(twins, sig)
+	for _, start := range []int{60, 160} {
+		for i := 0; i < 10; i++ {
+			twins[start+i] += 2
+		}
+	}
+	mp, err = New(twins, nil, 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if profile, err = mp.DiscordProfile(&DiscordOpts{Neighbor: 1, LeftOnly: true}); err != nil {
+		t.Fatal(err)
+	}
+	if profile[55] < 2*profile[155] {
+		t.Errorf("Expected the first anomaly to score far above its repeat, but got %.3f and %.3f", profile[55], profile[155])
+	}
+
+	ab, err := New(sig[:100], sig[100:], 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = ab.DiscordProfile(&DiscordOpts{Neighbor: 1, LeftOnly: true}); err == nil {
+		t.Errorf("Expected an error for left only discords of an AB join")
+	}
+}
+
+func TestPatternProbability(t *testing.T) {
+	// a repeating sine with a burst of noise in the middle that has no repeats
+	sig := siggen.Sin(1, 4, 0, 0, 100, 4)
+	noise := siggen.Noise(3, 60)
+	for i := range noise {
+		sig[170+i] += noise[i]
+	}
+
+	mp, err := New(sig, nil, 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = mp.PatternProbability(4); err == nil {
+		t.Errorf("Expected an error before the matrix profile is computed")
+	}
+	if err = mp.Compute(NewMPOpts()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = mp.PatternProbability(1); err == nil {
+		t.Errorf("Expected an error for a single bin")
+	}
+
+	prob, err := mp.PatternProbability(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(prob) != len(sig) {
+		t.Fatalf("Expected %d values, but got %d", len(sig), len(prob))
+	}
+	for i, p := range prob {
+		if p < 0 || p > 1 {
+			t.Fatalf("Expected a probability between 0 and 1, but got %.3f at %d", p, i)
+		}
+	}
+	if prob[50] != 1 || prob[350] != 1 {
+		t.Errorf("Expected points of the repeated sine to score 1, but got %.3f and %.3f", prob[50], prob[350])
+	}
+	if prob[200] > 0.5 {
+		t.Errorf("Expected a point in the noise burst to score low, but got %.3f", prob[200])
+	}
+}
+
+func TestAnomalyEvents(t *testing.T) {
+	sig := siggen.Add(siggen.Sin(1, 4, 0, 0, 100, 5), siggen.Noise(0.05, 500))
+	for _, start := range []int{120, 340} {
+		for i := 0; i < 10; i++ {
+			sig[start+i] += 3 * float64(i%2)
+		}
+	}
+
+	mp, err := New(sig, nil, 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = mp.AnomalyEvents(nil); err == nil {
+		t.Errorf("Expected an error before the matrix profile is computed")
+	}
+	if err = mp.Compute(NewMPOpts()); err != nil {
+		t.Fatal(err)
+	}
+
+	testdata := []struct {
+		o              *EventOpts
+		expectedEvents int
+		expectedErr    bool
+	}{
+		{nil, 2, false},
+		{&EventOpts{MaxGap: 300}, 1, false},
+		{&EventOpts{Threshold: 100}, 0, false},
+		{&EventOpts{Threshold: -1}, 0, true},
+		{&EventOpts{MaxGap: -1}, 0, true},
+	}
+
+	for _, d := range testdata {
+		events, err := mp.AnomalyEvents(d.o)
+		if d.expectedErr {
+			if err == nil {
+				t.Errorf("Expected an error for %+v", d.o)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("Did not expect an error, %v, for %+v", err, d.o)
+		}
+		if len(events) != d.expectedEvents {
+			t.Errorf("Expected %d events, but got %+v for %+v", d.expectedEvents, events, d.o)
+			continue
+		}
+
+		for _, e := range events {
+			if e.Duration != e.End-e.Start || e.Peak < e.Start || e.Peak+mp.W > e.End {
+				t.Errorf("Expected a consistent event, but got %+v", e)
+			}
+		}
+		if d.expectedEvents == 2 {
+			for i, start := range []int{120, 340} {
+				if events[i].Start > start || events[i].End < start+10 {
+					t.Errorf("Expected event %d to cover the anomaly at %d, but got %+v", i, start, events[i])
+				}
+			}
+		}
+	}
+}
+
+func TestSegmentTree(t *testing.T) {
+	// three regimes of differently shaped waves
+	sig := siggen.Append(
+		siggen.Sin(1, 2, 0, 0, 100, 3),
+		siggen.Square(1, 3, 0, 0, 100, 3),
+		siggen.Sawtooth(1, 4, 0, 0, 100, 3),
+	)
+	sig = siggen.Add(sig, siggen.Noise(0.05, len(sig)))
+
+	mp, err := New(sig, nil, 30)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = mp.SegmentTree(nil); err == nil {
+		t.Errorf("Expected an error before the matrix profile is computed")
+	}
+	if err = mp.Compute(NewMPOpts()); err != nil {
+		t.Fatal(err)
+	}
+	for _, o := range []*SegmentOpts{{MinLength: -1}, {MaxDepth: -1}} {
+		if _, err = mp.SegmentTree(o); err == nil {
+			t.Errorf("Expected an error for %+v", o)
+		}
+	}
+
+	testdata := []struct {
+		maxDepth       int
+		expectedSplits []int
+	}{
+		{1, []int{600}},
+		{0, []int{300, 600}},
+	}
+
+	for _, d := range testdata {
+		o := NewSegmentOpts()
+		o.MaxDepth = d.maxDepth
+		root, err := mp.SegmentTree(o)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// collects splits in time order while checking children tile their parent
+		var splits []int
+		var walk func(s *Segment)
+		walk = func(s *Segment) {
+			if s.Split < 0 {
+				if len(s.Children) != 0 {
+					t.Errorf("Expected a leaf without children, but got %+v", s)
+				}
+				return
+			}
+			if len(s.Children) != 2 || s.Children[0].Start != s.Start || s.Children[0].End != s.Split || s.Children[1].Start != s.Split || s.Children[1].End != s.End {
+				t.Errorf("Expected children split at %d to tile [%d, %d), but got %+v and %+v", s.Split, s.Start, s.End, s.Children[0], s.Children[1])
+				return
+			}
+			walk(s.Children[0])
+			splits = append(splits, s.Split)
+			walk(s.Children[1])
+		}
+		walk(root)
+
+		if root.Start != 0 || root.End != len(mp.MP) || len(splits) != len(d.expectedSplits) {
+			t.Errorf("Expected splits near %v, but got %v for a max depth of %d", d.expectedSplits, splits, d.maxDepth)
+			continue
+		}
+		for i, split := range splits {
+			if math.Abs(float64(split-d.expectedSplits[i])) > 30 {
+				t.Errorf("Expected splits near %v, but got %v for a max depth of %d", d.expectedSplits, splits, d.maxDepth)
+				break
+			}
+		}
+	}
+}
+
+func TestDiscordEvents(t *testing.T) {
+	sig := siggen.Add(siggen.Sin(1, 4, 0, 0, 100, 5), siggen.Noise(0.05, 500))
+	for _, start := range []int{120, 340} {
+		for i := 0; i < 10; i++ {
+			sig[start+i] += 3 * float64(i%2)
+		}
+	}
+
+	mp, err := New(sig, nil, 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = mp.Compute(NewMPOpts()); err != nil {
+		t.Fatal(err)
+	}
+
+	testdata := []struct {
+		k           int
+		maxGap      int
+		expectedErr bool
+	}{
+		{0, 0, true},
+		{5, -1, true},
+		{1, 0, false},
+		{20, 0, false},
+		{20, 300, false},
+	}
+
+	for _, d := range testdata {
+		events, err := mp.DiscordEvents(d.k, d.maxGap)
+		if d.expectedErr {
+			if err == nil {
+				t.Errorf("Expected an error for k %d and maximum gap %d", d.k, d.maxGap)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		covered := 0
+		for i, e := range events {
+			if e.Duration != e.End-e.Start || e.Peak < e.Start || e.Peak+mp.W > e.End {
+				t.Errorf("Expected a consistent event, but got %+v", e)
+			}
+			if i > 0 && e.PeakScore > events[i-1].PeakScore {
+				t.Errorf("Expected events by decreasing peak score, but got %+v", events)
+			}
+			covered += e.Duration
+		}
+		switch {
+		case d.k == 1:
+			if len(events) != 1 || events[0].Duration != mp.W {
+				t.Errorf("Expected a single subsequence, but got %+v", events)
+			}
+		case d.maxGap == 300:
+			if len(events) != 1 || events[0].Start > 120 || events[0].End < 350 {
+				t.Errorf("Expected a single event covering both anomalies, but got %+v", events)
+			}
+		default:
+			// the top discords all overlap one of the two anomalies
+			if len(events) != 2 || covered >= 20*mp.W {
+				t.Fatalf("Expected 2 events, but got %+v", events)
+			}
+			for _, start := range []int{120, 340} {
+				if !(events[0].Start <= start && events[0].End >= start+10) && !(events[1].Start <= start && events[1].End >= start+10) {
+					t.Errorf("Expected an event covering the anomaly at %d, but got %+v", start, events)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package matrixprofile
+
+import (
+	"fmt"
+	"math"
+)
+
+// PatternChange is a subsequence of one period of a source with no close match in the
+// other period.
+type PatternChange struct {
+	Idx  int     // starting index of the subsequence in its own period
+	NN   int     // starting index of its nearest neighbor in the other period
+	Dist float64 // euclidean distance to the nearest neighbor
+}
+
+// EmergingPatterns compares two periods of the same source, such as the weeks before
+// and after a deployment. It returns the k subsequences of length w of after farthest
+// from anything in before, the novel patterns, and the k subsequences of before
+// farthest from anything in after, the patterns that disappeared. Both come from a
+// single AB join of after against before, whose two directions are the AB and BA
+// matrix profiles. Each is ordered by decreasing distance, with an exclusion zone of
+// half the subsequence length around each pick, and flat subsequences, whose distance
+// is undefined, are never reported. Distances range up to 2*sqrt(w), and a distance
+// near sqrt(2*w) means the closest match is no better than an uncorrelated one.
+func EmergingPatterns(before, after []float64, w, k int, o *MPOpts) ([]PatternChange, []PatternChange, error) {
+	if o == nil {
+		o = NewMPOpts()
+	}
+	if k < 1 {
+		return nil, nil, fmt.Errorf("number of patterns, %d, must be at least 1", k)
+	}
+
+	mp, err := New(after, before, w)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err = mp.Compute(o); err != nil {
+		return nil, nil, err
+	}
+	ab, abIdx, ba, baIdx, err := mp.bothDirections()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	changes := func(profile []float64, idx []int) []PatternChange {
+		// undefined distances of flat subsequences are skipped like infinite ones
+		for i, d := range profile {
+			if math.IsNaN(d) {
+				profile[i] = math.Inf(1)
+			}
+		}
+		dists := append([]float64(nil), profile...)
+		picks := topDiscords(profile, k, w/2)
+		out := make([]PatternChange, len(picks))
+		for n, i := range picks {
+			out[n] = PatternChange{Idx: i, NN: idx[i], Dist: dists[i]}
+		}
+		return out
+	}
+	return changes(ab, abIdx), changes(ba, baIdx), nil
+}
@@ -0,0 +1,66 @@
+package matrixprofile
+
+import (
+	"testing"
+
+	"github.com/matrix-profile-foundation/go-matrixprofile/siggen"
+)
+
+func TestEmergingPatterns(t *testing.T) {
+	w := 40
+	period := func(n int) []float64 {
+		return siggen.Add(siggen.Sin(1, 5, 0, 0, 100, float64(n)/100), siggen.Noise(0.1, n))
+	}
+	burst := func(ts, pattern []float64, at int) {
+		for i, v := range pattern {
+			ts[at+i] = v
+		}
+	}
+
+	// the sawtooth burst only happens before and the square burst only after
+	before := period(800)
+	after := period(1000)
+	burst(before, siggen.Sawtooth(2, 10, 0, 0, 100, 0.5), 300)
+	burst(after, siggen.Square(2, 13, 0, 0, 100, 0.5), 500)
+
+	if _, _, err := EmergingPatterns(before, after, w, 0, nil); err == nil {
+		t.Errorf("Expected an error for 0 patterns")
+	}
+	if _, _, err := EmergingPatterns(before, after[:20], w, 2, nil); err == nil {
+		t.Errorf("Expected an error for a period shorter than the subsequence length")
+	}
+
+	for _, algo := range []Algo{AlgoMPX, AlgoSTOMP} {
+		o := NewMPOpts()
+		o.Algorithm = algo
+		novel, gone, err := EmergingPatterns(before, after, w, 2, o)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		testdata := []struct {
+			name     string
+			changes  []PatternChange
+			start    int
+			otherLen int
+		}{
+			{"novel", novel, 500, len(before) - w + 1},
+			{"disappeared", gone, 300, len(after) - w + 1},
+		}
+		for _, d := range testdata {
+			if len(d.changes) != 2 {
+				t.Fatalf("%s %s: expected 2 patterns, but got %v", algo, d.name, d.changes)
+			}
+			top := d.changes[0]
+			if top.Idx+w <= d.start || top.Idx >= d.start+50 {
+				t.Errorf("%s %s: expected the top pattern to overlap the burst at %d, but got %+v", algo, d.name, d.start, top)
+			}
+			if top.NN < 0 || top.NN >= d.otherLen {
+				t.Errorf("%s %s: expected a nearest neighbor in the other period, but got %d", algo, d.name, top.NN)
+			}
+			if d.changes[1].Dist > top.Dist {
+				t.Errorf("%s %s: expected patterns ordered by decreasing distance, but got %v", algo, d.name, d.changes)
+			}
+		}
+	}
+}
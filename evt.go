@@ -0,0 +1,167 @@
+package matrixprofile
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+
+	"gonum.org/v1/gonum/stat"
+)
+
+// EVTOpts are parameters used to calibrate profile values into anomaly probabilities
+// with extreme value theory.
+type EVTOpts struct {
+	InitialQuantile float64 `json:"initial_quantile"` // quantile of the calibration values used as the peaks over threshold cutoff. Defaults to 0.98
+	Risk            float64 `json:"risk"`             // probability of a normal value exceeding the anomaly threshold. Defaults to 1e-4
+}
+
+// NewEVTOpts returns a default EVTOpts
+func NewEVTOpts() *EVTOpts {
+	return &EVTOpts{
+		InitialQuantile: 0.98,
+		Risk:            1e-4,
+	}
+}
+
+// Validate returns an error if the options are out of range.
+func (o EVTOpts) Validate() error {
+	if o.InitialQuantile <= 0 || o.InitialQuantile >= 1 {
+		return fmt.Errorf("initial quantile, %.3f, must be between 0 and 1", o.InitialQuantile)
+	}
+	if o.Risk <= 0 || o.Risk >= 1-o.InitialQuantile {
+		return fmt.Errorf("risk, %g, must be between 0 and %g", o.Risk, 1-o.InitialQuantile)
+	}
+	return nil
+}
+
+// SPOT calibrates a stream of profile values with the streaming peaks over threshold
+// method. Values above an initial threshold are modelled by a generalized Pareto
+// distribution, which gives the probability of any larger value and an anomaly
+// threshold that is only exceeded by normal values with the configured risk. The tail
+// is refit as values above the initial threshold arrive, so the anomaly threshold
+// adapts to the stream rather than being a hand tuned distance cutoff.
+type SPOT struct {
+	Threshold float64 // anomaly threshold, only exceeded by normal values with probability Risk
+	Opts      *EVTOpts
+
+	init     float64   // peaks over threshold cutoff
+	excesses []float64 // amounts by which the peaks exceed the cutoff
+	n        int       // number of normal values seen
+	gamma    float64   // shape of the fitted generalized Pareto distribution
+	sigma    float64   // scale of the fitted generalized Pareto distribution
+}
+
+// NewSPOT creates a SPOT calibrated on an initial batch of values, such as the
+// matrix profile of a period known to be normal. Infinite and NaN values are ignored.
+func NewSPOT(calibration []float64, o *EVTOpts) (*SPOT, error) {
+	if o == nil {
+		o = NewEVTOpts()
+	}
+	if err := o.Validate(); err != nil {
+		return nil, err
+	}
+
+	var vals []float64
+	for _, v := range calibration {
+		if !math.IsInf(v, 0) && !math.IsNaN(v) {
+			vals = append(vals, v)
+		}
+	}
+	sort.Float64s(vals)
+
+	s := &SPOT{Opts: o, n: len(vals)}
+	s.init = stat.Quantile(o.InitialQuantile, stat.Empirical, vals, nil)
+	for _, v := range vals {
+		if v > s.init {
+			s.excesses = append(s.excesses, v-s.init)
+		}
+	}
+	if len(s.excesses) < 2 {
+		return nil, fmt.Errorf("calibration values produced %d peaks over the initial threshold, need at least 2", len(s.excesses))
+	}
+	s.fit()
+	return s, nil
+}
+
+// CalibrateEVT creates a SPOT calibrated on the euclidean matrix profile values so
+// new profile values, such as those produced by Update, can be scored.
+func (mp MatrixProfile) CalibrateEVT(o *EVTOpts) (*SPOT, error) {
+	if mp.MP == nil {
+		return nil, errors.New("matrix profile has not been computed")
+	}
+	return NewSPOT(mp.euclideanMP(), o)
+}
+
+// fit estimates the generalized Pareto distribution of the excesses with the method of
+// moments and updates the anomaly threshold.
+func (s *SPOT) fit() {
+	mean, std := stat.MeanStdDev(s.excesses, nil)
+	ratio := 0.0
+	if std > 0 {
+		ratio = mean * mean / (std * std)
+	}
+	s.gamma = 0.5 * (1 - ratio)
+	s.sigma = 0.5 * mean * (ratio + 1)
+	s.fitThreshold()
+}
+
+// tail returns the probability of a normal value exceeding x, which must be above the
+// initial threshold.
+func (s SPOT) tail(x float64) float64 {
+	frac := float64(len(s.excesses)) / float64(s.n)
+	y := x - s.init
+	if math.Abs(s.gamma) < 1e-8 {
+		return frac * math.Exp(-y/s.sigma)
+	}
+	base := 1 + s.gamma*y/s.sigma
+	if base <= 0 {
+		// beyond the upper end point of a bounded tail
+		return 0
+	}
+	return frac * math.Pow(base, -1/s.gamma)
+}
+
+// Probability returns the probability that x is anomalous, one minus the modelled
+// probability of a normal value exceeding it. Values at or below the initial threshold
+// are not extreme and return 0.
+func (s SPOT) Probability(x float64) float64 {
+	if math.IsNaN(x) || x <= s.init {
+		return 0
+	}
+	return 1 - s.tail(x)
+}
+
+// Update scores a new value and reports whether it exceeds the anomaly threshold.
+// Values that are not anomalous update the model, and those above the initial
+// threshold refit the tail and move the anomaly threshold.
+func (s *SPOT) Update(x float64) (float64, bool) {
+	if math.IsInf(x, 0) || math.IsNaN(x) {
+		return 0, false
+	}
+	prob := s.Probability(x)
+	if x > s.Threshold {
+		return prob, true
+	}
+
+	s.n++
+	if x > s.init {
+		s.excesses = append(s.excesses, x-s.init)
+		s.fit()
+	} else {
+		// the threshold depends on the fraction of peaks among the values seen
+		s.fitThreshold()
+	}
+	return prob, false
+}
+
+// fitThreshold updates the anomaly threshold for the current number of values without
+// refitting the tail distribution.
+func (s *SPOT) fitThreshold() {
+	r := s.Opts.Risk * float64(s.n) / float64(len(s.excesses))
+	if math.Abs(s.gamma) < 1e-8 {
+		s.Threshold = s.init - s.sigma*math.Log(r)
+	} else {
+		s.Threshold = s.init + s.sigma/s.gamma*(math.Pow(r, -s.gamma)-1)
+	}
+}
@@ -0,0 +1,74 @@
+package matrixprofile
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestNewSPOT(t *testing.T) {
+	testdata := []struct {
+		calibration []float64
+		opts        *EVTOpts
+		expectedErr bool
+	}{
+		{[]float64{1, 2, 3}, &EVTOpts{InitialQuantile: 1, Risk: 1e-4}, true},
+		{[]float64{1, 2, 3}, &EVTOpts{InitialQuantile: 0.98, Risk: 0.1}, true},
+		{[]float64{1, 1, 1, 1}, nil, true},
+		{[]float64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}, &EVTOpts{InitialQuantile: 0.5, Risk: 1e-3}, false},
+	}
+
+	for _, d := range testdata {
+		_, err := NewSPOT(d.calibration, d.opts)
+		if d.expectedErr && err == nil {
+			t.Errorf("Expected an error, but got none for %v", d.calibration)
+		}
+		if !d.expectedErr && err != nil {
+			t.Errorf("Did not expect an error, but got %v for %v", err, d.calibration)
+		}
+	}
+}
+
+func TestSPOT(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	calibration := make([]float64, 10000)
+	for i := range calibration {
+		calibration[i] = r.ExpFloat64()
+	}
+
+	o := NewEVTOpts()
+	o.Risk = 1e-3
+	s, err := NewSPOT(calibration, o)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// an exponential tail exceeds -ln(risk) with probability risk
+	if math.Abs(s.Threshold+math.Log(o.Risk)) > 1 {
+		t.Errorf("Expected an anomaly threshold near %.3f, but got %.3f", -math.Log(o.Risk), s.Threshold)
+	}
+	if p := s.Probability(s.Threshold); math.Abs(p-(1-o.Risk)) > 1e-6 {
+		t.Errorf("Expected a probability of %.4f at the threshold, but got %.4f", 1-o.Risk, p)
+	}
+	if p := s.Probability(0.5); p != 0 {
+		t.Errorf("Expected a probability of 0 below the initial threshold, but got %.4f", p)
+	}
+	if s.Probability(5) >= s.Probability(6) {
+		t.Errorf("Expected the probability to increase with the value")
+	}
+
+	before := s.Threshold
+	for i := 0; i < 1000; i++ {
+		s.Update(r.ExpFloat64())
+	}
+	if math.Abs(s.Threshold-before) > 1 {
+		t.Errorf("Expected the threshold to stay near %.3f on normal values, but got %.3f", before, s.Threshold)
+	}
+
+	if prob, anomaly := s.Update(50); !anomaly || prob < 1-o.Risk {
+		t.Errorf("Expected an anomaly, but got %v with probability %.4f", anomaly, prob)
+	}
+	if _, anomaly := s.Update(0.1); anomaly {
+		t.Errorf("Did not expect a small value to be anomalous")
+	}
+}
@@ -43,7 +43,10 @@ func ExampleMatrixProfile_DiscoverSegments() {
 
 	// segment the timeseries using the number of arc crossings over
 	// each index in the matrix profile index
-	idx, cac, _ := mp.DiscoverSegments()
+	idx, cac, _, err := mp.DiscoverSegments()
+	if err != nil {
+		panic(err)
+	}
 	fmt.Printf("Signal change foud at index: %d\n", idx)
 	fmt.Printf("Corrected Arc Curve (CAC) value: %.3f\n", cac)
 
@@ -0,0 +1,124 @@
+package matrixprofile
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+)
+
+// Labels given to exported spans.
+const (
+	LabelMotif   = "motif"
+	LabelDiscord = "discord"
+	LabelRegime  = "regime"
+)
+
+// Span is a labeled range of points of timeseries a, [Start, End), in a generic
+// schema that labeling tools can import for human review or training data.
+type Span struct {
+	Start     int        `json:"start"`                // first point of the span
+	End       int        `json:"end"`                  // point after the last point of the span
+	Label     string     `json:"label"`                // kind of span, such as motif, discord, or regime
+	Group     int        `json:"group"`                // motif group, discord rank, or regime number, starting at 0
+	Score     float64    `json:"score"`                // minimum distance of a motif group or matrix profile value of a discord, 0 for regimes
+	StartTime *time.Time `json:"start_time,omitempty"` // timestamp of the first point when the matrix profile has a time index
+	EndTime   *time.Time `json:"end_time,omitempty"`   // timestamp of the last point when the matrix profile has a time index
+}
+
+// Spans labels each occurrence of the discovered motifs, each discovered discord, and
+// the regimes between the given boundaries, such as a split from DiscoverSegments or
+// SegmentTree. Motifs and discords are taken from the last calls to DiscoverMotifs and
+// DiscoverDiscords. Motif spans come first, then discords, then regimes, each ordered
+// by group.
+func (mp MatrixProfile) Spans(boundaries []int) ([]Span, error) {
+	if mp.A == nil {
+		return nil, errors.New("matrix profile has no timeseries")
+	}
+
+	var spans []Span
+	for g, motif := range mp.Motifs {
+		for _, idx := range motif.Idx {
+			spans = append(spans, mp.span(idx, idx+mp.W, LabelMotif, g, motif.MinDist))
+		}
+	}
+
+	for rank, idx := range mp.Discords {
+		score := 0.0
+		if idx < len(mp.MP) {
+			score = mp.MP[idx]
+		}
+		spans = append(spans, mp.span(idx, idx+mp.W, LabelDiscord, rank, score))
+	}
+
+	if boundaries != nil {
+		start := 0
+		for g, b := range append(append([]int(nil), boundaries...), len(mp.A)) {
+			if b <= start || b > len(mp.A) {
+				return nil, fmt.Errorf("regime boundary, %d, must be increasing and within the timeseries", b)
+			}
+			spans = append(spans, mp.span(start, b, LabelRegime, g, 0))
+			start = b
+		}
+	}
+	return spans, nil
+}
+
+// span creates a span, with timestamps when the matrix profile has a time index.
+func (mp MatrixProfile) span(start, end int, label string, group int, score float64) Span {
+	s := Span{Start: start, End: end, Label: label, Group: group, Score: score}
+	if len(mp.TimeIndex) >= end && end > start {
+		st, et := mp.TimeIndex[start], mp.TimeIndex[end-1]
+		s.StartTime, s.EndTime = &st, &et
+	}
+	return s
+}
+
+// WriteSpans writes spans as a json array or as csv with a header row. The csv
+// columns are start, end, label, group, and score, followed by start_time and end_time
+// in RFC 3339 when the spans have timestamps.
+func WriteSpans(w io.Writer, spans []Span, format string) error {
+	switch format {
+	case "json":
+		if spans == nil {
+			spans = []Span{}
+		}
+		return json.NewEncoder(w).Encode(spans)
+	case "csv":
+		timed := len(spans) > 0 && spans[0].StartTime != nil
+		header := []string{"start", "end", "label", "group", "score"}
+		if timed {
+			header = append(header, "start_time", "end_time")
+		}
+
+		cw := csv.NewWriter(w)
+		if err := cw.Write(header); err != nil {
+			return err
+		}
+		for _, s := range spans {
+			record := []string{
+				strconv.Itoa(s.Start),
+				strconv.Itoa(s.End),
+				s.Label,
+				strconv.Itoa(s.Group),
+				strconv.FormatFloat(s.Score, 'g', -1, 64),
+			}
+			if timed {
+				if s.StartTime == nil || s.EndTime == nil {
+					return fmt.Errorf("span at %d is missing timestamps", s.Start)
+				}
+				record = append(record, s.StartTime.Format(time.RFC3339Nano), s.EndTime.Format(time.RFC3339Nano))
+			}
+			if err := cw.Write(record); err != nil {
+				return err
+			}
+		}
+		cw.Flush()
+		return cw.Error()
+	default:
+		return fmt.Errorf("invalid span format, %s", format)
+	}
+}
@@ -0,0 +1,80 @@
+package matrixprofile
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSpans(t *testing.T) {
+	a := []float64{0, 1, 0, 1, 0, 1, 5, 1, 0, 1}
+	times := make([]time.Time, len(a))
+	for i := range times {
+		times[i] = time.Date(2020, 1, 1, 0, i, 0, 0, time.UTC)
+	}
+	mp, err := NewWithOpts(a, WithWindow(3), WithTimeIndex(times))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mp.MP = []float64{0, 0, 0, 0, 1, 2, 1.5, 0}
+	mp.Motifs = []MotifGroup{{Idx: []int{0, 2}, MinDist: 0.1}}
+	mp.Discords = []int{5}
+
+	if _, err = mp.Spans([]int{5, 3}); err == nil {
+		t.Errorf("Expected an error for decreasing regime boundaries")
+	}
+
+	spans, err := mp.Spans([]int{6})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []Span{
+		{Start: 0, End: 3, Label: LabelMotif, Group: 0, Score: 0.1},
+		{Start: 2, End: 5, Label: LabelMotif, Group: 0, Score: 0.1},
+		{Start: 5, End: 8, Label: LabelDiscord, Group: 0, Score: 2},
+		{Start: 0, End: 6, Label: LabelRegime, Group: 0},
+		{Start: 6, End: 10, Label: LabelRegime, Group: 1},
+	}
+	if len(spans) != len(expected) {
+		t.Fatalf("Expected %d spans, but got %d", len(expected), len(spans))
+	}
+	for i, s := range spans {
+		e := expected[i]
+		if s.Start != e.Start || s.End != e.End || s.Label != e.Label || s.Group != e.Group || s.Score != e.Score {
+			t.Errorf("Expected span %+v, but got %+v", e, s)
+		}
+		if s.StartTime == nil || !s.StartTime.Equal(times[e.Start]) || !s.EndTime.Equal(times[e.End-1]) {
+			t.Errorf("Expected timestamps of points %d and %d for %+v", e.Start, e.End-1, s)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err = WriteSpans(&buf, spans, "csv"); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if lines[0] != "start,end,label,group,score,start_time,end_time" {
+		t.Errorf("Unexpected csv header, %s", lines[0])
+	}
+	if lines[3] != "5,8,discord,0,2,2020-01-01T00:05:00Z,2020-01-01T00:07:00Z" {
+		t.Errorf("Unexpected csv row, %s", lines[3])
+	}
+
+	buf.Reset()
+	if err = WriteSpans(&buf, spans, "json"); err != nil {
+		t.Fatal(err)
+	}
+	var decoded []Span
+	if err = json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if len(decoded) != len(spans) || decoded[4].Label != LabelRegime || decoded[4].Start != 6 {
+		t.Errorf("Expected the spans to round trip through json, but got %+v", decoded)
+	}
+
+	if err = WriteSpans(&buf, spans, "xml"); err == nil {
+		t.Errorf("Expected an error for an invalid format")
+	}
+}
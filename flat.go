@@ -0,0 +1,172 @@
+package matrixprofile
+
+import (
+	"math"
+)
+
+// FlatMode chooses how subsequences with a near constant value are handled. Their
+// z-normalized distance is undefined, so left alone each algorithm reports something
+// different for them, from +Inf to arbitrary distances or an error.
+type FlatMode string
+
+const (
+	FlatNone  FlatMode = ""      // flat subsequences are left to the algorithm and only masked from motifs and discords when FlatThreshold is set
+	FlatSkip  FlatMode = "skip"  // flat subsequences are masked from motifs and discords
+	FlatInf   FlatMode = "inf"   // flat subsequences have a distance of +Inf to every subsequence, so they have no nearest neighbor and are no one's nearest neighbor
+	FlatFloor FlatMode = "floor" // the standard deviation of flat subsequences is raised to the flat threshold before z-normalizing, so they are close to each other and about the square root of the subsequence length from the rest
+)
+
+// flatEpsilon is the fraction of the standard deviation of the timeseries below which a
+// subsequence is flat when no flat threshold is set.
+const flatEpsilon = 1e-8
+
+// flatThreshold returns the fraction of the standard deviation of the timeseries below
+// which a subsequence is flat.
+func (o MPOpts) flatThreshold() float64 {
+	if o.FlatThreshold > 0 {
+		return o.FlatThreshold
+	}
+	return flatEpsilon
+}
+
+// recomputesFlat returns whether the distances of flat subsequences are recomputed
+// after the join.
+func (o MPOpts) recomputesFlat() bool {
+	return o.Flat == FlatInf || o.Flat == FlatFloor
+}
+
+// maskFlat sets the mask to the flat subsequences of a when the options mask them from
+// motifs and discords.
+func (mp *MatrixProfile) maskFlat(o *MPOpts) error {
+	if o.FlatThreshold <= 0 && o.Flat != FlatSkip {
+		return nil
+	}
+	var err error
+	mp.Mask, _, _, err = mp.flatSubsequences(o.flatThreshold())
+	return err
+}
+
+// extendMask flags the subsequences appended to a streaming self join since the mask
+// was last set, so the mask keeps covering every subsequence of a. Earlier flags are
+// kept as they were set rather than re-evaluated against the grown timeseries.
+func (mp *MatrixProfile) extendMask() {
+	if mp.Mask == nil || mp.Opts == nil {
+		return
+	}
+	_, total := windowMeanStd(mp.A)
+	threshold := mp.Opts.flatThreshold() * total
+	for i := len(mp.Mask); i+mp.W <= len(mp.A); i++ {
+		_, std := windowMeanStd(mp.A[i : i+mp.W])
+		mp.Mask = append(mp.Mask, std < threshold)
+	}
+}
+
+// flatSubsequences flags the subsequences of a whose standard deviation is below the
+// threshold fraction of the standard deviation of a, returning the sliding mean and
+// standard deviation they were found with. Nearly flat windows have their statistics
+// computed directly, so constant windows are found whatever the threshold.
+func (mp MatrixProfile) flatSubsequences(threshold float64) ([]bool, []float64, []float64, error) {
+	mean, std, err := movMeanStd(mp.A, mp.W)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	_, total := windowMeanStd(mp.A)
+
+	flat := make([]bool, len(std))
+	for i, s := range std {
+		flat[i] = s < threshold*total
+	}
+	return flat, mean, std, nil
+}
+
+// handleFlat recomputes the rows of a self join matrix profile that involve flat
+// subsequences following the flat mode of the options, whatever the algorithm made of
+// them. Only the flat rows and the rows whose nearest neighbor is flat are recomputed,
+// so the cost stays small when few subsequences are flat. zone is the exclusion zone
+// the algorithm applied.
+func (mp *MatrixProfile) handleFlat(zone int) error {
+	o := mp.Opts
+	if !o.recomputesFlat() {
+		return nil
+	}
+
+	threshold := o.flatThreshold()
+	flat, mean, std, err := mp.flatSubsequences(threshold)
+	if err != nil {
+		return err
+	}
+	var rows []int
+	for i, f := range flat {
+		if f || (mp.Idx[i] >= 0 && mp.Idx[i] < len(flat) && flat[mp.Idx[i]]) {
+			rows = append(rows, i)
+		}
+	}
+	if len(rows) == 0 {
+		return nil
+	}
+
+	_, total := windowMeanStd(mp.A)
+	for i := range std {
+		if flat[i] {
+			std[i] = math.Max(std[i], threshold*total)
+		}
+	}
+	// dividing the sliding dot product by a floored standard deviation would blow up
+	// its rounding errors, so pairs with a flat subsequence are compared point by point
+	direct := func(i, j int) float64 {
+		var sq float64
+		for k := 0; k < mp.W; k++ {
+			d := (mp.A[i+k]-mean[i])/std[i] - (mp.A[j+k]-mean[j])/std[j]
+			sq += d * d
+		}
+		return sq
+	}
+
+	for _, i := range rows {
+		mp.MP[i], mp.Idx[i] = math.Inf(1), math.MaxInt64
+	}
+	if mp.BF == nil {
+		if err = mp.initCaches(); err != nil {
+			return err
+		}
+	}
+
+	w := float64(mp.W)
+	fft := mp.newFFT()
+	profile := make([]float64, len(mp.MP))
+	for _, i := range rows {
+		var dot []float64
+		if !flat[i] {
+			if dot, err = mp.crossCorrelate(mp.A[i:i+mp.W], fft); err != nil {
+				return err
+			}
+		}
+		for j := range profile {
+			switch {
+			case std[i] == 0 || std[j] == 0 || o.Flat == FlatInf && (flat[i] || flat[j]):
+				profile[j] = math.Inf(1)
+			case flat[i] || flat[j]:
+				profile[j] = direct(i, j)
+			default:
+				// rounding can take the distance of identical subsequences below 0
+				profile[j] = math.Max(2*(w-(dot[j]-w*mean[i]*mean[j])/(std[i]*std[j])), 0)
+			}
+		}
+		mp.finishProfile(profile, std[i], std)
+		mp.applyExclusionZone(profile, i, 0, zone)
+
+		for j, d := range profile {
+			if math.IsInf(d, 1) {
+				continue
+			}
+			if isBetterMatch(d, j, mp.MP[i], mp.Idx[i], true) {
+				mp.MP[i], mp.Idx[i] = d, j
+			}
+			// the distances of a flat row are new to every other row as well
+			if flat[i] && isBetterMatch(d, i, mp.MP[j], mp.Idx[j], true) {
+				mp.MP[j], mp.Idx[j] = d, i
+			}
+		}
+	}
+	return nil
+}
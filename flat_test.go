@@ -0,0 +1,132 @@
+package matrixprofile
+
+import (
+	"math"
+	"testing"
+
+	"github.com/matrix-profile-foundation/go-matrixprofile/siggen"
+)
+
+// bruteFlatProfile computes the self join matrix profile of ts directly, with the
+// standard deviation of subsequences below the floor raised to it, along with the
+// distance between any two subsequences. A floor of 0 leaves flat subsequences with no
+// distance.
+func bruteFlatProfile(ts []float64, w int, floor float64) ([]float64, func(i, j int) float64) {
+	n := len(ts) - w + 1
+	norm := make([][]float64, n)
+	for i := range norm {
+		mean, std := windowMeanStd(ts[i : i+w])
+		if std < floor {
+			std = floor
+		}
+		if std == 0 {
+			continue
+		}
+		norm[i] = make([]float64, w)
+		for k := range norm[i] {
+			norm[i][k] = (ts[i+k] - mean) / std
+		}
+	}
+
+	dist := func(i, j int) float64 {
+		if norm[i] == nil || norm[j] == nil {
+			return math.Inf(1)
+		}
+		var sq float64
+		for k := 0; k < w; k++ {
+			sq += (norm[i][k] - norm[j][k]) * (norm[i][k] - norm[j][k])
+		}
+		return math.Sqrt(sq)
+	}
+
+	mp := make([]float64, n)
+	for i := range mp {
+		mp[i] = math.Inf(1)
+		for j := 0; j < n; j++ {
+			if j < i-w/2 || j >= i+w/2 {
+				mp[i] = math.Min(mp[i], dist(i, j))
+			}
+		}
+	}
+	return mp, dist
+}
+
+func TestComputeFlat(t *testing.T) {
+	w := 20
+	sig := siggen.Add(siggen.Sin(1, 4, 0, 0, 100, 3), siggen.Noise(0.1, 300))
+	for i := 100; i < 160; i++ {
+		sig[i] = 5
+	}
+	_, total := windowMeanStd(sig)
+	floor := flatEpsilon * total
+
+	testdata := []struct {
+		mode  FlatMode
+		floor float64
+	}{
+		{FlatInf, 0},
+		{FlatFloor, floor},
+	}
+
+	for _, d := range testdata {
+		expMP, dist := bruteFlatProfile(sig, w, d.floor)
+		for _, algo := range []Algo{AlgoSTOMP, AlgoSTAMP, AlgoSTMP, AlgoSCRIMP, AlgoSCAMP} {
+			mp, err := New(sig, nil, w)
+			if err != nil {
+				t.Fatal(err)
+			}
+			o := NewMPOpts()
+			o.Algorithm = algo
+			o.Flat = d.mode
+			if err = mp.Compute(o); err != nil {
+				t.Fatalf("%s %s: %v", d.mode, algo, err)
+			}
+
+			for i := range expMP {
+				if math.IsInf(expMP[i], 1) {
+					if !math.IsInf(mp.MP[i], 1) || mp.Idx[i] != math.MaxInt64 {
+						t.Errorf("%s %s: expected no neighbor at %d, but got %.4f at %d", d.mode, algo, i, mp.MP[i], mp.Idx[i])
+					}
+					continue
+				}
+				// constant subsequences are all equally close to the others, so only the
+				// distance to the index is checked
+				if math.Abs(mp.MP[i]-expMP[i]) > 1e-6 || math.Abs(dist(i, mp.Idx[i])-expMP[i]) > 1e-6 {
+					t.Errorf("%s %s: expected %.4f at %d, but got %.4f at %d", d.mode, algo, expMP[i], i, mp.MP[i], mp.Idx[i])
+				}
+			}
+		}
+	}
+}
+
+func TestComputeFlatSkip(t *testing.T) {
+	w := 20
+	sig := siggen.Add(siggen.Sin(1, 4, 0, 0, 100, 3), siggen.Noise(0.1, 300))
+	for i := 100; i < 160; i++ {
+		sig[i] = 5
+	}
+
+	mp, err := New(sig, nil, w)
+	if err != nil {
+		t.Fatal(err)
+	}
+	o := NewMPOpts()
+	o.Flat = FlatSkip
+	if err = mp.Compute(o); err != nil {
+		t.Fatal(err)
+	}
+	for i, masked := range mp.Mask {
+		if flat := i >= 100 && i+w <= 160; masked != flat {
+			t.Errorf("Expected subsequence %d to be masked %t, but got %t", i, flat, masked)
+		}
+	}
+
+	b, err := New(sig, sig[:200], w)
+	if err != nil {
+		t.Fatal(err)
+	}
+	o.Flat = FlatInf
+	if err = b.Compute(o); err == nil {
+		t.Errorf("Expected an error recomputing flat subsequences of an AB join")
+	}
+}
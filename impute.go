@@ -0,0 +1,223 @@
+package matrixprofile
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+// ImputeOpts are parameters used to fill gaps in a timeseries.
+type ImputeOpts struct {
+	Context int // number of points on each side of a gap matched against the rest of the timeseries. Defaults to 0 which uses twice the longest gap, at least 4
+}
+
+// NewImputeOpts returns a default ImputeOpts
+func NewImputeOpts() *ImputeOpts {
+	return &ImputeOpts{}
+}
+
+// gap is a run of missing values, [start, end).
+type gap struct {
+	start int
+	end   int
+}
+
+// Impute returns a copy of the timeseries with every run of NaN values filled so
+// exact algorithms can run on it. The context on either side of a gap is searched for
+// with MASS across the rest of the timeseries, and the values following the nearest
+// neighbor of the context are spliced into the gap. The spliced values are rescaled to
+// the level and spread of the gap's context and blended with a linear ramp so they
+// join the points on both sides of the gap. Gaps at either end of the timeseries are
+// matched on their one available side. A gap without a complete, non flat context or
+// without a candidate free of missing values is filled by linear interpolation.
+func Impute(ts []float64, o *ImputeOpts) ([]float64, error) {
+	if o == nil {
+		o = NewImputeOpts()
+	}
+	if o.Context < 0 {
+		return nil, fmt.Errorf("context, %d, must not be negative", o.Context)
+	}
+
+	var gaps []gap
+	for i := 0; i < len(ts); i++ {
+		if !math.IsNaN(ts[i]) {
+			continue
+		}
+		g := gap{start: i}
+		for i < len(ts) && math.IsNaN(ts[i]) {
+			i++
+		}
+		g.end = i
+		gaps = append(gaps, g)
+	}
+
+	out := make([]float64, len(ts))
+	copy(out, ts)
+	if len(gaps) == 0 {
+		return out, nil
+	}
+	if len(gaps) == 1 && gaps[0].start == 0 && gaps[0].end == len(ts) {
+		return nil, errors.New("timeseries has no values to impute from")
+	}
+
+	c := o.Context
+	if c == 0 {
+		for _, g := range gaps {
+			if 2*(g.end-g.start) > c {
+				c = 2 * (g.end - g.start)
+			}
+		}
+		if c < 4 {
+			c = 4
+		}
+	}
+
+	// the index is built over a linearly interpolated copy. Candidates that overlap
+	// any missing value are rejected, so the interpolated values are never spliced.
+	interp := interpolateGaps(ts, gaps)
+	if c > len(ts) {
+		return interp, nil
+	}
+	idx, err := NewSearchIndex(interp, c)
+	if err != nil {
+		return nil, err
+	}
+
+	// missing[i] is the number of NaNs before index i
+	missing := make([]int, len(ts)+1)
+	for i, v := range ts {
+		missing[i+1] = missing[i]
+		if math.IsNaN(v) {
+			missing[i+1]++
+		}
+	}
+	complete := func(start, end int) bool {
+		return start >= 0 && end <= len(ts) && missing[end] == missing[start]
+	}
+
+	for _, g := range gaps {
+		copy(out[g.start:g.end], interp[g.start:g.end])
+		if err = spliceGap(out, ts, interp, idx, g, c, complete); err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+// spliceGap fills a gap of out with the values following the nearest neighbor of its
+// context, leaving the linear interpolation in place when no neighbor is found.
+func spliceGap(out, ts, interp []float64, idx *SearchIndex, g gap, c int, complete func(int, int) bool) error {
+	n := g.end - g.start
+	useLeft := complete(g.start-c, g.start)
+	useRight := complete(g.end, g.end+c)
+
+	var left, right []float64
+	var err error
+	if useLeft {
+		if left, err = idx.DistanceProfile(ts[g.start-c : g.start]); err != nil {
+			// a flat context carries no shape to match on
+			useLeft = false
+		}
+	}
+	if useRight {
+		if right, err = idx.DistanceProfile(ts[g.end : g.end+c]); err != nil {
+			useRight = false
+		}
+	}
+	if !useLeft && !useRight {
+		return nil
+	}
+
+	// find the start j of the candidate values whose context best matches the gap's
+	best, bestDist := -1, math.Inf(1)
+	for j := 0; j+n <= len(ts); j++ {
+		from, to := j, j+n
+		if useLeft {
+			from = j - c
+		}
+		if useRight {
+			to = j + n + c
+		}
+		if !complete(from, to) {
+			continue
+		}
+
+		var d float64
+		if useLeft {
+			d += left[j-c]
+		}
+		if useRight {
+			d += right[j+n]
+		}
+		if d < bestDist {
+			best, bestDist = j, d
+		}
+	}
+	if best < 0 {
+		return nil
+	}
+
+	// rescale the candidate values by the level and spread of the contexts
+	var ctx, cand []float64
+	if useLeft {
+		ctx = append(ctx, ts[g.start-c:g.start]...)
+		cand = append(cand, ts[best-c:best]...)
+	}
+	if useRight {
+		ctx = append(ctx, ts[g.end:g.end+c]...)
+		cand = append(cand, ts[best+n:best+n+c]...)
+	}
+	ctxMean, ctxStd := windowMeanStd(ctx)
+	candMean, candStd := windowMeanStd(cand)
+	scale := 1.0
+	if candStd > 0 {
+		scale = ctxStd / candStd
+	}
+	rescale := func(v float64) float64 {
+		return (v-candMean)*scale + ctxMean
+	}
+
+	// the mismatch at each boundary is spread across the gap with a linear ramp
+	var startErr, endErr float64
+	if useLeft {
+		startErr = ts[g.start-1] - rescale(ts[best-1])
+		endErr = startErr
+	}
+	if useRight {
+		endErr = ts[g.end] - rescale(ts[best+n])
+		if !useLeft {
+			startErr = endErr
+		}
+	}
+	for i := 0; i < n; i++ {
+		frac := float64(i+1) / float64(n+1)
+		out[g.start+i] = rescale(ts[best+i]) + (1-frac)*startErr + frac*endErr
+	}
+	return nil
+}
+
+// interpolateGaps returns a copy of the timeseries with each gap filled by a line
+// between the values on either side, or the nearest value for gaps at either end.
+func interpolateGaps(ts []float64, gaps []gap) []float64 {
+	out := make([]float64, len(ts))
+	copy(out, ts)
+	for _, g := range gaps {
+		switch {
+		case g.start == 0:
+			for i := g.start; i < g.end; i++ {
+				out[i] = ts[g.end]
+			}
+		case g.end == len(ts):
+			for i := g.start; i < g.end; i++ {
+				out[i] = ts[g.start-1]
+			}
+		default:
+			lo, hi := ts[g.start-1], ts[g.end]
+			for i := g.start; i < g.end; i++ {
+				frac := float64(i-g.start+1) / float64(g.end-g.start+1)
+				out[i] = lo + frac*(hi-lo)
+			}
+		}
+	}
+	return out
+}
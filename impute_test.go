@@ -0,0 +1,55 @@
+package matrixprofile
+
+import (
+	"math"
+	"testing"
+
+	"github.com/matrix-profile-foundation/go-matrixprofile/siggen"
+)
+
+func TestImpute(t *testing.T) {
+	truth := siggen.Add(siggen.Sin(1, 5, 0, 0, 100, 4), siggen.Noise(0.01, 400))
+
+	testdata := []struct {
+		gaps   [][2]int
+		maxErr float64
+	}{
+		{nil, 0},
+		{[][2]int{{103, 115}}, 0.1},
+		{[][2]int{{0, 8}, {390, 400}}, 0.1},
+		{[][2]int{{50, 55}, {200, 212}, {300, 301}}, 0.1},
+	}
+
+	for _, d := range testdata {
+		ts := make([]float64, len(truth))
+		copy(ts, truth)
+		for _, g := range d.gaps {
+			for i := g[0]; i < g[1]; i++ {
+				ts[i] = math.NaN()
+			}
+		}
+
+		out, err := Impute(ts, nil)
+		if err != nil {
+			t.Errorf("Did not expect an error, but got %v for %v", err, d.gaps)
+			continue
+		}
+		for i, v := range out {
+			if math.IsNaN(v) {
+				t.Errorf("Expected no missing values, but got NaN at %d for %v", i, d.gaps)
+				break
+			}
+			if math.Abs(v-truth[i]) > d.maxErr+1e-9 {
+				t.Errorf("Expected %.3f at %d, but got %.3f for %v", truth[i], i, v, d.gaps)
+				break
+			}
+		}
+	}
+
+	if _, err := Impute([]float64{math.NaN(), math.NaN()}, nil); err == nil {
+		t.Errorf("Expected an error for a timeseries without values")
+	}
+	if _, err := Impute(truth, &ImputeOpts{Context: -1}); err == nil {
+		t.Errorf("Expected an error for a negative context")
+	}
+}
@@ -0,0 +1,200 @@
+package ingest
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	LayoutUnix      = "unix"       // timestamps are seconds since the unix epoch
+	LayoutUnixMilli = "unix_milli" // timestamps are milliseconds since the unix epoch
+)
+
+// CSVOpts are the parameters used to read a timeseries from a csv stream with a
+// header row.
+type CSVOpts struct {
+	Column     string         // name of the column holding the values
+	TimeColumn string         // name of the column holding timestamps. Leave empty if there are none
+	TimeLayout string         // layout passed to time.Parse, or one of LayoutUnix and LayoutUnixMilli
+	Location   *time.Location // location used for timestamps without a zone which defaults to UTC
+	Interval   time.Duration  // regularizes the output to this fixed interval when greater than 0
+	Comma      rune           // field delimiter which defaults to ','
+}
+
+// NewCSVOpts returns a default CSVOpts reading the named value column with
+// RFC 3339 timestamps read from the "time" column.
+func NewCSVOpts(column string) *CSVOpts {
+	return &CSVOpts{
+		Column:     column,
+		TimeColumn: "time",
+		TimeLayout: time.RFC3339,
+		Location:   time.UTC,
+	}
+}
+
+// ReadCSV reads the selected value column and optional timestamp column from a
+// csv stream with a header row. Empty value cells are read as NaN. If an interval
+// is set, the output is regularized to that interval using Regularize. The
+// returned timestamps are nil if no timestamp column is set.
+func ReadCSV(r io.Reader, o *CSVOpts) ([]time.Time, []float64, error) {
+	if o == nil {
+		return nil, nil, errors.New("must provide csv options")
+	}
+	if o.Column == "" {
+		return nil, nil, errors.New("must provide the name of the value column")
+	}
+	if o.Interval > 0 && o.TimeColumn == "" {
+		return nil, nil, errors.New("a timestamp column is required to regularize the series")
+	}
+
+	cr := csv.NewReader(r)
+	cr.ReuseRecord = true
+	if o.Comma != 0 {
+		cr.Comma = o.Comma
+	}
+
+	header, err := cr.Read()
+	if err != nil {
+		if err == io.EOF {
+			return nil, nil, errors.New("csv stream is missing a header row")
+		}
+		return nil, nil, err
+	}
+
+	valCol, timeCol := -1, -1
+	for i, name := range header {
+		name = strings.TrimSpace(name)
+		if name == o.Column {
+			valCol = i
+		}
+		if o.TimeColumn != "" && name == o.TimeColumn {
+			timeCol = i
+		}
+	}
+	if valCol < 0 {
+		return nil, nil, fmt.Errorf("value column %q not found in header", o.Column)
+	}
+	if o.TimeColumn != "" && timeCol < 0 {
+		return nil, nil, fmt.Errorf("timestamp column %q not found in header", o.TimeColumn)
+	}
+
+	var times []time.Time
+	var vals []float64
+	for line := 2; ; line++ {
+		rec, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+
+		v, err := parseFloat(rec[valCol])
+		if err != nil {
+			return nil, nil, fmt.Errorf("record %d: %v", line, err)
+		}
+		vals = append(vals, v)
+
+		if timeCol >= 0 {
+			ts, err := parseTime(rec[timeCol], o.TimeLayout, o.Location)
+			if err != nil {
+				return nil, nil, fmt.Errorf("record %d: %v", line, err)
+			}
+			times = append(times, ts)
+		}
+	}
+
+	if o.Interval > 0 {
+		return Regularize(times, vals, o.Interval)
+	}
+	return times, vals, nil
+}
+
+// Regularize resamples irregularly spaced observations onto a fixed interval
+// starting at the earliest timestamp. Values on the new grid are linearly
+// interpolated between the two surrounding observations. Observations do not need
+// to be sorted, and if several share a timestamp the last one read is used.
+func Regularize(times []time.Time, vals []float64, interval time.Duration) ([]time.Time, []float64, error) {
+	if len(times) != len(vals) {
+		return nil, nil, fmt.Errorf("number of timestamps, %d, does not match number of values, %d", len(times), len(vals))
+	}
+	if interval <= 0 {
+		return nil, nil, fmt.Errorf("interval must be greater than 0, got %v", interval)
+	}
+	if len(times) == 0 {
+		return nil, nil, nil
+	}
+
+	order := make([]int, len(times))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return times[order[i]].Before(times[order[j]])
+	})
+
+	// collapse duplicate timestamps keeping the last observation
+	st := make([]time.Time, 0, len(times))
+	sv := make([]float64, 0, len(vals))
+	for _, idx := range order {
+		if len(st) > 0 && st[len(st)-1].Equal(times[idx]) {
+			sv[len(sv)-1] = vals[idx]
+			continue
+		}
+		st = append(st, times[idx])
+		sv = append(sv, vals[idx])
+	}
+
+	start := st[0]
+	n := int(st[len(st)-1].Sub(start)/interval) + 1
+	outTimes := make([]time.Time, n)
+	outVals := make([]float64, n)
+
+	j := 0
+	for i := 0; i < n; i++ {
+		t := start.Add(time.Duration(i) * interval)
+		for j < len(st)-1 && !st[j+1].After(t) {
+			j++
+		}
+		outTimes[i] = t
+		if st[j].Equal(t) || j == len(st)-1 {
+			outVals[i] = sv[j]
+			continue
+		}
+		frac := float64(t.Sub(st[j])) / float64(st[j+1].Sub(st[j]))
+		outVals[i] = sv[j] + frac*(sv[j+1]-sv[j])
+	}
+
+	return outTimes, outVals, nil
+}
+
+// parseTime parses a single timestamp field with the given layout.
+func parseTime(s, layout string, loc *time.Location) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	if loc == nil {
+		loc = time.UTC
+	}
+	switch layout {
+	case LayoutUnix, LayoutUnixMilli:
+		v, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid unix timestamp %q", s)
+		}
+		if layout == LayoutUnixMilli {
+			v /= 1000
+		}
+		sec, frac := math.Modf(v)
+		return time.Unix(int64(sec), int64(frac*1e9)).In(loc), nil
+	case "":
+		return time.ParseInLocation(time.RFC3339, s, loc)
+	default:
+		return time.ParseInLocation(layout, s, loc)
+	}
+}
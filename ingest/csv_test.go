@@ -0,0 +1,139 @@
+package ingest
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReadCSV(t *testing.T) {
+	base := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	testdata := []struct {
+		in            string
+		o             *CSVOpts
+		expectedTimes []time.Time
+		expectedVals  []float64
+		expectedErr   bool
+	}{
+		{
+			"time,cpu,mem\n2020-01-01T00:00:00Z,1,10\n2020-01-01T00:01:00Z,2,20\n",
+			NewCSVOpts("mem"),
+			[]time.Time{base, base.Add(time.Minute)},
+			[]float64{10, 20},
+			false,
+		},
+		{
+			"ts,cpu\n1577836800,1\n1577836860,2\n",
+			&CSVOpts{Column: "cpu", TimeColumn: "ts", TimeLayout: LayoutUnix},
+			[]time.Time{base, base.Add(time.Minute)},
+			[]float64{1, 2},
+			false,
+		},
+		{
+			"ts,cpu\n1577836800000,1\n1577836800500,2\n",
+			&CSVOpts{Column: "cpu", TimeColumn: "ts", TimeLayout: LayoutUnixMilli},
+			[]time.Time{base, base.Add(500 * time.Millisecond)},
+			[]float64{1, 2},
+			false,
+		},
+		{
+			"when;cpu\n2020-01-01 00:00;1\n2020-01-01 00:02;3\n2020-01-01 00:03;4\n",
+			&CSVOpts{Column: "cpu", TimeColumn: "when", TimeLayout: "2006-01-02 15:04", Interval: time.Minute, Comma: ';'},
+			[]time.Time{base, base.Add(time.Minute), base.Add(2 * time.Minute), base.Add(3 * time.Minute)},
+			[]float64{1, 2, 3, 4},
+			false,
+		},
+		{
+			"cpu\n1\n2\n",
+			&CSVOpts{Column: "cpu"},
+			nil,
+			[]float64{1, 2},
+			false,
+		},
+		{"cpu\n1\n", NewCSVOpts("cpu"), nil, nil, true},
+		{"time,cpu\n2020-01-01T00:00:00Z,1\n", NewCSVOpts("mem"), nil, nil, true},
+		{"time,cpu\nyesterday,1\n", NewCSVOpts("cpu"), nil, nil, true},
+		{"cpu\n1\n", &CSVOpts{Column: "cpu", Interval: time.Second}, nil, nil, true},
+		{"", NewCSVOpts("cpu"), nil, nil, true},
+		{"cpu\n1\n", nil, nil, nil, true},
+	}
+
+	for _, d := range testdata {
+		times, vals, err := ReadCSV(strings.NewReader(d.in), d.o)
+		if d.expectedErr {
+			if err == nil {
+				t.Errorf("Expected an error for %q", d.in)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Did not expect an error, %v, for %q", err, d.in)
+			continue
+		}
+		if !equalSeries(vals, d.expectedVals) {
+			t.Errorf("Expected %v, but got %v for %q", d.expectedVals, vals, d.in)
+		}
+		if len(times) != len(d.expectedTimes) {
+			t.Errorf("Expected %d timestamps, but got %d for %q", len(d.expectedTimes), len(times), d.in)
+			continue
+		}
+		for i := range times {
+			if !times[i].Equal(d.expectedTimes[i]) {
+				t.Errorf("Expected %v, but got %v for %q", d.expectedTimes, times, d.in)
+				break
+			}
+		}
+	}
+}
+
+func TestRegularize(t *testing.T) {
+	base := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	at := func(sec ...int) []time.Time {
+		out := make([]time.Time, len(sec))
+		for i, s := range sec {
+			out[i] = base.Add(time.Duration(s) * time.Second)
+		}
+		return out
+	}
+
+	testdata := []struct {
+		times        []time.Time
+		vals         []float64
+		interval     time.Duration
+		expectedVals []float64
+		expectedErr  bool
+	}{
+		{at(0, 10, 20), []float64{0, 1, 2}, 5 * time.Second, []float64{0, 0.5, 1, 1.5, 2}, false},
+		{at(20, 0, 10), []float64{2, 0, 1}, 10 * time.Second, []float64{0, 1, 2}, false},
+		{at(0, 0, 4), []float64{5, 0, 4}, 2 * time.Second, []float64{0, 2, 4}, false},
+		{at(0, 3), []float64{0, 3}, 2 * time.Second, []float64{0, 2}, false},
+		{at(0), []float64{7}, time.Second, []float64{7}, false},
+		{nil, nil, time.Second, nil, false},
+		{at(0, 1), []float64{1}, time.Second, nil, true},
+		{at(0, 1), []float64{0, 1}, 0, nil, true},
+	}
+
+	for _, d := range testdata {
+		times, vals, err := Regularize(d.times, d.vals, d.interval)
+		if d.expectedErr {
+			if err == nil {
+				t.Errorf("Expected an error for %v", d)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Did not expect an error, %v, for %v", err, d)
+			continue
+		}
+		if !equalSeries(vals, d.expectedVals) {
+			t.Errorf("Expected %v, but got %v", d.expectedVals, vals)
+		}
+		for i := 1; i < len(times); i++ {
+			if times[i].Sub(times[i-1]) != d.interval {
+				t.Errorf("Expected a fixed interval of %v, but got %v", d.interval, times[i].Sub(times[i-1]))
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,177 @@
+// Package ingest reads timeseries from files, pipes, or network streams so they can be
+// handed off to a matrix profile computation.
+package ingest
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Decoder decodes a stream of bytes into timeseries values. Decode calls fn for
+// each value in the order it appears in the stream and stops at the first error
+// returned by fn.
+type Decoder interface {
+	Decode(r io.Reader, fn func(float64) error) error
+}
+
+// ReadSeries reads all values from r using the provided decoder and returns them
+// as a timeseries.
+func ReadSeries(r io.Reader, dec Decoder) ([]float64, error) {
+	if dec == nil {
+		return nil, errors.New("must provide a decoder")
+	}
+	var ts []float64
+	err := dec.Decode(r, func(v float64) error {
+		ts = append(ts, v)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ts, nil
+}
+
+// CSVDecoder decodes a single column of a comma separated value stream. Empty
+// cells are decoded as NaN.
+type CSVDecoder struct {
+	Column int  // zero based index of the column to read
+	Header bool // skips the first record when set
+	Comma  rune // field delimiter which defaults to ','
+}
+
+// CSV returns a decoder reading the column at the given index of a headerless
+// csv stream.
+func CSV(column int) *CSVDecoder {
+	return &CSVDecoder{Column: column}
+}
+
+// Decode implements the Decoder interface
+func (d CSVDecoder) Decode(r io.Reader, fn func(float64) error) error {
+	if d.Column < 0 {
+		return fmt.Errorf("invalid csv column index, %d", d.Column)
+	}
+
+	cr := csv.NewReader(r)
+	cr.ReuseRecord = true
+	cr.FieldsPerRecord = -1
+	if d.Comma != 0 {
+		cr.Comma = d.Comma
+	}
+
+	skip := d.Header
+	for line := 1; ; line++ {
+		rec, err := cr.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if skip {
+			skip = false
+			continue
+		}
+		if d.Column >= len(rec) {
+			return fmt.Errorf("record %d has %d columns, but column %d was requested", line, len(rec), d.Column)
+		}
+		v, err := parseFloat(rec[d.Column])
+		if err != nil {
+			return fmt.Errorf("record %d: %v", line, err)
+		}
+		if err = fn(v); err != nil {
+			return err
+		}
+	}
+}
+
+// JSONDecoder decodes a JSON array of numbers, such as [1, 2.5, null, 3]. Null
+// entries are decoded as NaN.
+type JSONDecoder struct{}
+
+// JSON returns a decoder for a JSON array of numbers.
+func JSON() *JSONDecoder {
+	return &JSONDecoder{}
+}
+
+// Decode implements the Decoder interface
+func (d JSONDecoder) Decode(r io.Reader, fn func(float64) error) error {
+	jd := json.NewDecoder(r)
+	tok, err := jd.Token()
+	if err != nil {
+		return err
+	}
+	if delim, ok := tok.(json.Delim); !ok || delim != '[' {
+		return fmt.Errorf("expected the start of a json array, but got %v", tok)
+	}
+
+	for jd.More() {
+		tok, err = jd.Token()
+		if err != nil {
+			return err
+		}
+		var v float64
+		switch t := tok.(type) {
+		case float64:
+			v = t
+		case nil:
+			v = math.NaN()
+		default:
+			return fmt.Errorf("expected a number in the json array, but got %v", tok)
+		}
+		if err = fn(v); err != nil {
+			return err
+		}
+	}
+
+	if _, err = jd.Token(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// BinaryDecoder decodes a raw stream of little-endian IEEE 754 float64 values.
+type BinaryDecoder struct{}
+
+// Float64LE returns a decoder for a raw stream of little-endian float64 values.
+func Float64LE() *BinaryDecoder {
+	return &BinaryDecoder{}
+}
+
+// Decode implements the Decoder interface
+func (d BinaryDecoder) Decode(r io.Reader, fn func(float64) error) error {
+	br := bufio.NewReader(r)
+	buf := make([]byte, 8)
+	for {
+		n, err := io.ReadFull(br, buf)
+		if err == io.EOF {
+			return nil
+		}
+		if err == io.ErrUnexpectedEOF {
+			return fmt.Errorf("stream ended with a partial value of %d bytes", n)
+		}
+		if err != nil {
+			return err
+		}
+		if err = fn(math.Float64frombits(binary.LittleEndian.Uint64(buf))); err != nil {
+			return err
+		}
+	}
+}
+
+// parseFloat parses a single text field into a float, treating empty fields as
+// missing data.
+func parseFloat(s string) (float64, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return math.NaN(), nil
+	}
+	return strconv.ParseFloat(s, 64)
+}
@@ -0,0 +1,133 @@
+package ingest
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math"
+	"strings"
+	"testing"
+)
+
+func equalSeries(a, b []float64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if math.IsNaN(a[i]) && math.IsNaN(b[i]) {
+			continue
+		}
+		if math.Abs(a[i]-b[i]) > 1e-12 {
+			return false
+		}
+	}
+	return true
+}
+
+func TestReadSeriesCSV(t *testing.T) {
+	testdata := []struct {
+		in       string
+		dec      *CSVDecoder
+		expected []float64
+	}{
+		{"1\n2\n3\n", CSV(0), []float64{1, 2, 3}},
+		{"a,b\n1,4\n2,5\n3,6\n", &CSVDecoder{Column: 1, Header: true}, []float64{4, 5, 6}},
+		{"1;4\n2;\n3;6\n", &CSVDecoder{Column: 1, Comma: ';'}, []float64{4, math.NaN(), 6}},
+		{"1,4\n2\n", CSV(1), nil},
+		{"1\nfoo\n", CSV(0), nil},
+		{"1\n", CSV(-1), nil},
+		{"", CSV(0), []float64{}},
+	}
+
+	for _, d := range testdata {
+		out, err := ReadSeries(strings.NewReader(d.in), d.dec)
+		if err != nil {
+			if d.expected == nil {
+				continue
+			}
+			t.Errorf("Did not expect an error, %v, for %q", err, d.in)
+			continue
+		}
+		if d.expected == nil {
+			t.Errorf("Expected an error for %q, but got %v", d.in, out)
+			continue
+		}
+		if !equalSeries(out, d.expected) {
+			t.Errorf("Expected %v, but got %v for %q", d.expected, out, d.in)
+		}
+	}
+}
+
+func TestReadSeriesJSON(t *testing.T) {
+	testdata := []struct {
+		in       string
+		expected []float64
+	}{
+		{"[1, 2.5, -3]", []float64{1, 2.5, -3}},
+		{"[1, null, 3]", []float64{1, math.NaN(), 3}},
+		{"[]", []float64{}},
+		{"{\"a\": 1}", nil},
+		{"[1, \"b\"]", nil},
+		{"[1, 2", nil},
+	}
+
+	for _, d := range testdata {
+		out, err := ReadSeries(strings.NewReader(d.in), JSON())
+		if err != nil {
+			if d.expected == nil {
+				continue
+			}
+			t.Errorf("Did not expect an error, %v, for %q", err, d.in)
+			continue
+		}
+		if d.expected == nil {
+			t.Errorf("Expected an error for %q, but got %v", d.in, out)
+			continue
+		}
+		if !equalSeries(out, d.expected) {
+			t.Errorf("Expected %v, but got %v for %q", d.expected, out, d.in)
+		}
+	}
+}
+
+func TestReadSeriesBinary(t *testing.T) {
+	vals := []float64{1, -2.5, math.Pi, 0}
+	var buf bytes.Buffer
+	if err := binary.Write(&buf, binary.LittleEndian, vals); err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := ReadSeries(bytes.NewReader(buf.Bytes()), Float64LE())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !equalSeries(out, vals) {
+		t.Errorf("Expected %v, but got %v", vals, out)
+	}
+
+	if _, err = ReadSeries(bytes.NewReader(buf.Bytes()[:12]), Float64LE()); err == nil {
+		t.Errorf("Expected an error for a partial value")
+	}
+}
+
+func TestReadSeriesStopEarly(t *testing.T) {
+	stop := errors.New("stop")
+	var n int
+	err := CSV(0).Decode(strings.NewReader("1\n2\n3\n"), func(v float64) error {
+		n++
+		if n == 2 {
+			return stop
+		}
+		return nil
+	})
+	if err != stop {
+		t.Errorf("Expected the callback error, but got %v", err)
+	}
+	if n != 2 {
+		t.Errorf("Expected 2 values to be decoded, but got %d", n)
+	}
+
+	if _, err = ReadSeries(strings.NewReader("1"), nil); err == nil {
+		t.Errorf("Expected an error for a nil decoder")
+	}
+}
@@ -0,0 +1,364 @@
+package ingest
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// npyMagic starts every numpy .npy file.
+const npyMagic = "\x93NUMPY"
+
+// NpyArray is an n dimensional array in the numpy .npy format. Data is stored in row
+// major order and always held as float64 regardless of the stored type, which holds
+// profile indices exactly.
+type NpyArray struct {
+	Shape []int
+	Data  []float64
+}
+
+// NewNpyArray wraps a one dimensional timeseries or profile as an array.
+func NewNpyArray(data []float64) *NpyArray {
+	return &NpyArray{Shape: []int{len(data)}, Data: data}
+}
+
+// maxInt is the largest value of an int.
+const maxInt = int(^uint(0) >> 1)
+
+// size returns the number of elements described by the shape. Shapes read from a
+// header are untrusted, so negative dimensions and element counts overflowing an int
+// are errors.
+func (a NpyArray) size() (int, error) {
+	for _, s := range a.Shape {
+		if s < 0 {
+			return 0, fmt.Errorf("array shape %v has a negative dimension", a.Shape)
+		}
+		if s == 0 {
+			return 0, nil
+		}
+	}
+	n := 1
+	for _, s := range a.Shape {
+		if n > maxInt/s {
+			return 0, fmt.Errorf("array shape %v holds too many values", a.Shape)
+		}
+		n *= s
+	}
+	return n, nil
+}
+
+// ReadNpy reads an array from a numpy .npy stream as written by numpy.save. Little and
+// big endian floats as well as signed and unsigned integers are supported and
+// converted to float64. Fortran ordered arrays are reordered to row major order.
+func ReadNpy(r io.Reader) (*NpyArray, error) {
+	prefix := make([]byte, len(npyMagic)+2)
+	if _, err := io.ReadFull(r, prefix); err != nil {
+		return nil, fmt.Errorf("failed to read npy magic string, %v", err)
+	}
+	if string(prefix[:len(npyMagic)]) != npyMagic {
+		return nil, errors.New("not a npy stream")
+	}
+
+	var headerLen int
+	switch major := prefix[len(npyMagic)]; major {
+	case 1:
+		var n uint16
+		if err := binary.Read(r, binary.LittleEndian, &n); err != nil {
+			return nil, fmt.Errorf("failed to read npy header length, %v", err)
+		}
+		headerLen = int(n)
+	case 2, 3:
+		var n uint32
+		if err := binary.Read(r, binary.LittleEndian, &n); err != nil {
+			return nil, fmt.Errorf("failed to read npy header length, %v", err)
+		}
+		headerLen = int(n)
+	default:
+		return nil, fmt.Errorf("unsupported npy format version, %d", major)
+	}
+
+	header := make([]byte, headerLen)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, fmt.Errorf("failed to read npy header, %v", err)
+	}
+	descr, fortran, shape, err := parseNpyHeader(string(header))
+	if err != nil {
+		return nil, err
+	}
+
+	order, kind, width, err := parseNpyDescr(descr)
+	if err != nil {
+		return nil, err
+	}
+
+	a := &NpyArray{Shape: shape}
+	n, err := a.size()
+	if err != nil {
+		return nil, err
+	}
+	if n > maxInt/width {
+		return nil, fmt.Errorf("array shape %v holds too many values", a.Shape)
+	}
+	// the data is read as it arrives rather than allocated from the header, so a shape
+	// claiming more values than the stream holds can't exhaust memory
+	raw, err := ioutil.ReadAll(io.LimitReader(r, int64(n*width)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read npy data, %v", err)
+	}
+	if len(raw) != n*width {
+		return nil, fmt.Errorf("array shape %v of type %s needs %d bytes of data, but got %d", a.Shape, descr, n*width, len(raw))
+	}
+
+	a.Data = make([]float64, n)
+	for i := range a.Data {
+		a.Data[i] = decodeNpyValue(raw[i*width:(i+1)*width], order, kind)
+	}
+	if fortran && len(shape) > 1 {
+		a.Data = fortranToRowMajor(a.Data, shape)
+	}
+	return a, nil
+}
+
+// parseNpyHeader extracts the type description, order and shape from the python
+// dictionary literal of a npy header.
+func parseNpyHeader(header string) (string, bool, []int, error) {
+	value := func(key string) (string, error) {
+		i := strings.Index(header, "'"+key+"'")
+		if i < 0 {
+			return "", fmt.Errorf("npy header is missing %s", key)
+		}
+		rest := strings.TrimSpace(header[i+len(key)+2:])
+		if !strings.HasPrefix(rest, ":") {
+			return "", fmt.Errorf("malformed npy header entry for %s", key)
+		}
+		return strings.TrimSpace(rest[1:]), nil
+	}
+
+	descr, err := value("descr")
+	if err != nil {
+		return "", false, nil, err
+	}
+	if len(descr) < 2 || descr[0] != '\'' || strings.IndexByte(descr[1:], '\'') < 0 {
+		return "", false, nil, errors.New("unsupported npy type description, only simple types are supported")
+	}
+	descr = descr[1 : 1+strings.IndexByte(descr[1:], '\'')]
+
+	order, err := value("fortran_order")
+	if err != nil {
+		return "", false, nil, err
+	}
+	fortran := strings.HasPrefix(order, "True")
+	if !fortran && !strings.HasPrefix(order, "False") {
+		return "", false, nil, fmt.Errorf("malformed npy fortran order, %s", order)
+	}
+
+	dims, err := value("shape")
+	if err != nil {
+		return "", false, nil, err
+	}
+	end := strings.IndexByte(dims, ')')
+	if !strings.HasPrefix(dims, "(") || end < 0 {
+		return "", false, nil, fmt.Errorf("malformed npy shape, %s", dims)
+	}
+	shape := []int{}
+	for _, d := range strings.Split(dims[1:end], ",") {
+		d = strings.TrimSpace(d)
+		if d == "" {
+			continue
+		}
+		n, err := strconv.Atoi(strings.TrimSuffix(d, "L"))
+		if err != nil || n < 0 {
+			return "", false, nil, fmt.Errorf("malformed npy shape, %s", dims)
+		}
+		shape = append(shape, n)
+	}
+	return descr, fortran, shape, nil
+}
+
+// parseNpyDescr splits a numpy type description such as <f8 into its byte order,
+// kind and width in bytes.
+func parseNpyDescr(descr string) (binary.ByteOrder, byte, int, error) {
+	if len(descr) < 3 {
+		return nil, 0, 0, fmt.Errorf("unsupported npy type, %s", descr)
+	}
+
+	var order binary.ByteOrder
+	switch descr[0] {
+	case '<', '|', '=':
+		order = binary.LittleEndian
+	case '>':
+		order = binary.BigEndian
+	default:
+		return nil, 0, 0, fmt.Errorf("unsupported npy byte order in type, %s", descr)
+	}
+
+	kind := descr[1]
+	width, err := strconv.Atoi(descr[2:])
+	if err != nil {
+		return nil, 0, 0, fmt.Errorf("unsupported npy type, %s", descr)
+	}
+	switch {
+	case kind == 'f' && (width == 4 || width == 8):
+	case (kind == 'i' || kind == 'u') && (width == 1 || width == 2 || width == 4 || width == 8):
+	default:
+		return nil, 0, 0, fmt.Errorf("unsupported npy type, %s", descr)
+	}
+	return order, kind, width, nil
+}
+
+// decodeNpyValue converts a single stored value to a float64.
+func decodeNpyValue(b []byte, order binary.ByteOrder, kind byte) float64 {
+	var u uint64
+	switch len(b) {
+	case 1:
+		u = uint64(b[0])
+	case 2:
+		u = uint64(order.Uint16(b))
+	case 4:
+		u = uint64(order.Uint32(b))
+	case 8:
+		u = order.Uint64(b)
+	}
+
+	switch kind {
+	case 'f':
+		if len(b) == 4 {
+			return float64(math.Float32frombits(uint32(u)))
+		}
+		return math.Float64frombits(u)
+	case 'i':
+		// sign extend from the stored width
+		shift := uint(64 - 8*len(b))
+		return float64(int64(u<<shift) >> shift)
+	}
+	return float64(u)
+}
+
+// fortranToRowMajor reorders column major data of the given shape to row major order.
+func fortranToRowMajor(data []float64, shape []int) []float64 {
+	out := make([]float64, len(data))
+	idx := make([]int, len(shape))
+	for i := range out {
+		// i is the row major position, find the column major position of the same index
+		pos, stride := 0, 1
+		for d := range shape {
+			pos += idx[d] * stride
+			stride *= shape[d]
+		}
+		out[i] = data[pos]
+
+		for d := len(shape) - 1; d >= 0; d-- {
+			idx[d]++
+			if idx[d] < shape[d] {
+				break
+			}
+			idx[d] = 0
+		}
+	}
+	return out
+}
+
+// WriteNpy writes an array as little endian float64 values in the numpy .npy format so
+// it can be loaded with numpy.load.
+func WriteNpy(w io.Writer, a *NpyArray) error {
+	if a == nil {
+		return errors.New("must provide an array")
+	}
+	n, err := a.size()
+	if err != nil {
+		return err
+	}
+	if n != len(a.Data) {
+		return fmt.Errorf("array shape %v holds %d values, but got %d", a.Shape, n, len(a.Data))
+	}
+
+	dims := make([]string, len(a.Shape))
+	for i, s := range a.Shape {
+		dims[i] = strconv.Itoa(s)
+	}
+	shape := strings.Join(dims, ", ")
+	if len(a.Shape) == 1 {
+		shape += ","
+	}
+	header := fmt.Sprintf("{'descr': '<f8', 'fortran_order': False, 'shape': (%s), }", shape)
+
+	// the header is padded with spaces and a newline so the data is 64 byte aligned
+	preamble := len(npyMagic) + 4
+	pad := 64 - (preamble+len(header)+1)%64
+	if pad == 64 {
+		pad = 0
+	}
+	header += strings.Repeat(" ", pad) + "\n"
+	if len(header) > math.MaxUint16 {
+		return errors.New("npy header is too long")
+	}
+
+	buf := bytes.NewBuffer(make([]byte, 0, preamble+len(header)+8*len(a.Data)))
+	buf.WriteString(npyMagic)
+	buf.Write([]byte{1, 0, byte(len(header)), byte(len(header) >> 8)})
+	buf.WriteString(header)
+	b := make([]byte, 8)
+	for _, v := range a.Data {
+		binary.LittleEndian.PutUint64(b, math.Float64bits(v))
+		buf.Write(b)
+	}
+	_, err = w.Write(buf.Bytes())
+	return err
+}
+
+// ReadNpz reads every array of a numpy .npz archive as written by numpy.savez or
+// numpy.savez_compressed, keyed by the array name without the .npy extension.
+func ReadNpz(r io.ReaderAt, size int64) (map[string]*NpyArray, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, err
+	}
+
+	arrays := make(map[string]*NpyArray, len(zr.File))
+	for _, f := range zr.File {
+		if !strings.HasSuffix(f.Name, ".npy") {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return nil, err
+		}
+		a, err := ReadNpy(rc)
+		rc.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s, %v", f.Name, err)
+		}
+		arrays[strings.TrimSuffix(f.Name, ".npy")] = a
+	}
+	return arrays, nil
+}
+
+// WriteNpz writes a set of named arrays as a compressed numpy .npz archive that can
+// be loaded with numpy.load. Arrays are written in order of their names.
+func WriteNpz(w io.Writer, arrays map[string]*NpyArray) error {
+	names := make([]string, 0, len(arrays))
+	for name := range arrays {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	zw := zip.NewWriter(w)
+	for _, name := range names {
+		f, err := zw.Create(name + ".npy")
+		if err != nil {
+			return err
+		}
+		if err = WriteNpy(f, arrays[name]); err != nil {
+			return fmt.Errorf("failed to write %s, %v", name, err)
+		}
+	}
+	return zw.Close()
+}
@@ -0,0 +1,168 @@
+package ingest
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"testing"
+)
+
+// npyBytes builds a version 1 npy stream with the given header and raw data.
+func npyBytes(header string, data []byte) []byte {
+	var buf bytes.Buffer
+	buf.WriteString(npyMagic)
+	buf.Write([]byte{1, 0})
+	binary.Write(&buf, binary.LittleEndian, uint16(len(header)))
+	buf.WriteString(header)
+	buf.Write(data)
+	return buf.Bytes()
+}
+
+func TestReadNpy(t *testing.T) {
+	i4 := make([]byte, 12)
+	for i, v := range []int32{-1, 2, 300} {
+		binary.LittleEndian.PutUint32(i4[4*i:], uint32(v))
+	}
+	f8 := make([]byte, 16)
+	binary.BigEndian.PutUint64(f8, math.Float64bits(1.5))
+	binary.BigEndian.PutUint64(f8[8:], math.Float64bits(-2.25))
+	u1 := []byte{1, 2, 3, 4, 5, 6}
+
+	testdata := []struct {
+		in            []byte
+		expectedShape []int
+		expectedData  []float64
+		expectedErr   bool
+	}{
+		{npyBytes("{'descr': '<i4', 'fortran_order': False, 'shape': (3,), }\n", i4), []int{3}, []float64{-1, 2, 300}, false},
+		{npyBytes("{'descr': '>f8', 'fortran_order': False, 'shape': (2,), }\n", f8), []int{2}, []float64{1.5, -2.25}, false},
+		{npyBytes("{'descr': '|u1', 'fortran_order': True, 'shape': (2, 3), }\n", u1), []int{2, 3}, []float64{1, 3, 5, 2, 4, 6}, false},
+		{npyBytes("{'descr': '|u1', 'fortran_order': False, 'shape': (2, 3), }\n", u1), []int{2, 3}, []float64{1, 2, 3, 4, 5, 6}, false},
+		{npyBytes("{'descr': '<c16', 'fortran_order': False, 'shape': (1,), }\n", f8), nil, nil, true},
+		{npyBytes("{'descr': '<f8', 'fortran_order': False, 'shape': (3,), }\n", f8), nil, nil, true},
+		{npyBytes("{'descr': '<f8', 'shape': (2,), }\n", f8), nil, nil, true},
+		{[]byte("NUMPY\x01\x00"), nil, nil, true},
+		// shapes whose element count wraps around, exceeds an int, or claims more data
+		// than the stream holds
+		{npyBytes("{'descr': '<f8', 'fortran_order': False, 'shape': (4611686018427387904, 4), }\n", f8), nil, nil, true},
+		{npyBytes("{'descr': '<f8', 'fortran_order': False, 'shape': (4294967296, 4294967296), }\n", f8), nil, nil, true},
+		{npyBytes("{'descr': '<f8', 'fortran_order': False, 'shape': (1152921504606846976,), }\n", f8), nil, nil, true},
+		{npyBytes("{'descr': '<f8', 'fortran_order': False, 'shape': (1000000000,), }\n", f8), nil, nil, true},
+		{npyBytes("{'descr': '<f8', 'fortran_order': False, 'shape': (-2, -1), }\n", f8), nil, nil, true},
+		{npyBytes("{'descr': '<f8', 'fortran_order': False, 'shape': (4611686018427387904, 0), }\n", nil), []int{4611686018427387904, 0}, []float64{}, false},
+	}
+
+	for i, d := range testdata {
+		a, err := ReadNpy(bytes.NewReader(d.in))
+		if d.expectedErr {
+			if err == nil {
+				t.Errorf("Expected an error for case %d", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Did not expect an error, %v, for case %d", err, i)
+			continue
+		}
+		if len(a.Shape) != len(d.expectedShape) || len(a.Data) != len(d.expectedData) {
+			t.Errorf("Expected shape %v with %v, but got %v with %v for case %d", d.expectedShape, d.expectedData, a.Shape, a.Data, i)
+			continue
+		}
+		for j := range a.Shape {
+			if a.Shape[j] != d.expectedShape[j] {
+				t.Errorf("Expected shape %v, but got %v for case %d", d.expectedShape, a.Shape, i)
+				break
+			}
+		}
+		for j := range a.Data {
+			if a.Data[j] != d.expectedData[j] {
+				t.Errorf("Expected %v, but got %v for case %d", d.expectedData, a.Data, i)
+				break
+			}
+		}
+	}
+}
+
+func TestWriteNpy(t *testing.T) {
+	testdata := []struct {
+		a           *NpyArray
+		expectedErr bool
+	}{
+		{NewNpyArray([]float64{1, math.Inf(1), -3.5}), false},
+		{NewNpyArray([]float64{}), false},
+		{&NpyArray{Shape: []int{2, 2}, Data: []float64{1, 2, 3, 4}}, false},
+		{&NpyArray{Shape: []int{2, 3}, Data: []float64{1, 2, 3, 4}}, true},
+		{nil, true},
+	}
+
+	for i, d := range testdata {
+		var buf bytes.Buffer
+		err := WriteNpy(&buf, d.a)
+		if d.expectedErr {
+			if err == nil {
+				t.Errorf("Expected an error for case %d", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Did not expect an error, %v, for case %d", err, i)
+			continue
+		}
+
+		headerLen := int(binary.LittleEndian.Uint16(buf.Bytes()[8:10]))
+		if (10+headerLen)%64 != 0 {
+			t.Errorf("Expected the data to be 64 byte aligned, but got an offset of %d for case %d", 10+headerLen, i)
+		}
+		a, err := ReadNpy(&buf)
+		if err != nil {
+			t.Errorf("Did not expect an error reading back case %d, %v", i, err)
+			continue
+		}
+		if len(a.Shape) != len(d.a.Shape) || len(a.Data) != len(d.a.Data) {
+			t.Errorf("Expected %+v, but got %+v for case %d", d.a, a, i)
+			continue
+		}
+		for j := range a.Data {
+			if a.Data[j] != d.a.Data[j] {
+				t.Errorf("Expected %v, but got %v for case %d", d.a.Data, a.Data, i)
+				break
+			}
+		}
+	}
+}
+
+func TestNpzRoundTrip(t *testing.T) {
+	arrays := map[string]*NpyArray{
+		"mp": NewNpyArray([]float64{0.5, 0.25, math.Inf(1)}),
+		"pi": NewNpyArray([]float64{2, 0, 1}),
+	}
+
+	var buf bytes.Buffer
+	if err := WriteNpz(&buf, arrays); err != nil {
+		t.Fatal(err)
+	}
+	out, err := ReadNpz(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out) != len(arrays) {
+		t.Fatalf("Expected %d arrays, but got %d", len(arrays), len(out))
+	}
+	for name, a := range arrays {
+		b, ok := out[name]
+		if !ok {
+			t.Errorf("Expected array %s to be read back", name)
+			continue
+		}
+		for j := range a.Data {
+			if a.Data[j] != b.Data[j] {
+				t.Errorf("Expected %v, but got %v for %s", a.Data, b.Data, name)
+				break
+			}
+		}
+	}
+
+	if _, err = ReadNpz(bytes.NewReader([]byte("not a zip")), 9); err == nil {
+		t.Errorf("Expected an error reading an invalid archive")
+	}
+}
@@ -1,16 +1,22 @@
 package matrixprofile
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io/ioutil"
 	"math"
 	"os"
+	"runtime"
 	"sort"
+	"sync"
 
+	"github.com/matrix-profile-foundation/go-matrixprofile/storage"
 	"github.com/matrix-profile-foundation/go-matrixprofile/util"
 	"gonum.org/v1/gonum/dsp/fourier"
+	"gonum.org/v1/gonum/floats"
+	"gonum.org/v1/gonum/stat"
 	"gonum.org/v1/plot/plotter"
 )
 
@@ -21,17 +27,43 @@ type KMP struct {
 	T     [][]float64    // a set of timeseries where the number of row represents the number of dimensions and each row is a separate time series
 	tMean [][]float64    // sliding mean of each timeseries with a window of m each
 	tStd  [][]float64    // sliding standard deviation of each timeseries with a window of m each
-	tF    [][]complex128 // holds an existing calculation of the FFT for each timeseries
+	bF    [][]complex128 // holds an existing calculation of the FFT for each timeseries of b
 	n     int            // length of the timeseries
 	W     int            // length of a subsequence
 	MP    [][]float64    // matrix profile
 	Idx   [][]int        // matrix profile index
+
+	B        [][]float64 // set of timeseries to perform an AB join with, the same as T for a self join
+	bMean    [][]float64 // sliding mean of each timeseries of b with a window of m each
+	bStd     [][]float64 // sliding standard deviation of each timeseries of b with a window of m each
+	nB       int         // length of the timeseries of b
+	SelfJoin bool        // indicates whether a self join is performed with an exclusion zone
+	MPB      [][]float64 // matrix profile of each subsequence of b against t for an AB join
+	IdxB     [][]int     // matrix profile index of each subsequence of b into t for an AB join
+	Weights  []float64   // weight of each dimension's distances, normalized to a mean of 1. Nil weighs all dimensions equally
+	NJobs    int         // number of batches of rows computed concurrently, defaulting to the number of CPUs when less than 1
 }
 
 // NewKMP creates a matrix profile struct specifically to be used with the k dimensional
 // matrix profile computation. The number of rows represents the number of dimensions,
 // and each row holds a series of points of equal length as each other.
 func NewKMP(t [][]float64, w int) (*KMP, error) {
+	return newKMP(t, nil, w)
+}
+
+// NewKMPAB creates a k dimensional matrix profile struct for an AB join of the
+// timeseries t against b, such as a multichannel query recording against a
+// multichannel reference recording. Both must have the same number of dimensions, and
+// all timeseries within each must have the same length. MP is indexed by the
+// subsequences of t and MPB by the subsequences of b.
+func NewKMPAB(t, b [][]float64, w int) (*KMP, error) {
+	if b == nil || len(b) == 0 {
+		return nil, fmt.Errorf("second slice is nil or has a length of 0 dimensions")
+	}
+	return newKMP(t, b, w)
+}
+
+func newKMP(t, b [][]float64, w int) (*KMP, error) {
 	if t == nil || len(t) == 0 {
 		return nil, fmt.Errorf("slice is nil or has a length of 0 dimensions")
 	}
@@ -41,16 +73,30 @@ func NewKMP(t [][]float64, w int) (*KMP, error) {
 		W: w,
 		n: len(t[0]),
 	}
+	if b == nil {
+		k.B = t
+		k.SelfJoin = true
+	} else {
+		k.B = b
+	}
+	k.nB = len(k.B[0])
+
+	if len(k.B) != len(t) {
+		return nil, fmt.Errorf("second slice has %d dimensions and doesn't match the %d dimensions of the first", len(k.B), len(t))
+	}
 
 	// checks that all timeseries have the same length
 	for d := 0; d < len(t); d++ {
 		if len(t[d]) != k.n {
 			return nil, fmt.Errorf("timeseries %d has a length of %d and doesn't match the first timeseries with length %d", d, len(t[d]), k.n)
 		}
+		if len(k.B[d]) != k.nB {
+			return nil, fmt.Errorf("timeseries %d of b has a length of %d and doesn't match the first timeseries of b with length %d", d, len(k.B[d]), k.nB)
+		}
 	}
 
-	if k.W*2 >= k.n {
-		return nil, fmt.Errorf("subsequence length must be less than half the timeseries")
+	if k.W > k.n || k.W > k.nB {
+		return nil, fmt.Errorf("subsequence length must be less than the timeseries")
 	}
 
 	if k.W < 2 {
@@ -59,22 +105,12 @@ func NewKMP(t [][]float64, w int) (*KMP, error) {
 
 	k.tMean = make([][]float64, len(t))
 	k.tStd = make([][]float64, len(t))
-	k.tF = make([][]complex128, len(t))
-	k.MP = make([][]float64, len(t))
-	k.Idx = make([][]int, len(t))
-	for d := 0; d < len(t); d++ {
-		k.tMean[d] = make([]float64, k.n-k.W+1)
-		k.tStd[d] = make([]float64, k.n-k.W+1)
-		k.tF[d] = make([]complex128, k.n-k.W+1)
-		k.MP[d] = make([]float64, k.n-k.W+1)
-		k.Idx[d] = make([]int, k.n-k.W+1)
-	}
-
-	for d := 0; d < len(t); d++ {
-		for i := 0; i < k.n-k.W+1; i++ {
-			k.MP[d][i] = math.Inf(1)
-			k.Idx[d][i] = math.MaxInt64
-		}
+	k.bF = make([][]complex128, len(t))
+	k.MP, k.Idx = newKProfile(len(t), k.n-k.W+1)
+	if !k.SelfJoin {
+		k.bMean = make([][]float64, len(t))
+		k.bStd = make([][]float64, len(t))
+		k.MPB, k.IdxB = newKProfile(len(t), k.nB-k.W+1)
 	}
 
 	if err := k.initCaches(); err != nil {
@@ -84,6 +120,68 @@ func NewKMP(t [][]float64, w int) (*KMP, error) {
 	return &k, nil
 }
 
+// newKProfile allocates a k dimensional matrix profile and index of n subsequences
+// initialized to +Inf.
+func newKProfile(dims, n int) ([][]float64, [][]int) {
+	mp := make([][]float64, dims)
+	idx := make([][]int, dims)
+	for d := 0; d < dims; d++ {
+		mp[d] = make([]float64, n)
+		idx[d] = make([]int, n)
+		for i := 0; i < n; i++ {
+			mp[d][i] = math.Inf(1)
+			idx[d][i] = math.MaxInt64
+		}
+	}
+	return mp, idx
+}
+
+// SetWeights sets the weight each dimension's distances are multiplied by before the
+// dimensions are sorted and averaged, so low amplitude but informative channels can be
+// emphasized. Weights must be non-negative with at least one positive, and are
+// normalized to a mean of 1 so profile values stay on the same scale. Nil restores
+// equal weights.
+func (k *KMP) SetWeights(w []float64) error {
+	if w == nil {
+		k.Weights = nil
+		return nil
+	}
+	if len(w) != len(k.T) {
+		return fmt.Errorf("got %d weights for %d dimensions", len(w), len(k.T))
+	}
+
+	var sum float64
+	for d, v := range w {
+		if v < 0 || math.IsInf(v, 0) || math.IsNaN(v) {
+			return fmt.Errorf("weight of dimension %d, %.3f, must be a non-negative number", d, v)
+		}
+		sum += v
+	}
+	if sum == 0 {
+		return errors.New("at least one weight must be positive")
+	}
+
+	k.Weights = make([]float64, len(w))
+	for d, v := range w {
+		k.Weights[d] = v * float64(len(w)) / sum
+	}
+	return nil
+}
+
+// InverseVarianceWeights returns a weight for each dimension of t proportional to the
+// inverse of its variance, which can be passed to SetWeights.
+func (k KMP) InverseVarianceWeights() ([]float64, error) {
+	w := make([]float64, len(k.T))
+	for d := range k.T {
+		_, std := stat.MeanStdDev(k.T[d], nil)
+		if std == 0 || math.IsNaN(std) {
+			return nil, fmt.Errorf("dimension %d has no variance", d)
+		}
+		w[d] = 1 / (std * std)
+	}
+	return w, nil
+}
+
 // Save will save the current matrix profile struct to disk
 func (k KMP) Save(filepath, format string) error {
 	var err error
@@ -129,6 +227,38 @@ func (k *KMP) Load(filepath, format string) error {
 	return err
 }
 
+// SaveObject will save the current k-dimensional matrix profile struct to an object
+// store under a bucket and key, uploading it in parts when it is larger than the part
+// size so large profiles can be archived. A part size of 0 uses
+// storage.DefaultPartSize, and S3 stores need at least storage.MinPartSize.
+func (k KMP) SaveObject(ctx context.Context, s storage.Store, bucket, key, format string, partSize int) error {
+	switch format {
+	case "json":
+		out, err := json.Marshal(k)
+		if err != nil {
+			return err
+		}
+		return storage.Upload(ctx, s, bucket, key, out, partSize)
+	default:
+		return fmt.Errorf("invalid save format, %s", format)
+	}
+}
+
+// LoadObject will attempt to load a k-dimensional matrix profile from an object store
+// for iterative use
+func (k *KMP) LoadObject(ctx context.Context, s storage.Store, bucket, key, format string) error {
+	switch format {
+	case "json":
+		b, err := storage.Download(ctx, s, bucket, key)
+		if err != nil {
+			return err
+		}
+		return json.Unmarshal(b, k)
+	default:
+		return fmt.Errorf("invalid load format, %s", format)
+	}
+}
+
 // initCaches initializes cached data including the timeseries a and b rolling mean
 // and standard deviation and full fourier transform of timeseries b
 func (k *KMP) initCaches() error {
@@ -141,110 +271,243 @@ func (k *KMP) initCaches() error {
 			return err
 		}
 	}
+	if k.SelfJoin {
+		k.bMean, k.bStd = k.tMean, k.tStd
+	} else {
+		for d := 0; d < len(k.B); d++ {
+			k.bMean[d], k.bStd[d], err = util.MovMeanStd(k.B[d], k.W)
+			if err != nil {
+				return err
+			}
+		}
+	}
 
 	// precompute the fourier transform of the b timeseries since it will
 	// be used multiple times while computing the matrix profile
-	fft := fourier.NewFFT(k.n)
-	for d := 0; d < len(k.T); d++ {
-		k.tF[d] = fft.Coefficients(nil, k.T[d])
+	fft := k.newFFT()
+	tpad := make([]float64, fft.Len())
+	for d := 0; d < len(k.B); d++ {
+		copy(tpad, k.B[d])
+		k.bF[d] = fft.Coefficients(nil, tpad)
 	}
 
 	return nil
 }
 
+// newFFT returns a fourier transform whose length is the timeseries length of b padded
+// up to the next size with only small prime factors.
+func (k KMP) newFFT() *fourier.FFT {
+	return fourier.NewFFT(util.FFTLen(k.nB))
+}
+
 // Compute runs a k dimensional matrix profile calculation across all time series
 func (k *KMP) Compute() error {
 	return k.mStomp()
 }
 
-// MStomp computes the k dimensional matrix profile
+// MStomp computes the k dimensional matrix profile. Each row holds the distances of a
+// subsequence of t to every subsequence of b. For a self join the rows are merged
+// column wise, while an AB join also keeps the best of each row so both directions
+// are found in a single pass. The rows are split into NJobs batches computed
+// concurrently, each starting from its own sliding dot products and following them
+// with the stomp recurrence in every dimension.
 func (k *KMP) mStomp() error {
-	var err error
+	jobs := k.NJobs
+	if jobs < 1 {
+		jobs = runtime.NumCPU()
+	}
 
-	// save the first dot product of the first row that will be used by all future
-	// go routines
-	cachedDots := make([][]float64, len(k.T))
-	fft := fourier.NewFFT(k.n)
-	k.crossCorrelate(0, fft, cachedDots)
+	// save the dot products of the first subsequence, which by symmetry are the first
+	// column of every row of a self join
+	cachedDots := k.parallelCrossCorrelate(0, jobs)
 
-	var D [][]float64
-	D = make([][]float64, len(k.T))
-	for d := 0; d < len(D); d++ {
-		D[d] = make([]float64, k.n-k.W+1)
+	// the profile merged column wise is indexed by the subsequences of b
+	colMP, colIdx := k.MP, k.Idx
+	if !k.SelfJoin {
+		colMP, colIdx = k.MPB, k.IdxB
+	}
+
+	nA := k.n - k.W + 1
+	batchSize := nA/jobs + 1
+	results := make([]*kmpResult, jobs)
+	var wg sync.WaitGroup
+	for batch := 0; batch < jobs; batch++ {
+		wg.Add(1)
+		go func(batch int) {
+			defer wg.Done()
+			results[batch] = k.mStompBatch(batch*batchSize, batchSize, cachedDots)
+		}(batch)
+	}
+	wg.Wait()
+
+	// batches are merged in order of their rows, so ties keep the smallest index as if
+	// the rows were computed one after another
+	for _, r := range results {
+		if r == nil {
+			continue
+		}
+		for d := range r.MP {
+			for i := range r.MP[d] {
+				if r.MP[d][i] < colMP[d][i] {
+					colMP[d][i] = r.MP[d][i]
+					colIdx[d][i] = r.Idx[d][i]
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// kmpResult is the column wise k dimensional matrix profile of a batch of rows.
+type kmpResult struct {
+	MP  [][]float64
+	Idx [][]int
+}
+
+// mStompBatch computes size rows of the distance matrix starting at row start, writing
+// the best of each row of an AB join directly since no other batch has those rows.
+func (k *KMP) mStompBatch(start, size int, cachedDots [][]float64) *kmpResult {
+	nA := k.n - k.W + 1
+	nB := k.nB - k.W + 1
+	if start >= nA {
+		// got an index larger than the number of subsequences so ignore
+		return nil
+	}
+	end := start + size
+	if end > nA {
+		end = nA
 	}
 
 	dots := make([][]float64, len(k.T))
-	for d := 0; d < len(dots); d++ {
-		dots[d] = make([]float64, k.n-k.W+1)
-		copy(dots[d], cachedDots[d])
+	if start == 0 {
+		for d := range dots {
+			dots[d] = append([]float64(nil), cachedDots[d]...)
+		}
+	} else {
+		k.crossCorrelate(start, k.newFFT(), dots)
+	}
+
+	D := make([][]float64, len(k.T))
+	for d := 0; d < len(D); d++ {
+		D[d] = make([]float64, nB)
 	}
 
-	for idx := 0; idx < k.n-k.W+1; idx++ {
+	result := &kmpResult{}
+	result.MP, result.Idx = newKProfile(len(k.T), nB)
+
+	for idx := start; idx < end; idx++ {
 		for d := 0; d < len(dots); d++ {
-			if idx > 0 {
-				for j := k.n - k.W; j > 0; j-- {
-					dots[d][j] = dots[d][j-1] - k.T[d][j-1]*k.T[d][idx-1] + k.T[d][j+k.W-1]*k.T[d][idx+k.W-1]
+			if idx > start {
+				for j := nB - 1; j > 0; j-- {
+					dots[d][j] = dots[d][j-1] - k.B[d][j-1]*k.T[d][idx-1] + k.B[d][j+k.W-1]*k.T[d][idx+k.W-1]
+				}
+				if k.SelfJoin {
+					dots[d][0] = cachedDots[d][idx]
+				} else {
+					// the first column is only cached by symmetry for a self join
+					dots[d][0] = floats.Dot(k.T[d][idx:idx+k.W], k.B[d][:k.W])
 				}
-				dots[d][0] = cachedDots[d][idx]
 			}
 
-			for i := 0; i < k.n-k.W+1; i++ {
-				D[d][i] = math.Sqrt(2 * float64(k.W) * math.Abs(1-(dots[d][i]-float64(k.W)*k.tMean[d][i]*k.tMean[d][idx])/(float64(k.W)*k.tStd[d][i]*k.tStd[d][idx])))
+			for i := 0; i < nB; i++ {
+				D[d][i] = math.Sqrt(2 * float64(k.W) * math.Abs(1-(dots[d][i]-float64(k.W)*k.bMean[d][i]*k.tMean[d][idx])/(float64(k.W)*k.bStd[d][i]*k.tStd[d][idx])))
+			}
+			if k.Weights != nil {
+				floats.Scale(k.Weights[d], D[d])
+			}
+			if k.SelfJoin {
+				// sets the distance in the exclusion zone to +Inf
+				util.ApplyExclusionZone(D[d], idx, k.W/2)
 			}
-			// sets the distance in the exclusion zone to +Inf
-			util.ApplyExclusionZone(D[d], idx, k.W/2)
 		}
 
 		k.columnWiseSort(D)
 		k.columnWiseCumSum(D)
 
 		for d := 0; d < len(D); d++ {
-			for i := 0; i < k.n-k.W+1; i++ {
-				if D[d][i]/(float64(d)+1) < k.MP[d][i] {
-					k.MP[d][i] = D[d][i] / (float64(d) + 1)
-					k.Idx[d][i] = idx
+			for i := 0; i < nB; i++ {
+				dist := D[d][i] / (float64(d) + 1)
+				if dist < result.MP[d][i] {
+					result.MP[d][i] = dist
+					result.Idx[d][i] = idx
+				}
+				if !k.SelfJoin && dist < k.MP[d][idx] {
+					k.MP[d][idx] = dist
+					k.Idx[d][idx] = i
 				}
 			}
 		}
 	}
 
-	return err
+	return result
+}
+
+// parallelCrossCorrelate computes the sliding dot products of the subsequence at idx
+// of every dimension of t against the same dimension of b, spreading the dimensions
+// over up to jobs goroutines since each needs its own fourier transform.
+func (k KMP) parallelCrossCorrelate(idx, jobs int) [][]float64 {
+	dots := make([][]float64, len(k.T))
+	if jobs > len(dots) {
+		jobs = len(dots)
+	}
+
+	var wg sync.WaitGroup
+	for j := 0; j < jobs; j++ {
+		wg.Add(1)
+		go func(j int) {
+			defer wg.Done()
+			fft := k.newFFT()
+			qpad := make([]float64, fft.Len())
+			for d := j; d < len(dots); d += jobs {
+				dots[d] = k.crossCorrelateDim(d, idx, fft, qpad)
+			}
+		}(j)
+	}
+	wg.Wait()
+	return dots
 }
 
 // crossCorrelate computes the sliding dot product between two slices
 // given a query and time series. Uses fast fourier transforms to compute
 // the necessary values. Returns the a slice of floats for the cross-correlation
-// of the signal q and the k.b signal. This makes an optimization where the query
-// length must be less than half the length of the timeseries, b.
+// of the signal q and the k.b signal. The query is zero padded to the fft length, which
+// is at least the timeseries length, so the circular convolution never wraps around for
+// the valid lags. Values past the valid lags are discarded.
 func (k KMP) crossCorrelate(idx int, fft *fourier.FFT, D [][]float64) {
-	qpad := make([]float64, k.n)
-	var qf []complex128
-	var dot []float64
-
+	qpad := make([]float64, fft.Len())
 	for d := 0; d < len(D); d++ {
-		for i := 0; i < k.W; i++ {
-			qpad[i] = k.T[d][idx+k.W-i-1]
-		}
-		qf = fft.Coefficients(nil, qpad)
+		D[d] = k.crossCorrelateDim(d, idx, fft, qpad)
+	}
+}
 
-		// in place multiply the fourier transform of the b time series with
-		// the subsequence fourier transform and store in the subsequence fft slice
-		for i := 0; i < len(qf); i++ {
-			qf[i] = k.tF[d][i] * qf[i]
-		}
+// crossCorrelateDim computes the sliding dot product of the subsequence at idx of
+// dimension d of t against the same dimension of b, using qpad as the zero padded
+// query.
+func (k KMP) crossCorrelateDim(d, idx int, fft *fourier.FFT, qpad []float64) []float64 {
+	n := fft.Len()
+	for i := 0; i < k.W; i++ {
+		qpad[i] = k.T[d][idx+k.W-i-1]
+	}
+	qf := fft.Coefficients(nil, qpad)
+
+	// in place multiply the fourier transform of the b time series with
+	// the subsequence fourier transform and store in the subsequence fft slice
+	for i := 0; i < len(qf); i++ {
+		qf[i] = k.bF[d][i] * qf[i]
+	}
 
-		dot = fft.Sequence(nil, qf)
+	dot := fft.Sequence(nil, qf)
 
-		for i := 0; i < k.n-k.W+1; i++ {
-			dot[k.W-1+i] = dot[k.W-1+i] / float64(k.n)
-		}
-		D[d] = dot[k.W-1:]
+	for i := 0; i < k.nB-k.W+1; i++ {
+		dot[k.W-1+i] = dot[k.W-1+i] / float64(n)
 	}
+	return dot[k.W-1 : k.nB]
 }
 
 func (k KMP) columnWiseSort(D [][]float64) {
 	dist := make([]float64, len(D))
-	for i := 0; i < k.n-k.W+1; i++ {
+	for i := 0; i < len(D[0]); i++ {
 		for d := 0; d < len(D); d++ {
 			dist[d] = D[d][i]
 		}
@@ -259,7 +522,7 @@ func (k KMP) columnWiseCumSum(D [][]float64) {
 	for d := 0; d < len(D); d++ {
 		// change D to be a cumulative sum of distances across dimensions
 		if d > 0 {
-			for i := 0; i < k.n-k.W+1; i++ {
+			for i := 0; i < len(D[d]); i++ {
 				D[d][i] += D[d-1][i]
 			}
 		}
@@ -271,6 +534,150 @@ func (k KMP) Analyze(mo *MPOpts, ao *AnalyzeOpts) error {
 	return errors.New("Analyze for KMP has not been implemented yet.")
 }
 
+// KMotif is a motif of a k dimensional matrix profile along with the dimensions it
+// spans.
+type KMotif struct {
+	Idx     []int   // starting indices of the motif pair
+	Dims    []int   // dimensions the motif spans in ascending order
+	MinDist float64 // matrix profile value of the pair across its dimensions
+}
+
+// DiscoverMotifsMDL finds the top kMotifs motif pairs of a self join and picks the
+// dimensions each one spans with the minimum description length criterion of mSTAMP.
+// The best pair of the matrix profile of each number of dimensions is a candidate, and
+// the candidate can span any number of the dimensions where its subsequences are
+// closest. Each choice is scored by the bits needed to store both subsequences in every
+// dimension after discretizing them to the given number of bits, where a spanned
+// dimension stores the first subsequence and the entropy coded difference of the
+// second from it along with its code table, and other dimensions store both
+// subsequences. The choice with the fewest bits is the motif, and the exclusion zones
+// of its pair are removed from all dimensions before the next one.
+func (k KMP) DiscoverMotifsMDL(kMotifs, bits int) ([]KMotif, error) {
+	if !k.SelfJoin {
+		return nil, errors.New("can only find top motifs if a self join is performed")
+	}
+	if bits < 1 || bits > 16 {
+		return nil, fmt.Errorf("number of bits, %d, must be between 1 and 16", bits)
+	}
+
+	mp := make([][]float64, len(k.MP))
+	for d := range mp {
+		mp[d] = make([]float64, len(k.MP[d]))
+		copy(mp[d], k.MP[d])
+	}
+
+	var motifs []KMotif
+	for len(motifs) < kMotifs {
+		var best *KMotif
+		bestBits := math.Inf(1)
+		for d := range mp {
+			i := floats.MinIdx(mp[d])
+			if math.IsInf(mp[d][i], 1) || k.Idx[d][i] >= len(mp[d]) {
+				continue
+			}
+			j := k.Idx[d][i]
+			order, dists := k.dimDistances(i, j)
+			var sum float64
+			for n := 1; n <= len(order); n++ {
+				sum += dists[order[n-1]]
+				if math.IsInf(sum, 1) {
+					break
+				}
+				dims := append([]int(nil), order[:n]...)
+				sort.Ints(dims)
+				if b := k.pairBits(i, j, dims, bits); b < bestBits {
+					bestBits = b
+					best = &KMotif{Idx: []int{i, j}, Dims: dims, MinDist: sum / float64(n)}
+				}
+			}
+		}
+		if best == nil {
+			// can't find any more motifs so returning what we currently found
+			break
+		}
+		motifs = append(motifs, *best)
+
+		for d := range mp {
+			for _, idx := range best.Idx {
+				util.ApplyExclusionZone(mp[d], idx, k.W/2)
+			}
+		}
+	}
+	return motifs, nil
+}
+
+// dimDistances returns the z-normalized distance between the subsequences at i and j
+// in each dimension, along with the dimensions ordered from the closest. A dimension
+// where either subsequence is flat has a distance of +Inf.
+func (k KMP) dimDistances(i, j int) ([]int, []float64) {
+	dists := make([]float64, len(k.T))
+	order := make([]int, len(k.T))
+	for d := range k.T {
+		order[d] = d
+		a, errA := util.ZNormalize(k.T[d][i : i+k.W])
+		b, errB := util.ZNormalize(k.T[d][j : j+k.W])
+		if errA != nil || errB != nil {
+			dists[d] = math.Inf(1)
+			continue
+		}
+		dists[d] = floats.Distance(a, b, 2)
+	}
+	sort.SliceStable(order, func(x, y int) bool {
+		return dists[order[x]] < dists[order[y]]
+	})
+	return order, dists
+}
+
+// pairBits returns the description length in bits of the subsequences at i and j in
+// every dimension, compressing the second subsequence by its difference from the first
+// in the given dimensions. Differences are entropy coded, and the code table is part of
+// the description so a dimension where the pair differs like noise is cheaper to store
+// as is.
+func (k KMP) pairBits(i, j int, dims []int, bits int) float64 {
+	spanned := make(map[int]bool, len(dims))
+	for _, d := range dims {
+		spanned[d] = true
+	}
+
+	var total float64
+	for d := range k.T {
+		if !spanned[d] {
+			total += float64(2 * k.W * bits)
+			continue
+		}
+		a := discretize(k.T[d][i:i+k.W], bits)
+		b := discretize(k.T[d][j:j+k.W], bits)
+		counts := make(map[int]int)
+		for t := range a {
+			counts[b[t]-a[t]]++
+		}
+		var entropy float64
+		for _, c := range counts {
+			p := float64(c) / float64(k.W)
+			entropy -= p * math.Log2(p)
+		}
+		// the code table holds each distinct difference with one more bit for its sign
+		total += float64(k.W*bits) + entropy*float64(k.W) + float64(len(counts)*(bits+1))
+	}
+	return total
+}
+
+// discretize z-normalizes a subsequence and rounds it to 2^bits levels between the
+// bounds of +-3 standard deviations. A flat subsequence is all at the middle level.
+func discretize(ts []float64, bits int) []int {
+	levels := float64(int(1)<<uint(bits) - 1)
+	out := make([]int, len(ts))
+	norm, err := util.ZNormalize(ts)
+	if err != nil {
+		norm = make([]float64, len(ts))
+	}
+	for t, v := range norm {
+		v = math.Max(-3, math.Min(3, v))
+		out[t] = int(math.Round((v + 3) / 6 * levels))
+	}
+	return out
+}
+
 // DiscoverMotifs has not been implemented yet
 func (k KMP) DiscoverMotifs(kMotifs int, r float64) ([]MotifGroup, error) {
 	return nil, errors.New("Motifs for KMP has not been implemented yet.")
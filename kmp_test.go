@@ -3,9 +3,12 @@ package matrixprofile
 import (
 	"math"
 	"os"
+	"sort"
 	"testing"
 
-	"gonum.org/v1/gonum/dsp/fourier"
+	"github.com/matrix-profile-foundation/go-matrixprofile/siggen"
+	"github.com/matrix-profile-foundation/go-matrixprofile/util"
+	"gonum.org/v1/gonum/floats"
 )
 
 func TestNewKMP(t *testing.T) {
@@ -17,6 +20,7 @@ func TestNewKMP(t *testing.T) {
 		{[][]float64{}, 2, true},
 		{[][]float64{{1, 1, 1, 1, 1}}, 2, false},
 		{[][]float64{{1, 1, 1, 1, 1}}, 1, true},
+		{[][]float64{{1, 1, 1, 1, 1}}, 4, false},
 		{[][]float64{{1, 1, 1, 1, 1}}, 6, true},
 		{[][]float64{{1, 1, 1, 1, 1}, {1, 1, 1}}, 2, true},
 	}
@@ -32,6 +36,84 @@ func TestNewKMP(t *testing.T) {
 	}
 }
 
+func TestNewKMPAB(t *testing.T) {
+	testdata := []struct {
+		t           [][]float64
+		b           [][]float64
+		w           int
+		expectedErr bool
+	}{
+		{[][]float64{{1, 2, 3, 4, 5}}, [][]float64{{1, 2, 3}}, 2, false},
+		{[][]float64{{1, 2, 3, 4, 5}}, [][]float64{{1, 2, 3}}, 4, true},
+		{[][]float64{{1, 2, 3, 4, 5}}, [][]float64{}, 2, true},
+		{[][]float64{{1, 2, 3, 4, 5}}, [][]float64{{1, 2, 3}, {1, 2, 3}}, 2, true},
+		{[][]float64{{1, 2, 3}, {1, 2, 3}}, [][]float64{{1, 2, 3}, {1, 2}}, 2, true},
+	}
+
+	for _, d := range testdata {
+		_, err := NewKMPAB(d.t, d.b, d.w)
+		if d.expectedErr && err == nil {
+			t.Errorf("Expected an error, but got none for %v", d)
+		}
+		if !d.expectedErr && err != nil {
+			t.Errorf("Expected no error, but got %v for %v", err, d)
+		}
+	}
+}
+
+func TestMStompAB(t *testing.T) {
+	a := [][]float64{siggen.Noise(1, 100), siggen.Noise(1, 100)}
+	b := [][]float64{siggen.Noise(1, 150), siggen.Noise(1, 150)}
+	w := 10
+
+	k, err := NewKMPAB(a, b, w)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = k.Compute(); err != nil {
+		t.Fatal(err)
+	}
+
+	// brute force the mean of the d+1 smallest per dimension distances of every pair
+	dist := func(x, y []float64) float64 {
+		xn, _ := util.ZNormalize(x)
+		yn, _ := util.ZNormalize(y)
+		return floats.Distance(xn, yn, 2)
+	}
+	expectedMP, _ := newKProfile(len(a), len(a[0])-w+1)
+	expectedMPB, _ := newKProfile(len(a), len(b[0])-w+1)
+	pair := make([]float64, len(a))
+	for i := range expectedMP[0] {
+		for j := range expectedMPB[0] {
+			for d := range a {
+				pair[d] = dist(a[d][i:i+w], b[d][j:j+w])
+			}
+			sort.Float64s(pair)
+			var sum float64
+			for d := range pair {
+				sum += pair[d]
+				expectedMP[d][i] = math.Min(expectedMP[d][i], sum/float64(d+1))
+				expectedMPB[d][j] = math.Min(expectedMPB[d][j], sum/float64(d+1))
+			}
+		}
+	}
+
+	for d := range a {
+		for i := range expectedMP[d] {
+			if math.Abs(k.MP[d][i]-expectedMP[d][i]) > 1e-6 {
+				t.Errorf("Expected %.6f at %d for %d dimensions, but got %.6f", expectedMP[d][i], i, d+1, k.MP[d][i])
+				break
+			}
+		}
+		for j := range expectedMPB[d] {
+			if math.Abs(k.MPB[d][j]-expectedMPB[d][j]) > 1e-6 {
+				t.Errorf("Expected %.6f at %d of b for %d dimensions, but got %.6f", expectedMPB[d][j], j, d+1, k.MPB[d][j])
+				break
+			}
+		}
+	}
+}
+
 func TestKCrossCorrelate(t *testing.T) {
 	var err error
 	var mp *KMP
@@ -65,7 +147,7 @@ func TestKCrossCorrelate(t *testing.T) {
 			}
 		}
 
-		fft := fourier.NewFFT(mp.n)
+		fft := mp.newFFT()
 		D := make([][]float64, len(mp.T))
 		mp.crossCorrelate(0, fft, D)
 		if err != nil && d.expected == nil {
@@ -195,6 +277,58 @@ func TestMStomp(t *testing.T) {
 	}
 }
 
+func TestMStompParallel(t *testing.T) {
+	dims := 24
+	a := make([][]float64, dims)
+	b := make([][]float64, dims)
+	for d := 0; d < dims; d++ {
+		a[d] = siggen.Add(siggen.Sin(1, float64(d%5+2), 0, 0, 100, 2), siggen.Noise(0.3, 200))
+		b[d] = siggen.Noise(1, 150)
+	}
+
+	for _, selfJoin := range []bool{true, false} {
+		var serial *KMP
+		for _, jobs := range []int{1, 3, 7, 500} {
+			var k *KMP
+			var err error
+			if selfJoin {
+				k, err = NewKMP(a, 15)
+			} else {
+				k, err = NewKMPAB(a, b, 15)
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			k.NJobs = jobs
+			if err = k.Compute(); err != nil {
+				t.Fatal(err)
+			}
+			if serial == nil {
+				serial = k
+				continue
+			}
+
+			for d := 0; d < dims; d++ {
+				for i := range serial.MP[d] {
+					if math.Abs(k.MP[d][i]-serial.MP[d][i]) > 1e-9 || k.Idx[d][i] != serial.Idx[d][i] {
+						t.Errorf("Expected %.6f at %d with index %d for %d dimensions, but got %.6f with index %d for %d jobs and self join %t", serial.MP[d][i], i, serial.Idx[d][i], d+1, k.MP[d][i], k.Idx[d][i], jobs, selfJoin)
+						break
+					}
+				}
+				if selfJoin {
+					continue
+				}
+				for i := range serial.MPB[d] {
+					if math.Abs(k.MPB[d][i]-serial.MPB[d][i]) > 1e-9 || k.IdxB[d][i] != serial.IdxB[d][i] {
+						t.Errorf("Expected %.6f at %d of b with index %d for %d dimensions, but got %.6f with index %d for %d jobs", serial.MPB[d][i], i, serial.IdxB[d][i], d+1, k.MPB[d][i], k.IdxB[d][i], jobs)
+						break
+					}
+				}
+			}
+		}
+	}
+}
+
 func TestKMPSave(t *testing.T) {
 	ts := [][]float64{{1, 2, 3, 4, 5, 6, 7, 8, 9}}
 	m := 3
@@ -237,3 +371,105 @@ func TestKMPLoad(t *testing.T) {
 	}
 
 }
+
+func TestKMPWeights(t *testing.T) {
+	ts := [][]float64{siggen.Noise(1, 100), siggen.Noise(5, 100)}
+
+	k, err := NewKMP(ts, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, w := range [][]float64{{1}, {1, -1}, {0, 0}, {1, math.NaN()}} {
+		if err = k.SetWeights(w); err == nil {
+			t.Errorf("Expected an error for weights %v", w)
+		}
+	}
+
+	w, err := k.InverseVarianceWeights()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w[0] < 10*w[1] {
+		t.Errorf("Expected the low variance dimension to be weighed higher, but got %v", w)
+	}
+	if err = k.SetWeights(w); err != nil {
+		t.Fatal(err)
+	}
+	if math.Abs(k.Weights[0]+k.Weights[1]-2) > 1e-9 {
+		t.Errorf("Expected weights normalized to a mean of 1, but got %v", k.Weights)
+	}
+
+	// ignoring the second dimension averages the first dimension's distances with zeros
+	if err = k.SetWeights([]float64{1, 0}); err != nil {
+		t.Fatal(err)
+	}
+	if err = k.Compute(); err != nil {
+		t.Fatal(err)
+	}
+	single, err := NewKMP(ts[:1], 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = single.Compute(); err != nil {
+		t.Fatal(err)
+	}
+	for i := range single.MP[0] {
+		if math.Abs(k.MP[1][i]-single.MP[0][i]) > 1e-7 || k.MP[0][i] != 0 {
+			t.Errorf("Expected %.6f and 0 at %d, but got %.6f and %.6f", single.MP[0][i], i, k.MP[1][i], k.MP[0][i])
+			break
+		}
+	}
+}
+
+func TestDiscoverMotifsMDL(t *testing.T) {
+	// a pattern recurs at 100 and 300 in the first and third dimensions only
+	ts := [][]float64{siggen.Noise(0.1, 500), siggen.Noise(1, 500), siggen.Noise(0.1, 500)}
+	pattern := siggen.Sin(1, 3, 0, 0, 100, 0.3)
+	for _, d := range []int{0, 2} {
+		for _, start := range []int{100, 300} {
+			for i, v := range pattern {
+				ts[d][start+i] += v
+			}
+		}
+	}
+
+	k, err := NewKMP(ts, 30)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = k.Compute(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = k.DiscoverMotifsMDL(1, 0); err == nil {
+		t.Errorf("Expected an error for 0 bits")
+	}
+
+	motifs, err := k.DiscoverMotifsMDL(2, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(motifs) != 2 {
+		t.Fatalf("Expected 2 motifs, but got %d", len(motifs))
+	}
+	m := motifs[0]
+	if len(m.Dims) != 2 || m.Dims[0] != 0 || m.Dims[1] != 2 {
+		t.Errorf("Expected the first motif to span dimensions [0 2], but got %v", m.Dims)
+	}
+	// the pair can be shifted together since shifts of the pattern still overlap it
+	idx := append([]int(nil), m.Idx...)
+	sort.Ints(idx)
+	if idx[1]-idx[0] != 200 || idx[0]+30 <= 100 || idx[0] >= 130 {
+		t.Errorf("Expected the first motif to overlap the patterns at 100 and 300, but got %v", m.Idx)
+	}
+	if len(motifs[1].Dims) == 0 {
+		t.Errorf("Expected the second motif to span at least one dimension")
+	}
+
+	ab, err := NewKMPAB(ts, ts, 30)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = ab.DiscoverMotifsMDL(1, 4); err == nil {
+		t.Errorf("Expected an error for an AB join")
+	}
+}
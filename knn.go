@@ -0,0 +1,119 @@
+package matrixprofile
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+
+	"github.com/matrix-profile-foundation/go-matrixprofile/util"
+	"gonum.org/v1/gonum/floats"
+)
+
+// KNN holds the k nearest subsequences of b for every subsequence of a.
+type KNN struct {
+	K    int
+	Idx  [][]int     // indices in b of the nearest subsequences to each subsequence of a, closest first
+	Dist [][]float64 // distances matching each index in Idx
+}
+
+// KNN finds for each subsequence of a its k nearest subsequences in b rather than only
+// the single best match kept in the matrix profile. Once a neighbor is chosen, the
+// subsequences within its exclusion zone are skipped so the neighbors are distinct
+// occurrences instead of trivially shifted copies of each other. Rows have fewer than
+// k entries when b doesn't hold that many distinct subsequences. Distances follow the
+// euclidean options of the matrix profile, and rows are processed in parallel across
+// the number of jobs in the options.
+func (mp *MatrixProfile) KNN(k int) (*KNN, error) {
+	return mp.nearestNeighbors(k, false)
+}
+
+// nearestNeighbors finds the k nearest distinct subsequences of b for every subsequence
+// of a. If leftOnly is set, only subsequences of a self join starting before each
+// subsequence are considered.
+func (mp *MatrixProfile) nearestNeighbors(k int, leftOnly bool) (*KNN, error) {
+	if leftOnly && !mp.SelfJoin {
+		return nil, errors.New("left only neighbors are only defined for self joins")
+	}
+	if k < 1 {
+		return nil, fmt.Errorf("number of neighbors, %d, must be at least 1", k)
+	}
+	if err := mp.initCaches(); err != nil {
+		return nil, err
+	}
+
+	njobs := 1
+	if mp.Opts != nil && mp.Opts.NJobs > 1 {
+		njobs = mp.Opts.NJobs
+	}
+
+	n := len(mp.A) - mp.W + 1
+	knn := &KNN{
+		K:    k,
+		Idx:  make([][]int, n),
+		Dist: make([][]float64, n),
+	}
+	exclZone := mp.exclusionZone(mp.W / 2)
+	batchSize := n/njobs + 1
+	errs := make([]error, njobs)
+
+	var wg sync.WaitGroup
+	wg.Add(njobs)
+	for batch := 0; batch < njobs; batch++ {
+		go func(batch int) {
+			defer wg.Done()
+			start := batch * batchSize
+			if start >= n {
+				return
+			}
+			end := start + batchSize
+			if end > n {
+				end = n
+			}
+
+			dot, err := mp.crossCorrelate(mp.A[start:start+mp.W], mp.newFFT())
+			if err != nil {
+				errs[batch] = err
+				return
+			}
+			profile := make([]float64, len(dot))
+			for i := start; i < end; i++ {
+				if i > start {
+					for j := len(dot) - 1; j > 0; j-- {
+						dot[j] = dot[j-1] - mp.B[j-1]*mp.A[i-1] + mp.B[j+mp.W-1]*mp.A[i+mp.W-1]
+					}
+					// the first cross correlation is not covered by the recurrence
+					dot[0] = floats.Dot(mp.A[i:i+mp.W], mp.B[:mp.W])
+				}
+				if err := mp.calculateDistanceProfile(dot, i, profile); err != nil {
+					errs[batch] = err
+					return
+				}
+				for j, d := range profile {
+					if math.IsNaN(d) || (leftOnly && j >= i) {
+						profile[j] = math.Inf(1)
+					}
+				}
+
+				for len(knn.Idx[i]) < k {
+					j := floats.MinIdx(profile)
+					if math.IsInf(profile[j], 1) {
+						break
+					}
+					knn.Idx[i] = append(knn.Idx[i], j)
+					knn.Dist[i] = append(knn.Dist[i], profile[j])
+					util.ApplyExclusionZone(profile, j, exclZone)
+					profile[j] = math.Inf(1)
+				}
+			}
+		}(batch)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+	return knn, nil
+}
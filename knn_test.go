@@ -0,0 +1,78 @@
+package matrixprofile
+
+import (
+	"math"
+	"testing"
+
+	"github.com/matrix-profile-foundation/go-matrixprofile/siggen"
+)
+
+func TestKNN(t *testing.T) {
+	a := siggen.Add(siggen.Sin(1, 4, 0, 0, 100, 1), siggen.Noise(0.1, 100))
+	b := siggen.Add(siggen.Sin(1, 4, 0, 0, 100, 2), siggen.Noise(0.1, 200))
+
+	testdata := []struct {
+		a           []float64
+		b           []float64
+		k           int
+		expectedErr bool
+	}{
+		{a, b, 0, true},
+		{a, b, 1, false},
+		{a, b, 3, false},
+		{b, nil, 4, false},
+	}
+
+	for _, d := range testdata {
+		mp, err := New(d.a, d.b, 20)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// matches the exclusion zone of MPX to the one used between neighbors
+		mp.ExclusionZone = mp.W / 2
+		o := NewMPOpts()
+		o.NJobs = 3
+		if err = mp.Compute(o); err != nil {
+			t.Fatal(err)
+		}
+
+		knn, err := mp.KNN(d.k)
+		if d.expectedErr {
+			if err == nil {
+				t.Errorf("Expected an error for k %d", d.k)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("Did not expect an error, %v, for k %d", err, d.k)
+		}
+
+		exclZone := mp.ExclusionZone
+		profile := make([]float64, len(mp.B)-mp.W+1)
+		for i := range knn.Idx {
+			if len(knn.Idx[i]) != d.k {
+				t.Fatalf("Expected %d neighbors for %d, but got %d", d.k, i, len(knn.Idx[i]))
+			}
+			if math.Abs(knn.Dist[i][0]-mp.MP[i]) > 1e-6 {
+				t.Errorf("Expected the nearest neighbor distance %.6f at %d, but got %.6f", mp.MP[i], i, knn.Dist[i][0])
+			}
+
+			if err = mp.distanceProfile(i, profile, mp.newFFT()); err != nil {
+				t.Fatal(err)
+			}
+			for n, j := range knn.Idx[i] {
+				if math.Abs(profile[j]-knn.Dist[i][n]) > 1e-6 {
+					t.Errorf("Expected distance %.6f to neighbor %d of %d, but got %.6f", profile[j], j, i, knn.Dist[i][n])
+				}
+				if n > 0 && knn.Dist[i][n] < knn.Dist[i][n-1] {
+					t.Errorf("Expected neighbors of %d sorted by distance, but got %v", i, knn.Dist[i])
+				}
+				for _, prev := range knn.Idx[i][:n] {
+					if j-prev < exclZone && prev-j <= exclZone {
+						t.Errorf("Expected neighbors of %d to be distinct occurrences, but got %v", i, knn.Idx[i])
+					}
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package matrixprofile
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/matrix-profile-foundation/go-matrixprofile/util"
+)
+
+// MadridOpts are parameters to vary the multi length discord search of Madrid.
+type MadridOpts struct {
+	LowerM int `json:"lower_m"` // shortest subsequence length searched
+	UpperM int `json:"upper_m"` // longest subsequence length searched
+	Step   int `json:"step"`    // increment between the subsequence lengths searched
+	Split  int `json:"split"`   // first point of the test region, the points before it are only used as reference
+	K      int `json:"k"`       // number of discords in the leaderboard across lengths
+}
+
+// NewMadridOpts returns a default MadridOpts searching every subsequence length from l
+// to u for discords ending after the split point.
+func NewMadridOpts(l, u, split int) *MadridOpts {
+	if l > u {
+		u = l
+	}
+	return &MadridOpts{
+		LowerM: l,
+		UpperM: u,
+		Step:   1,
+		Split:  split,
+		K:      5,
+	}
+}
+
+// MadridResult holds the discords found by Madrid.
+type MadridResult struct {
+	Windows     []int        // subsequence lengths searched in ascending order
+	Discords    []PanDiscord // best discord at each subsequence length, aligned with Windows, with an Idx of -1 when every subsequence is flat
+	Leaderboard []PanDiscord // best discords across lengths in descending order of normalized distance, skipping any overlapping a higher ranked one
+}
+
+// Madrid searches for discords across a range of subsequence lengths in one call,
+// following MADRID, as a single subsequence length routinely misses short spikes or
+// long drifts. Each subsequence ending in the test region, after the split point, is
+// scored by its distance to its nearest neighbor entirely before it, as in DAMP, so
+// the best discord at each length is a pattern never seen before. The lengths are
+// searched in ascending order and each search starts from the exact score of the
+// previous length's discord at the new length, a lower bound of the best score that
+// lets DAMP abandon most subsequences right away. Distances are normalized by
+// 2*sqrt(w) so the discords of all lengths are ranked together in the leaderboard.
+func Madrid(ts []float64, o *MadridOpts) (*MadridResult, error) {
+	if o == nil {
+		return nil, errors.New("must provide Madrid options")
+	}
+	if o.LowerM < 2 || o.UpperM < o.LowerM {
+		return nil, fmt.Errorf("subsequence lengths, %d to %d, must be increasing from at least 2", o.LowerM, o.UpperM)
+	}
+	if o.Step < 1 {
+		return nil, fmt.Errorf("step between subsequence lengths, %d, must be at least 1", o.Step)
+	}
+	if o.Split < 2*o.UpperM || o.Split >= len(ts) {
+		return nil, fmt.Errorf("split point, %d, must leave at least twice the upper subsequence length, %d, before it and a point after it", o.Split, o.UpperM)
+	}
+	if o.K < 1 {
+		return nil, fmt.Errorf("number of discords, %d, must be at least 1", o.K)
+	}
+
+	res := &MadridResult{}
+	for w := o.LowerM; w <= o.UpperM; w += o.Step {
+		res.Windows = append(res.Windows, w)
+	}
+	res.Discords = make([]PanDiscord, len(res.Windows))
+
+	prev := -1
+	for n, w := range res.Windows {
+		d, err := NewDAMP(ts[:o.Split], w)
+		if err != nil {
+			return nil, err
+		}
+		if prev >= 0 && prev+w <= len(ts) && prev+w > o.Split {
+			d.Discord, d.DiscordVal = prev, leftDistance(ts, prev, w, d.ffts)
+			if math.IsInf(d.DiscordVal, 1) {
+				d.Discord, d.DiscordVal = -1, 0
+			}
+		}
+		for _, v := range ts[o.Split:] {
+			if _, err = d.Update(v); err != nil {
+				return nil, err
+			}
+		}
+
+		res.Discords[n] = PanDiscord{Idx: d.Discord, W: w}
+		if d.Discord >= 0 {
+			res.Discords[n].Dist = d.DiscordVal / (2 * math.Sqrt(float64(w)))
+			prev = d.Discord
+		}
+	}
+
+	candidates := make([]PanDiscord, 0, len(res.Discords))
+	for _, c := range res.Discords {
+		if c.Idx >= 0 {
+			candidates = append(candidates, c)
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].Dist > candidates[j].Dist
+	})
+	for _, c := range candidates {
+		if len(res.Leaderboard) == o.K {
+			break
+		}
+		overlaps := false
+		for _, l := range res.Leaderboard {
+			if c.Idx < l.Idx+l.W && l.Idx < c.Idx+c.W {
+				overlaps = true
+				break
+			}
+		}
+		if !overlaps {
+			res.Leaderboard = append(res.Leaderboard, c)
+		}
+	}
+	return res, nil
+}
+
+// leftDistance returns the z-normalized distance between the subsequence of length w
+// at idx and its nearest neighbor entirely before it, or +Inf if it is flat or has
+// none. The fourier transform caches are shared with a DAMP of the same length.
+func leftDistance(ts []float64, idx, w int, ffts map[int]*fftCache) float64 {
+	q, err := util.ZNormalize(ts[idx : idx+w])
+	if err != nil || idx < w {
+		return math.Inf(1)
+	}
+	past := &DAMP{W: w, ts: make([]float64, 0, idx), ffts: ffts}
+	for _, v := range ts[:idx] {
+		past.push(v)
+	}
+	return past.chunkMin(q, 0, idx)
+}
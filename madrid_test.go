@@ -0,0 +1,78 @@
+package matrixprofile
+
+import (
+	"math"
+	"testing"
+
+	"github.com/matrix-profile-foundation/go-matrixprofile/siggen"
+	"gonum.org/v1/gonum/floats"
+)
+
+func TestMadrid(t *testing.T) {
+	sig := siggen.Add(siggen.Sin(1, 4, 0, 0, 100, 8), siggen.Noise(0.05, 800))
+	// a short spike and a long drift in the test region
+	sig[450] += 2
+	for i := 0; i < 60; i++ {
+		sig[600+i] += 0.6 * math.Sin(math.Pi*float64(i)/60)
+	}
+	split := 250
+
+	testdata := []struct {
+		opts        *MadridOpts
+		expectedErr bool
+	}{
+		{nil, true},
+		{NewMadridOpts(1, 10, split), true},
+		{&MadridOpts{LowerM: 10, UpperM: 20, Step: 0, Split: split, K: 3}, true},
+		{NewMadridOpts(10, 200, split), true},
+		{NewMadridOpts(10, 20, len(sig)), true},
+		{&MadridOpts{LowerM: 10, UpperM: 20, Step: 1, Split: split, K: 0}, true},
+	}
+	for _, d := range testdata {
+		if _, err := Madrid(sig, d.opts); err == nil {
+			t.Errorf("Expected an error for options %+v", d.opts)
+		}
+	}
+
+	o := NewMadridOpts(8, 80, split)
+	o.Step = 24
+	res, err := Madrid(sig, o)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Windows) != 4 || len(res.Discords) != 4 {
+		t.Fatalf("Expected 4 subsequence lengths, but got %v and %v", res.Windows, res.Discords)
+	}
+
+	for n, w := range res.Windows {
+		// the best distance of a subsequence ending in the test region to its nearest
+		// neighbor entirely before it
+		best := 0.0
+		for i := split + 1 - w; i+w <= len(sig); i++ {
+			best = math.Max(best, floats.Min(bruteDistanceProfile(sig[i:i+w], sig[:i])))
+		}
+		d := res.Discords[n]
+		if d.W != w || math.Abs(d.Dist*2*math.Sqrt(float64(w))-best) > 1e-6 {
+			t.Errorf("Expected the best discord of length %d with %.6f, but got %+v", w, best, d)
+		}
+		left := floats.Min(bruteDistanceProfile(sig[d.Idx:d.Idx+w], sig[:d.Idx]))
+		if math.Abs(left-best) > 1e-6 {
+			t.Errorf("Expected the discord of length %d at %d to score %.6f, but it scores %.6f", w, d.Idx, best, left)
+		}
+	}
+
+	if len(res.Leaderboard) < 2 {
+		t.Fatalf("Expected the spike and the drift in the leaderboard, but got %v", res.Leaderboard)
+	}
+	var spike, drift bool
+	for i, d := range res.Leaderboard {
+		spike = spike || d.Idx <= 450 && d.Idx+d.W > 450
+		drift = drift || d.Idx < 660 && d.Idx+d.W > 600
+		if i > 0 && d.Dist > res.Leaderboard[i-1].Dist {
+			t.Errorf("Expected the leaderboard in descending order, but got %v", res.Leaderboard)
+		}
+	}
+	if !spike || !drift {
+		t.Errorf("Expected both the spike and the drift in the leaderboard, but got %v", res.Leaderboard)
+	}
+}
@@ -0,0 +1,131 @@
+package matrixprofile
+
+import (
+	"math"
+
+	"github.com/matrix-profile-foundation/go-matrixprofile/util"
+)
+
+// manhattan returns whether the matrix profile compares subsequences with the L1
+// distance rather than the euclidean distance, so distance profiles used after the
+// computation, such as for motifs and streaming updates, agree with it.
+func (mp MatrixProfile) manhattan() bool {
+	return mp.Opts != nil && mp.Opts.Manhattan
+}
+
+// manhattanJoin computes the matrix profile with the L1 distance, the sum of the
+// absolute differences between two subsequences, which a single spike can't dominate
+// the way it does the euclidean distance. There is no fourier transform shortcut for
+// it, so every diagonal of the distance matrix is visited. The plain distances of AAMP
+// and ACAMP keep a running sum along each diagonal in O(n^2) time, while z-normalized
+// distances are summed for every pair in O(n^2*w) time.
+func (mp *MatrixProfile) manhattanJoin() error {
+	if err := mp.initCaches(); err != nil {
+		return err
+	}
+
+	lenA := len(mp.A) - mp.W + 1
+	lenB := len(mp.B) - mp.W + 1
+	mp.MP = make([]float64, lenB)
+	mp.Idx = make([]int, lenB)
+	for i := 0; i < lenB; i++ {
+		mp.MP[i] = math.Inf(1)
+		mp.Idx[i] = math.MaxInt64
+	}
+
+	// diagonals are visited the same way as ACAMP
+	first, count := 1, lenA-1
+	if !mp.SelfJoin {
+		first, count = 1-lenA, lenA+lenB-1
+	}
+	batchScheme := util.DiagBatchingScheme(count, mp.Opts.NJobs)
+	return mp.runBatches(func(batch int) *mpResult {
+		b := batchScheme[batch]
+		end := b.Idx + b.Size
+		if end > count {
+			end = count
+		}
+		return mp.manhattanBatch(first+b.Idx, first+end, lenB)
+	})
+}
+
+// manhattanBatch processes the diagonals from start up to end, where diagonal k
+// compares the subsequence of a at i with the subsequence of b at i+k.
+func (mp MatrixProfile) manhattanBatch(start, end, lenB int) *mpResult {
+	lenA := len(mp.A) - mp.W + 1
+	result := newBatchResult(lenB)
+	zone := mp.exclusionZone(mp.W / 2)
+	raw := mp.nonNormalized()
+
+	for k := start; k < end; k++ {
+		i0, j0 := 0, k
+		if k < 0 {
+			i0, j0 = -k, 0
+		}
+
+		var sum float64
+		if raw {
+			sum = mp.manhattanDistance(i0, j0)
+		}
+		for i, j := i0, j0; i < lenA && j < lenB; i, j = i+1, j+1 {
+			var dist float64
+			if raw {
+				if i > i0 {
+					sum += math.Abs(mp.A[i+mp.W-1]-mp.B[j+mp.W-1]) - math.Abs(mp.A[i-1]-mp.B[j-1])
+				}
+				// rounding can take the distance of identical subsequences below 0
+				dist = math.Max(sum, 0)
+			} else {
+				dist = mp.manhattanDistance(i, j)
+			}
+
+			if mp.SelfJoin {
+				mp.updatePair(result, dist, i, j, zone)
+			} else if isBetterMatch(dist, i, result.MP[j], result.Idx[j], true) {
+				result.MP[j] = dist
+				result.Idx[j] = i
+			}
+		}
+	}
+	return result
+}
+
+// manhattanDistance returns the L1 distance between the subsequence of a at i and the
+// subsequence of b at j, z-normalizing both first unless the distances are
+// non-normalized. A z-normalized distance involving a flat subsequence is +Inf.
+func (mp MatrixProfile) manhattanDistance(i, j int) float64 {
+	a, b := mp.A[i:i+mp.W], mp.B[j:j+mp.W]
+
+	var sum float64
+	if mp.nonNormalized() {
+		for t := range a {
+			sum += math.Abs(a[t] - b[t])
+		}
+		return sum
+	}
+
+	if !(mp.AStd[i] > 0 && mp.BStd[j] > 0) {
+		return math.Inf(1)
+	}
+	sa, sb := 1/mp.AStd[i], 1/mp.BStd[j]
+	for t := range a {
+		sum += math.Abs((a[t]-mp.AMean[i])*sa - (b[t]-mp.BMean[j])*sb)
+	}
+	return sum
+}
+
+// manhattanProfile writes the L1 distance between the subsequence of a at idx and
+// every subsequence of b to profile. Distances above the maximum distance of the
+// options are +Inf.
+func (mp MatrixProfile) manhattanProfile(idx int, profile []float64) {
+	limit := math.Inf(1)
+	if mp.Opts.MaxDistance > 0 {
+		limit = mp.Opts.MaxDistance
+	}
+	for j := range profile[:len(mp.B)-mp.W+1] {
+		profile[j] = mp.manhattanDistance(idx, j)
+		if profile[j] > limit {
+			profile[j] = math.Inf(1)
+		}
+	}
+}
@@ -0,0 +1,195 @@
+package matrixprofile
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/matrix-profile-foundation/go-matrixprofile/util"
+)
+
+// bruteManhattanProfile computes the L1 matrix profile of every subsequence of b
+// against the subsequences of a, excluding trivial matches of self joins.
+func bruteManhattanProfile(a, b []float64, w int, selfJoin, raw bool) []float64 {
+	sub := func(ts []float64, i int) []float64 {
+		if raw {
+			return ts[i : i+w]
+		}
+		out, err := util.ZNormalize(ts[i : i+w])
+		if err != nil {
+			return nil
+		}
+		return out
+	}
+
+	zone := w / 2
+	out := make([]float64, len(b)-w+1)
+	for j := range out {
+		out[j] = math.Inf(1)
+		sb := sub(b, j)
+		for i := 0; i+w <= len(a); i++ {
+			sa := sub(a, i)
+			if (selfJoin && j >= i-zone && j < i+zone) || sa == nil || sb == nil {
+				continue
+			}
+			var d float64
+			for k := 0; k < w; k++ {
+				d += math.Abs(sa[k] - sb[k])
+			}
+			out[j] = math.Min(out[j], d)
+		}
+	}
+	return out
+}
+
+func TestComputeManhattan(t *testing.T) {
+	r := rand.New(rand.NewSource(3))
+	a := make([]float64, 160)
+	b := make([]float64, 90)
+	for i := range a {
+		a[i] = math.Sin(float64(i)/5) + 0.2*r.NormFloat64() + float64(i/80)*5
+	}
+	for i := range b {
+		b[i] = r.NormFloat64()
+	}
+	// a flat stretch has no z-normalized distance to anything
+	for i := 100; i < 120; i++ {
+		a[i] = 1
+	}
+
+	testdata := []struct {
+		b     []float64
+		algo  Algo
+		njobs int
+	}{
+		{nil, AlgoSTOMP, 1},
+		{nil, AlgoMPX, 3},
+		{b, AlgoSTOMP, 2},
+		{nil, AlgoAAMP, 1},
+		{nil, AlgoACAMP, 4},
+		{b, AlgoAAMP, 3},
+	}
+
+	for i, d := range testdata {
+		mp, err := New(a, d.b, 12)
+		if err != nil {
+			t.Fatal(err)
+		}
+		o := NewMPOpts()
+		o.Algorithm = d.algo
+		o.NJobs = d.njobs
+		o.Manhattan = true
+		if err = mp.Compute(o); err != nil {
+			t.Fatal(err)
+		}
+
+		expected := bruteManhattanProfile(a, mp.B, 12, mp.SelfJoin, mp.nonNormalized())
+		if len(mp.MP) != len(expected) {
+			t.Fatalf("Expected %d elements, but got %d for case %d", len(expected), len(mp.MP), i)
+		}
+		for j := range expected {
+			if math.Abs(mp.MP[j]-expected[j]) > 1e-6 && !(math.IsInf(mp.MP[j], 1) && math.IsInf(expected[j], 1)) {
+				t.Errorf("Expected %.6f at %d, but got %.6f for case %d", expected[j], j, mp.MP[j], i)
+				break
+			}
+		}
+
+		// distance profiles used after the computation agree with the matrix profile
+		profile := make([]float64, len(mp.B)-mp.W+1)
+		for _, j := range []int{0, 40, len(mp.A) - mp.W} {
+			if err = mp.distanceProfile(j, profile, nil); err != nil {
+				t.Fatal(err)
+			}
+			for k, v := range profile {
+				if v < mp.MP[k]-1e-6 {
+					t.Errorf("Expected no distance below the matrix profile, %.6f, but got %.6f at %d from %d for case %d", mp.MP[k], v, k, j, i)
+					break
+				}
+			}
+		}
+	}
+}
+
+func TestComputeManhattanSpike(t *testing.T) {
+	// two noisy copies of a pattern, one of them hit by a single spike, and a third
+	// copy distorted a little everywhere. The spike dominates the plain euclidean
+	// distance but counts for a single point of the L1 distance.
+	r := rand.New(rand.NewSource(9))
+	w := 40
+	pattern := make([]float64, w)
+	for i := range pattern {
+		pattern[i] = math.Sin(float64(i) * 2 * math.Pi / float64(w))
+	}
+	ts := make([]float64, 400)
+	for i := range ts {
+		ts[i] = r.NormFloat64()
+	}
+	for i := 0; i < w; i++ {
+		ts[50+i] = 3 * pattern[i]
+		ts[200+i] = 3 * pattern[i]
+		ts[320+i] = 3*pattern[i] + 0.9*math.Sin(float64(i))
+	}
+	ts[220] += 15
+
+	nearest := func(manhattan bool) int {
+		mp, err := New(ts, nil, w)
+		if err != nil {
+			t.Fatal(err)
+		}
+		o := NewMPOpts()
+		o.Algorithm = AlgoAAMP
+		o.Manhattan = manhattan
+		if err = mp.Compute(o); err != nil {
+			t.Fatal(err)
+		}
+		return mp.Idx[50]
+	}
+
+	if idx := nearest(false); idx != 320 {
+		t.Fatalf("Expected the euclidean nearest neighbor of the pattern to avoid the spike at 320, but got %d", idx)
+	}
+	if idx := nearest(true); idx != 200 {
+		t.Errorf("Expected the manhattan nearest neighbor of the pattern to be the spiked copy at 200, but got %d", idx)
+	}
+}
+
+func TestUpdateManhattan(t *testing.T) {
+	r := rand.New(rand.NewSource(4))
+	sig := make([]float64, 220)
+	for i := range sig {
+		sig[i] = math.Sin(float64(i)/4) + 0.3*r.NormFloat64()
+	}
+
+	for _, algo := range []Algo{AlgoMPX, AlgoAAMP} {
+		mp, err := New(append([]float64{}, sig[:60]...), nil, 16)
+		if err != nil {
+			t.Fatal(err)
+		}
+		o := NewMPOpts()
+		o.Algorithm = algo
+		o.Manhattan = true
+		if err = mp.Compute(o); err != nil {
+			t.Fatal(err)
+		}
+		for i := 60; i < len(sig); i += 7 {
+			end := i + 7
+			if end > len(sig) {
+				end = len(sig)
+			}
+			if err = mp.Update(sig[i:end]); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		expected := bruteManhattanProfile(sig, sig, 16, true, algo == AlgoAAMP)
+		if len(mp.MP) != len(expected) {
+			t.Fatalf("Expected %d elements, but got %d with %s", len(expected), len(mp.MP), algo)
+		}
+		for j := range expected {
+			if math.Abs(mp.MP[j]-expected[j]) > 1e-6 {
+				t.Errorf("Expected %.6f at %d, but got %.6f with %s", expected[j], j, mp.MP[j], algo)
+				break
+			}
+		}
+	}
+}
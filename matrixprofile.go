@@ -3,6 +3,7 @@ package matrixprofile
 
 import (
 	"container/heap"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -14,8 +15,10 @@ import (
 	"sort"
 	"strconv"
 	"sync"
+	"time"
 
 	"github.com/matrix-profile-foundation/go-matrixprofile/av"
+	"github.com/matrix-profile-foundation/go-matrixprofile/storage"
 	"github.com/matrix-profile-foundation/go-matrixprofile/util"
 	"gonum.org/v1/gonum/dsp/fourier"
 	"gonum.org/v1/gonum/floats"
@@ -42,8 +45,23 @@ type MatrixProfile struct {
 	IdxB     []int        `json:"pi_ba"`             // matrix profile index for the BA join
 	AV       av.AV        `json:"annotation_vector"` // type of annotation vector which defaults to all ones
 	Opts     *MPOpts      `json:"options"`           // options used for the computation
+	Stream   *StreamOpts  `json:"stream_options"`    // options used when streaming new values with Update
+	Offset   int          `json:"offset"`            // number of leading points retired from the timeseries while streaming
+	Mask     []bool       `json:"mask"`              // subsequences of a excluded from motif and discord discovery
 	Motifs   []MotifGroup
 	Discords []int
+
+	ExclusionZone int           `json:"exclusion_zone"` // trivial match exclusion zone for self joins. Defaults to 0 which uses each algorithm's own zone
+	TimeIndex     []time.Time   `json:"time_index"`     // optional timestamp of each point in a
+	Normalization Normalization `json:"normalization"`  // how subsequences are normalized before being compared
+	RawA          []float64     `json:"raw_a"`          // timeseries a before the options transform was applied, nil without a transform
+	RawB          []float64     `json:"raw_b"`          // timeseries b before the options transform was applied, nil without a transform or for self joins
+	RawTimeIndex  []time.Time   `json:"raw_time_index"` // time index of a before the options transform was applied, nil without a transform
+	CustomAV      []float64     `json:"custom_av"`      // annotation vector over the subsequences of a used in place of AV when set, such as one built with av.Compose
+
+	mpxStream *mpxStream    // incremental MPX state of a self join kept across updates
+	stampi    *stampiStream // incremental STAMPI state of a self join kept across updates
+	mpxStats  *mpxStats     // sliding statistics of the last MPX self join, reused by motif discovery
 }
 
 // New creates a matrix profile struct with a given timeseries length n and
@@ -81,35 +99,47 @@ func New(a, b []float64, w int) (*MatrixProfile, error) {
 	}
 
 	mp.AV = av.Default
+	mp.Normalization = NormZ
 
 	return &mp, nil
 }
 
-func applySingleAV(mp, ts []float64, w int, a av.AV) ([]float64, error) {
-	avec, err := av.Create(a, ts, w)
-	if err != nil {
+// annotationVector returns the custom annotation vector if set, otherwise the one
+// created from the timeseries, checking it covers the n values of the matrix profile.
+func annotationVector(ts []float64, w, n int, a av.AV, custom []float64) ([]float64, error) {
+	avec := custom
+	if avec == nil {
+		var err error
+		if avec, err = av.Create(a, ts, w); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(avec) != n {
+		return nil, fmt.Errorf("annotation vector length, %d, does not match matrix profile length, %d", len(avec), n)
+	}
+
+	// check that all annotation vector values are between 0 and 1
+	if err := av.Validate(avec); err != nil {
 		return nil, err
 	}
+	return avec, nil
+}
 
-	if len(avec) != len(mp) {
-		return nil, fmt.Errorf("annotation vector length, %d, does not match matrix profile length, %d", len(avec), len(mp))
+func applySingleAV(mp, ts []float64, w int, a av.AV, custom []float64) ([]float64, error) {
+	avec, err := annotationVector(ts, w, len(mp), a, custom)
+	if err != nil {
+		return nil, err
 	}
 
 	// find the maximum matrix profile value
 	maxMP := 0.0
 	for _, val := range mp {
-		if val > maxMP {
+		if !math.IsInf(val, 1) && val > maxMP {
 			maxMP = val
 		}
 	}
 
-	// check that all annotation vector values are between 0 and 1
-	for idx, val := range avec {
-		if val < 0.0 || val > 1.0 {
-			return nil, fmt.Errorf("got an annotation vector value of %.3f at index %d. must be between 0 and 1", val, idx)
-		}
-	}
-
 	// applies the matrix profile correction. 1 results in no change to the matrix profile and
 	// 0 results in lifting the current matrix profile value by the maximum matrix profile value
 	out := make([]float64, len(mp))
@@ -120,9 +150,52 @@ func applySingleAV(mp, ts []float64, w int, a av.AV) ([]float64, error) {
 	return out, nil
 }
 
-// ApplyAV applies an annotation vector to the current matrix profile. Annotation vector
-// values must be between 0 and 1.
-func (mp MatrixProfile) ApplyAV() ([]float64, []float64, error) {
+// applyDiscordAV applies the annotation vector to a profile scored for discords by
+// scaling each value with it. Lifting low annotations towards the maximum, as done for
+// motifs, would turn the regions marked uninteresting into the top discords, so they
+// sink towards 0 instead. Undefined values are kept.
+func applyDiscordAV(profile, ts []float64, w int, a av.AV, custom []float64) ([]float64, error) {
+	avec, err := annotationVector(ts, w, len(profile), a, custom)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]float64, len(profile))
+	for idx, val := range profile {
+		out[idx] = val
+		if !math.IsInf(val, 0) {
+			out[idx] *= avec[idx]
+		}
+	}
+	return out, nil
+}
+
+// discordMP returns the euclidean matrix profile with the annotation vector applied
+// for discords and the mask applied.
+func (mp MatrixProfile) discordMP() ([]float64, error) {
+	out, err := applyDiscordAV(mp.euclideanMP(), mp.A, mp.W, mp.AV, mp.CustomAV)
+	if err != nil {
+		return nil, err
+	}
+	mp.applyMask(out)
+	return out, nil
+}
+
+// ApplyAV applies an annotation vector to the current matrix profile, lifting values
+// annotated below 1 towards the maximum so motif discovery avoids them, following the
+// guided motif search paper. Annotation vector values must be between 0 and 1, one
+// per subsequence of a. Non-nil weights replace CustomAV, so later motif, discord and
+// segmentation calls operate on the corrected matrix profile as well, while nil
+// weights apply CustomAV if set or the AV of the matrix profile otherwise. Either is
+// validated before it is applied. Discord discovery scales the matrix profile by the
+// annotation vector instead, see DiscoverDiscords.
+func (mp *MatrixProfile) ApplyAV(weights []float64) ([]float64, []float64, error) {
+	custom := mp.CustomAV
+	if weights != nil {
+		custom = make([]float64, len(weights))
+		copy(custom, weights)
+	}
+
 	var err error
 	abmp := make([]float64, len(mp.MP))
 	bamp := make([]float64, len(mp.MPB))
@@ -134,13 +207,13 @@ func (mp MatrixProfile) ApplyAV() ([]float64, []float64, error) {
 		util.P2E(bamp, mp.W)
 	}
 
-	abmp, err = applySingleAV(abmp, mp.A, mp.W, mp.AV)
+	abmp, err = applySingleAV(abmp, mp.A, mp.W, mp.AV, custom)
 	if err != nil {
 		return nil, nil, err
 	}
 
 	if mp.MPB != nil {
-		bamp, err = applySingleAV(bamp, mp.B, mp.W, mp.AV)
+		bamp, err = applySingleAV(bamp, mp.B, mp.W, mp.AV, nil)
 	}
 
 	if err != nil {
@@ -152,9 +225,140 @@ func (mp MatrixProfile) ApplyAV() ([]float64, []float64, error) {
 		util.E2P(bamp, mp.W)
 	}
 
+	mp.CustomAV = custom
 	return abmp, bamp, nil
 }
 
+// euclideanMP returns a copy of the matrix profile as euclidean distances
+// regardless of whether the computation stored pearson correlations or squared
+// distances.
+func (mp MatrixProfile) euclideanMP() []float64 {
+	out := make([]float64, len(mp.MP))
+	copy(out, mp.MP)
+	if mp.Opts != nil && !mp.Opts.Euclidean {
+		util.P2E(out, mp.W)
+	}
+	if mp.squared() {
+		for i, d := range out {
+			out[i] = math.Sqrt(d)
+		}
+	}
+	return out
+}
+
+// squared returns whether distances are kept as squared euclidean distances.
+func (mp MatrixProfile) squared() bool {
+	return mp.Opts != nil && mp.Opts.Squared
+}
+
+// noiseVar returns the scaled noise variance, (w+1)*noiseStd^2, used for the noise
+// correction or 0 if no correction is applied.
+func (mp MatrixProfile) noiseVar() float64 {
+	if mp.Opts == nil || mp.Opts.NoiseStd <= 0 {
+		return 0
+	}
+	return float64(mp.W+1) * mp.Opts.NoiseStd * mp.Opts.NoiseStd
+}
+
+// squaredLimit returns the maximum distance of the options as a squared euclidean
+// distance, or +Inf if every distance is kept.
+func (mp MatrixProfile) squaredLimit() float64 {
+	if mp.Opts == nil || mp.Opts.MaxDistance <= 0 {
+		return math.Inf(1)
+	}
+	if mp.squared() {
+		return mp.Opts.MaxDistance
+	}
+	return mp.Opts.MaxDistance * mp.Opts.MaxDistance
+}
+
+// minCorrelation returns the smallest pearson correlation within the maximum distance
+// of the options, or -Inf if every distance is kept.
+func (mp MatrixProfile) minCorrelation() float64 {
+	return 1 - mp.squaredLimit()/(2*float64(mp.W))
+}
+
+// finishDistance converts a squared euclidean distance into the distance kept by the
+// matrix profile, skipping the square root of distances above the squared limit and
+// returning +Inf for them instead.
+func finishDistance(sq, limit float64, squared bool) float64 {
+	switch {
+	case sq > limit:
+		return math.Inf(1)
+	case squared:
+		return sq
+	}
+	return math.Sqrt(sq)
+}
+
+// applyMaxDistance clears the matches above the maximum distance of the options from
+// the matrix profiles, leaving +Inf and no index, so every algorithm reports the same
+// matches within the radius.
+func (mp *MatrixProfile) applyMaxDistance() {
+	if mp.Opts == nil || mp.Opts.MaxDistance <= 0 {
+		return
+	}
+	limitProfile := func(profile []float64, idx []int) {
+		for i, d := range profile {
+			if !(d <= mp.Opts.MaxDistance) {
+				profile[i] = math.Inf(1)
+				idx[i] = math.MaxInt64
+			}
+		}
+	}
+	limitProfile(mp.MP, mp.Idx)
+	if mp.MPB != nil {
+		limitProfile(mp.MPB, mp.IdxB)
+	}
+}
+
+// finishProfile converts a distance profile of squared euclidean distances in place
+// into the distances kept by the matrix profile. If a noise standard deviation is set,
+// the expected contribution of the noise, 2(w+1)noiseStd^2/max(stdA, stdB)^2, is
+// subtracted from each squared distance. The square root is then taken unless
+// squared distances are requested, and distances above the maximum distance of the
+// options are set to +Inf.
+func (mp MatrixProfile) finishProfile(profile []float64, stdA float64, stdB []float64) {
+	if noise := mp.noiseVar(); noise > 0 {
+		for i := range profile {
+			s := math.Max(stdA, stdB[i])
+			profile[i] -= 2 * noise / (s * s)
+			if profile[i] < 0 {
+				profile[i] = 0
+			}
+		}
+	}
+
+	limit, squared := mp.squaredLimit(), mp.squared()
+	for i := range profile {
+		profile[i] = finishDistance(profile[i], limit, squared)
+	}
+}
+
+// applyMask sets the masked entries of a profile indexed by the subsequences of a to
+// +Inf so they are excluded from discovery. Entries beyond the length of the mask are
+// left untouched.
+func (mp MatrixProfile) applyMask(profile []float64) {
+	for i, masked := range mp.Mask {
+		if i >= len(profile) {
+			break
+		}
+		if masked {
+			profile[i] = math.Inf(1)
+		}
+	}
+}
+
+// p2e converts pearson correlations in place to euclidean distances, or squared
+// euclidean distances if set in the options.
+func (mp MatrixProfile) p2e(profile []float64) {
+	if mp.squared() {
+		util.P2ESquared(profile, mp.W)
+		return
+	}
+	util.P2E(profile, mp.W)
+}
+
 // Save will save the current matrix profile struct to disk
 func (mp MatrixProfile) Save(filepath, format string) error {
 	var err error
@@ -200,6 +404,39 @@ func (mp *MatrixProfile) Load(filepath, format string) error {
 	return err
 }
 
+// SaveObject will save the current matrix profile struct to an object store under a
+// bucket and key, uploading it in parts when it is larger than the part size so large
+// profiles can be archived. A part size of 0 uses storage.DefaultPartSize, and S3
+// stores need at least storage.MinPartSize.
+func (mp MatrixProfile) SaveObject(ctx context.Context, s storage.Store, bucket, key, format string, partSize int) error {
+	switch format {
+	case "json":
+		out, err := json.Marshal(mp)
+		if err != nil {
+			return err
+		}
+		return storage.Upload(ctx, s, bucket, key, out, partSize)
+	default:
+		return fmt.Errorf("invalid save format, %s", format)
+	}
+}
+
+// LoadObject will attempt to load a matrix profile from an object store for iterative use
+func (mp *MatrixProfile) LoadObject(ctx context.Context, s storage.Store, bucket, key, format string) error {
+	switch format {
+	case "json":
+		b, err := storage.Download(ctx, s, bucket, key)
+		if err != nil {
+			return err
+		}
+		return json.Unmarshal(b, mp)
+	default:
+		return fmt.Errorf("invalid load format, %s", format)
+	}
+}
+
+// mpVals is a max heap of matrix profile values used to track the lowest k values
+// with the largest of them at the root.
 type mpVals []float64
 
 func (m mpVals) Len() int {
@@ -211,7 +448,7 @@ func (m mpVals) Swap(i, j int) {
 }
 
 func (m mpVals) Less(i, j int) bool {
-	return m[i] < m[j]
+	return m[i] > m[j]
 }
 
 // Push implements the function in the heap interface
@@ -226,11 +463,17 @@ func (m *mpVals) Pop() interface{} {
 	return x
 }
 
+// mpdistThresh is the fraction of the combined length of both timeseries used by MPdist
+// to pick the reported distance out of the joined matrix profiles.
+const mpdistThresh = 0.05
+
+// MPDistOpts are parameters to vary the matrix profile distance measure.
 type MPDistOpts struct {
-	AV   av.AV
-	Opts *MPOpts
+	AV   av.AV   // annotation vector applied to both joined matrix profiles
+	Opts *MPOpts // options of the AB join between the two timeseries
 }
 
+// NewMPDistOpts returns a default MPDistOpts
 func NewMPDistOpts() *MPDistOpts {
 	return &MPDistOpts{
 		AV:   av.Default,
@@ -238,8 +481,11 @@ func NewMPDistOpts() *MPDistOpts {
 	}
 }
 
-// MPDist computes the matrix profile distance measure between a and b with a
-// subsequence window of m.
+// MPDist computes the matrix profile distance measure, MPdist, between a and b with a
+// subsequence window of w. The AB and BA joins are concatenated and the distance is
+// the value at 5% of the combined length of both timeseries in ascending order, or the
+// largest value when the joins are shorter than that, so two series sharing most of
+// their subsequences are close even when the subsequences occur in different orders.
 func MPDist(a, b []float64, w int, o *MPDistOpts) (float64, error) {
 	if o == nil {
 		o = NewMPDistOpts()
@@ -249,18 +495,24 @@ func MPDist(a, b []float64, w int, o *MPDistOpts) (float64, error) {
 	if err != nil {
 		return 0, err
 	}
+	mp.AV = o.AV
 
 	if err = mp.Compute(o.Opts); err != nil {
-		return 0, nil
+		return 0, err
 	}
 
-	mpab, mpba, err := mp.ApplyAV()
+	return mp.mpDist()
+}
+
+// mpDist picks the matrix profile distance out of a computed AB join after
+// applying the annotation vector.
+func (mp MatrixProfile) mpDist() (float64, error) {
+	mpab, mpba, err := mp.ApplyAV(nil)
 	if err != nil {
-		return 0, nil
+		return 0, err
 	}
 
-	thresh := 0.05
-	k := int(thresh * float64(len(a)+len(b)))
+	k := int(mpdistThresh * float64(len(mp.A)+len(mp.B)))
 	mpABBASize := len(mpab) + len(mpba)
 
 	if k < mpABBASize {
@@ -310,7 +562,7 @@ func MPDist(a, b []float64, w int, o *MPDistOpts) (float64, error) {
 		trackVal = 1
 	}
 
-	for _, d := range mp.MP {
+	for _, d := range mpab {
 		if mp.Opts.Euclidean {
 			if d > trackVal {
 				trackVal = d
@@ -322,7 +574,7 @@ func MPDist(a, b []float64, w int, o *MPDistOpts) (float64, error) {
 		}
 	}
 
-	for _, d := range mp.MPB {
+	for _, d := range mpba {
 		if mp.Opts.Euclidean {
 			if d > trackVal {
 				trackVal = d
@@ -337,22 +589,294 @@ func MPDist(a, b []float64, w int, o *MPDistOpts) (float64, error) {
 	return trackVal, nil
 }
 
+// MPDistMatrix computes the pairwise matrix profile distance between every pair
+// of timeseries in series with a subsequence window of w. The sliding statistics
+// of each timeseries are computed once and shared across all of its pairings, and
+// pairs are processed in parallel across the number of jobs in the options. The
+// result is symmetric with zeros on the diagonal.
+func MPDistMatrix(series [][]float64, w int, o *MPDistOpts) ([][]float64, error) {
+	if o == nil {
+		o = NewMPDistOpts()
+	}
+	if o.Opts == nil {
+		o.Opts = NewMPOpts()
+	}
+	if err := o.Opts.Validate(); err != nil {
+		return nil, err
+	}
+	if o.Opts.Algorithm != AlgoMPX {
+		return nil, fmt.Errorf("MPDistMatrix only supports the %s algorithm, got %s", AlgoMPX, o.Opts.Algorithm)
+	}
+
+	if w < 2 {
+		return nil, fmt.Errorf("subsequence length must be at least 2")
+	}
+	for i, ts := range series {
+		if len(ts) < w {
+			return nil, fmt.Errorf("timeseries %d has a length of %d which is less than the subsequence length %d", i, len(ts), w)
+		}
+	}
+
+	stats := make([]*mpxStats, len(series))
+	var wg sync.WaitGroup
+	for i := range series {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			stats[i] = newMPXStats(series[i], w)
+		}(i)
+	}
+	wg.Wait()
+
+	dist := make([][]float64, len(series))
+	for i := range dist {
+		dist[i] = make([]float64, len(series))
+	}
+
+	// each pair runs a single threaded join since the pairs themselves are
+	// already spread out across the jobs
+	pairOpts := *o.Opts
+	pairOpts.NJobs = 1
+
+	type pair struct{ i, j int }
+	pairs := make(chan pair)
+	// only the first error is kept since any error fails the whole matrix
+	errs := make(chan error, 1)
+	fail := func(err error) {
+		select {
+		case errs <- err:
+		default:
+		}
+	}
+	njobs := o.Opts.NJobs
+	if njobs < 1 {
+		njobs = 1
+	}
+	for job := 0; job < njobs; job++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for p := range pairs {
+				mp, err := New(series[p.i], series[p.j], w)
+				if err != nil {
+					fail(err)
+					continue
+				}
+				mp.AV = o.AV
+				mp.Opts = &pairOpts
+				if err = mp.mpxWithStats(stats[p.i], stats[p.j]); err != nil {
+					fail(err)
+					continue
+				}
+				d, err := mp.mpDist()
+				if err != nil {
+					fail(err)
+					continue
+				}
+				dist[p.i][p.j] = d
+				dist[p.j][p.i] = d
+			}
+		}()
+	}
+
+	for i := 0; i < len(series); i++ {
+		for j := i + 1; j < len(series); j++ {
+			pairs <- pair{i, j}
+		}
+	}
+	close(pairs)
+	wg.Wait()
+	close(errs)
+
+	if err := <-errs; err != nil {
+		return nil, err
+	}
+	return dist, nil
+}
+
 type Algo string
 
 const (
-	AlgoSTOMP Algo = "stomp"
-	AlgoSTAMP Algo = "stamp"
-	AlgoSTMP  Algo = "stmp"
-	AlgoMPX   Algo = "mpx"
+	AlgoSTOMP     Algo = "stomp"
+	AlgoSTAMP     Algo = "stamp"
+	AlgoSTMP      Algo = "stmp"
+	AlgoMPX       Algo = "mpx"
+	AlgoSCRIMP    Algo = "scrimp"
+	AlgoPreSCRIMP Algo = "prescrimp" // only the PreSCRIMP pass of SCRIMP, an approximate matrix profile
+	AlgoAAMP      Algo = "aamp"      // plain euclidean distances between subsequences that are not z-normalized
+	AlgoACAMP     Algo = "acamp"     // the same distances as AAMP computed along diagonals without fourier transforms
+	AlgoSCAMP     Algo = "scamp"     // the same distances as STOMP computed in tiles of the distance matrix, see Tiles
+)
+
+// Transform is applied to the timeseries before the matrix profile is computed.
+type Transform string
+
+const (
+	TransformNone    Transform = ""        // the timeseries is profiled as is
+	TransformDiff    Transform = "diff"    // the first difference of the timeseries is profiled
+	TransformDetrend Transform = "detrend" // the timeseries is profiled with its linear trend removed
 )
 
 // MPOpts are parameters to vary the algorithm to compute the matrix profile.
 type MPOpts struct {
-	Algorithm    Algo    `json:"algorithm"`  // choose which algorithm to compute the matrix profile
-	SamplePct    float64 `json:"sample_pct"` // only applicable to algorithm STAMP
-	NJobs        int     `json:"n_jobs"`
-	Euclidean    bool    `json:"euclidean"`                  // defaults to using euclidean distance instead of pearson correlation for matrix profile
-	RemapNegCorr bool    `json:"remap_negative_correlation"` // defaults to no remapping. This is used so that highly negatively correlated sequences will show a low distance as well.
+	Algorithm     Algo     `json:"algorithm"` // choose which algorithm to compute the matrix profile
+	NJobs         int      `json:"n_jobs"`
+	Euclidean     bool     `json:"euclidean"`                  // defaults to using euclidean distance instead of pearson correlation for matrix profile
+	RemapNegCorr  bool     `json:"remap_negative_correlation"` // defaults to no remapping. This is used so that highly negatively correlated sequences will show a low distance as well.
+	Squared       bool     `json:"squared"`                    // defaults to false. Keeps euclidean profiles as squared distances which skips the square root while preserving ordering
+	NoiseStd      float64  `json:"noise_std"`                  // defaults to 0. Standard deviation of i.i.d. noise in the timeseries whose expected contribution is removed from the distances
+	FlatThreshold float64  `json:"flat_threshold"`             // defaults to 0. Subsequences with a standard deviation below this fraction of the timeseries standard deviation are masked from motifs and discords
+	Flat          FlatMode `json:"flat_mode"`                  // defaults to none. Chooses how subsequences flatter than the flat threshold, or nearly constant without one, are handled, see FlatMode
+	MaxDistance   float64  `json:"max_distance"`               // defaults to 0 which keeps every distance. Only matches within this euclidean distance, squared when Squared is set, are kept and the rest are left as +Inf with no index
+	Manhattan     bool     `json:"manhattan"`                  // defaults to false. Compares subsequences with the L1 distance, which is robust to spikes, instead of the euclidean distance. Z-normalized unless the algorithm is AAMP or ACAMP, and computed without fourier transforms
+
+	Transform Transform `json:"transform"` // defaults to none. Differences or detrends the timeseries before profiling, see RawSpan to map indices back
+	Circular  bool      `json:"circular"`  // defaults to false. Treats a self join timeseries as periodic so subsequences wrap around its end
+	Period    int       `json:"period"`    // defaults to 0 which excludes no seasonal matches. For self joins, matches lagging a subsequence by a multiple of this many points, give or take the exclusion zone, are never its nearest neighbor
+	Device    Device    `json:"device"`    // defaults to the CPU. Computes on an accelerator when its backend is built in with the device's build tag, a device is present, and it supports the algorithm and options, otherwise falls back to the CPU
+
+	STAMP  *STAMPOpts  `json:"stamp_options"`  // options only applicable to algorithm STAMP
+	SCRIMP *SCRIMPOpts `json:"scrimp_options"` // options only applicable to algorithms SCRIMP and PreSCRIMP
+	SCAMP  *SCAMPOpts  `json:"scamp_options"`  // options only applicable to algorithm SCAMP
+}
+
+// SCAMPOpts are parameters only used by the SCAMP algorithm.
+type SCAMPOpts struct {
+	TileSize int `json:"tile_size"` // number of subsequences on each side of a tile of the distance matrix. Defaults to 0 which picks a size from the subsequence length
+}
+
+// STAMPOpts are parameters only used by the STAMP algorithm.
+type STAMPOpts struct {
+	SamplePct      float64 `json:"sample_pct"`      // fraction of the subsequences whose distance profiles are computed before stopping early with an approximate matrix profile. Defaults to 0 which computes all of them
+	Seed           int64   `json:"seed"`            // seeds the random order subsequences are sampled in so runs are reproducible. Defaults to 0 which uses a random order
+	ComplexityBias bool    `json:"complexity_bias"` // samples subsequences of high complexity earlier so partial runs find motifs and discords sooner. Defaults to false which samples uniformly
+}
+
+// SCRIMPOpts are parameters only used by the SCRIMP and PreSCRIMP algorithms.
+type SCRIMPOpts struct {
+	SamplePct float64 `json:"sample_pct"` // fraction of the diagonals SCRIMP visits after the PreSCRIMP pass before stopping early. Defaults to 0 which visits all of them. Not applicable to PreSCRIMP, which always completes its pass
+	Seed      int64   `json:"seed"`       // seeds the random order diagonals are visited in so runs are reproducible. Defaults to 0 which uses a random order
+	Stride    int     `json:"stride"`     // distance between the subsequences whose distance profiles the PreSCRIMP pass computes. A larger stride is faster and less accurate. Defaults to 0 which uses a quarter of the subsequence length
+}
+
+// samplePct returns the fraction of the matrix profile the algorithm computes before
+// stopping early, 1 unless the options of STAMP or SCRIMP ask for sampling.
+func (o MPOpts) samplePct() float64 {
+	var pct float64
+	switch {
+	case o.Algorithm == AlgoSTAMP && o.STAMP != nil:
+		pct = o.STAMP.SamplePct
+	case o.Algorithm == AlgoSCRIMP && o.SCRIMP != nil:
+		pct = o.SCRIMP.SamplePct
+	}
+	if pct == 0 {
+		return 1
+	}
+	return pct
+}
+
+// Validate checks that the options are consistent before any computation starts.
+func (o MPOpts) Validate() error {
+	switch o.Algorithm {
+	case AlgoSTOMP, AlgoSTAMP, AlgoSTMP, AlgoMPX, AlgoSCRIMP, AlgoPreSCRIMP, AlgoAAMP, AlgoACAMP, AlgoSCAMP:
+	default:
+		return fmt.Errorf("unsupported algorithm for matrix profile, %s", o.Algorithm)
+	}
+	if o.NJobs < 1 {
+		return fmt.Errorf("number of jobs, %d, must be at least 1", o.NJobs)
+	}
+	if o.Squared && !o.Euclidean {
+		return errors.New("squared distances are only supported with euclidean distances")
+	}
+	if o.NoiseStd < 0 {
+		return fmt.Errorf("noise standard deviation, %.3f, must not be negative", o.NoiseStd)
+	}
+	if o.Algorithm == AlgoAAMP || o.Algorithm == AlgoACAMP {
+		if !o.Euclidean {
+			return fmt.Errorf("algorithm %s only supports euclidean distances", o.Algorithm)
+		}
+		if o.NoiseStd > 0 {
+			return fmt.Errorf("noise correction is not supported by algorithm %s", o.Algorithm)
+		}
+	}
+	if o.FlatThreshold < 0 {
+		return fmt.Errorf("flat threshold, %.3f, must not be negative", o.FlatThreshold)
+	}
+	switch o.Flat {
+	case FlatNone, FlatSkip, FlatInf, FlatFloor:
+	default:
+		return fmt.Errorf("unsupported flat mode, %s", o.Flat)
+	}
+	if o.recomputesFlat() && (!o.Euclidean || o.Manhattan || o.Algorithm == AlgoAAMP || o.Algorithm == AlgoACAMP) {
+		return fmt.Errorf("flat mode %s only supports z-normalized euclidean distances", o.Flat)
+	}
+	if o.Period < 0 {
+		return fmt.Errorf("period, %d, must not be negative", o.Period)
+	}
+	if o.Period > 0 && o.recomputesFlat() {
+		return fmt.Errorf("periodic exclusion zones don't support flat mode %s", o.Flat)
+	}
+	if o.MaxDistance < 0 {
+		return fmt.Errorf("max distance, %.3f, must not be negative", o.MaxDistance)
+	}
+	if o.MaxDistance > 0 && !o.Euclidean {
+		return errors.New("max distance is only supported with euclidean distances")
+	}
+	if o.Manhattan {
+		if !o.Euclidean || o.Squared {
+			return errors.New("manhattan distances replace euclidean distances, so pearson correlations and squared distances are not supported")
+		}
+		if o.NoiseStd > 0 {
+			return errors.New("noise correction is not supported with manhattan distances")
+		}
+		if o.samplePct() < 1 {
+			return errors.New("sampling is not supported with manhattan distances")
+		}
+		if o.Device != DeviceCPU {
+			return errors.New("manhattan distances are only computed on the cpu")
+		}
+	}
+	switch o.Transform {
+	case TransformNone, TransformDiff, TransformDetrend:
+	default:
+		return fmt.Errorf("unsupported transform, %s", o.Transform)
+	}
+	switch o.Device {
+	case DeviceCPU, DeviceCUDA, DeviceOpenCL:
+	default:
+		return fmt.Errorf("unsupported device, %s", o.Device)
+	}
+	if o.STAMP != nil {
+		if o.Algorithm != AlgoSTAMP {
+			return fmt.Errorf("stamp options are not applicable to algorithm %s", o.Algorithm)
+		}
+		if o.STAMP.SamplePct < 0 || o.STAMP.SamplePct > 1 {
+			return fmt.Errorf("must provide a sampling between 0 and 1, sample: %.3f", o.STAMP.SamplePct)
+		}
+	}
+	if o.SCAMP != nil {
+		if o.Algorithm != AlgoSCAMP {
+			return fmt.Errorf("scamp options are not applicable to algorithm %s", o.Algorithm)
+		}
+		if o.SCAMP.TileSize < 0 {
+			return fmt.Errorf("tile size, %d, must not be negative", o.SCAMP.TileSize)
+		}
+	}
+	if o.SCRIMP != nil {
+		if o.Algorithm != AlgoSCRIMP && o.Algorithm != AlgoPreSCRIMP {
+			return fmt.Errorf("scrimp options are not applicable to algorithm %s", o.Algorithm)
+		}
+		if o.SCRIMP.Stride < 0 {
+			return fmt.Errorf("prescrimp stride, %d, must not be negative", o.SCRIMP.Stride)
+		}
+		if o.SCRIMP.SamplePct < 0 || o.SCRIMP.SamplePct > 1 {
+			return fmt.Errorf("must provide a sampling between 0 and 1, sample: %.3f", o.SCRIMP.SamplePct)
+		}
+		if o.Algorithm == AlgoPreSCRIMP && o.SCRIMP.SamplePct != 0 {
+			return errors.New("prescrimp always completes its pass, so sampling is not applicable")
+		}
+	}
+	return nil
 }
 
 // NewMPOpts returns a default MPOpts
@@ -363,7 +887,6 @@ func NewMPOpts() *MPOpts {
 	}
 	return &MPOpts{
 		Algorithm: AlgoMPX,
-		SamplePct: 1.0,
 		NJobs:     p,
 		Euclidean: true,
 	}
@@ -375,26 +898,177 @@ func (mp *MatrixProfile) Compute(o *MPOpts) error {
 		o = NewMPOpts()
 	}
 	mp.Opts = o
+	mp.mpxStream = nil
+	mp.stampi = nil
 
-	if o.SamplePct < 1 {
-		return mp.stamp()
+	if err := o.Validate(); err != nil {
+		return err
+	}
+	if err := mp.validateNormalization(o); err != nil {
+		return err
 	}
 
-	switch o.Algorithm {
-	case AlgoSTOMP:
-		return mp.stomp()
-	case AlgoSTAMP:
-		return mp.stamp()
-	case AlgoSTMP:
-		return mp.stmp()
-	case AlgoMPX:
-		return mp.mpx()
-	default:
-		return fmt.Errorf("Unsupported algorithm for matrix profile, %s", o.Algorithm)
+	if o.recomputesFlat() && !mp.SelfJoin {
+		return errors.New("flat subsequences can only be recomputed for self joins")
+	}
+
+	algo := o.Algorithm
+
+	zone := mp.exclusionZone(mp.W / 2)
+	if algo == AlgoMPX {
+		zone = mp.mpxExclusionZone()
+	}
+	if err := mp.validatePeriod(zone); err != nil {
+		return err
+	}
+
+	if err := mp.applyTransform(o); err != nil {
+		return err
+	}
+
+	var computed bool
+	var err error
+	if o.Manhattan {
+		// the L1 distance has no fourier transform shortcut, so every algorithm shares
+		// the same traversal of the distance matrix
+		computed, err = true, mp.manhattanJoin()
+	} else {
+		computed, err = mp.computeOnDevice(algo)
+	}
+	if err != nil {
+		return err
+	}
+
+	if !computed {
+		switch algo {
+		case AlgoSTOMP:
+			err = mp.stomp()
+		case AlgoSTAMP:
+			err = mp.stamp()
+		case AlgoSTMP:
+			err = mp.stmp()
+		case AlgoMPX:
+			err = mp.mpx()
+		case AlgoSCRIMP:
+			err = mp.scrimp()
+		case AlgoPreSCRIMP:
+			err = mp.prescrimp()
+		case AlgoAAMP:
+			err = mp.aamp()
+		case AlgoACAMP:
+			err = mp.acamp()
+		case AlgoSCAMP:
+			err = mp.scamp()
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	if err = mp.handleFlat(zone); err != nil {
+		return err
+	}
+	if err = mp.handlePeriod(zone); err != nil {
+		return err
+	}
+	mp.applyMaxDistance()
+
+	return mp.maskFlat(o)
+}
+
+// applyTransform replaces a and b with their transformed versions, keeping the
+// originals in RawA and RawB. A circular self join then appends the first w-1 points
+// so the last subsequences wrap around. The time index follows a, with each difference
+// stamped with the time of the later of its two points and each wrapped point with its
+// time one period later, so it stays increasing. The original is kept in
+// RawTimeIndex. The transform always starts from the originals so a matrix profile can
+// be recomputed with different options, and TransformNone without wrapping restores
+// them.
+func (mp *MatrixProfile) applyTransform(o *MPOpts) error {
+	if o.Circular && !mp.SelfJoin {
+		return errors.New("circular joins are only supported for self joins")
+	}
+	none := o.Transform == TransformNone && !o.Circular
+	if mp.RawA == nil {
+		if none {
+			return nil
+		}
+		mp.RawA = mp.A
+		if !mp.SelfJoin {
+			mp.RawB = mp.B
+		}
+		mp.RawTimeIndex = mp.TimeIndex
+	}
+
+	a, err := transformSeries(mp.RawA, o.Transform)
+	if err != nil {
+		return err
+	}
+	b := a
+	if !mp.SelfJoin {
+		if b, err = transformSeries(mp.RawB, o.Transform); err != nil {
+			return err
+		}
+	}
+	if mp.W > len(a) || mp.W > len(b) {
+		return fmt.Errorf("subsequence length must be less than the transformed timeseries")
+	}
+	times := mp.RawTimeIndex
+	if len(times) == len(mp.RawA) && o.Transform == TransformDiff {
+		times = times[1:]
+	}
+	if o.Circular {
+		wrapped := make([]float64, len(a), len(a)+mp.W-1)
+		copy(wrapped, a)
+		a = append(wrapped, a[:mp.W-1]...)
+		b = a
+		if len(times) == len(wrapped) && len(times) > 1 {
+			// the period spans the timeseries and one more sampling interval
+			last := len(times) - 1
+			period := times[last].Sub(times[0]) + times[last].Sub(times[last-1])
+			wrappedTimes := make([]time.Time, len(times), len(a))
+			copy(wrappedTimes, times)
+			for _, t := range times[:mp.W-1] {
+				wrappedTimes = append(wrappedTimes, t.Add(period))
+			}
+			times = wrappedTimes
+		}
+	}
+
+	mp.A, mp.B, mp.N = a, b, len(b)
+	mp.TimeIndex = times
+	mp.BF = nil
+	mp.mpxStats = nil
+	if none {
+		mp.RawA, mp.RawB, mp.RawTimeIndex = nil, nil, nil
 	}
 	return nil
 }
 
+// transformSeries applies a transform to a timeseries.
+func transformSeries(ts []float64, t Transform) ([]float64, error) {
+	switch t {
+	case TransformDiff:
+		return util.Diff(ts)
+	case TransformDetrend:
+		return util.Detrend(ts)
+	}
+	return ts, nil
+}
+
+// RawSpan maps the subsequence starting at idx in the profiled timeseries a to the
+// points it covers in the timeseries before the options transform, returning the
+// start and exclusive end. A differenced subsequence of length w spans w+1 original
+// points starting at the same index, while other transforms keep the same span. The
+// span is not wrapped around for circular self joins, so the end of a subsequence that
+// wraps may run past the length of the timeseries.
+func (mp MatrixProfile) RawSpan(idx int) (int, int) {
+	if mp.Opts != nil && mp.Opts.Transform == TransformDiff {
+		return idx, idx + mp.W + 1
+	}
+	return idx, idx + mp.W
+}
+
 // initCaches initializes cached data including the timeseries a and b rolling mean
 // and standard deviation and full fourier transform of timeseries b
 func (mp *MatrixProfile) initCaches() error {
@@ -412,54 +1086,149 @@ func (mp *MatrixProfile) initCaches() error {
 	}
 
 	// precompute the fourier transform of the b timeseries since it will
-	// be used multiple times while computing the matrix profile
-	fft := fourier.NewFFT(mp.N)
-	mp.BF = fft.Coefficients(nil, mp.B)
+	// be used multiple times while computing the matrix profile. The timeseries
+	// is zero padded to a length the fft handles quickly.
+	mp.BF = mp.newFFT().coefficients(mp.B)
 
 	return nil
 }
 
+// fftCache holds a real fourier transform along with the buffers reused across cross
+// correlations, so repeated queries against the same timeseries don't allocate. A
+// cache must not be shared across goroutines.
+type fftCache struct {
+	fft  *fourier.FFT
+	qpad []float64    // zero padded reversed query
+	qf   []complex128 // half spectrum of the query, multiplied in place
+	dot  []float64    // circular convolution of the query and timeseries
+}
+
+// newFFTCache creates a fourier transform of length n with its buffers.
+func newFFTCache(n int) *fftCache {
+	return &fftCache{
+		fft:  fourier.NewFFT(n),
+		qpad: make([]float64, n),
+		qf:   make([]complex128, n/2+1),
+		dot:  make([]float64, n),
+	}
+}
+
+// Len returns the length of the fourier transform.
+func (c *fftCache) Len() int {
+	return len(c.qpad)
+}
+
+// coefficients computes the half spectrum of a timeseries zero padded to the
+// transform length.
+func (c *fftCache) coefficients(ts []float64) []complex128 {
+	pad := make([]float64, c.Len())
+	copy(pad, ts)
+	return c.fft.Coefficients(nil, pad)
+}
+
+// correlate computes the sliding dot product of q against the timeseries whose half
+// spectrum is tf. Both are real so only the half spectrum is multiplied. The returned
+// slice starts at the first full overlap and is only valid until the next call.
+func (c *fftCache) correlate(q []float64, tf []complex128) []float64 {
+	return c.correlateSpectrum(c.querySpectrum(q, c.qf), len(q), tf)
+}
+
+// querySpectrum writes the half spectrum of the reversed query, zero padded to the
+// transform length, to dst so it can be correlated with several timeseries.
+func (c *fftCache) querySpectrum(q []float64, dst []complex128) []complex128 {
+	m := len(q)
+	for i := range c.qpad {
+		c.qpad[i] = 0
+	}
+	for i := 0; i < m; i++ {
+		c.qpad[i] = q[m-i-1]
+	}
+	return c.fft.Coefficients(dst, c.qpad)
+}
+
+// correlateSpectrum is correlate for a query of length m whose half spectrum, qf, was
+// computed with querySpectrum.
+func (c *fftCache) correlateSpectrum(qf []complex128, m int, tf []complex128) []float64 {
+	// multiply the fourier transform of the timeseries with the subsequence fourier
+	// transform and store in the subsequence fft slice, which qf may already be
+	for i := range qf {
+		c.qf[i] = qf[i] * tf[i]
+	}
+
+	dot := c.fft.Sequence(c.dot, c.qf)
+	n := float64(len(dot))
+	for i := m - 1; i < len(dot); i++ {
+		dot[i] /= n
+	}
+	return dot[m-1:]
+}
+
+// newFFT returns a fourier transform whose length is the b timeseries length padded
+// up to the next size with only small prime factors.
+func (mp MatrixProfile) newFFT() *fftCache {
+	return newFFTCache(util.FFTLen(mp.N))
+}
+
 // crossCorrelate computes the sliding dot product between two slices
 // given a query and time series. Uses fast fourier transforms to compute
 // the necessary values. Returns the a slice of floats for the cross-correlation
-// of the signal q and the mp.B signal. This makes an optimization where the query
-// length must be less than half the length of the timeseries, b.
-func (mp MatrixProfile) crossCorrelate(q []float64, fft *fourier.FFT) []float64 {
-	qpad := make([]float64, mp.N)
-	for i := 0; i < len(q); i++ {
-		qpad[i] = q[mp.W-i-1]
+// of the signal q and the mp.B signal. The query is reversed and zero padded to the
+// fft length, which is at least the length of b, so the circular convolution never
+// wraps around for the valid lags and any query up to the length of b is supported.
+// Values past the valid lags are discarded. The returned slice reuses the buffers of
+// the fft cache.
+func (mp MatrixProfile) crossCorrelate(q []float64, fft *fftCache) ([]float64, error) {
+	m := len(q)
+	if m == 0 || m > mp.N {
+		return nil, fmt.Errorf("query length, %d, must be between 1 and the timeseries length, %d", m, mp.N)
 	}
-	qf := fft.Coefficients(nil, qpad)
 
-	// in place multiply the fourier transform of the b time series with
-	// the subsequence fourier transform and store in the subsequence fft slice
-	for i := 0; i < len(qf); i++ {
-		qf[i] = mp.BF[i] * qf[i]
+	n := fft.Len()
+	if n < mp.N || len(mp.BF) != n/2+1 {
+		return nil, fmt.Errorf("fft length, %d, does not match the cached fourier transform of b", n)
 	}
 
-	dot := fft.Sequence(nil, qf)
-
-	for i := 0; i < mp.N-mp.W+1; i++ {
-		dot[mp.W-1+i] = dot[mp.W-1+i] / float64(mp.N)
-	}
-	return dot[mp.W-1:]
+	return fft.correlate(q, mp.BF)[:mp.N-m+1], nil
 }
 
 // mass calculates the Mueen's algorithm for similarity search (MASS)
 // between a specified query and timeseries. Writes the euclidean distance
 // of the query to every subsequence in mp.B to profile.
-func (mp MatrixProfile) mass(q []float64, profile []float64, fft *fourier.FFT) error {
+func (mp MatrixProfile) mass(q []float64, profile []float64, fft *fftCache) error {
 	qnorm, err := util.ZNormalize(q)
+	if err != nil {
+		if mp.Opts == nil || !mp.Opts.recomputesFlat() {
+			return err
+		}
+		// a flat query has no defined distance until its row is recomputed
+		for i := 0; i <= len(mp.B)-len(q) && i < len(profile); i++ {
+			profile[i] = math.Inf(1)
+		}
+		return nil
+	}
+
+	dot, err := mp.crossCorrelate(qnorm, fft)
 	if err != nil {
 		return err
 	}
 
-	dot := mp.crossCorrelate(qnorm, fft)
+	// converting cross correlation value to squared euclidian distance,
+	// 2*|w - dot/std|, operating on whole slices
+	profile = profile[:len(dot)]
+	floats.DivTo(profile, dot, mp.BStd[:len(dot)])
+	floats.Scale(-2, profile)
+	floats.AddConst(2*float64(mp.W), profile)
+	absInPlace(profile)
 
-	// converting cross correlation value to euclidian distance
-	for i := 0; i < len(dot); i++ {
-		profile[i] = math.Sqrt(math.Abs(2 * (float64(mp.W) - (dot[i] / mp.BStd[i]))))
+	var qstd float64
+	if mp.noiseVar() > 0 {
+		_, std, err := util.MovMeanStd(q, len(q))
+		if err != nil {
+			return err
+		}
+		qstd = std[0]
 	}
+	mp.finishProfile(profile, qstd, mp.BStd)
 	return nil
 }
 
@@ -467,22 +1236,63 @@ func (mp MatrixProfile) mass(q []float64, profile []float64, fft *fourier.FFT) e
 // If b is set to nil then it assumes a self join and will create an exclusion
 // area for trivial nearest neighbors. Writes the euclidean distance between
 // the specified subsequence in mp.A with each subsequence in mp.B to profile
-func (mp MatrixProfile) distanceProfile(idx int, profile []float64, fft *fourier.FFT) error {
+func (mp MatrixProfile) distanceProfile(idx int, profile []float64, fft *fftCache) error {
 	if idx > len(mp.A)-mp.W {
 		return fmt.Errorf("provided index  %d is beyond the length of timeseries %d minus the subsequence length %d", idx, len(mp.A), mp.W)
 	}
 
-	if err := mp.mass(mp.A[idx:idx+mp.W], profile, fft); err != nil {
+	var err error
+	switch {
+	case mp.manhattan():
+		mp.manhattanProfile(idx, profile)
+	case mp.nonNormalized():
+		err = mp.rawMass(mp.A[idx:idx+mp.W], profile, fft)
+	default:
+		err = mp.mass(mp.A[idx:idx+mp.W], profile, fft)
+	}
+	if err != nil {
 		return err
 	}
 
 	// sets the distance in the exclusion zone to +Inf
 	if mp.SelfJoin {
-		util.ApplyExclusionZone(profile, idx, mp.W/2)
+		mp.applyExclusionZone(profile, idx, 0, mp.exclusionZone(mp.W/2))
 	}
 	return nil
 }
 
+// DistanceProfile computes the distance between the query and every subsequence of b,
+// finding all matches of a shape. The query must be as long as a subsequence, and is
+// compared the same way as subsequences of the matrix profile, with the distance and
+// options of its last computation or the z-normalized euclidean distance if it was
+// never computed. The cached statistics and fourier transform of b are reused across
+// calls. A query is compared as given, so it isn't transformed like b. Use MASS to
+// search for a query of any length.
+func (mp *MatrixProfile) DistanceProfile(query []float64) ([]float64, error) {
+	if len(query) != mp.W {
+		return nil, fmt.Errorf("query length, %d, does not match the subsequence length, %d", len(query), mp.W)
+	}
+	if mp.BF == nil {
+		if err := mp.initCaches(); err != nil {
+			return nil, err
+		}
+	}
+
+	// the query takes the place of a in a join against b
+	mean, std := windowMeanStd(query)
+	view := *mp
+	view.A = query
+	view.AMean = []float64{mean}
+	view.AStd = []float64{std}
+	view.SelfJoin = false
+
+	profile := make([]float64, len(mp.B)-mp.W+1)
+	if err := view.distanceProfile(0, profile, mp.newFFT()); err != nil {
+		return nil, err
+	}
+	return profile, nil
+}
+
 // calculateDistanceProfile converts a sliding dot product slice of floats into
 // distances and normalizes the output. Writes results back into the profile slice
 // of floats representing the distance profile.
@@ -495,18 +1305,73 @@ func (mp MatrixProfile) calculateDistanceProfile(dot []float64, idx int, profile
 		return fmt.Errorf("profile length, %d, is not the same as the dot product length, %d", len(profile), len(dot))
 	}
 
-	// converting cross correlation value to euclidian distance
-	for i := 0; i < len(dot); i++ {
-		profile[i] = math.Sqrt(2 * float64(mp.W) * math.Abs(1-(dot[i]-float64(mp.W)*mp.BMean[i]*mp.AMean[idx])/(float64(mp.W)*mp.BStd[i]*mp.AStd[idx])))
-	}
+	mp.dotsToDistances(dot, idx, 0, profile)
 
 	if mp.SelfJoin {
 		// sets the distance in the exclusion zone to +Inf
-		util.ApplyExclusionZone(profile, idx, mp.W/2)
+		mp.applyExclusionZone(profile, idx, 0, mp.exclusionZone(mp.W/2))
 	}
 	return nil
 }
 
+// dotsToDistances converts the sliding dot products of the subsequence of a at idx
+// with the subsequences of b from the index from on into distances, leaving the
+// entries of profile before from untouched.
+func (mp MatrixProfile) dotsToDistances(dot []float64, idx, from int, profile []float64) {
+	// converting cross correlation value to squared euclidian distance,
+	// |2w - 2*(dot - w*muA*muB)/(stdA*stdB)|, operating on whole slices
+	w := float64(mp.W)
+	n := len(dot)
+	p := profile[from:n]
+	floats.AddScaledTo(p, dot[from:], -w*mp.AMean[idx], mp.BMean[from:n])
+	floats.Div(p, mp.BStd[from:n])
+	floats.Scale(-2/mp.AStd[idx], p)
+	floats.AddConst(2*w, p)
+	absInPlace(p)
+	mp.finishProfile(p, mp.AStd[idx], mp.BStd[from:])
+}
+
+// applyExclusionZone sets the trivial matches of the subsequence at idx in a self join
+// distance profile to +Inf, where the profile starts at the subsequence from. For
+// circular self joins the trivial matches also wrap around the end of the timeseries.
+func (mp MatrixProfile) applyExclusionZone(profile []float64, idx, from, zone int) {
+	util.ApplyExclusionZone(profile, idx-from, zone)
+	if mp.circular() {
+		n := len(mp.A) - mp.W + 1
+		util.ApplyExclusionZone(profile, idx-from+n, zone)
+		util.ApplyExclusionZone(profile, idx-from-n, zone)
+	}
+}
+
+// circular returns whether the self join treats the timeseries as periodic.
+func (mp MatrixProfile) circular() bool {
+	return mp.SelfJoin && mp.Opts != nil && mp.Opts.Circular
+}
+
+// exclusionZone returns the configured self join exclusion zone, or def if none is set.
+func (mp MatrixProfile) exclusionZone(def int) int {
+	if mp.ExclusionZone > 0 {
+		return mp.ExclusionZone
+	}
+	return def
+}
+
+// mpxExclusionZone returns the smallest diagonal offset compared by MPX self joins.
+func (mp MatrixProfile) mpxExclusionZone() int {
+	exclZone := 1 // for seljoin we should at least get rid of neighboring points
+	if mp.W/4 > exclZone {
+		exclZone = mp.W / 4
+	}
+	return mp.exclusionZone(exclZone)
+}
+
+// absInPlace replaces every value of the slice with its absolute value.
+func absInPlace(s []float64) {
+	for i, v := range s {
+		s[i] = math.Abs(v)
+	}
+}
+
 // stmp computes the full matrix profile given two time series as inputs.
 // If the second time series is set to nil then a self join on the first
 // will be performed. Stores the matrix profile and matrix profile index
@@ -526,14 +1391,14 @@ func (mp *MatrixProfile) stmp() error {
 	var err error
 	profile := make([]float64, mp.N-mp.W+1)
 
-	fft := fourier.NewFFT(mp.N)
-	for i := 0; i < mp.N-mp.W+1; i++ {
+	fft := mp.newFFT()
+	for i := 0; i < len(mp.A)-mp.W+1; i++ {
 		if err = mp.distanceProfile(i, profile, fft); err != nil {
 			return err
 		}
 
 		for j := 0; j < len(profile); j++ {
-			if profile[j] <= mp.MP[j] {
+			if isBetterMatch(profile[j], i, mp.MP[j], mp.Idx[j], true) {
 				mp.MP[j] = profile[j]
 				mp.Idx[j] = i
 			}
@@ -543,55 +1408,463 @@ func (mp *MatrixProfile) stmp() error {
 	return nil
 }
 
+// StreamOpts are parameters to vary how a matrix profile is maintained as new values
+// arrive through Update.
+type StreamOpts struct {
+	MaxLen         int                  `json:"max_len"`         // caps the self join timeseries length by retiring the oldest points. Defaults to 0 which keeps all history
+	FadeFactor     float64              `json:"fade_factor"`     // per point discount of self join matches to older subsequences, between 0 and 1. Defaults to 0 which disables fading
+	Horizon        int                  `json:"horizon"`         // number of most recent subsequences a new self join subsequence is matched against. Defaults to 0 which matches all history
+	DiscordK       int                  `json:"discord_k"`       // number of top discords to re-rank after each Update. Defaults to 0 which disables tracking
+	DiscordHorizon int                  `json:"discord_horizon"` // number of most recent subsequences searched for discords. Defaults to 0 which searches the whole profile
+	OnDiscords     func(discords []int) `json:"-"`               // called after an Update only when the top discords change
+	MotifK         int                  `json:"motif_k"`         // number of top motifs to track after each Update. Defaults to 0 which disables tracking
+	MotifRadius    float64              `json:"motif_radius"`    // radius multiple of the minimum motif distance used to find motif members. Defaults to 2
+	MotifMaxDist   float64              `json:"motif_max_dist"`  // motifs with a minimum distance above this are not tracked. Defaults to 0 which tracks all motifs
+	MotifExpiry    int                  `json:"motif_expiry"`    // number of points without a new member after which a motif stops recurring. Defaults to 0 which never expires motifs
+	OnMotifEvent   func(MotifEvent)     `json:"-"`               // called for each motif that emerges, gains members, or stops recurring
+
+	discords    []int           // last ranking of discords passed to OnDiscords
+	motifs      []*trackedMotif // motifs tracked across updates
+	nextMotifID int             // identifier given to the next emerging motif
+}
+
+// NewStreamOpts returns a default StreamOpts
+func NewStreamOpts() *StreamOpts {
+	return &StreamOpts{
+		MotifRadius: 2,
+	}
+}
+
 // Update updates a matrix profile and matrix profile index in place providing streaming
-// like behavior.
+// like behavior. For a self join with a StreamOpts MaxLen set, the oldest points are
+// retired once the timeseries grows beyond MaxLen so memory stays constant. Indices
+// remain relative to the retained timeseries and Offset tracks how many points have
+// been retired. For AB joins the new values are appended to the b timeseries and only
+// the new subsequences of b are compared against a, rather than recomputing the full join.
+// If discord tracking is enabled in StreamOpts, the top discords are re-ranked after each
+// batch of values and OnDiscords is called when the ranking changes. Self joins computed
+// with MPX are extended incrementally along each diagonal, costing O(n) per new value.
+// Matches of new self join subsequences to old data can be faded or cut off at a
+// horizon through StreamOpts so the profile adapts to drift.
 func (mp *MatrixProfile) Update(newValues []float64) error {
+	if mp.RawA != nil {
+		return errors.New("streaming updates are not supported on a transformed timeseries")
+	}
+
 	var err error
+	if mp.SelfJoin {
+		err = mp.updateSelfJoin(newValues)
+	} else {
+		err = mp.updateB(newValues)
+	}
+	if err != nil {
+		return err
+	}
+	mp.applyMaxDistance()
+	return mp.trackStream()
+}
+
+// updateSelfJoin appends values to a self join timeseries and updates the matrix profile
+// with the distance profile of each new subsequence.
+func (mp *MatrixProfile) updateSelfJoin(newValues []float64) error {
+	maxLen := 0
+	if mp.Stream != nil {
+		maxLen = mp.Stream.MaxLen
+	}
+	if mp.Opts != nil && mp.Opts.Period > 0 {
+		return errors.New("streaming updates don't support periodic exclusion zones")
+	}
+	if maxLen > 0 && maxLen < 2*mp.W {
+		return fmt.Errorf("stream max length, %d, must be at least twice the subsequence length, %d", maxLen, mp.W)
+	}
+	if mp.Stream != nil {
+		if f := mp.Stream.FadeFactor; f < 0 || f > 1 {
+			return fmt.Errorf("fade factor, %.3f, must be between 0 and 1", f)
+		}
+		if mp.Stream.Horizon < 0 {
+			return fmt.Errorf("stream horizon, %d, must not be negative", mp.Stream.Horizon)
+		}
+	}
+
+	var err error
+	useMPX := mp.Opts != nil && mp.Opts.Algorithm == AlgoMPX && !mp.Opts.Manhattan
 
-	var profile []float64
 	for _, val := range newValues {
-		// add to the a and b time series and increment the time series length
-		if mp.SelfJoin {
-			mp.A = append(mp.A, val)
-			mp.B = mp.A
-		} else {
-			mp.B = append(mp.B, val)
+		if useMPX {
+			mp.appendMPX(val)
+		} else if err = mp.appendDistanceProfile(val); err != nil {
+			return err
+		}
+
+		if maxLen > 0 && mp.N > maxLen {
+			if err = mp.retire(mp.N - maxLen); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// appendDistanceProfile appends a value to a self join timeseries and updates the
+// matrix profile with the distance profile of the newest subsequence, following STAMPI.
+// The sliding dot products of the previous subsequence are stepped forward with the
+// STOMP recurrence and only the statistics of the new subsequence are computed, so each
+// point costs O(n) without any fourier transforms. Manhattan distances are summed
+// directly for every subsequence instead.
+func (mp *MatrixProfile) appendDistanceProfile(val float64) error {
+	if mp.stampi == nil || len(mp.stampi.dot) != len(mp.A)-mp.W+1 {
+		s, err := mp.newSTAMPIStream()
+		if err != nil {
+			return err
+		}
+		mp.stampi = s
+	}
+	s := mp.stampi
+
+	// add to the a and b time series and increment the time series length
+	mp.A = append(mp.A, val)
+	mp.B = mp.A
+	mp.N++
+
+	// the fft cache no longer reflects the timeseries and is rebuilt lazily when needed
+	mp.BF = nil
+	mp.mpxStats = nil
+
+	last := mp.N - mp.W
+	mean, std := windowMeanStd(mp.A[last:])
+	mp.AMean = append(mp.AMean, mean)
+	mp.AStd = append(mp.AStd, std)
+	mp.BMean = append(mp.BMean, mean)
+	mp.BStd = append(mp.BStd, std)
+	if s.sumSq != nil {
+		s.sumSq = append(s.sumSq, floats.Dot(mp.A[last:], mp.A[last:]))
+	}
+
+	s.dot = append(s.dot, 0)
+	if !mp.manhattan() {
+		for j := last; j > 0; j-- {
+			s.dot[j] = s.dot[j-1] - mp.A[j-1]*mp.A[last-1] + mp.A[j+mp.W-1]*mp.A[mp.N-1]
+		}
+		// the first dot product is not covered by the update above
+		s.dot[0] = floats.Dot(mp.A[:mp.W], mp.A[last:])
+	}
+
+	// increase the size of the Matrix Profile and Index
+	mp.MP = append(mp.MP, math.Inf(1))
+	mp.Idx = append(mp.Idx, math.MaxInt64)
+
+	// only compute the last distance profile
+	if cap(s.profile) < len(s.dot) {
+		s.profile = make([]float64, len(s.dot), 2*len(s.dot))
+	}
+	profile := s.profile[:len(s.dot)]
+	switch {
+	case mp.manhattan():
+		// the L1 distance is summed directly, so no fourier transform is needed
+		if err := mp.distanceProfile(last, profile, nil); err != nil {
+			return err
+		}
+	case mp.nonNormalized():
+		mp.rawDistances(s.dot, s.sumSq[last], mp.AMean[last], s.sumSq, profile)
+		mp.applyExclusionZone(profile, last, 0, mp.exclusionZone(mp.W/2))
+	default:
+		if std == 0 {
+			return fmt.Errorf("standard deviation is zero")
+		}
+		if err := mp.calculateDistanceProfile(s.dot, last, profile); err != nil {
+			return err
 		}
-		mp.N++
+	}
+	mp.fadeProfile(profile, last, true)
+
+	minVal := math.Inf(1)
+	minIdx := math.MaxInt64
+	for j := 0; j < len(profile)-1; j++ {
+		if isBetterMatch(profile[j], last, mp.MP[j], mp.Idx[j], true) {
+			mp.MP[j] = profile[j]
+			mp.Idx[j] = last
+		}
+		if profile[j] < minVal {
+			minVal = profile[j]
+			minIdx = j
+		}
+	}
+	mp.MP[last] = minVal
+	mp.Idx[last] = minIdx
+	mp.extendMask()
+	return nil
+}
 
-		// increase the size of the Matrix Profile and Index
-		mp.MP = append(mp.MP, math.Inf(1))
-		mp.Idx = append(mp.Idx, math.MaxInt64)
+// retire drops the first k points of a self join timeseries along with their matrix
+// profile entries, rebasing the remaining indices. Subsequences whose nearest neighbor
+// was retired have their distance profile recomputed against the retained timeseries.
+// Values are shifted within the existing slices so no new memory is allocated.
+func (mp *MatrixProfile) retire(k int) error {
+	n := copy(mp.A, mp.A[k:])
+	mp.A = mp.A[:n]
+	mp.B = mp.A
+	mp.N = n
+	mp.Offset += k
+	mp.mpxStats = nil
+	if mp.mpxStream != nil {
+		mp.mpxStream.retire(k)
+	}
+
+	n = copy(mp.MP, mp.MP[k:])
+	mp.MP = mp.MP[:n]
+	copy(mp.Idx, mp.Idx[k:])
+	mp.Idx = mp.Idx[:n]
+	if k < len(mp.Mask) {
+		n = copy(mp.Mask, mp.Mask[k:])
+		mp.Mask = mp.Mask[:n]
+	} else {
+		mp.Mask = nil
+	}
 
-		if err = mp.initCaches(); err != nil {
+	var stale []int
+	for i, idx := range mp.Idx {
+		if idx == math.MaxInt64 {
+			continue
+		}
+		mp.Idx[i] = idx - k
+		if mp.Idx[i] < 0 {
+			stale = append(stale, i)
+		}
+	}
+
+	// the sliding statistics kept up to date by the appends only need to be shifted, and
+	// the fourier transform of the timeseries is only rebuilt when a distance profile has
+	// to be recomputed
+	if mp.stampi != nil {
+		mp.stampi.retire(k)
+	}
+	mp.BF = nil
+	if len(mp.AMean) == len(mp.MP)+k && len(mp.BMean) == len(mp.MP)+k {
+		mp.AMean = shiftOut(mp.AMean, k)
+		mp.AStd = shiftOut(mp.AStd, k)
+		mp.BMean = shiftOut(mp.BMean, k)
+		mp.BStd = shiftOut(mp.BStd, k)
+	} else {
+		// statistics left behind by a batch compute are rebuilt once, after which the
+		// appends keep them in step
+		var err error
+		if mp.AMean, mp.AStd, err = util.MovMeanStd(mp.A, mp.W); err != nil {
 			return err
 		}
+		if mp.BMean, mp.BStd, err = util.MovMeanStd(mp.B, mp.W); err != nil {
+			return err
+		}
+	}
+	if len(stale) == 0 {
+		return nil
+	}
+	mp.BF = mp.newFFT().coefficients(mp.B)
 
-		// only compute the last distance profile
-		profile = make([]float64, len(mp.MP))
-		fft := fourier.NewFFT(mp.N)
-		if err = mp.distanceProfile(len(mp.A)-mp.W, profile, fft); err != nil {
+	// the incremental MPX path keeps pearson correlations when not using euclidean distances
+	euclidean := mp.mpxStream == nil || mp.Opts.Euclidean
+
+	profile := make([]float64, len(mp.MP))
+	fft := mp.newFFT()
+	for _, i := range stale {
+		if err := mp.distanceProfile(i, profile, fft); err != nil {
 			return err
 		}
+		if !euclidean {
+			util.E2P(profile, mp.W)
+		}
+		mp.MP[i] = math.Inf(1)
+		if !euclidean {
+			mp.MP[i] = math.Inf(-1)
+		}
+		mp.Idx[i] = math.MaxInt64
+		for j, d := range profile {
+			if isBetter(d, mp.MP[i], euclidean) {
+				mp.MP[i] = d
+				mp.Idx[i] = j
+			}
+		}
+	}
+	return nil
+}
 
-		minVal := math.Inf(1)
-		minIdx := math.MaxInt64
-		for j := 0; j < len(profile)-1; j++ {
-			if profile[j] <= mp.MP[j] {
-				mp.MP[j] = profile[j]
-				mp.Idx[j] = mp.N - mp.W
+// UpdateA appends values to the a timeseries of an AB join and updates the matrix
+// profile by comparing only the new subsequences of a against all of b. For a self
+// join this is the same as Update.
+func (mp *MatrixProfile) UpdateA(newValues []float64) error {
+	if mp.SelfJoin {
+		return mp.Update(newValues)
+	}
+	if len(newValues) == 0 {
+		return nil
+	}
+
+	oldLenA := len(mp.A) - mp.W + 1
+	mp.A = append(mp.A, newValues...)
+
+	euclidean := mp.Opts == nil || mp.Opts.Euclidean
+	mpxLayout := mp.MPB != nil
+
+	err := mp.joinSubsequences(oldLenA, len(mp.A)-mp.W+1, euclidean || !mpxLayout, func(i int, profile []float64, bestVal float64, bestIdx int) {
+		for j, d := range profile {
+			if mpxLayout {
+				if isBetter(d, mp.MPB[j], euclidean) {
+					mp.MPB[j] = d
+					mp.IdxB[j] = i
+				}
+			} else if d < mp.MP[j] {
+				mp.MP[j] = d
+				mp.Idx[j] = i
 			}
-			if profile[j] < minVal {
-				minVal = profile[j]
-				minIdx = j
+		}
+
+		if mpxLayout {
+			mp.MP = append(mp.MP, bestVal)
+			mp.Idx = append(mp.Idx, bestIdx)
+		}
+	})
+	if err != nil {
+		return err
+	}
+	mp.applyMaxDistance()
+	return mp.trackStream()
+}
+
+// trackStream runs the discord and motif tracking configured in the stream options
+// after a batch of values has been added.
+func (mp *MatrixProfile) trackStream() error {
+	if err := mp.trackDiscords(); err != nil {
+		return err
+	}
+	return mp.trackMotifs()
+}
+
+// trackDiscords re-ranks the top discords over the configured horizon of the matrix
+// profile and calls OnDiscords if the ranking differs from the previous one. Discord
+// indices passed to the callback include the retired Offset so they stay comparable
+// as old points are dropped. The profile is scaled by the annotation vector as in
+// DiscoverDiscords.
+func (mp *MatrixProfile) trackDiscords() error {
+	s := mp.Stream
+	if s == nil || s.DiscordK <= 0 || s.OnDiscords == nil {
+		return nil
+	}
+
+	mpCurrent, err := mp.discordMP()
+	if err != nil {
+		return err
+	}
+
+	start := 0
+	if s.DiscordHorizon > 0 && s.DiscordHorizon < len(mpCurrent) {
+		start = len(mpCurrent) - s.DiscordHorizon
+	}
+
+	discords := topDiscords(mpCurrent[start:], s.DiscordK, mp.exclusionZone(mp.W/2))
+	for i := range discords {
+		discords[i] += start + mp.Offset
+	}
+
+	changed := len(discords) != len(s.discords)
+	for i := 0; !changed && i < len(discords); i++ {
+		changed = discords[i] != s.discords[i]
+	}
+	if !changed {
+		return nil
+	}
+
+	s.discords = discords
+	out := make([]int, len(discords))
+	copy(out, discords)
+	s.OnDiscords(out)
+	return nil
+}
+
+// updateB appends values to the b timeseries of an AB join and updates the matrix
+// profile using only the new subsequences of b. When the join was computed with MPX,
+// MP is indexed by the subsequences of a and MPB by the subsequences of b, so MP is
+// updated in place and MPB is extended. Otherwise MP is indexed by the subsequences of
+// b and is extended.
+func (mp *MatrixProfile) updateB(newValues []float64) error {
+	if len(newValues) == 0 {
+		return nil
+	}
+
+	oldLenB := len(mp.B) - mp.W + 1
+	mp.B = append(mp.B, newValues...)
+	mp.N = len(mp.B)
+
+	euclidean := mp.Opts == nil || mp.Opts.Euclidean
+	mpxLayout := mp.MPB != nil
+
+	// swaps a and b so that each new subsequence of b is used as a query against all of a
+	swapped := MatrixProfile{A: mp.B, B: mp.A, W: mp.W, N: len(mp.A), Opts: mp.Opts}
+	return swapped.joinSubsequences(oldLenB, len(mp.B)-mp.W+1, euclidean || !mpxLayout, func(j int, profile []float64, bestVal float64, bestIdx int) {
+		if mpxLayout {
+			for i, d := range profile {
+				if isBetter(d, mp.MP[i], euclidean) {
+					mp.MP[i] = d
+					mp.Idx[i] = j
+				}
 			}
+			mp.MPB = append(mp.MPB, bestVal)
+			mp.IdxB = append(mp.IdxB, bestIdx)
+		} else {
+			mp.MP = append(mp.MP, bestVal)
+			mp.Idx = append(mp.Idx, bestIdx)
 		}
-		mp.MP[mp.N-mp.W] = minVal
-		mp.Idx[mp.N-mp.W] = minIdx
+	})
+}
+
+// joinSubsequences computes the distance profile of each subsequence of a from start
+// up to end against all of b and calls fn with the profile along with its best value
+// and index. Distances are converted to pearson correlations when euclidean is false.
+func (mp MatrixProfile) joinSubsequences(start, end int, euclidean bool, fn func(idx int, profile []float64, bestVal float64, bestIdx int)) error {
+	if err := mp.initCaches(); err != nil {
+		return err
+	}
+
+	profile := make([]float64, len(mp.B)-mp.W+1)
+	fft := mp.newFFT()
+	for idx := start; idx < end; idx++ {
+		if err := mp.distanceProfile(idx, profile, fft); err != nil {
+			return err
+		}
+		if !euclidean {
+			util.E2P(profile, mp.W)
+		}
+
+		bestVal := profile[0]
+		bestIdx := 0
+		for i, d := range profile {
+			if isBetter(d, bestVal, euclidean) {
+				bestVal = d
+				bestIdx = i
+			}
+		}
+		fn(idx, profile, bestVal, bestIdx)
 	}
 	return nil
 }
 
+// isBetter reports whether a matrix profile value of a is a closer match than b. Lower
+// is better for euclidean distances and higher is better for pearson correlations.
+func isBetter(a, b float64, euclidean bool) bool {
+	if euclidean {
+		return a < b
+	}
+	return a > b
+}
+
+// isBetterMatch reports whether the match a at index aIdx should replace the match b
+// at index bIdx, breaking ties in favor of the smaller index so the matrix profile
+// index is the same regardless of the order or batching of the updates.
+func isBetterMatch(a float64, aIdx int, b float64, bIdx int, euclidean bool) bool {
+	if a == b {
+		return aIdx < bIdx
+	}
+	return isBetter(a, b, euclidean)
+}
+
 // mpResult is the output struct from a batch processing for STAMP, STOMP, and MPX. This struct
 // can later be merged together in linear time or with a divide and conquer approach
 type mpResult struct {
@@ -603,7 +1876,8 @@ type mpResult struct {
 }
 
 // mergeMPResults reads from a slice of channels for Matrix Profile results and
-// updates the matrix profile in the struct
+// updates the matrix profile in the struct. Ties go to the smaller index so the
+// result doesn't depend on how the work was batched.
 func (mp *MatrixProfile) mergeMPResults(results []chan *mpResult, euclidean bool) error {
 	var err error
 
@@ -625,16 +1899,9 @@ func (mp *MatrixProfile) mergeMPResults(results []chan *mpResult, euclidean bool
 			continue
 		}
 		for j := 0; j < len(resultSlice[i].MP); j++ {
-			if euclidean {
-				if resultSlice[i].MP[j] <= mp.MP[j] {
-					mp.MP[j] = resultSlice[i].MP[j]
-					mp.Idx[j] = resultSlice[i].Idx[j]
-				}
-			} else {
-				if math.Abs(resultSlice[i].MP[j]) < math.Abs(mp.MP[j]) {
-					mp.MP[j] = resultSlice[i].MP[j]
-					mp.Idx[j] = resultSlice[i].Idx[j]
-				}
+			if isBetterMatch(resultSlice[i].MP[j], resultSlice[i].Idx[j], mp.MP[j], mp.Idx[j], euclidean) {
+				mp.MP[j] = resultSlice[i].MP[j]
+				mp.Idx[j] = resultSlice[i].Idx[j]
 			}
 		}
 
@@ -643,16 +1910,9 @@ func (mp *MatrixProfile) mergeMPResults(results []chan *mpResult, euclidean bool
 			continue
 		}
 		for j := 0; j < len(resultSlice[i].MPB); j++ {
-			if euclidean {
-				if resultSlice[i].MPB[j] <= mp.MPB[j] {
-					mp.MPB[j] = resultSlice[i].MPB[j]
-					mp.IdxB[j] = resultSlice[i].IdxB[j]
-				}
-			} else {
-				if math.Abs(resultSlice[i].MPB[j]) < math.Abs(mp.MPB[j]) {
-					mp.MPB[j] = resultSlice[i].MPB[j]
-					mp.IdxB[j] = resultSlice[i].IdxB[j]
-				}
+			if isBetterMatch(resultSlice[i].MPB[j], resultSlice[i].IdxB[j], mp.MPB[j], mp.IdxB[j], euclidean) {
+				mp.MPB[j] = resultSlice[i].MPB[j]
+				mp.IdxB[j] = resultSlice[i].IdxB[j]
 			}
 		}
 
@@ -660,16 +1920,52 @@ func (mp *MatrixProfile) mergeMPResults(results []chan *mpResult, euclidean bool
 	return err
 }
 
-// stamp uses random ordering to compute the matrix profile. User can specify the
-// sample to be anything between 0 and 1 so that the computation early terminates
-// and provides the current computed matrix profile. 1 represents the exact matrix
-// profile. This should compute far faster at the cost of an approximation of the
-// matrix profile. Stores the matrix profile and matrix profile index in the struct.
-func (mp *MatrixProfile) stamp() error {
-	if mp.Opts.SamplePct <= 0.0 {
-		return fmt.Errorf("must provide a sampling greater than 0 and at most 1, sample: %.3f", mp.Opts.SamplePct)
+// runBatches runs a function for each of the configured number of jobs concurrently
+// and merges the matrix profile each one returns into the matrix profile.
+func (mp *MatrixProfile) runBatches(fn func(batch int) *mpResult) error {
+	results := make([]chan *mpResult, mp.Opts.NJobs)
+	for i := 0; i < mp.Opts.NJobs; i++ {
+		results[i] = make(chan *mpResult)
+	}
+
+	var err error
+	done := make(chan bool)
+	go func() {
+		err = mp.mergeMPResults(results, true)
+		done <- true
+	}()
+
+	for batch := 0; batch < mp.Opts.NJobs; batch++ {
+		go func(idx int) {
+			results[idx] <- fn(idx)
+		}(batch)
+	}
+
+	// waits for all results to be read and merged before returning success
+	<-done
+	return err
+}
+
+// newBatchResult returns a matrix profile result of length n with no matches.
+func newBatchResult(n int) *mpResult {
+	r := &mpResult{
+		MP:  make([]float64, n),
+		Idx: make([]int, n),
+	}
+	for i := range r.MP {
+		r.MP[i] = math.Inf(1)
+		r.Idx[i] = math.MaxInt64
 	}
+	return r
+}
 
+// stamp uses random ordering to compute the matrix profile. User can specify the
+// sample in the STAMP options to be anything between 0 and 1 so that the computation
+// early terminates and provides the current computed matrix profile. 1 represents the
+// exact matrix profile. This should compute far faster at the cost of an
+// approximation of the matrix profile. Stores the matrix profile and matrix profile
+// index in the struct.
+func (mp *MatrixProfile) stamp() error {
 	if err := mp.initCaches(); err != nil {
 		return err
 	}
@@ -681,9 +1977,8 @@ func (mp *MatrixProfile) stamp() error {
 		mp.Idx[i] = math.MaxInt64
 	}
 
-	randIdx := rand.Perm(len(mp.A) - mp.W + 1)
-
 	batchSize := (len(mp.A)-mp.W+1)/mp.Opts.NJobs + 1
+	randIdx := mp.stampOrder(batchSize)
 	results := make([]chan *mpResult, mp.Opts.NJobs)
 	for i := 0; i < mp.Opts.NJobs; i++ {
 		results[i] = make(chan *mpResult)
@@ -706,7 +2001,7 @@ func (mp *MatrixProfile) stamp() error {
 	wg.Add(mp.Opts.NJobs)
 	for batch := 0; batch < mp.Opts.NJobs; batch++ {
 		go func(idx int) {
-			results[idx] <- mp.stampBatch(idx, batchSize, mp.Opts.SamplePct, randIdx, &wg)
+			results[idx] <- mp.stampBatch(idx, batchSize, mp.Opts.samplePct(), randIdx, &wg)
 		}(batch)
 	}
 	wg.Wait()
@@ -717,6 +2012,65 @@ func (mp *MatrixProfile) stamp() error {
 	return err
 }
 
+// stampOrder returns the order subsequences are sampled in, laid out so that each
+// batch of the given size processes the first part of its own slice. With a complexity
+// bias the order is a weighted random permutation favoring subsequences with a high
+// complexity estimate, the length of the z-normalized subsequence stretched out into a
+// line, and it is dealt across the batches so every batch samples the most complex
+// subsequences first.
+func (mp MatrixProfile) stampOrder(batchSize int) []int {
+	n := len(mp.A) - mp.W + 1
+	rng := rand.New(rand.NewSource(rand.Int63()))
+	if mp.Opts.STAMP != nil && mp.Opts.STAMP.Seed != 0 {
+		rng = rand.New(rand.NewSource(mp.Opts.STAMP.Seed))
+	}
+	if mp.Opts.STAMP == nil || !mp.Opts.STAMP.ComplexityBias {
+		return rng.Perm(n)
+	}
+
+	// the complexity estimate of each subsequence from a running sum of squared
+	// differences, scaled by the standard deviation to match the z-normalized shape
+	sq := make([]float64, len(mp.A))
+	for i := 1; i < len(mp.A); i++ {
+		d := mp.A[i] - mp.A[i-1]
+		sq[i] = sq[i-1] + d*d
+	}
+	weights := make([]float64, n)
+	var total float64
+	for i := range weights {
+		if mp.AStd[i] > 0 {
+			weights[i] = math.Sqrt(sq[i+mp.W-1]-sq[i]) / mp.AStd[i]
+		}
+		total += weights[i]
+	}
+
+	// weighted sampling without replacement orders by u^(1/w) for a uniform u, which
+	// is compared as log(u)/w. A floor keeps flat subsequences in the order.
+	floor := 0.01 * total / float64(n)
+	keys := make([]float64, n)
+	perm := make([]int, n)
+	for i := range keys {
+		keys[i] = math.Log(rng.Float64()) / (weights[i] + floor)
+		perm[i] = i
+	}
+	sort.Slice(perm, func(i, j int) bool {
+		return keys[perm[i]] > keys[perm[j]]
+	})
+
+	// the batch starting at b*batchSize gets every NJobs-th subsequence of the order
+	order := make([]int, n)
+	k := 0
+	for i := 0; i < batchSize; i++ {
+		for batch := 0; batch < mp.Opts.NJobs; batch++ {
+			if p := batch*batchSize + i; p < n {
+				order[p] = perm[k]
+				k++
+			}
+		}
+	}
+	return order
+}
+
 // stampBatch processes a batch set of rows in a matrix profile calculation
 func (mp MatrixProfile) stampBatch(idx, batchSize int, sample float64, randIdx []int, wg *sync.WaitGroup) *mpResult {
 	defer wg.Done()
@@ -737,7 +2091,7 @@ func (mp MatrixProfile) stampBatch(idx, batchSize int, sample float64, randIdx [
 
 	var err error
 	profile := make([]float64, len(result.MP))
-	fft := fourier.NewFFT(mp.N)
+	fft := mp.newFFT()
 	for i := 0; i < int(float64(batchSize)*sample); i++ {
 		if idx*batchSize+i >= len(randIdx) {
 			break
@@ -746,7 +2100,7 @@ func (mp MatrixProfile) stampBatch(idx, batchSize int, sample float64, randIdx [
 			return &mpResult{nil, nil, nil, nil, err}
 		}
 		for j := 0; j < len(profile); j++ {
-			if profile[j] <= result.MP[j] {
+			if isBetterMatch(profile[j], randIdx[idx*batchSize+i], result.MP[j], result.Idx[j], true) {
 				result.MP[j] = profile[j]
 				result.Idx[j] = randIdx[idx*batchSize+i]
 			}
@@ -789,13 +2143,24 @@ func (mp *MatrixProfile) stomp() error {
 		done <- true
 	}()
 
+	// a self join only visits the upper triangle of the symmetric distance matrix, so
+	// its rows are batched like the diagonals of MPX to even out the work per batch
+	var batchScheme []util.Batch
+	if mp.SelfJoin {
+		batchScheme = util.DiagBatchingScheme(len(mp.A)-mp.W+1, mp.Opts.NJobs)
+	}
+
 	// kick off multiple go routines to process a batch of rows returning back
 	// the matrix profile for that batch and any error encountered
 	var wg sync.WaitGroup
 	wg.Add(mp.Opts.NJobs)
 	for batch := 0; batch < mp.Opts.NJobs; batch++ {
 		go func(idx int) {
-			results[idx] <- mp.stompBatch(idx, batchSize, &wg)
+			if mp.SelfJoin {
+				results[idx] <- mp.stompSelfBatch(batchScheme[idx].Idx, batchScheme[idx].Size, &wg)
+			} else {
+				results[idx] <- mp.stompBatch(idx, batchSize, &wg)
+			}
 		}(batch)
 	}
 	wg.Wait()
@@ -806,11 +2171,10 @@ func (mp *MatrixProfile) stomp() error {
 	return err
 }
 
-// stompBatch processes a batch set of rows in matrix profile calculation. Each batch
-// will compute its first row's dot product and build the subsequent matrix profile and
-// matrix profile index using the stomp iterative algorithm. This also uses the very
-// first row's dot product to update the very first index of the current row's
-// dot product.
+// stompBatch processes a batch set of rows in matrix profile calculation of an AB join.
+// Each batch will compute its first row's dot product and build the subsequent matrix
+// profile and matrix profile index using the stomp iterative algorithm. The very first
+// index of each row's dot product is not covered by the recurrence and is recomputed.
 func (mp MatrixProfile) stompBatch(idx, batchSize int, wg *sync.WaitGroup) *mpResult {
 	defer wg.Done()
 	if idx*batchSize+mp.W > len(mp.A) {
@@ -819,11 +2183,13 @@ func (mp MatrixProfile) stompBatch(idx, batchSize int, wg *sync.WaitGroup) *mpRe
 	}
 
 	// compute for this batch the first row's sliding dot product
-	fft := fourier.NewFFT(mp.N)
-	dot := mp.crossCorrelate(mp.A[idx*batchSize:idx*batchSize+mp.W], fft)
+	fft := mp.newFFT()
+	dot, err := mp.crossCorrelate(mp.A[idx*batchSize:idx*batchSize+mp.W], fft)
+	if err != nil {
+		return &mpResult{nil, nil, nil, nil, err}
+	}
 
 	profile := make([]float64, len(dot))
-	var err error
 	if err = mp.calculateDistanceProfile(dot, idx*batchSize, profile); err != nil {
 		return &mpResult{nil, nil, nil, nil, err}
 	}
@@ -852,9 +2218,7 @@ func (mp MatrixProfile) stompBatch(idx, batchSize int, wg *sync.WaitGroup) *mpRe
 			dot[j] = dot[j-1] - mp.B[j-1]*mp.A[idx*batchSize+i-1] + mp.B[j+mp.W-1]*mp.A[idx*batchSize+i+mp.W-1]
 		}
 
-		// recompute the first cross correlation since the algorithm is only valid for
-		// points after it. Previous optimization of using a precomputed cache ONLY applies
-		// if we're doing a self-join and is invalidated with AB-joins of different time series
+		// the first cross correlation is not covered by the update above
 		nextDotZero = 0
 		for k := 0; k < mp.W; k++ {
 			nextDotZero += mp.A[idx*batchSize+i+k] * mp.B[k]
@@ -866,7 +2230,7 @@ func (mp MatrixProfile) stompBatch(idx, batchSize int, wg *sync.WaitGroup) *mpRe
 
 		// element wise min update of the matrix profile and matrix profile index
 		for j := 0; j < len(profile); j++ {
-			if profile[j] <= result.MP[j] {
+			if isBetterMatch(profile[j], idx*batchSize+i, result.MP[j], result.Idx[j], true) {
 				result.MP[j] = profile[j]
 				result.Idx[j] = idx*batchSize + i
 			}
@@ -875,14 +2239,180 @@ func (mp MatrixProfile) stompBatch(idx, batchSize int, wg *sync.WaitGroup) *mpRe
 	return result
 }
 
+// stompSelfBatch processes a batch of size rows of a self join starting at row start.
+// The distance matrix of a self join is symmetric, so each row only computes its
+// distances from the diagonal on and updates both its own entry and the entry of each
+// column it is compared with. A row's sliding dot products from the diagonal on only
+// depend on the previous row's from its diagonal on, so the stomp recurrence still
+// applies while computing each pair once.
+func (mp MatrixProfile) stompSelfBatch(start, size int, wg *sync.WaitGroup) *mpResult {
+	defer wg.Done()
+	n := len(mp.A) - mp.W + 1
+	if start >= n {
+		// got an index larger than mp.A so ignore
+		return &mpResult{}
+	}
+	end := start + size
+	if end > n {
+		end = n
+	}
+
+	// compute for this batch the first row's sliding dot product
+	dot, err := mp.crossCorrelate(mp.A[start:start+mp.W], mp.newFFT())
+	if err != nil {
+		return &mpResult{nil, nil, nil, nil, err}
+	}
+
+	// initialize this batch's matrix profile results
+	result := &mpResult{
+		MP:  make([]float64, n),
+		Idx: make([]int, n),
+	}
+	for i := range result.MP {
+		result.MP[i] = math.Inf(1)
+		result.Idx[i] = math.MaxInt64
+	}
+
+	exclZone := mp.exclusionZone(mp.W / 2)
+	profile := make([]float64, n)
+	for i := start; i < end; i++ {
+		if i > start {
+			for j := n - 1; j >= i; j-- {
+				dot[j] = dot[j-1] - mp.B[j-1]*mp.A[i-1] + mp.B[j+mp.W-1]*mp.A[i+mp.W-1]
+			}
+		}
+		mp.dotsToDistances(dot, i, i, profile)
+		mp.applyExclusionZone(profile, i, 0, exclZone)
+
+		// element wise min update of the row's and the columns' matrix profile and
+		// matrix profile index. The exclusion zone reaches one further before an index
+		// than after it, so a pair exactly exclZone apart is only a match of the later one
+		for j := i; j < n; j++ {
+			if isBetterMatch(profile[j], i, result.MP[j], result.Idx[j], true) {
+				result.MP[j] = profile[j]
+				result.Idx[j] = i
+			}
+			if j-i > exclZone && isBetterMatch(profile[j], j, result.MP[i], result.Idx[i], true) {
+				result.MP[i] = profile[j]
+				result.Idx[i] = j
+			}
+		}
+	}
+	return result
+}
+
+// mpxStats holds the sliding statistics of a timeseries needed by the MPX
+// algorithm. These only depend on the timeseries and subsequence length, so they
+// can be shared across many joins involving the same timeseries.
+type mpxStats struct {
+	mu  []float64 // sliding mean
+	sig []float64 // sliding inverse of the norm of each mean centered subsequence
+	df  []float64 // half the difference between the entering and leaving points
+	dg  []float64 // sum of the entering and leaving points centered by their means
+}
+
+// newMPXStats computes the MPX sliding statistics of a timeseries for a
+// subsequence length of w.
+func newMPXStats(ts []float64, w int) *mpxStats {
+	n := len(ts) - w + 1
+	s := &mpxStats{
+		df: make([]float64, n),
+		dg: make([]float64, n),
+	}
+	s.mu, s.sig = util.MuInvN(ts, w)
+	for i := 0; i < n-1; i++ {
+		s.df[i+1] = 0.5 * (ts[w+i] - ts[i])
+		s.dg[i+1] = (ts[w+i] - s.mu[1+i]) + (ts[i] - s.mu[i])
+	}
+	return s
+}
+
 func (mp *MatrixProfile) mpx() error {
+	sa := newMPXStats(mp.A, mp.W)
+	sb := sa
+	if !mp.SelfJoin {
+		sb = newMPXStats(mp.B, mp.W)
+	}
+	if err := mp.mpxWithStats(sa, sb); err != nil {
+		return err
+	}
+	if mp.SelfJoin {
+		mp.mpxStats = sa
+	}
+	return nil
+}
+
+// directStats returns the MPX sliding statistics of a self join when distance profiles
+// for motif discovery are cheaper to compute from them directly than with fourier
+// transforms, otherwise nil. The O(1) MPX recurrence only steps along a diagonal of
+// the distance matrix, while a distance profile is a row of it, so each distance takes
+// w steps against the O(log n) per distance of a transform. Only subsequences of at
+// most 2*log2(n) points for n subsequences qualify, see DiscoverMotifs.
+func (mp MatrixProfile) directStats() *mpxStats {
+	if !mp.SelfJoin || mp.Opts == nil || !mp.Opts.Euclidean || mp.Opts.Manhattan ||
+		mp.nonNormalized() || mp.Opts.recomputesFlat() {
+		return nil
+	}
+	st := mp.mpxStats
+	if mp.mpxStream != nil {
+		// streaming updates keep the statistics of the stream current
+		st = mp.mpxStream.stats
+	}
+	n := len(mp.A) - mp.W + 1
+	if st == nil || len(st.mu) != n || float64(mp.W) > 2*math.Log2(float64(n)) {
+		return nil
+	}
+	return st
+}
+
+// directDistanceProfile writes the distance between the subsequence of a starting at
+// idx and every subsequence of the self join to profile like distanceProfile, computing
+// each mean centered dot product directly with the cached MPX statistics.
+func (mp MatrixProfile) directDistanceProfile(st *mpxStats, idx int, profile []float64) {
+	q := make([]float64, mp.W)
+	copy(q, mp.A[idx:idx+mp.W])
+	floats.AddConst(-st.mu[idx], q)
+
+	w := float64(mp.W)
+	var std []float64
+	if mp.noiseVar() > 0 {
+		// the noise correction works on standard deviations rather than inverse norms
+		std = make([]float64, len(st.sig))
+		for i, s := range st.sig {
+			std[i] = 1 / (s * math.Sqrt(w))
+		}
+	}
+	for j := range st.mu {
+		// the query sums to 0, so the mean of the other subsequence drops out
+		corr := floats.Dot(q, mp.A[j:j+mp.W]) * st.sig[idx] * st.sig[j]
+		profile[j] = math.Abs(2 * w * (1 - corr))
+		if math.IsNaN(profile[j]) {
+			profile[j] = math.Inf(1)
+		}
+	}
+	var qstd float64
+	if std != nil {
+		qstd = std[idx]
+	}
+	mp.finishProfile(profile[:len(st.mu)], qstd, std)
+	mp.applyExclusionZone(profile, idx, 0, mp.exclusionZone(mp.W/2))
+}
+
+// mpxWithStats runs the MPX algorithm using precomputed sliding statistics for
+// the a and b timeseries.
+func (mp *MatrixProfile) mpxWithStats(sa, sb *mpxStats) error {
 	lenA := len(mp.A) - mp.W + 1
 	lenB := len(mp.B) - mp.W + 1
 
+	// pearson correlations are merged keeping the largest
+	worst := math.Inf(1)
+	if !mp.Opts.Euclidean {
+		worst = math.Inf(-1)
+	}
 	mp.MP = make([]float64, lenA)
 	mp.Idx = make([]int, lenA)
 	for i := 0; i < len(mp.MP); i++ {
-		mp.MP[i] = math.Inf(1)
+		mp.MP[i] = worst
 		mp.Idx[i] = math.MaxInt64
 	}
 
@@ -890,33 +2420,13 @@ func (mp *MatrixProfile) mpx() error {
 		mp.MPB = make([]float64, lenB)
 		mp.IdxB = make([]int, lenB)
 		for i := 0; i < len(mp.MPB); i++ {
-			mp.MPB[i] = math.Inf(1)
+			mp.MPB[i] = worst
 			mp.IdxB[i] = math.MaxInt64
 		}
 	}
 
-	mua, siga := util.MuInvN(mp.A, mp.W)
-	mub, sigb := mua, siga
-	if !mp.SelfJoin {
-		mub, sigb = util.MuInvN(mp.B, mp.W)
-	}
-
-	dfa := make([]float64, lenA)
-	dga := make([]float64, lenA)
-	for i := 0; i < lenA-1; i++ {
-		dfa[i+1] = 0.5 * (mp.A[mp.W+i] - mp.A[i])
-		dga[i+1] = (mp.A[mp.W+i] - mua[1+i]) + (mp.A[i] - mua[i])
-	}
-
-	dfb, dgb := dfa, dga
-	if !mp.SelfJoin {
-		dfb = make([]float64, lenB)
-		dgb = make([]float64, lenB)
-		for i := 0; i < lenB-1; i++ {
-			dfb[i+1] = 0.5 * (mp.B[mp.W+i] - mp.B[i])
-			dgb[i+1] = (mp.B[mp.W+i] - mub[1+i]) + (mp.B[i] - mub[i])
-		}
-	}
+	mua, siga, dfa, dga := sa.mu, sa.sig, sa.df, sa.dg
+	mub, sigb, dfb, dgb := sb.mu, sb.sig, sb.df, sb.dg
 
 	// setup for AB join
 	batchScheme := util.DiagBatchingScheme(lenA, mp.Opts.NJobs)
@@ -995,10 +2505,7 @@ func (mp *MatrixProfile) mpx() error {
 // mpxBatch processes a batch set of rows in matrix profile calculation.
 func (mp MatrixProfile) mpxBatch(idx int, mu, sig, df, dg []float64, batchSize int, wg *sync.WaitGroup) *mpResult {
 	defer wg.Done()
-	exclZone := 1 // for seljoin we should at least get rid of neighboring points
-	if mp.W/4 > exclZone {
-		exclZone = mp.W / 4
-	}
+	exclZone := mp.mpxExclusionZone()
 	if idx+exclZone > len(mp.A)-mp.W+1 {
 		// got an index larger than max lag so ignore
 		return &mpResult{}
@@ -1012,11 +2519,23 @@ func (mp MatrixProfile) mpxBatch(idx int, mu, sig, df, dg []float64, batchSize i
 		mpr.MP[i] = -1
 	}
 
+	// the noise correction in terms of pearson correlation is (w+1)*noiseStd^2*min(sig)^2
+	// since sig is the inverse of sqrt(w) times the standard deviation
+	noise := mp.noiseVar()
+	minCorr := mp.minCorrelation()
+
+	// a circular self join also excludes the diagonals whose lag wraps around to within
+	// the exclusion zone
+	maxDiag := len(mp.A) - mp.W + 1
+	if mp.circular() {
+		maxDiag -= exclZone - 1
+	}
+
 	var c, c_cmp float64
 	s1 := make([]float64, mp.W)
 	s2 := make([]float64, mp.W)
 	for diag := idx + exclZone; diag < idx+batchSize+exclZone; diag++ {
-		if diag >= len(mp.A)-mp.W+1 {
+		if diag >= maxDiag {
 			break
 		}
 
@@ -1035,11 +2554,19 @@ func (mp MatrixProfile) mpxBatch(idx int, mu, sig, df, dg []float64, batchSize i
 			if mp.Opts.RemapNegCorr && c_cmp < 0 {
 				c_cmp = -c_cmp
 			}
-			if c_cmp > mpr.MP[offset] {
+			if noise > 0 {
+				s := math.Min(sig[offset], sig[offset+diag])
+				c_cmp += noise * s * s
+			}
+			if c_cmp < minCorr {
+				// the pair is farther apart than the maximum distance
+				continue
+			}
+			if isBetterMatch(c_cmp, offset+diag, mpr.MP[offset], mpr.Idx[offset], false) {
 				mpr.MP[offset] = c_cmp
 				mpr.Idx[offset] = offset + diag
 			}
-			if c_cmp > mpr.MP[offset+diag] {
+			if isBetterMatch(c_cmp, offset, mpr.MP[offset+diag], mpr.Idx[offset+diag], false) {
 				mpr.MP[offset+diag] = c_cmp
 				mpr.Idx[offset+diag] = offset
 			}
@@ -1047,7 +2574,7 @@ func (mp MatrixProfile) mpxBatch(idx int, mu, sig, df, dg []float64, batchSize i
 	}
 
 	if mp.Opts.Euclidean {
-		util.P2E(mpr.MP, mp.W)
+		mp.p2e(mpr.MP)
 	}
 
 	return mpr
@@ -1077,6 +2604,11 @@ func (mp MatrixProfile) mpxabBatch(idx int, mua, siga, dfa, dga, mub, sigb, dfb,
 		mpr.MPB[i] = -1
 	}
 
+	// the noise correction in terms of pearson correlation is (w+1)*noiseStd^2*min(sig)^2
+	// since sig is the inverse of sqrt(w) times the standard deviation
+	noise := mp.noiseVar()
+	minCorr := mp.minCorrelation()
+
 	var c, c_cmp float64
 	var offsetMax int
 	s1 := make([]float64, mp.W)
@@ -1106,11 +2638,19 @@ func (mp MatrixProfile) mpxabBatch(idx int, mua, siga, dfa, dga, mub, sigb, dfb,
 			if mp.Opts.RemapNegCorr && c_cmp < 0 {
 				c_cmp = -c_cmp
 			}
-			if c_cmp > mpr.MP[offset+diag] {
+			if noise > 0 {
+				s := math.Min(sigb[offset], siga[offset+diag])
+				c_cmp += noise * s * s
+			}
+			if c_cmp < minCorr {
+				// the pair is farther apart than the maximum distance
+				continue
+			}
+			if isBetterMatch(c_cmp, offset, mpr.MP[offset+diag], mpr.Idx[offset+diag], false) {
 				mpr.MP[offset+diag] = c_cmp
 				mpr.Idx[offset+diag] = offset
 			}
-			if c_cmp > mpr.MPB[offset] {
+			if isBetterMatch(c_cmp, offset+diag, mpr.MPB[offset], mpr.IdxB[offset], false) {
 				mpr.MPB[offset] = c_cmp
 				mpr.IdxB[offset] = offset + diag
 			}
@@ -1118,8 +2658,8 @@ func (mp MatrixProfile) mpxabBatch(idx int, mua, siga, dfa, dga, mub, sigb, dfb,
 	}
 
 	if mp.Opts.Euclidean {
-		util.P2E(mpr.MP, mp.W)
-		util.P2E(mpr.MPB, mp.W)
+		mp.p2e(mpr.MP)
+		mp.p2e(mpr.MPB)
 	}
 
 	return mpr
@@ -1149,6 +2689,11 @@ func (mp MatrixProfile) mpxbaBatch(idx int, mua, siga, dfa, dga, mub, sigb, dfb,
 		mpr.MPB[i] = -1
 	}
 
+	// the noise correction in terms of pearson correlation is (w+1)*noiseStd^2*min(sig)^2
+	// since sig is the inverse of sqrt(w) times the standard deviation
+	noise := mp.noiseVar()
+	minCorr := mp.minCorrelation()
+
 	var c, c_cmp float64
 	var offsetMax int
 	s1 := make([]float64, mp.W)
@@ -1178,11 +2723,19 @@ func (mp MatrixProfile) mpxbaBatch(idx int, mua, siga, dfa, dga, mub, sigb, dfb,
 			if mp.Opts.RemapNegCorr && c_cmp < 0 {
 				c_cmp = -c_cmp
 			}
-			if c_cmp > mpr.MP[offset] {
+			if noise > 0 {
+				s := math.Min(siga[offset], sigb[offset+diag])
+				c_cmp += noise * s * s
+			}
+			if c_cmp < minCorr {
+				// the pair is farther apart than the maximum distance
+				continue
+			}
+			if isBetterMatch(c_cmp, offset+diag, mpr.MP[offset], mpr.Idx[offset], false) {
 				mpr.MP[offset] = c_cmp
 				mpr.Idx[offset] = offset + diag
 			}
-			if c_cmp > mpr.MPB[offset+diag] {
+			if isBetterMatch(c_cmp, offset, mpr.MPB[offset+diag], mpr.IdxB[offset+diag], false) {
 				mpr.MPB[offset+diag] = c_cmp
 				mpr.IdxB[offset+diag] = offset
 			}
@@ -1190,8 +2743,8 @@ func (mp MatrixProfile) mpxbaBatch(idx int, mua, siga, dfa, dga, mub, sigb, dfb,
 	}
 
 	if mp.Opts.Euclidean {
-		util.P2E(mpr.MP, mp.W)
-		util.P2E(mpr.MPB, mp.W)
+		mp.p2e(mpr.MP)
+		mp.p2e(mpr.MPB)
 	}
 
 	return mpr
@@ -1211,12 +2764,12 @@ func (mp MatrixProfile) Analyze(mo *MPOpts, ao *AnalyzeOpts) error {
 		ao = NewAnalyzeOpts()
 	}
 
-	_, err = mp.DiscoverMotifs(ao.kMotifs, ao.rMotifs, 10, mp.W/2)
+	_, err = mp.DiscoverMotifs(ao.kMotifs, ao.rMotifs, 10, mp.exclusionZone(mp.W/2))
 	if err != nil {
 		return err
 	}
 
-	_, err = mp.DiscoverDiscords(ao.kDiscords, mp.W/2)
+	_, err = mp.DiscoverDiscords(ao.kDiscords, mp.exclusionZone(mp.W/2))
 	if err != nil {
 		return err
 	}
@@ -1224,8 +2777,19 @@ func (mp MatrixProfile) Analyze(mo *MPOpts, ao *AnalyzeOpts) error {
 	return mp.Visualize(ao.OutputFilename)
 }
 
+// AutoRadius can be passed as the radius to DiscoverMotifs to choose the radius of
+// each motif automatically. It is negative so that a radius of 0 keeps its meaning of
+// only returning the seed pair of each motif.
+const AutoRadius = -1.0
+
 // DiscoverMotifs will iteratively go through the matrix profile to find the
-// top k motifs with a given radius. Only applies to self joins.
+// top k motifs with a given radius. Only applies to self joins. If the radius is
+// AutoRadius, the closest non trivial matches to each seed pair are collected up to
+// the neighbor count and the motif is cut at the largest jump between consecutive
+// distances, starting from the seed pair distance. After a z-normalized euclidean MPX
+// self join, subsequences of at most 2*log2(n) points for n subsequences take their
+// distance profiles directly from the MPX statistics, while longer ones, and every
+// other computation, use fourier transforms of the timeseries.
 func (mp *MatrixProfile) DiscoverMotifs(k int, radius float64, neighborCount, exclusionZone int) ([]MotifGroup, error) {
 	if !mp.SelfJoin {
 		return nil, errors.New("can only find top motifs if a self join is performed")
@@ -1240,19 +2804,27 @@ func (mp *MatrixProfile) DiscoverMotifs(k int, radius float64, neighborCount, ex
 
 	motifs := make([]MotifGroup, k)
 
-	mpCurrent, _, err := mp.ApplyAV()
+	mpCurrent, _, err := mp.ApplyAV(nil)
 	if err != nil {
 		return nil, err
 	}
+	mp.applyMask(mpCurrent)
 
-	if mp.BF == nil {
-		if err = mp.initCaches(); err != nil {
-			return nil, err
+	// after MPX the distance profiles of short subsequences come straight from its
+	// statistics, sparing the fourier transforms of the timeseries and of every seed
+	st := mp.directStats()
+	var fft *fftCache
+	if st == nil {
+		if mp.BF == nil {
+			if err = mp.initCaches(); err != nil {
+				return nil, err
+			}
 		}
+		fft = mp.newFFT()
 	}
 
-	prof := make([]float64, len(mpCurrent)) // stores minimum matrix profile distance between motif pairs
-	fft := fourier.NewFFT(mp.N)
+	prof := make([]float64, len(mpCurrent))     // stores minimum matrix profile distance between motif pairs
+	seedProf := make([]float64, len(mpCurrent)) // distance profile of the seed before any exclusion zones
 	var j int
 
 	for j = 0; j < k; j++ {
@@ -1271,15 +2843,20 @@ func (mp *MatrixProfile) DiscoverMotifs(k int, radius float64, neighborCount, ex
 			return motifs, nil
 		}
 
-		// filter out all indexes that have a distance within r*motifDistance
-		motifSet := make(map[int]struct{})
-		initialMotif := []int{minIdx, mp.Idx[minIdx]}
-		motifSet[minIdx] = struct{}{}
-		motifSet[mp.Idx[minIdx]] = struct{}{}
-
-		if err = mp.distanceProfile(initialMotif[0], prof, fft); err != nil {
+		if st != nil {
+			mp.directDistanceProfile(st, minIdx, prof)
+		} else if err = mp.distanceProfile(minIdx, prof, fft); err != nil {
 			return nil, err
 		}
+		mp.applyMask(prof)
+		copy(seedProf, prof)
+
+		// filter out all indexes that have a distance within r*motifDistance,
+		// keeping the distance of each member to the seed
+		motifSet := make(map[int]float64)
+		initialMotif := []int{minIdx, mp.Idx[minIdx]}
+		motifSet[minIdx] = 0
+		motifSet[mp.Idx[minIdx]] = seedProf[mp.Idx[minIdx]]
 
 		// kill off any indices around the initial motif pair since they are
 		// trivial solutions
@@ -1296,21 +2873,33 @@ func (mp *MatrixProfile) DiscoverMotifs(k int, radius float64, neighborCount, ex
 		// index found will have an exclusion zone applied as to remove
 		// trivial solutions. This eventually exits when there's nothing
 		// found within the radius distance.
-		for {
+		if radius == AutoRadius {
+			var candidates []int
+			dists := []float64{motifDistance}
+			for len(motifSet)+len(candidates) < neighborCount {
+				minDistIdx = floats.MinIdx(prof)
+				if math.IsInf(prof[minDistIdx], 1) {
+					break
+				}
+				candidates = append(candidates, minDistIdx)
+				dists = append(dists, prof[minDistIdx])
+				util.ApplyExclusionZone(prof, minDistIdx, exclusionZone)
+			}
+			for _, idx := range candidates[:kneeCut(dists)] {
+				motifSet[idx] = seedProf[idx]
+			}
+		}
+		for radius != AutoRadius && len(motifSet) < neighborCount {
 			minDistIdx = floats.MinIdx(prof)
 
 			if prof[minDistIdx] < motifDistance*radius {
-				motifSet[minDistIdx] = struct{}{}
+				motifSet[minDistIdx] = seedProf[minDistIdx]
 				util.ApplyExclusionZone(prof, minDistIdx, exclusionZone)
 			} else {
 				// the closest distance in the profile is greater than the desired
 				// distance so break
 				break
 			}
-			// we hit our limit of neighborCount so stop searching
-			if len(motifSet) == neighborCount {
-				break
-			}
 		}
 
 		// store the found motif indexes and create an exclusion zone around
@@ -1326,21 +2915,356 @@ func (mp *MatrixProfile) DiscoverMotifs(k int, radius float64, neighborCount, ex
 
 		// sorts the indices in ascending order
 		sort.IntSlice(motifs[j].Idx).Sort()
+		motifs[j].Dists = make([]float64, len(motifs[j].Idx))
+		for i, idx := range motifs[j].Idx {
+			motifs[j].Dists[i] = motifSet[idx]
+			motifs[j].Radius = math.Max(motifs[j].Radius, motifSet[idx])
+		}
 	}
 	mp.Motifs = motifs[:j]
 
 	return motifs[:j], nil
 }
 
+// MotifSubsequences returns a copy of the subsequence of a starting at each member of
+// the motif group, in the order of its indices. When normalize is true the
+// subsequences are z-normalized, which fails for a flat member.
+func (mp MatrixProfile) MotifSubsequences(g MotifGroup, normalize bool) ([][]float64, error) {
+	out := make([][]float64, len(g.Idx))
+	for i, idx := range g.Idx {
+		if idx < 0 || idx+mp.W > len(mp.A) {
+			return nil, fmt.Errorf("motif index, %d, is out of range of the %d subsequences", idx, len(mp.A)-mp.W+1)
+		}
+		if normalize {
+			var err error
+			if out[i], err = util.ZNormalize(mp.A[idx : idx+mp.W]); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		out[i] = make([]float64, mp.W)
+		copy(out[i], mp.A[idx:idx+mp.W])
+	}
+	return out, nil
+}
+
+// MotifTemplate returns the canonical shape of a motif group, the average of its
+// z-normalized members, z-normalized again so it compares with any occurrence. Flat
+// members have no shape and are left out. When the matrix profile remaps negative
+// correlations, members anti-correlated with the first member are flipped before being
+// averaged, so they reinforce the shape rather than cancel it.
+func (mp MatrixProfile) MotifTemplate(g MotifGroup) ([]float64, error) {
+	if len(g.Idx) == 0 {
+		return nil, errors.New("motif group has no members")
+	}
+	subs, err := mp.MotifSubsequences(g, false)
+	if err != nil {
+		return nil, err
+	}
+	remap := mp.Opts != nil && mp.Opts.RemapNegCorr
+
+	var ref []float64
+	sum := make([]float64, mp.W)
+	for _, sub := range subs {
+		norm, err := util.ZNormalize(sub)
+		if err != nil {
+			continue
+		}
+		if ref == nil {
+			ref = norm
+		}
+		sign := 1.0
+		if remap && floats.Dot(norm, ref) < 0 {
+			sign = -1
+		}
+		floats.AddScaled(sum, sign, norm)
+	}
+	if ref == nil {
+		return nil, errors.New("every member of the motif group is flat")
+	}
+
+	template, err := util.ZNormalize(sum)
+	if err != nil {
+		return nil, errors.New("members of the motif group cancel out")
+	}
+	return template, nil
+}
+
+// MotifsNear finds the motif of a shape given by the user rather than one seeded at the
+// minimum of the matrix profile. Every subsequence of b within radius of the query is an
+// occurrence, and the group is then expanded with the subsequences within radius of
+// the closest occurrence, which catches matches of the shape as it actually appears in
+// the data that an idealized query misses. Occurrences are picked closest first, each
+// applying an exclusion zone of half the subsequence length. The closest occurrence
+// seeds the group, so its distances and radius are measured from it and MinDist is the
+// distance from it to the closest other member, +Inf when it is alone. The query must
+// be as long as a subsequence, and the radius is in the units of the distance profile
+// as in MotifNeighbors.
+func (mp *MatrixProfile) MotifsNear(query []float64, radius float64) (MotifGroup, error) {
+	if radius < 0 {
+		return MotifGroup{}, fmt.Errorf("radius, %.3f, must not be negative", radius)
+	}
+	prof, err := mp.DistanceProfile(query)
+	if err != nil {
+		return MotifGroup{}, err
+	}
+	if mp.SelfJoin {
+		mp.applyMask(prof)
+	}
+
+	// pick is the closest subsequence within radius that isn't excluded yet
+	pick := func(prof []float64) int {
+		minDistIdx := floats.MinIdx(prof)
+		if !(prof[minDistIdx] <= radius) {
+			return -1
+		}
+		return minDistIdx
+	}
+
+	var members []int
+	for idx := pick(prof); idx >= 0; idx = pick(prof) {
+		members = append(members, idx)
+		util.ApplyExclusionZone(prof, idx, mp.W/2)
+	}
+	if len(members) == 0 {
+		return MotifGroup{}, fmt.Errorf("no subsequence is within a radius of %.3f of the query", radius)
+	}
+
+	seed := members[0]
+	toSeed, err := mp.DistanceProfile(mp.B[seed : seed+mp.W])
+	if err != nil {
+		return MotifGroup{}, err
+	}
+	prof = make([]float64, len(toSeed))
+	copy(prof, toSeed)
+	if mp.SelfJoin {
+		mp.applyMask(prof)
+	}
+	for _, idx := range members {
+		util.ApplyExclusionZone(prof, idx, mp.W/2)
+	}
+	for idx := pick(prof); idx >= 0; idx = pick(prof) {
+		members = append(members, idx)
+		util.ApplyExclusionZone(prof, idx, mp.W/2)
+	}
+
+	sort.Ints(members)
+	g := MotifGroup{Idx: members, MinDist: math.Inf(1), Dists: make([]float64, len(members))}
+	for k, idx := range members {
+		if idx == seed {
+			continue
+		}
+		g.Dists[k] = toSeed[idx]
+		g.MinDist = math.Min(g.MinDist, toSeed[idx])
+		g.Radius = math.Max(g.Radius, toSeed[idx])
+	}
+	return g, nil
+}
+
+// MotifNeighbors returns the starting indices, in ascending order, of every
+// subsequence within radius of the subsequence of a starting at idx using a single
+// distance profile. Each neighbor found applies an exclusion zone of half the
+// subsequence length so that trivial matches are not returned. The radius is in the
+// units of the distance profile, which are squared distances when the Squared option
+// is set. For a self join idx itself is included, and for an AB join the neighbors
+// are subsequences of b.
+func (mp *MatrixProfile) MotifNeighbors(idx int, radius float64) ([]int, error) {
+	if idx < 0 || idx > len(mp.A)-mp.W {
+		return nil, fmt.Errorf("index %d is outside of the %d subsequences of a", idx, len(mp.A)-mp.W+1)
+	}
+
+	if mp.BF == nil {
+		if err := mp.initCaches(); err != nil {
+			return nil, err
+		}
+	}
+
+	prof := make([]float64, len(mp.B)-mp.W+1)
+	if err := mp.distanceProfile(idx, prof, mp.newFFT()); err != nil {
+		return nil, err
+	}
+
+	var neighbors []int
+	if mp.SelfJoin {
+		neighbors = append(neighbors, idx)
+	}
+	for {
+		minDistIdx := floats.MinIdx(prof)
+		if !(prof[minDistIdx] <= radius) {
+			break
+		}
+		neighbors = append(neighbors, minDistIdx)
+		util.ApplyExclusionZone(prof, minDistIdx, mp.W/2)
+	}
+
+	sort.Ints(neighbors)
+	return neighbors, nil
+}
+
+// DensityProfile counts for each subsequence how many other subsequences lie within
+// radius of it, which separates one-off discords from rare but repeated events. Matches
+// are counted by scanning each distance profile in order and skipping any match within
+// half a subsequence length of the previously counted one, so that a single occurrence
+// is not counted multiple times through its trivially shifted neighbors. The radius is
+// in the units of the distance profile. Only applies to self joins.
+func (mp *MatrixProfile) DensityProfile(radius float64) ([]int, error) {
+	if !mp.SelfJoin {
+		return nil, errors.New("can only compute a density profile if a self join is performed")
+	}
+	if err := mp.initCaches(); err != nil {
+		return nil, err
+	}
+
+	njobs := 1
+	if mp.Opts != nil && mp.Opts.NJobs > 1 {
+		njobs = mp.Opts.NJobs
+	}
+
+	n := len(mp.A) - mp.W + 1
+	density := make([]int, n)
+	batchSize := n/njobs + 1
+	firstRow, err := mp.crossCorrelate(mp.A[:mp.W], mp.newFFT())
+	if err != nil {
+		return nil, err
+	}
+	errs := make([]error, njobs)
+
+	var wg sync.WaitGroup
+	wg.Add(njobs)
+	for batch := 0; batch < njobs; batch++ {
+		go func(batch int) {
+			defer wg.Done()
+			start := batch * batchSize
+			if start >= n {
+				return
+			}
+			end := start + batchSize
+			if end > n {
+				end = n
+			}
+
+			dot, err := mp.crossCorrelate(mp.A[start:start+mp.W], mp.newFFT())
+			if err != nil {
+				errs[batch] = err
+				return
+			}
+			profile := make([]float64, len(dot))
+			for i := start; i < end; i++ {
+				if i > start {
+					for j := n - 1; j > 0; j-- {
+						dot[j] = dot[j-1] - mp.A[j-1]*mp.A[i-1] + mp.A[j+mp.W-1]*mp.A[i+mp.W-1]
+					}
+					// the first cross correlation is not covered by the recurrence and
+					// matches the first row by symmetry
+					dot[0] = firstRow[i]
+				}
+				if err := mp.calculateDistanceProfile(dot, i, profile); err != nil {
+					errs[batch] = err
+					return
+				}
+
+				last := math.MinInt64
+				for j, d := range profile {
+					if d <= radius && (last == math.MinInt64 || j-last >= mp.W/2) {
+						density[i]++
+						last = j
+					}
+				}
+			}
+		}(batch)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+	return density, nil
+}
+
+// kneeCut returns the number of distances after the first to keep from an ascending
+// slice of distances by cutting at the largest jump between consecutive distances. If
+// the largest jump does not stand out from the average jump, all distances are kept.
+func kneeCut(dists []float64) int {
+	if len(dists) < 2 {
+		return 0
+	}
+
+	var cut int
+	var maxJump, total float64
+	for i := 1; i < len(dists); i++ {
+		jump := dists[i] - dists[i-1]
+		total += jump
+		if jump > maxJump {
+			maxJump = jump
+			cut = i - 1
+		}
+	}
+
+	if maxJump <= 2*total/float64(len(dists)-1) {
+		return len(dists) - 1
+	}
+	return cut
+}
+
 // DiscoverDiscords finds the top k time series discords starting indexes from a computed
 // matrix profile. Each discovery of a discord will apply an exclusion zone around
-// the found index so that new discords can be discovered.
+// the found index so that new discords can be discovered. Unlike motif discovery, which
+// lifts the subsequences annotated below 1 towards the maximum of the profile, the
+// profile is scaled by the annotation vector. Lifting would rank the regions marked as
+// uninteresting as the top discords, whereas scaling sinks them, so subsequences
+// annotated with 0 are never discords.
 func (mp *MatrixProfile) DiscoverDiscords(k int, exclusionZone int) ([]int, error) {
-	mpCurrent, _, err := mp.ApplyAV()
+	mpCurrent, err := mp.discordMP()
+	if err != nil {
+		return nil, err
+	}
+
+	mp.Discords = topDiscords(mpCurrent, k, exclusionZone)
+
+	return mp.Discords, nil
+}
+
+// Discord is a discovered discord along with the values that explain it.
+type Discord struct {
+	Rank  int     // position in the ranking, starting at 0 for the most anomalous
+	Idx   int     // starting index of the discord
+	NN    int     // starting index of its nearest neighbor, math.MaxInt64 if it has none
+	Dist  float64 // euclidean distance to its nearest neighbor
+	Score float64 // value the discords are ranked by, the distance scaled by the annotation vector
+}
+
+// DiscoverDiscordDetails finds the same top k discords as DiscoverDiscords, returning
+// the distance to and index of the nearest neighbor of each along with its rank, so
+// they can be thresholded and explained without reading them back from the matrix
+// profile.
+func (mp *MatrixProfile) DiscoverDiscordDetails(k int, exclusionZone int) ([]Discord, error) {
+	scores, err := mp.discordMP()
 	if err != nil {
 		return nil, err
 	}
+	ranked := make([]float64, len(scores))
+	copy(ranked, scores)
+	mp.Discords = topDiscords(ranked, k, exclusionZone)
+
+	dists := mp.euclideanMP()
+	out := make([]Discord, len(mp.Discords))
+	for rank, idx := range mp.Discords {
+		out[rank] = Discord{
+			Rank:  rank,
+			Idx:   idx,
+			NN:    mp.Idx[idx],
+			Dist:  dists[idx],
+			Score: scores[idx],
+		}
+	}
+	return out, nil
+}
 
+// topDiscords finds the indexes of the k largest finite values of a matrix profile,
+// applying an exclusion zone around each discovery. The profile is modified in place.
+func topDiscords(mpCurrent []float64, k int, exclusionZone int) []int {
 	// if requested k is larger than length of the matrix profile, cap it
 	if k > len(mpCurrent) {
 		k = len(mpCurrent)
@@ -1368,9 +3292,8 @@ func (mp *MatrixProfile) DiscoverDiscords(k int, exclusionZone int) ([]int, erro
 		discords[i] = maxIdx
 		util.ApplyExclusionZone(mpCurrent, maxIdx, exclusionZone)
 	}
-	mp.Discords = discords[:i]
 
-	return discords[:i], nil
+	return discords[:i]
 }
 
 // DiscoverSegments finds the the index where there may be a potential timeseries
@@ -1379,14 +3302,15 @@ func (mp *MatrixProfile) DiscoverDiscords(k int, exclusionZone int) ([]int, erro
 // the matrix profile index. This approach is based on the UCR paper on
 // segmentation of timeseries using matrix profiles which can be found
 // https://www.cs.ucr.edu/%7Eeamonn/Segmentation_ICDM.pdf
-func (mp MatrixProfile) DiscoverSegments() (int, float64, []float64) {
-	histo := arcCurve(mp.Idx)
-
-	for i := 0; i < len(histo); i++ {
-		if i == 0 || i == len(histo)-1 {
-			histo[i] = math.Min(1.0, float64(len(histo)))
-		} else {
-			histo[i] = math.Min(1.0, histo[i]/iac(float64(i), len(histo)))
+// A custom annotation vector, such as one set with ApplyAV, lifts the arc curve of
+// the subsequences annotated below 1 so they are not reported as changes, and an
+// error is returned if it does not match the matrix profile.
+func (mp MatrixProfile) DiscoverSegments() (int, float64, []float64, error) {
+	histo := correctedArcCurve(mp.Idx)
+	if mp.CustomAV != nil {
+		var err error
+		if histo, err = applySingleAV(histo, mp.A, mp.W, mp.AV, mp.CustomAV); err != nil {
+			return 0, 0, nil, err
 		}
 	}
 
@@ -1399,7 +3323,7 @@ func (mp MatrixProfile) DiscoverSegments() (int, float64, []float64) {
 		}
 	}
 
-	return minIdx, float64(minVal), histo
+	return minIdx, float64(minVal), histo, nil
 }
 
 // Visualize creates a png of the matrix profile given a matrix profile.
@@ -1427,3 +3351,36 @@ func (mp MatrixProfile) Visualize(fn string) error {
 
 	return plotMP(sigPts, mpPts, motifPts, discordPts, discordLabels, fn)
 }
+
+// VisualizeOverlay creates a png of the signal with the occurrences of each motif group,
+// the discords, and the given anomaly events drawn as shaded regions on top of it, with
+// a legend keyed by motif group. Motifs and discords are taken from the last calls to
+// DiscoverMotifs and DiscoverDiscords, and events may be nil.
+func (mp MatrixProfile) VisualizeOverlay(fn string, events []AnomalyEvent) error {
+	var groups []overlayGroup
+	for i, motif := range mp.Motifs {
+		g := overlayGroup{label: fmt.Sprintf("motif %d", i)}
+		for _, idx := range motif.Idx {
+			g.spans = append(g.spans, [2]float64{float64(idx), float64(idx + mp.W - 1)})
+		}
+		groups = append(groups, g)
+	}
+
+	if len(mp.Discords) > 0 {
+		g := overlayGroup{label: "discords"}
+		for _, idx := range mp.Discords {
+			g.spans = append(g.spans, [2]float64{float64(idx), float64(idx + mp.W - 1)})
+		}
+		groups = append(groups, g)
+	}
+
+	if len(events) > 0 {
+		g := overlayGroup{label: "anomaly events"}
+		for _, e := range events {
+			g.spans = append(g.spans, [2]float64{float64(e.Start), float64(e.End - 1)})
+		}
+		groups = append(groups, g)
+	}
+
+	return plotOverlay(points(mp.A, len(mp.A)), groups, fn)
+}
@@ -6,7 +6,6 @@ import (
 
 	"github.com/matrix-profile-foundation/go-matrixprofile/siggen"
 	"github.com/matrix-profile-foundation/go-matrixprofile/util"
-	"gonum.org/v1/gonum/dsp/fourier"
 )
 
 func setupData(numPoints int) []float64 {
@@ -71,9 +70,12 @@ func BenchmarkCrossCorrelate(b *testing.B) {
 		b.Error(err)
 	}
 
-	fft := fourier.NewFFT(mp.N)
+	fft := mp.newFFT()
 	for i := 0; i < b.N; i++ {
-		cc = mp.crossCorrelate(q, fft)
+		cc, err = mp.crossCorrelate(q, fft)
+		if err != nil {
+			b.Error(err)
+		}
 		if len(cc) < 1 {
 			b.Error("expected at least one value from cross correlation of a timeseries")
 		}
@@ -95,7 +97,7 @@ func BenchmarkMass(b *testing.B) {
 	}
 
 	mprof := make([]float64, mp.N-mp.W+1)
-	fft := fourier.NewFFT(mp.N)
+	fft := mp.newFFT()
 	for i := 0; i < b.N; i++ {
 		q = sig[:32]
 		err = mp.mass(q, mprof, fft)
@@ -122,7 +124,7 @@ func BenchmarkDistanceProfile(b *testing.B) {
 	}
 
 	mprof := make([]float64, mp.N-mp.W+1)
-	fft := fourier.NewFFT(mp.N)
+	fft := mp.newFFT()
 	for i := 0; i < b.N; i++ {
 		err = mp.distanceProfile(0, mprof, fft)
 		if err != nil {
@@ -147,8 +149,11 @@ func BenchmarkCalculateDistanceProfile(b *testing.B) {
 		b.Error(err)
 	}
 
-	fft := fourier.NewFFT(mp.N)
-	dot := mp.crossCorrelate(mp.A[:mp.W], fft)
+	fft := mp.newFFT()
+	dot, err := mp.crossCorrelate(mp.A[:mp.W], fft)
+	if err != nil {
+		b.Error(err)
+	}
 
 	mprof := make([]float64, len(dot))
 
@@ -207,7 +212,6 @@ func BenchmarkStamp(b *testing.B) {
 
 	o := NewMPOpts()
 	o.Algorithm = AlgoSTAMP
-	o.SamplePct = 1.0
 	o.NJobs = 2
 
 	b.Run("m32_p2_pts1k", func(b *testing.B) {
@@ -1,15 +1,38 @@
 package matrixprofile
 
 import (
+	"bytes"
+	"container/heap"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
 	"math"
+	"math/rand"
 	"os"
+	"path/filepath"
+	"reflect"
 	"sort"
 	"testing"
+	"time"
 
 	"github.com/matrix-profile-foundation/go-matrixprofile/av"
-	"gonum.org/v1/gonum/dsp/fourier"
+	"github.com/matrix-profile-foundation/go-matrixprofile/siggen"
+	"github.com/matrix-profile-foundation/go-matrixprofile/util"
+	"gonum.org/v1/gonum/floats"
 )
 
+// seededNoise is siggen.Noise drawn from a seeded source, so tests that depend on
+// where matches land are reproducible.
+func seededNoise(seed int64, amp float64, n int) []float64 {
+	r := rand.New(rand.NewSource(seed))
+	out := make([]float64, n)
+	for i := range out {
+		out[i] = amp * (r.Float64() - 0.5)
+	}
+	return out
+}
+
 func TestNew(t *testing.T) {
 	testdata := []struct {
 		a           []float64
@@ -64,7 +87,7 @@ func TestApplyAVDefault(t *testing.T) {
 		}
 
 		mp.AV = av.Default
-		outab, _, err = mp.ApplyAV()
+		outab, _, err = mp.ApplyAV(nil)
 		if err != nil {
 			t.Fatal(err)
 		}
@@ -82,6 +105,50 @@ func TestApplyAVDefault(t *testing.T) {
 	}
 }
 
+func TestApplyCustomAV(t *testing.T) {
+	mp, err := New(siggen.Add(siggen.Sin(1, 4, 0, 0, 100, 2), siggen.Noise(0.1, 200)), nil, 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = mp.Compute(NewMPOpts()); err != nil {
+		t.Fatal(err)
+	}
+
+	mp.CustomAV = make([]float64, len(mp.MP)-1)
+	if _, _, err = mp.ApplyAV(nil); err == nil {
+		t.Errorf("Expected an error for a custom annotation vector of the wrong length")
+	}
+
+	// suppress a known event while keeping the default elsewhere
+	events := make([]float64, len(mp.MP))
+	for i := range events {
+		if i < 50 || i >= 60 {
+			events[i] = 1
+		}
+	}
+	def, err := av.Create(av.Default, mp.A, mp.W)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mp.CustomAV, err = av.Compose(av.Multiply, def, events); err != nil {
+		t.Fatal(err)
+	}
+
+	outab, _, err := mp.ApplyAV(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range outab {
+		if i >= 50 && i < 60 {
+			if outab[i] <= mp.MP[i] {
+				t.Errorf("Expected the suppressed value at %d to be raised above %.3f, but got %.3f", i, mp.MP[i], outab[i])
+			}
+		} else if math.Abs(outab[i]-mp.MP[i]) > 1e-7 {
+			t.Errorf("Expected %.3f at %d, but got %.3f", mp.MP[i], i, outab[i])
+		}
+	}
+}
+
 func TestSave(t *testing.T) {
 	ts := []float64{1, 2, 3, 4, 5, 6, 7, 8, 9}
 	w := 3
@@ -125,6 +192,200 @@ func TestLoad(t *testing.T) {
 
 }
 
+// memStore is an in memory object store.
+type memStore map[string][]byte
+
+func (m memStore) Put(ctx context.Context, bucket, key string, data []byte) error {
+	m[bucket+"/"+key] = append([]byte(nil), data...)
+	return nil
+}
+
+func (m memStore) Get(ctx context.Context, bucket, key string) (io.ReadCloser, error) {
+	data, ok := m[bucket+"/"+key]
+	if !ok {
+		return nil, fmt.Errorf("object %s/%s not found", bucket, key)
+	}
+	return ioutil.NopCloser(bytes.NewReader(data)), nil
+}
+
+func TestSaveLoadObject(t *testing.T) {
+	ts := []float64{1, 2, 3, 4, 5, 6, 7, 8, 9}
+	w := 3
+	p, err := New(ts, nil, w)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = p.Compute(NewMPOpts()); err != nil {
+		t.Fatal(err)
+	}
+
+	store := memStore{}
+	ctx := context.Background()
+	if err = p.SaveObject(ctx, store, "profiles", "mp.json", "csv", 0); err == nil {
+		t.Errorf("Expected an error for an invalid save format")
+	}
+	if err = p.SaveObject(ctx, store, "profiles", "mp.json", "json", 0); err != nil {
+		t.Fatal(err)
+	}
+
+	newP := &MatrixProfile{}
+	if err = newP.LoadObject(ctx, store, "profiles", "missing.json", "json"); err == nil {
+		t.Errorf("Expected an error for a missing object")
+	}
+	if err = newP.LoadObject(ctx, store, "profiles", "mp.json", "json"); err != nil {
+		t.Fatal(err)
+	}
+	if newP.W != w || len(newP.MP) != len(p.MP) {
+		t.Errorf("Expected window of %d and %d profile values, but got %d and %d", w, len(p.MP), newP.W, len(newP.MP))
+	}
+}
+
+func TestApplyAV(t *testing.T) {
+	mp, err := New(siggen.Add(siggen.Sin(1, 4, 0, 0, 100, 2), seededNoise(1, 0.1, 200)), nil, 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = mp.Compute(NewMPOpts()); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err = mp.ApplyAV(make([]float64, len(mp.MP)-1)); err == nil {
+		t.Errorf("Expected an error for an annotation vector of the wrong length")
+	}
+	if mp.CustomAV != nil {
+		t.Errorf("Expected an invalid annotation vector not to be kept, but got %v", mp.CustomAV)
+	}
+
+	annotation := make([]float64, len(mp.MP))
+	for i := range annotation {
+		if i < 50 || i >= 60 {
+			annotation[i] = 1
+		}
+	}
+	outab, _, err := mp.ApplyAV(annotation)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(mp.CustomAV, annotation) {
+		t.Errorf("Expected the annotation vector to be kept for later calls, but got %v", mp.CustomAV)
+	}
+	maxMP := floats.Max(mp.MP)
+	for i := range outab {
+		expected := mp.MP[i] + (1-annotation[i])*maxMP
+		if math.Abs(outab[i]-expected) > 1e-7 {
+			t.Errorf("Expected %.4f at %d, but got %.4f", expected, i, outab[i])
+			break
+		}
+	}
+
+	// motifs are found on the corrected matrix profile
+	motifs, err := mp.DiscoverMotifs(3, 2, 10, mp.W/2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, g := range motifs {
+		for _, idx := range g.Idx[:2] {
+			if idx >= 50 && idx < 60 {
+				t.Errorf("Expected no motif pair in the annotated region, but got %v", g.Idx)
+			}
+		}
+	}
+
+	// the subsequence at 5 would be the segment boundary without the annotation
+	seg := MatrixProfile{A: make([]float64, 10), W: 4, MP: make([]float64, 7), Idx: []int{4, 5, 6, 0, 2, 1, 0}, AV: av.Default, Opts: NewMPOpts()}
+	if idx, _, _, err := seg.DiscoverSegments(); err != nil || idx != 5 {
+		t.Fatalf("Expected a segment boundary at 5, but got %d with %v", idx, err)
+	}
+	if _, _, err = seg.ApplyAV([]float64{1, 1, 1, 1, 1, 0, 1}); err != nil {
+		t.Fatal(err)
+	}
+	idx, val, histo, err := seg.DiscoverSegments()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if idx == 5 || math.Abs(histo[5]-1.7) > 1e-7 {
+		t.Errorf("Expected the annotated boundary to be lifted to 1.7, but got %d at %.3f with %v", idx, val, histo)
+	}
+
+	// a custom annotation vector that does not match the matrix profile is reported
+	for _, custom := range [][]float64{{1, 1, 1}, {1, 1, 1, 1, 1, 2, 1}} {
+		seg.CustomAV = custom
+		if _, _, _, err = seg.DiscoverSegments(); err == nil {
+			t.Errorf("Expected an error for the custom annotation vector %v", custom)
+		}
+		if _, _, err = seg.ApplyAV(nil); err == nil {
+			t.Errorf("Expected ApplyAV to reject the custom annotation vector %v", custom)
+		}
+	}
+}
+
+func TestCustomAVDiscovery(t *testing.T) {
+	w := 20
+	sig := siggen.Add(siggen.Sin(1, 4, 0, 0, 100, 4), seededNoise(1, 0.1, 400))
+	known, unknown := 100, 300
+	for i := 0; i < w/2; i++ {
+		sig[known+i] += 3
+		sig[unknown+i] -= 3
+	}
+	nearUnknown := func(idx int) bool {
+		return idx >= unknown-w && idx <= unknown+w/2
+	}
+
+	mp, err := New(sig, nil, w)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = mp.Compute(NewMPOpts()); err != nil {
+		t.Fatal(err)
+	}
+
+	// the known anomaly is uninteresting, so discovery must skip past it
+	mp.CustomAV = make([]float64, len(mp.MP))
+	for i := range mp.CustomAV {
+		if i < known-w || i > known+w {
+			mp.CustomAV[i] = 1
+		}
+	}
+
+	// lifting the annotated region as for motifs would make it the top discord
+	lifted, _, err := mp.ApplyAV(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if top := floats.MaxIdx(lifted); top < known-w || top > known+w {
+		t.Errorf("Expected the lifted profile to peak in the annotated region, but got %d", top)
+	}
+
+	discords, err := mp.DiscoverDiscords(1, w/2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(discords) != 1 || !nearUnknown(discords[0]) {
+		t.Errorf("Expected a discord near %d, but got %v", unknown, discords)
+	}
+
+	events, err := mp.AnomalyEvents(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) == 0 {
+		t.Errorf("Expected an event near %d, but got none", unknown)
+	}
+	for _, e := range events {
+		if e.Start < known+w/2 && known < e.End {
+			t.Errorf("Expected no event over the annotated anomaly, but got %+v", e)
+		}
+	}
+
+	events, err = mp.DiscordEvents(1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 || !nearUnknown(events[0].Peak) {
+		t.Errorf("Expected a discord event near %d, but got %+v", unknown, events)
+	}
+}
+
 func TestMPDist(t *testing.T) {
 	testData := []struct {
 		a        []float64
@@ -156,6 +417,193 @@ func TestMPDist(t *testing.T) {
 	}
 }
 
+func TestMPDistDefinition(t *testing.T) {
+	a := siggen.Add(siggen.Sin(1, 5, 0, 0, 100, 1), siggen.Noise(0.2, 120))
+	b := siggen.Add(siggen.Sin(1, 7, 0, 0, 100, 1), siggen.Noise(0.2, 90))
+	w := 12
+
+	// concatenates the nearest neighbor distance of every subsequence of a in b and of b
+	// in a, then picks the value at 5% of the combined length
+	var abba []float64
+	for _, pair := range [][2][]float64{{a, b}, {b, a}} {
+		x, y := pair[0], pair[1]
+		for i := 0; i+w <= len(x); i++ {
+			abba = append(abba, floats.Min(bruteDistanceProfile(x[i:i+w], y)))
+		}
+	}
+	sort.Float64s(abba)
+	expected := abba[int(0.05*float64(len(a)+len(b)))]
+
+	dist, err := MPDist(a, b, w, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if math.Abs(dist-expected) > 1e-6 {
+		t.Errorf("Expected %.6f, but got %.6f", expected, dist)
+	}
+
+	o := NewMPDistOpts()
+	o.Opts.Algorithm = "scampi"
+	if _, err = MPDist(a, b, w, o); err == nil {
+		t.Errorf("Expected an error for an invalid algorithm")
+	}
+	if _, err = MPDist(a, b, 200, nil); err == nil {
+		t.Errorf("Expected an error for a subsequence longer than the timeseries")
+	}
+}
+
+func TestMPDistKthValue(t *testing.T) {
+	// 97 values in each direction of the join of two timeseries of 100 points, so the
+	// distance is the value at index 10 of the 194 values in ascending order. A heap
+	// ordered the wrong way round would keep the smallest value at its root instead
+	r := rand.New(rand.NewSource(1))
+	values := r.Perm(194)
+	mpab, mpba := make([]float64, 97), make([]float64, 97)
+	for i := range mpab {
+		mpab[i] = float64(values[i]) / 10
+		mpba[i] = float64(values[97+i]) / 10
+	}
+
+	for _, euclidean := range []bool{true, false} {
+		mp := MatrixProfile{A: make([]float64, 100), B: make([]float64, 100), W: 4, AV: av.Default, Opts: NewMPOpts()}
+		mp.MP = append([]float64(nil), mpab...)
+		mp.MPB = append([]float64(nil), mpba...)
+		expected := 1.0
+		if !euclidean {
+			// correlations are ranked from the highest down
+			mp.Opts.Euclidean = false
+			for i := range mp.MP {
+				mp.MP[i] = 1 - mp.MP[i]/100
+				mp.MPB[i] = 1 - mp.MPB[i]/100
+			}
+			expected = 1 - expected/100
+		}
+
+		dist, err := mp.mpDist()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if math.Abs(dist-expected) > 1e-9 {
+			t.Errorf("Expected the 11th best value, %.4f, with euclidean %t, but got %.4f", expected, euclidean, dist)
+		}
+	}
+}
+
+// minMPVals orders mpVals the way it was ordered before it became a max heap, with
+// the smallest value at the root.
+type minMPVals struct{ mpVals }
+
+func (m minMPVals) Less(i, j int) bool {
+	return m.mpVals[i] < m.mpVals[j]
+}
+
+// lowestMP replays the selection of mpDist over the values with the given heap.
+func lowestMP(h heap.Interface, root func() float64, k int, vals ...[]float64) float64 {
+	for _, v := range vals {
+		for _, d := range v {
+			if h.Len() == k+1 {
+				if d < root() {
+					heap.Pop(h)
+					heap.Push(h, d)
+				}
+			} else {
+				heap.Push(h, d)
+			}
+		}
+	}
+	return root()
+}
+
+func TestMPDistHeapOrder(t *testing.T) {
+	// the same join as TestMPDistKthValue. Ordered as a min heap the root is the
+	// smallest value kept, so the selection returned the best value, 0.0, instead of
+	// the 11th best one, 1.0
+	r := rand.New(rand.NewSource(1))
+	values := r.Perm(194)
+	mpab, mpba := make([]float64, 97), make([]float64, 97)
+	for i := range mpab {
+		mpab[i] = float64(values[i]) / 10
+		mpba[i] = float64(values[97+i]) / 10
+	}
+	k := int(mpdistThresh * 200)
+
+	old := &minMPVals{}
+	oldDist := lowestMP(old, func() float64 { return old.mpVals[0] }, k, mpab, mpba)
+	cur := &mpVals{}
+	curDist := lowestMP(cur, func() float64 { return (*cur)[0] }, k, mpab, mpba)
+	if oldDist != 0 {
+		t.Errorf("Expected the min heap to pick 0.0, but got %.4f", oldDist)
+	}
+	if curDist != 1 {
+		t.Errorf("Expected the max heap to pick 1.0, but got %.4f", curDist)
+	}
+
+	mp := MatrixProfile{A: make([]float64, 100), B: make([]float64, 100), W: 4, AV: av.Default, Opts: NewMPOpts()}
+	mp.MP, mp.MPB = mpab, mpba
+	dist, err := mp.mpDist()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dist != curDist {
+		t.Errorf("Expected MPDist to pick %.4f like the max heap, but got %.4f", curDist, dist)
+	}
+}
+
+func TestMPDistMatrix(t *testing.T) {
+	series := [][]float64{
+		siggen.Add(siggen.Sin(1, 4, 0, 0, 100, 2), siggen.Noise(0.1, 200)),
+		siggen.Add(siggen.Sin(1, 4, 0, 0, 100, 2), siggen.Noise(0.1, 200)),
+		siggen.Add(siggen.Sawtooth(1, 5, 0, 0, 100, 2), siggen.Noise(0.1, 200)),
+		siggen.Add(siggen.Square(1, 3, 0, 0, 100, 3), siggen.Noise(0.1, 300)),
+	}
+
+	testdata := []struct {
+		series      [][]float64
+		w           int
+		njobs       int
+		expectedErr bool
+	}{
+		{series, 1, 1, true},
+		{series, 250, 1, true},
+		{series, 20, 1, false},
+		{series, 20, 3, false},
+		{series[:1], 20, 2, false},
+		{[][]float64{}, 20, 2, false},
+	}
+
+	for _, d := range testdata {
+		o := NewMPDistOpts()
+		o.Opts.NJobs = d.njobs
+		dist, err := MPDistMatrix(d.series, d.w, o)
+		if d.expectedErr {
+			if err == nil {
+				t.Errorf("Expected an error for w=%d, but got none", d.w)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("Did not expect an error, %v", err)
+		}
+		if len(dist) != len(d.series) {
+			t.Fatalf("Expected %d rows, but got %d", len(d.series), len(dist))
+		}
+		for i := range d.series {
+			if dist[i][i] != 0 {
+				t.Errorf("Expected a zero diagonal, but got %.6f at %d", dist[i][i], i)
+			}
+			for j := i + 1; j < len(d.series); j++ {
+				expected, err := MPDist(d.series[i], d.series[j], d.w, nil)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if math.Abs(dist[i][j]-expected) > 1e-6 || dist[i][j] != dist[j][i] {
+					t.Errorf("Expected %.6f for pair (%d, %d), but got %.6f and %.6f", expected, i, j, dist[i][j], dist[j][i])
+				}
+			}
+		}
+	}
+}
+
 func TestCrossCorrelate(t *testing.T) {
 	var err error
 	var out []float64
@@ -171,6 +619,9 @@ func TestCrossCorrelate(t *testing.T) {
 		{[]float64{1, 2}, []float64{1, 2, 3, 3, 2, 1, 1}, []float64{5, 8, 9, 7, 4, 3}},
 		{[]float64{1, 2, 1}, []float64{1, 2, 3, 4, 3, 2, 1}, []float64{8, 12, 14, 12, 8}},
 		{[]float64{1, 2, 1}, []float64{1, 2, 3, 4, 3, 2, 1, 1}, []float64{8, 12, 14, 12, 8, 5}},
+		{[]float64{1, 2, 1}, []float64{1, 2, 3, 4}, []float64{8, 12}},
+		{[]float64{1, 2, 3, 4}, []float64{4, 3, 2, 1, 0}, []float64{20, 10}},
+		{[]float64{1, 2, 3}, []float64{1, 2, 3}, []float64{14}},
 	}
 
 	for _, d := range testdata {
@@ -188,8 +639,8 @@ func TestCrossCorrelate(t *testing.T) {
 			t.Errorf("Failed to initialize cache, %v", err)
 		}
 
-		fft := fourier.NewFFT(mp.N)
-		out = mp.crossCorrelate(d.q, fft)
+		fft := mp.newFFT()
+		out, err = mp.crossCorrelate(d.q, fft)
 		if err != nil && d.expected == nil {
 			// Got an error while z normalizing and expected an error
 			continue
@@ -214,6 +665,17 @@ func TestCrossCorrelate(t *testing.T) {
 		}
 
 	}
+
+	mp, err = New([]float64{1, 2}, []float64{1, 2, 3}, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = mp.initCaches(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = mp.crossCorrelate([]float64{1, 2, 3, 4}, mp.newFFT()); err == nil {
+		t.Errorf("Expected an error for a query longer than the timeseries")
+	}
 }
 
 func TestMass(t *testing.T) {
@@ -246,7 +708,7 @@ func TestMass(t *testing.T) {
 			t.Errorf("Failed to initialize cache, %v", err)
 		}
 		out = make([]float64, mp.N-mp.W+1)
-		fft := fourier.NewFFT(mp.N)
+		fft := mp.newFFT()
 		err = mp.mass(d.q, out, fft)
 		if err != nil && d.expected == nil {
 			// Got an error while z normalizing and expected an error
@@ -308,7 +770,7 @@ func TestDistanceProfile(t *testing.T) {
 		}
 
 		mprof = make([]float64, mp.N-mp.W+1)
-		fft := fourier.NewFFT(mp.N)
+		fft := mp.newFFT()
 		err = mp.distanceProfile(d.idx, mprof, fft)
 		if err != nil && d.expectedMP == nil {
 			// Got an error while z normalizing and expected an error
@@ -335,6 +797,72 @@ func TestDistanceProfile(t *testing.T) {
 	}
 }
 
+func TestMatrixProfileDistanceProfile(t *testing.T) {
+	sig := siggen.Add(siggen.Sin(1, 4, 0, 0, 100, 2), siggen.Noise(0.2, 200))
+	q := siggen.Add(siggen.Sin(1, 4, 0, 0, 100, 0.2), siggen.Noise(0.1, 20))
+
+	testdata := []struct {
+		algo Algo // empty to leave the matrix profile uncomputed
+		raw  bool
+	}{
+		{"", false},
+		{AlgoSTOMP, false},
+		{AlgoMPX, false},
+		{AlgoAAMP, true},
+	}
+
+	for _, d := range testdata {
+		mp, err := New(sig, nil, 20)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if d.algo != "" {
+			o := NewMPOpts()
+			o.Algorithm = d.algo
+			if err = mp.Compute(o); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		for _, query := range [][]float64{q, sig[40:60]} {
+			profile, err := mp.DistanceProfile(query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			expected := bruteDistanceProfile(query, sig)
+			if d.raw {
+				for i := range expected {
+					var sq float64
+					for k := range query {
+						sq += (query[k] - sig[i+k]) * (query[k] - sig[i+k])
+					}
+					expected[i] = math.Sqrt(sq)
+				}
+			}
+			if len(profile) != len(expected) {
+				t.Fatalf("Expected %d elements, but got %d for %+v", len(expected), len(profile), d)
+			}
+			for i := range expected {
+				if math.Abs(profile[i]*profile[i]-expected[i]*expected[i]) > 1e-6 {
+					t.Errorf("Expected %.6f at %d, but got %.6f for %+v", expected[i], i, profile[i], d)
+					break
+				}
+			}
+		}
+	}
+
+	mp, err := New(sig, nil, 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = mp.DistanceProfile(q[:10]); err == nil {
+		t.Errorf("Expected an error for a query of the wrong length")
+	}
+	if _, err = mp.DistanceProfile(make([]float64, 20)); err == nil {
+		t.Errorf("Expected an error for a constant query")
+	}
+}
+
 func TestCalculateDistanceProfile(t *testing.T) {
 	var err error
 	var mprof []float64
@@ -365,8 +893,11 @@ func TestCalculateDistanceProfile(t *testing.T) {
 			t.Errorf("Failed to initialize cache, %v", err)
 		}
 
-		fft := fourier.NewFFT(mp.N)
-		dot := mp.crossCorrelate(mp.A[:mp.W], fft)
+		fft := mp.newFFT()
+		dot, err := mp.crossCorrelate(mp.A[:mp.W], fft)
+		if err != nil {
+			t.Fatal(err)
+		}
 
 		mprof = make([]float64, mp.N-mp.W+1)
 		err = mp.calculateDistanceProfile(dot, d.idx, mprof)
@@ -482,7 +1013,7 @@ func TestComputeStamp(t *testing.T) {
 		{[]float64{0, 0.99, 1, 0, 0, 0.98, 1, 0, 0, 0.96, 1, 0}, nil, 4, 1.0,
 			[]float64{0.014355034678331376, 0.014355034678269504, 0.0291386974835963, 0.029138697483626783, 0.01435503467830044, 0.014355034678393249, 0.029138697483504856, 0.029138697483474377, 0.0291386974835963},
 			[]int{4, 5, 6, 7, 0, 1, 2, 3, 4}},
-		{[]float64{0, 0.99, 1, 0, 0, 0.98, 1, 0, 0, 0.96, 1, 0}, nil, 4, 0.0, nil, nil},
+		{[]float64{0, 0.99, 1, 0, 0, 0.98, 1, 0, 0, 0.96, 1, 0}, nil, 4, -0.5, nil, nil},
 	}
 
 	for _, d := range testdata {
@@ -494,7 +1025,7 @@ func TestComputeStamp(t *testing.T) {
 
 		o := NewMPOpts()
 		o.Algorithm = AlgoSTAMP
-		o.SamplePct = d.sample
+		o.STAMP = &STAMPOpts{SamplePct: d.sample}
 
 		err = mp.Compute(o)
 
@@ -628,14 +1159,14 @@ func TestComputeMpx(t *testing.T) {
 		{[]float64{}, []float64{}, 2, 1, false, nil, nil},
 		{[]float64{1, 1, 1, 1, 1}, []float64{}, 2, 1, false, nil, nil},
 		{[]float64{}, []float64{1, 1, 1, 1, 1}, 2, 1, false, nil, nil},
-		{[]float64{1, 2, 1, 3, 1}, []float64{2, 1, 1, 2, 1, 3, 1, -1, -2}, 2, 1, false, []float64{0, 0, 0, 0}, []int{2, 3, 2, 3}},
-		{[]float64{1, 1, 1, 1, 1}, []float64{1, 1, 1, 1, 1, 2, 2, 3, 4, 5}, 2, 1, false, []float64{2, 2, 2, 2}, []int{0, 1, 2, 3}},
+		{[]float64{1, 2, 1, 3, 1}, []float64{2, 1, 1, 2, 1, 3, 1, -1, -2}, 2, 1, false, []float64{0, 0, 0, 0}, []int{2, 0, 2, 0}},
+		{[]float64{1, 1, 1, 1, 1}, []float64{1, 1, 1, 1, 1, 2, 2, 3, 4, 5}, 2, 1, false, []float64{2, 2, 2, 2}, []int{0, 0, 0, 0}},
 		{[]float64{0, 0.99, 1, 0, 0, 0.98, 1, 0, 0, 0.96, 1, 0}, []float64{0, 0.99, 1, 0, 0, 0.98, 1, 0, 0, 0.96, 1, 0}, 4, 1, false,
 			[]float64{0, 0, 0, 0, 0, 0, 0, 0, 0},
 			[]int{0, 1, 2, 3, 4, 5, 6, 7, 8}},
 		{[]float64{0, 1, 1, 1, 0, 0, 2, 1, 0, 0, 2, 1}, nil, 4, 1, false,
 			[]float64{1.9550, 1.8388, 0.8739, 0, 0, 1.9550, 0.8739, 0, 0},
-			[]int{4, 2, 6, 7, 8, 1, 2, 3, 4}},
+			[]int{4, 2, 6, 7, 8, 0, 2, 3, 4}},
 		{[]float64{0, 1, 1, 1, 0, 0, 2, 1, 0, 0, 2, 1}, nil, 4, 1, true,
 			[]float64{1.0183, 1.0183, 0.8739, 0, 0, 1.2060, 0.8739, 0, 0},
 			[]int{6, 3, 4, 7, 8, 3, 2, 3, 4}},
@@ -761,50 +1292,1429 @@ func TestUpdate(t *testing.T) {
 	}
 }
 
-func TestDiscoverDiscords(t *testing.T) {
-	mprof := []float64{1, 2, 3, 4}
-	a := []float64{1, 2, 3, 4, 5, 6}
-	w := 3
-
+func TestMPOptsValidate(t *testing.T) {
 	testdata := []struct {
-		mp               []float64
-		k                int
-		exzone           int
-		expectedDiscords []int
+		modify      func(o *MPOpts)
+		expectedErr bool
 	}{
-		{mprof, 4, 0, []int{3, 3, 3, 3}},
-		{mprof, 4, 1, []int{3, 1}},
-		{mprof, 10, 1, []int{3, 1}},
-		{mprof, 0, 1, []int{}},
+		{func(o *MPOpts) {}, false},
+		{func(o *MPOpts) { o.Algorithm = "scampi" }, true},
+		{func(o *MPOpts) { o.Algorithm = AlgoSCAMP; o.SCAMP = &SCAMPOpts{TileSize: 64} }, false},
+		{func(o *MPOpts) { o.Algorithm = AlgoSCAMP; o.SCAMP = &SCAMPOpts{TileSize: -1} }, true},
+		{func(o *MPOpts) { o.SCAMP = &SCAMPOpts{TileSize: 64} }, true},
+		{func(o *MPOpts) { o.NJobs = 0 }, true},
+		{func(o *MPOpts) { o.Algorithm = AlgoSTAMP; o.STAMP = &STAMPOpts{SamplePct: -0.5} }, true},
+		{func(o *MPOpts) { o.Algorithm = AlgoSTAMP; o.STAMP = &STAMPOpts{SamplePct: 1.5} }, true},
+		{func(o *MPOpts) { o.Algorithm = AlgoSCRIMP; o.SCRIMP = &SCRIMPOpts{SamplePct: 1.5} }, true},
+		{func(o *MPOpts) { o.Euclidean = false; o.Squared = true }, true},
+		{func(o *MPOpts) { o.NoiseStd = -1 }, true},
+		{func(o *MPOpts) { o.FlatThreshold = -0.1 }, true},
+		{func(o *MPOpts) { o.Flat = FlatFloor }, false},
+		{func(o *MPOpts) { o.Flat = "zero" }, true},
+		{func(o *MPOpts) { o.Flat = FlatInf; o.Euclidean = false }, true},
+		{func(o *MPOpts) { o.Flat = FlatInf; o.Algorithm = AlgoAAMP }, true},
+		{func(o *MPOpts) { o.Flat = FlatSkip; o.Algorithm = AlgoAAMP }, false},
+		{func(o *MPOpts) { o.Manhattan = true }, false},
+		{func(o *MPOpts) { o.Manhattan = true; o.Algorithm = AlgoAAMP }, false},
+		{func(o *MPOpts) { o.Manhattan = true; o.Squared = true }, true},
+		{func(o *MPOpts) { o.Manhattan = true; o.Euclidean = false }, true},
+		{func(o *MPOpts) { o.Manhattan = true; o.NoiseStd = 0.1 }, true},
+		{func(o *MPOpts) { o.Manhattan = true; o.Algorithm = AlgoSTAMP; o.STAMP = &STAMPOpts{SamplePct: 0.5} }, true},
+		{func(o *MPOpts) { o.Manhattan = true; o.Device = DeviceCUDA }, true},
+		{func(o *MPOpts) { o.STAMP = &STAMPOpts{Seed: 1} }, true},
+		{func(o *MPOpts) { o.Algorithm = AlgoSTAMP; o.STAMP = &STAMPOpts{Seed: 1} }, false},
+		{func(o *MPOpts) { o.Algorithm = AlgoSTAMP; o.STAMP = &STAMPOpts{SamplePct: 0.5, Seed: 1} }, false},
+		{func(o *MPOpts) { o.STAMP = &STAMPOpts{SamplePct: 0.5} }, true},
+		{func(o *MPOpts) { o.Algorithm = AlgoSTOMP; o.STAMP = &STAMPOpts{SamplePct: 0.5} }, true},
+		{func(o *MPOpts) { o.Algorithm = AlgoSCRIMP; o.STAMP = &STAMPOpts{SamplePct: 0.5} }, true},
+		{func(o *MPOpts) { o.Algorithm = AlgoAAMP; o.STAMP = &STAMPOpts{SamplePct: 0.5} }, true},
+		{func(o *MPOpts) { o.SCRIMP = &SCRIMPOpts{Seed: 1} }, true},
+		{func(o *MPOpts) { o.Algorithm = AlgoSCRIMP; o.SCRIMP = &SCRIMPOpts{Stride: -1} }, true},
+		{func(o *MPOpts) { o.Algorithm = AlgoSCRIMP; o.SCRIMP = &SCRIMPOpts{SamplePct: 0.5, Stride: 4} }, false},
+		{func(o *MPOpts) { o.Algorithm = AlgoPreSCRIMP; o.SCRIMP = &SCRIMPOpts{Stride: 4} }, false},
+		{func(o *MPOpts) { o.Algorithm = AlgoPreSCRIMP; o.SCRIMP = &SCRIMPOpts{SamplePct: 0.5} }, true},
+		{func(o *MPOpts) { o.Transform = TransformDetrend }, false},
+		{func(o *MPOpts) { o.Transform = "log" }, true},
+		{func(o *MPOpts) { o.Algorithm = AlgoAAMP; o.Squared = true }, false},
+		{func(o *MPOpts) { o.Algorithm = AlgoAAMP; o.Euclidean = false }, true},
+		{func(o *MPOpts) { o.Algorithm = AlgoAAMP; o.NoiseStd = 0.1 }, true},
+		{func(o *MPOpts) { o.Algorithm = AlgoACAMP; o.Euclidean = false }, true},
+		{func(o *MPOpts) { o.MaxDistance = 2 }, false},
+		{func(o *MPOpts) { o.MaxDistance = -1 }, true},
+		{func(o *MPOpts) { o.Euclidean = false; o.MaxDistance = 2 }, true},
+		{func(o *MPOpts) { o.Device = DeviceCUDA }, false},
+		{func(o *MPOpts) { o.Device = DeviceOpenCL }, false},
+		{func(o *MPOpts) { o.Device = "tpu" }, true},
 	}
 
-	for _, d := range testdata {
-		mp := MatrixProfile{A: a, B: a, W: w, MP: d.mp, AV: av.Default, Opts: NewMPOpts()}
-		discords, err := mp.DiscoverDiscords(d.k, d.exzone)
+	for i, d := range testdata {
+		o := NewMPOpts()
+		d.modify(o)
+		err := o.Validate()
+		if d.expectedErr && err == nil {
+			t.Errorf("Expected an error for case %d, %+v", i, o)
+		}
+		if !d.expectedErr && err != nil {
+			t.Errorf("Did not expect an error, %v, for case %d", err, i)
+		}
+	}
+}
+
+func TestSTAMPSeed(t *testing.T) {
+	sig := siggen.Add(siggen.Sin(1, 4, 0, 0, 100, 2), siggen.Noise(0.1, 200))
+
+	var first []int
+	for run := 0; run < 2; run++ {
+		mp, err := New(sig, nil, 20)
 		if err != nil {
-			t.Errorf("Got error %v on %v", err, d)
-			return
+			t.Fatal(err)
 		}
-		if len(discords) != len(d.expectedDiscords) {
-			t.Errorf("Got a length of %d discords, but expected %d, for %v", len(discords), len(d.expectedDiscords), d)
-			return
+		o := NewMPOpts()
+		o.Algorithm = AlgoSTAMP
+		o.NJobs = 1
+		o.STAMP = &STAMPOpts{SamplePct: 0.2, Seed: 42}
+		if err = mp.Compute(o); err != nil {
+			t.Fatal(err)
 		}
-		for i, idx := range discords {
-			if idx != d.expectedDiscords[i] {
-				t.Errorf("expected index, %d, but got %d, for %v", d.expectedDiscords[i], idx, d)
-				return
+		if first == nil {
+			first = mp.Idx
+			continue
+		}
+		for i := range first {
+			if mp.Idx[i] != first[i] {
+				t.Errorf("Expected seeded runs to match, but got %d and %d at %d", first[i], mp.Idx[i], i)
+				break
 			}
 		}
 	}
 }
 
-func TestDiscoverMotifs(t *testing.T) {
-	a := []float64{0, 0, 0.56, 0.99, 0.97, 0.75, 0, 0, 0, 0.43, 0.98, 0.99, 0.65, 0, 0, 0, 0.6, 0.97, 0.965, 0.8, 0, 0, 0}
+func TestSTAMPComplexityBias(t *testing.T) {
+	// a smooth sine with a noisy stretch of high complexity from 300 to 400
+	sig := siggen.Sin(1, 1, 0, 0, 100, 6)
+	r := rand.New(rand.NewSource(1))
+	for i := 300; i < 400; i++ {
+		sig[i] += r.NormFloat64()
+	}
 
-	testdata := []struct {
-		a               []float64
-		b               []float64
-		m               int
+	for _, njobs := range []int{1, 3} {
+		mp, err := New(sig, nil, 20)
+		if err != nil {
+			t.Fatal(err)
+		}
+		o := NewMPOpts()
+		o.Algorithm = AlgoSTAMP
+		o.NJobs = njobs
+		o.STAMP = &STAMPOpts{Seed: 7, ComplexityBias: true}
+		if err = mp.Compute(o); err != nil {
+			t.Fatal(err)
+		}
+
+		exact, err := New(sig, nil, 20)
+		if err != nil {
+			t.Fatal(err)
+		}
+		eo := NewMPOpts()
+		eo.Algorithm = AlgoSTAMP
+		if err = exact.Compute(eo); err != nil {
+			t.Fatal(err)
+		}
+		for i := range exact.MP {
+			if math.Abs(mp.MP[i]-exact.MP[i]) > 1e-4 {
+				t.Errorf("Expected the full biased sample to be exact, but got %.6f instead of %.6f at %d", mp.MP[i], exact.MP[i], i)
+				break
+			}
+		}
+
+		// the first tenth sampled by each batch should favor the noisy stretch, which
+		// holds a quarter of the subsequences
+		n := len(mp.MP)
+		batchSize := n/njobs + 1
+		order := mp.stampOrder(batchSize)
+		var sampled, complex int
+		for batch := 0; batch < njobs; batch++ {
+			for i := 0; i < batchSize/10; i++ {
+				sampled++
+				if idx := order[batch*batchSize+i]; idx >= 280 && idx < 400 {
+					complex++
+				}
+			}
+		}
+		if 10*complex < 4*sampled {
+			t.Errorf("Expected at least 40%% of early samples in the complex region, but got %d of %d with %d jobs", complex, sampled, njobs)
+		}
+
+		seen := make([]bool, n)
+		for _, idx := range order {
+			seen[idx] = true
+		}
+		for i, ok := range seen {
+			if !ok {
+				t.Errorf("Expected every subsequence to be sampled, but %d was not with %d jobs", i, njobs)
+				break
+			}
+		}
+	}
+}
+
+func TestComputeMaxDistance(t *testing.T) {
+	sig := siggen.Add(siggen.Sin(1, 4, 0, 0, 100, 2), siggen.Noise(0.1, 200))
+
+	testdata := []struct {
+		algo    Algo
+		b       []float64
+		squared bool
+	}{
+		{AlgoMPX, nil, false},
+		{AlgoMPX, nil, true},
+		{AlgoMPX, siggen.Noise(1, 120), false},
+		{AlgoSTOMP, nil, false},
+		{AlgoSTMP, nil, true},
+		{AlgoSTAMP, nil, false},
+		{AlgoSCRIMP, nil, false},
+		{AlgoAAMP, nil, false},
+		{AlgoACAMP, nil, true},
+	}
+
+	for _, d := range testdata {
+		exact, err := New(sig, d.b, 20)
+		if err != nil {
+			t.Fatal(err)
+		}
+		o := NewMPOpts()
+		o.Algorithm = d.algo
+		o.Squared = d.squared
+		if err = exact.Compute(o); err != nil {
+			t.Fatal(err)
+		}
+
+		// the radius lies halfway between two distances of the profile so rounding
+		// can't move any match across it
+		sorted := append([]float64(nil), exact.MP...)
+		sort.Float64s(sorted)
+		k := len(sorted) / 2
+		for k < len(sorted)-1 && sorted[k+1]-sorted[k] < 1e-6 {
+			k++
+		}
+		radius := (sorted[k] + sorted[k+1]) / 2
+
+		mp, err := New(sig, d.b, 20)
+		if err != nil {
+			t.Fatal(err)
+		}
+		o = NewMPOpts()
+		o.Algorithm = d.algo
+		o.Squared = d.squared
+		o.MaxDistance = radius
+		if err = mp.Compute(o); err != nil {
+			t.Fatal(err)
+		}
+
+		for i := range exact.MP {
+			if exact.MP[i] <= radius {
+				if math.Abs(mp.MP[i]-exact.MP[i]) > 1e-6 {
+					t.Errorf("Expected %.6f within the radius at %d, but got %.6f with %s and squared %t", exact.MP[i], i, mp.MP[i], d.algo, d.squared)
+					break
+				}
+				continue
+			}
+			if !math.IsInf(mp.MP[i], 1) || mp.Idx[i] != math.MaxInt64 {
+				t.Errorf("Expected no match beyond the radius at %d, but got %.6f at %d with %s and squared %t", i, mp.MP[i], mp.Idx[i], d.algo, d.squared)
+				break
+			}
+		}
+		for i := range mp.MPB {
+			if mp.MPB[i] > radius && !math.IsInf(mp.MPB[i], 1) {
+				t.Errorf("Expected no BA match beyond the radius, but got %.6f at %d with %s", mp.MPB[i], i, d.algo)
+				break
+			}
+		}
+	}
+}
+
+func TestUpdateMaxDistance(t *testing.T) {
+	sig := siggen.Add(siggen.Sin(1, 4, 0, 0, 100, 2), siggen.Noise(0.1, 200))
+	mp, err := New(sig[:150], nil, 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	o := NewMPOpts()
+	o.MaxDistance = 1
+	if err = mp.Compute(o); err != nil {
+		t.Fatal(err)
+	}
+	if err = mp.Update(sig[150:]); err != nil {
+		t.Fatal(err)
+	}
+
+	for i, d := range mp.MP {
+		if d > 1 || (math.IsInf(d, 1) && mp.Idx[i] != math.MaxInt64) {
+			t.Errorf("Expected streamed matches within the radius, but got %.6f at %d", d, mp.Idx[i])
+			break
+		}
+	}
+}
+
+func TestComputeSquared(t *testing.T) {
+	a := siggen.Add(siggen.Sin(1, 4, 0, 0, 100, 2), siggen.Noise(0.1, 200))
+	b := siggen.Noise(1, 120)
+
+	testdata := []struct {
+		b    []float64
+		algo Algo
+	}{
+		{nil, AlgoSTMP},
+		{nil, AlgoSTAMP},
+		{nil, AlgoSTOMP},
+		{nil, AlgoMPX},
+		{b, AlgoSTOMP},
+		{b, AlgoMPX},
+	}
+
+	for _, d := range testdata {
+		mp, err := New(a, d.b, 16)
+		if err != nil {
+			t.Fatal(err)
+		}
+		o := NewMPOpts()
+		o.Algorithm = d.algo
+		if err = mp.Compute(o); err != nil {
+			t.Fatal(err)
+		}
+
+		sq, err := New(a, d.b, 16)
+		if err != nil {
+			t.Fatal(err)
+		}
+		o.Squared = true
+		if err = sq.Compute(o); err != nil {
+			t.Fatal(err)
+		}
+
+		for i := range mp.MP {
+			if math.Abs(sq.MP[i]-mp.MP[i]*mp.MP[i]) > 1e-6 {
+				t.Errorf("Expected %.6f at %d, but got %.6f for %+v", mp.MP[i]*mp.MP[i], i, sq.MP[i], d)
+				break
+			}
+		}
+		for i := range mp.MPB {
+			if math.Abs(sq.MPB[i]-mp.MPB[i]*mp.MPB[i]) > 1e-6 {
+				t.Errorf("Expected %.6f at %d in MPB, but got %.6f for %+v", mp.MPB[i]*mp.MPB[i], i, sq.MPB[i], d)
+				break
+			}
+		}
+	}
+
+	mp, err := New(a, nil, 16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	o := NewMPOpts()
+	o.Squared = true
+	o.Euclidean = false
+	if err = mp.Compute(o); err == nil {
+		t.Errorf("Expected an error for squared pearson correlations")
+	}
+}
+
+// bruteNoiseCorrectedMP computes the noise corrected matrix profile of a against b
+// where allowed reports whether the pair of subsequences may be matched.
+func bruteNoiseCorrectedMP(a, b []float64, w int, noiseStd float64, allowed func(i, j int) bool) []float64 {
+	_, stdA, _ := util.MovMeanStd(a, w)
+	_, stdB, _ := util.MovMeanStd(b, w)
+	out := make([]float64, len(a)-w+1)
+	for i := range out {
+		out[i] = math.Inf(1)
+		qa, _ := util.ZNormalize(a[i : i+w])
+		for j := 0; j < len(b)-w+1; j++ {
+			if !allowed(i, j) {
+				continue
+			}
+			qb, _ := util.ZNormalize(b[j : j+w])
+			var d float64
+			for k := range qa {
+				d += (qa[k] - qb[k]) * (qa[k] - qb[k])
+			}
+			s := math.Max(stdA[i], stdB[j])
+			d -= 2 * float64(w+1) * noiseStd * noiseStd / (s * s)
+			if d < 0 {
+				d = 0
+			}
+			if math.Sqrt(d) < out[i] {
+				out[i] = math.Sqrt(d)
+			}
+		}
+	}
+	return out
+}
+
+func TestNoiseCorrection(t *testing.T) {
+	w := 12
+	noiseStd := 0.2
+	a := siggen.Add(siggen.Sin(0.5, 3, 0, 0, 100, 1), siggen.Noise(noiseStd, 100))
+	b := siggen.Add(siggen.Sin(0.5, 3, 0, 0, 100, 1.2), siggen.Noise(noiseStd, 120))
+
+	testdata := []struct {
+		b    []float64
+		algo Algo
+	}{
+		{nil, AlgoMPX},
+		{b, AlgoMPX},
+		{b, AlgoSTOMP},
+		{b, AlgoSTMP},
+	}
+
+	for _, d := range testdata {
+		mp, err := New(a, d.b, w)
+		if err != nil {
+			t.Fatal(err)
+		}
+		o := NewMPOpts()
+		o.Algorithm = d.algo
+		o.NoiseStd = noiseStd
+		if err = mp.Compute(o); err != nil {
+			t.Fatal(err)
+		}
+
+		var expected []float64
+		if d.b == nil {
+			expected = bruteNoiseCorrectedMP(a, a, w, noiseStd, func(i, j int) bool {
+				return i-j >= w/4 || j-i >= w/4
+			})
+		} else if d.algo == AlgoMPX {
+			expected = bruteNoiseCorrectedMP(a, d.b, w, noiseStd, func(i, j int) bool { return true })
+		} else {
+			// the STOMP family indexes the AB join profile by the subsequences of b
+			expected = bruteNoiseCorrectedMP(d.b, a, w, noiseStd, func(i, j int) bool { return true })
+		}
+
+		if len(mp.MP) != len(expected) {
+			t.Fatalf("Expected %d elements, but got %d for %+v", len(expected), len(mp.MP), d.algo)
+		}
+		for i := range expected {
+			if math.Abs(mp.MP[i]-expected[i]) > 1e-5 {
+				t.Errorf("Expected %.6f at %d, but got %.6f for %s", expected[i], i, mp.MP[i], d.algo)
+				break
+			}
+		}
+	}
+}
+
+func TestUpdateMaxLen(t *testing.T) {
+	// the subsequence length spans most of a period so nearest neighbors are a full
+	// period apart rather than at the edge of the exclusion zone
+	sig := siggen.Add(siggen.Sin(1, 4, 0, 0, 100, 2), siggen.Noise(0.1, 200))
+
+	testdata := []struct {
+		maxLen      int
+		batch       int
+		expectedErr bool
+	}{
+		{0, 10, false},
+		{30, 10, true},
+		{60, 1, false},
+		{60, 7, false},
+		{100, 25, false},
+	}
+
+	for _, d := range testdata {
+		a := make([]float64, 50)
+		copy(a, sig[:50])
+		mp, err := New(a, nil, 20)
+		if err != nil {
+			t.Fatal(err)
+		}
+		o := NewMPOpts()
+		o.Algorithm = AlgoSTOMP
+		if err = mp.Compute(o); err != nil {
+			t.Fatal(err)
+		}
+		mp.Stream = NewStreamOpts()
+		mp.Stream.MaxLen = d.maxLen
+
+		for i := 50; i < len(sig); i += d.batch {
+			end := i + d.batch
+			if end > len(sig) {
+				end = len(sig)
+			}
+			if err = mp.Update(sig[i:end]); err != nil {
+				break
+			}
+		}
+		if d.expectedErr {
+			if err == nil {
+				t.Errorf("Expected an error for %+v", d)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("Did not expect an error, %v, for %+v", err, d)
+		}
+
+		expectedLen := len(sig)
+		if d.maxLen > 0 {
+			expectedLen = d.maxLen
+		}
+		if len(mp.A) != expectedLen || mp.Offset != len(sig)-expectedLen {
+			t.Fatalf("Expected length %d and offset %d, but got %d and %d for %+v", expectedLen, len(sig)-expectedLen, len(mp.A), mp.Offset, d)
+		}
+		for i := range mp.A {
+			if mp.A[i] != sig[mp.Offset+i] {
+				t.Fatalf("Expected retained value %.3f at %d, but got %.3f for %+v", sig[mp.Offset+i], i, mp.A[i], d)
+			}
+		}
+
+		full, err := New(mp.A, nil, 20)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = full.Compute(o); err != nil {
+			t.Fatal(err)
+		}
+		for i := range full.MP {
+			if math.Abs(mp.MP[i]-full.MP[i]) > 1e-6 || mp.Idx[i] != full.Idx[i] {
+				t.Errorf("Expected (%.6f, %d) at %d, but got (%.6f, %d) for %+v", full.MP[i], full.Idx[i], i, mp.MP[i], mp.Idx[i], d)
+				break
+			}
+		}
+	}
+}
+
+func TestUpdateSTAMPI(t *testing.T) {
+	sig := siggen.Add(siggen.Sin(1, 4, 0, 0, 100, 6), siggen.Noise(0.1, 600))
+
+	testdata := []struct {
+		algo   Algo
+		maxLen int
+		batch  int
+	}{
+		{AlgoSTOMP, 0, 1},
+		{AlgoSTOMP, 0, 9},
+		{AlgoSTOMP, 150, 4},
+		{AlgoSTAMP, 0, 25},
+		{AlgoAAMP, 0, 3},
+		{AlgoAAMP, 120, 1},
+	}
+
+	for _, d := range testdata {
+		a := make([]float64, 50)
+		copy(a, sig[:50])
+		mp, err := New(a, nil, 20)
+		if err != nil {
+			t.Fatal(err)
+		}
+		o := NewMPOpts()
+		o.Algorithm = d.algo
+		if err = mp.Compute(o); err != nil {
+			t.Fatal(err)
+		}
+		mp.Stream = NewStreamOpts()
+		mp.Stream.MaxLen = d.maxLen
+
+		for i := 50; i < len(sig); i += d.batch {
+			end := i + d.batch
+			if end > len(sig) {
+				end = len(sig)
+			}
+			if err = mp.Update(sig[i:end]); err != nil {
+				t.Fatalf("Did not expect an error, %v, for %+v", err, d)
+			}
+		}
+
+		// the sliding statistics are kept up to date without being recomputed
+		mean, std, err := util.MovMeanStd(mp.A, mp.W)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(mp.AMean) != len(mean) || len(mp.BStd) != len(std) {
+			t.Fatalf("Expected %d sliding statistics, but got %d and %d for %+v", len(mean), len(mp.AMean), len(mp.BStd), d)
+		}
+		for i := range mean {
+			if math.Abs(mp.AMean[i]-mean[i]) > 1e-9 || math.Abs(mp.BStd[i]-std[i]) > 1e-9 {
+				t.Fatalf("Expected mean %.6f and std %.6f at %d, but got %.6f and %.6f for %+v", mean[i], std[i], i, mp.AMean[i], mp.BStd[i], d)
+			}
+		}
+
+		full, err := New(mp.A, nil, 20)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = full.Compute(o); err != nil {
+			t.Fatal(err)
+		}
+		if len(mp.MP) != len(full.MP) {
+			t.Fatalf("Expected %d profile values, but got %d for %+v", len(full.MP), len(mp.MP), d)
+		}
+		for i := range full.MP {
+			if math.Abs(mp.MP[i]-full.MP[i]) > 1e-6 || mp.Idx[i] != full.Idx[i] {
+				t.Errorf("Expected (%.6f, %d) at %d, but got (%.6f, %d) for %+v", full.MP[i], full.Idx[i], i, mp.MP[i], mp.Idx[i], d)
+				break
+			}
+		}
+	}
+}
+
+func TestUpdateMPX(t *testing.T) {
+	sig := siggen.Add(siggen.Sin(1, 4, 0, 0, 100, 2), siggen.Noise(0.1, 200))
+
+	testdata := []struct {
+		maxLen  int
+		batch   int
+		squared bool
+	}{
+		{0, 1, false},
+		{0, 13, false},
+		{0, 10, true},
+		{80, 7, false},
+		{80, 1, false},
+	}
+
+	for _, d := range testdata {
+		a := make([]float64, 50)
+		copy(a, sig[:50])
+		mp, err := New(a, nil, 20)
+		if err != nil {
+			t.Fatal(err)
+		}
+		o := NewMPOpts()
+		o.Squared = d.squared
+		if err = mp.Compute(o); err != nil {
+			t.Fatal(err)
+		}
+		mp.Stream = NewStreamOpts()
+		mp.Stream.MaxLen = d.maxLen
+
+		for i := 50; i < len(sig); i += d.batch {
+			end := i + d.batch
+			if end > len(sig) {
+				end = len(sig)
+			}
+			if err = mp.Update(sig[i:end]); err != nil {
+				t.Fatalf("Did not expect an error, %v, for %+v", err, d)
+			}
+		}
+
+		// retiring points shifts the sliding statistics instead of recomputing the caches
+		if d.maxLen > 0 {
+			mean, std, err := util.MovMeanStd(mp.A, mp.W)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(mp.AMean) != len(mean) || len(mp.BStd) != len(std) {
+				t.Fatalf("Expected %d sliding statistics, but got %d and %d for %+v", len(mean), len(mp.AMean), len(mp.BStd), d)
+			}
+			for i := range mean {
+				if math.Abs(mp.AMean[i]-mean[i]) > 1e-9 || math.Abs(mp.BStd[i]-std[i]) > 1e-9 {
+					t.Fatalf("Expected mean %.6f and std %.6f at %d, but got %.6f and %.6f for %+v", mean[i], std[i], i, mp.AMean[i], mp.BStd[i], d)
+				}
+			}
+		}
+
+		full, err := New(mp.A, nil, 20)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = full.Compute(o); err != nil {
+			t.Fatal(err)
+		}
+		if len(mp.MP) != len(full.MP) {
+			t.Fatalf("Expected %d profile values, but got %d for %+v", len(full.MP), len(mp.MP), d)
+		}
+		for i := range full.MP {
+			if math.Abs(mp.MP[i]-full.MP[i]) > 1e-6 || mp.Idx[i] != full.Idx[i] {
+				t.Errorf("Expected (%.6f, %d) at %d, but got (%.6f, %d) for %+v", full.MP[i], full.Idx[i], i, mp.MP[i], mp.Idx[i], d)
+				break
+			}
+		}
+	}
+}
+
+func TestUpdateDiscordCallback(t *testing.T) {
+	sig := siggen.Add(siggen.Sin(1, 4, 0, 0, 100, 3), siggen.Noise(0.05, 300))
+	// inject an anomaly late in the stream
+	for i := 240; i < 250; i++ {
+		sig[i] += 1.5
+	}
+
+	testdata := []struct {
+		horizon int
+		maxLen  int
+	}{
+		{0, 0},
+		{50, 0},
+		{0, 150},
+	}
+
+	for _, d := range testdata {
+		a := make([]float64, 100)
+		copy(a, sig[:100])
+		mp, err := New(a, nil, 20)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = mp.Compute(NewMPOpts()); err != nil {
+			t.Fatal(err)
+		}
+
+		var calls [][]int
+		mp.Stream = NewStreamOpts()
+		mp.Stream.MaxLen = d.maxLen
+		mp.Stream.DiscordK = 2
+		mp.Stream.DiscordHorizon = d.horizon
+		mp.Stream.OnDiscords = func(discords []int) {
+			calls = append(calls, discords)
+		}
+
+		for i := 100; i < len(sig); i += 10 {
+			if err = mp.Update(sig[i : i+10]); err != nil {
+				t.Fatal(err)
+			}
+		}
+		nCalls := len(calls)
+		if err = mp.Update(nil); err != nil {
+			t.Fatal(err)
+		}
+		if len(calls) != nCalls {
+			t.Errorf("Expected no callback when the ranking is unchanged for %+v", d)
+		}
+
+		if len(calls) == 0 || len(calls) > 20 {
+			t.Fatalf("Expected between 1 and 20 callbacks, but got %d for %+v", len(calls), d)
+		}
+		for i := 1; i < len(calls); i++ {
+			if len(calls[i]) == len(calls[i-1]) && calls[i][0] == calls[i-1][0] && calls[i][1] == calls[i-1][1] {
+				t.Errorf("Expected the callback only on a ranking change, but got %v twice for %+v", calls[i], d)
+			}
+		}
+
+		last := calls[len(calls)-1]
+		found := false
+		for _, idx := range last {
+			if idx >= 220 && idx <= 250 {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("Expected a discord near the anomaly, but got %v for %+v", last, d)
+		}
+	}
+}
+
+func TestUpdateAB(t *testing.T) {
+	a := siggen.Noise(1, 100)
+	b := siggen.Noise(1, 60)
+	appends := [][]float64{{}, {0.5}, siggen.Noise(1, 7), siggen.Noise(1, 20)}
+
+	testdata := []struct {
+		algo Algo
+	}{
+		{AlgoMPX},
+		{AlgoSTOMP},
+		{AlgoSTMP},
+	}
+
+	for _, d := range testdata {
+		aCopy := make([]float64, len(a))
+		copy(aCopy, a)
+		bCopy := make([]float64, len(b))
+		copy(bCopy, b)
+		mp, err := New(aCopy, bCopy, 8)
+		if err != nil {
+			t.Fatal(err)
+		}
+		o := NewMPOpts()
+		o.Algorithm = d.algo
+		if err = mp.Compute(o); err != nil {
+			t.Fatal(err)
+		}
+
+		for k, vals := range appends {
+			// alternates between growing b and growing a
+			if k%2 == 0 {
+				err = mp.Update(vals)
+			} else {
+				err = mp.UpdateA(vals)
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			full, err := New(mp.A, mp.B, 8)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err = full.Compute(o); err != nil {
+				t.Fatal(err)
+			}
+
+			if len(mp.MP) != len(full.MP) || len(mp.MPB) != len(full.MPB) {
+				t.Fatalf("Expected lengths %d and %d, but got %d and %d for %+v", len(full.MP), len(full.MPB), len(mp.MP), len(mp.MPB), d)
+			}
+			for i := range full.MP {
+				if math.Abs(mp.MP[i]-full.MP[i]) > 1e-6 || mp.Idx[i] != full.Idx[i] {
+					t.Errorf("Expected (%.6f, %d) at %d, but got (%.6f, %d) for %+v", full.MP[i], full.Idx[i], i, mp.MP[i], mp.Idx[i], d)
+					break
+				}
+			}
+			for i := range full.MPB {
+				if math.Abs(mp.MPB[i]-full.MPB[i]) > 1e-6 || mp.IdxB[i] != full.IdxB[i] {
+					t.Errorf("Expected (%.6f, %d) at %d in MPB, but got (%.6f, %d) for %+v", full.MPB[i], full.IdxB[i], i, mp.MPB[i], mp.IdxB[i], d)
+					break
+				}
+			}
+		}
+	}
+}
+
+func TestKneeCut(t *testing.T) {
+	testdata := []struct {
+		dists    []float64
+		expected int
+	}{
+		{[]float64{}, 0},
+		{[]float64{0.1}, 0},
+		{[]float64{0.1, 0.2}, 1},
+		{[]float64{0.1, 0.15, 0.2, 4, 4.1, 4.3}, 2},
+		{[]float64{0.1, 3, 3.1, 3.2}, 0},
+		{[]float64{1, 1.1, 1.2, 1.3, 1.4}, 4},
+	}
+
+	for _, d := range testdata {
+		if out := kneeCut(d.dists); out != d.expected {
+			t.Errorf("Expected %d, but got %d for %v", d.expected, out, d.dists)
+		}
+	}
+}
+
+func TestDiscoverMotifsAutoRadius(t *testing.T) {
+	w := 20
+	sig := seededNoise(1, 0.1, 600)
+	occurrences := []int{50, 190, 330, 470}
+	for _, start := range occurrences {
+		for i := 0; i < w; i++ {
+			sig[start+i] += math.Sin(2 * math.Pi * float64(i) / float64(w))
+		}
+	}
+
+	mp, err := New(sig, nil, w)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = mp.Compute(NewMPOpts()); err != nil {
+		t.Fatal(err)
+	}
+
+	motifs, err := mp.DiscoverMotifs(1, AutoRadius, 10, w/2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(motifs) != 1 || len(motifs[0].Idx) != len(occurrences) {
+		t.Fatalf("Expected one motif with %d members, but got %+v", len(occurrences), motifs)
+	}
+	for i, idx := range motifs[0].Idx {
+		if idx < occurrences[i]-w/4 || idx > occurrences[i]+w/4 {
+			t.Errorf("Expected a member near %d, but got %v", occurrences[i], motifs[0].Idx)
+			break
+		}
+	}
+
+	// a radius of 0 still only keeps the seed pair
+	motifs, err = mp.DiscoverMotifs(1, 0, 10, w/2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(motifs) != 1 || len(motifs[0].Idx) != 2 {
+		t.Errorf("Expected one motif of the seed pair for a radius of 0, but got %+v", motifs)
+	}
+}
+
+func TestDiscoverMotifsDirect(t *testing.T) {
+	w := 12
+	sig := siggen.Noise(0.1, 600)
+	for _, start := range []int{50, 190, 330, 470} {
+		for i := 0; i < w; i++ {
+			sig[start+i] += math.Sin(2 * math.Pi * float64(i) / float64(w))
+		}
+	}
+
+	for _, noise := range []float64{0, 0.05} {
+		mp, err := New(sig, nil, w)
+		if err != nil {
+			t.Fatal(err)
+		}
+		o := NewMPOpts()
+		o.NoiseStd = noise
+		if err = mp.Compute(o); err != nil {
+			t.Fatal(err)
+		}
+		st := mp.directStats()
+		if st == nil {
+			t.Fatal("Expected the MPX statistics to be reused")
+		}
+
+		// the same motifs are found through fourier transforms without the statistics
+		fftMP := *mp
+		fftMP.mpxStats = nil
+		expected, err := fftMP.DiscoverMotifs(3, 2, 10, w/2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		motifs, err := mp.DiscoverMotifs(3, 2, 10, w/2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if mp.BF != nil {
+			t.Errorf("Expected no fourier transform of the timeseries")
+		}
+		if len(motifs) != len(expected) {
+			t.Fatalf("Expected %v, but got %v", expected, motifs)
+		}
+		for i := range motifs {
+			if !reflect.DeepEqual(motifs[i].Idx, expected[i].Idx) {
+				t.Errorf("Expected members %v, but got %v", expected[i].Idx, motifs[i].Idx)
+				continue
+			}
+			for k := range motifs[i].Dists {
+				if math.Abs(motifs[i].Dists[k]-expected[i].Dists[k]) > 1e-6 {
+					t.Errorf("Expected distances %v, but got %v", expected[i].Dists, motifs[i].Dists)
+					break
+				}
+			}
+		}
+	}
+
+	// subsequences longer than 2*log2(n) points are cheaper through fourier transforms.
+	// With 583 subsequences of 18 points the threshold is 18.37, with 582 of 19 it is
+	// 18.36, and both sides find the same motifs as the fourier transforms alone
+	for _, d := range []struct {
+		w      int
+		direct bool
+	}{{18, true}, {19, false}, {40, false}} {
+		mp, err := New(sig, nil, d.w)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = mp.Compute(NewMPOpts()); err != nil {
+			t.Fatal(err)
+		}
+		if direct := mp.directStats() != nil; direct != d.direct {
+			t.Errorf("Expected direct distance profiles %t for a subsequence length of %d, but got %t", d.direct, d.w, direct)
+		}
+
+		fftMP := *mp
+		fftMP.mpxStats = nil
+		expected, err := fftMP.DiscoverMotifs(3, 2, 10, d.w/2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		motifs, err := mp.DiscoverMotifs(3, 2, 10, d.w/2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if (mp.BF == nil) != d.direct {
+			t.Errorf("Expected a fourier transform of the timeseries %t for a subsequence length of %d", !d.direct, d.w)
+		}
+		if len(motifs) != len(expected) {
+			t.Fatalf("Expected %v, but got %v for a subsequence length of %d", expected, motifs, d.w)
+		}
+		for i := range motifs {
+			if !reflect.DeepEqual(motifs[i].Idx, expected[i].Idx) {
+				t.Errorf("Expected members %v, but got %v for a subsequence length of %d", expected[i].Idx, motifs[i].Idx, d.w)
+			}
+		}
+	}
+}
+
+func TestMotifSubsequences(t *testing.T) {
+	w := 20
+	sig := siggen.Noise(0.1, 600)
+	for _, start := range []int{50, 190, 330, 470} {
+		for i := 0; i < w; i++ {
+			sig[start+i] += math.Sin(2 * math.Pi * float64(i) / float64(w))
+		}
+	}
+
+	mp, err := New(sig, nil, w)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = mp.Compute(NewMPOpts()); err != nil {
+		t.Fatal(err)
+	}
+	motifs, err := mp.DiscoverMotifs(2, 3, 10, w/2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, g := range motifs {
+		if len(g.Dists) != len(g.Idx) {
+			t.Fatalf("Expected %d distances, but got %v", len(g.Idx), g.Dists)
+		}
+		subs, err := mp.MotifSubsequences(g, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		raw, err := mp.MotifSubsequences(g, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// the seed is the member at distance 0
+		seed := -1
+		var radius float64
+		for i, d := range g.Dists {
+			if d == 0 {
+				seed = i
+			}
+			radius = math.Max(radius, d)
+		}
+		if seed < 0 {
+			t.Fatalf("Expected a seed at distance 0, but got %v", g.Dists)
+		}
+		if g.Radius != radius {
+			t.Errorf("Expected a radius of %.4f, but got %.4f", radius, g.Radius)
+		}
+		if g.Radius < g.MinDist-1e-7 {
+			t.Errorf("Expected a radius of at least %.4f, but got %.4f", g.MinDist, g.Radius)
+		}
+
+		for i, idx := range g.Idx {
+			if len(subs[i]) != w || raw[i][0] != mp.A[idx] {
+				t.Fatalf("Expected the subsequence at %d, but got %v", idx, raw[i])
+			}
+			var sq float64
+			for k := range subs[i] {
+				sq += (subs[i][k] - subs[seed][k]) * (subs[i][k] - subs[seed][k])
+			}
+			if math.Abs(math.Sqrt(sq)-g.Dists[i]) > 1e-6 {
+				t.Errorf("Expected a distance of %.6f for member %d, but got %.6f", math.Sqrt(sq), idx, g.Dists[i])
+			}
+		}
+	}
+
+	if _, err = mp.MotifSubsequences(MotifGroup{Idx: []int{len(sig)}}, false); err == nil {
+		t.Errorf("Expected an error for an out of range index")
+	}
+}
+
+func TestMotifTemplate(t *testing.T) {
+	w := 20
+	shape := make([]float64, w)
+	for i := range shape {
+		shape[i] = math.Sin(2 * math.Pi * float64(i) / float64(w))
+	}
+	expected, err := util.ZNormalize(shape)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sig := siggen.Noise(0.2, 700)
+	for _, start := range []int{50, 190, 330, 470} {
+		for i := 0; i < w; i++ {
+			sig[start+i] += shape[i]
+		}
+	}
+	// an upside down occurrence and a flat stretch
+	for i := 0; i < w; i++ {
+		sig[560+i] -= shape[i]
+		sig[620+i] = 1
+	}
+
+	testdata := []struct {
+		name      string
+		idx       []int
+		remap     bool
+		maxDist   float64
+		expectErr bool
+	}{
+		{"empty group", nil, false, 0, true},
+		{"flat group", []int{620}, false, 0, true},
+		{"out of range", []int{50, 690}, false, 0, true},
+		{"single member", []int{50}, false, 2, false},
+		{"averaged members", []int{50, 190, 330, 470}, false, 1.2, false},
+		{"flat member left out", []int{50, 190, 330, 470, 620}, false, 1.2, false},
+		{"flipped member", []int{50, 190, 330, 470, 560}, true, 1.2, false},
+		{"flipped pair", []int{50, 560}, true, 1.5, false},
+	}
+
+	for _, d := range testdata {
+		mp, err := New(sig, nil, w)
+		if err != nil {
+			t.Fatal(err)
+		}
+		mp.Opts = NewMPOpts()
+		mp.Opts.RemapNegCorr = d.remap
+
+		template, err := mp.MotifTemplate(MotifGroup{Idx: d.idx})
+		if d.expectErr {
+			if err == nil {
+				t.Errorf("%s: expected an error", d.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", d.name, err)
+			continue
+		}
+		if len(template) != w {
+			t.Fatalf("%s: expected a template of length %d, but got %d", d.name, w, len(template))
+		}
+		if dist := floats.Distance(template, expected, 2); dist > d.maxDist {
+			t.Errorf("%s: expected the template within %.1f of the planted shape, but got %.3f", d.name, d.maxDist, dist)
+		}
+	}
+}
+
+func TestMotifsNear(t *testing.T) {
+	w := 20
+	sig := siggen.Noise(0.1, 600)
+	occurrences := []int{50, 190, 330, 470}
+	for _, start := range occurrences {
+		for i := 0; i < w; i++ {
+			sig[start+i] += math.Sin(2 * math.Pi * float64(i) / float64(w))
+		}
+	}
+	query := make([]float64, w)
+	for i := range query {
+		query[i] = math.Sin(2 * math.Pi * float64(i) / float64(w))
+	}
+
+	mp, err := New(sig, nil, w)
+	if err != nil {
+		t.Fatal(err)
+	}
+	g, err := mp.MotifsNear(query, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(g.Idx) != len(occurrences) {
+		t.Fatalf("Expected %d occurrences, but got %+v", len(occurrences), g)
+	}
+	seed := -1
+	for k, idx := range g.Idx {
+		if idx < occurrences[k]-w/4 || idx > occurrences[k]+w/4 {
+			t.Errorf("Expected an occurrence near %d, but got %v", occurrences[k], g.Idx)
+		}
+		if g.Dists[k] == 0 {
+			seed = idx
+		}
+	}
+	if seed < 0 {
+		t.Fatalf("Expected a seed at distance 0, but got %v", g.Dists)
+	}
+	toSeed, err := mp.DistanceProfile(mp.A[seed : seed+w])
+	if err != nil {
+		t.Fatal(err)
+	}
+	for k, idx := range g.Idx {
+		if idx != seed && math.Abs(g.Dists[k]-toSeed[idx]) > 1e-9 {
+			t.Errorf("Expected a distance of %.4f to the seed for %d, but got %.4f", toSeed[idx], idx, g.Dists[k])
+		}
+		if g.Dists[k] > g.Radius || (idx != seed && g.Dists[k] < g.MinDist) {
+			t.Errorf("Expected distances between %.4f and %.4f, but got %v", g.MinDist, g.Radius, g.Dists)
+		}
+	}
+
+	if _, err = mp.MotifsNear(query, 0); err == nil {
+		t.Errorf("Expected an error when nothing is within the radius")
+	}
+	if _, err = mp.MotifsNear(query[:w-1], 2); err == nil {
+		t.Errorf("Expected an error for a query of the wrong length")
+	}
+}
+
+func TestMotifNeighbors(t *testing.T) {
+	w := 20
+	sig := siggen.Noise(0.1, 500)
+	occurrences := []int{40, 160, 300, 420}
+	for _, start := range occurrences {
+		for i := 0; i < w; i++ {
+			sig[start+i] += math.Sin(2 * math.Pi * float64(i) / float64(w))
+		}
+	}
+	query := make([]float64, 60)
+	copy(query, sig[150:210])
+
+	testdata := []struct {
+		b           []float64
+		idx         int
+		radius      float64
+		expected    []int
+		expectedErr bool
+	}{
+		{nil, -1, 1, nil, true},
+		{nil, 481, 1, nil, true},
+		{nil, 160, 0, []int{160}, false},
+		{nil, 160, 2, occurrences, false},
+		{nil, 300, 2, occurrences, false},
+		{query, 300, 2, []int{10}, false},
+	}
+
+	for _, d := range testdata {
+		mp, err := New(sig, d.b, w)
+		if err != nil {
+			t.Fatal(err)
+		}
+		out, err := mp.MotifNeighbors(d.idx, d.radius)
+		if d.expectedErr {
+			if err == nil {
+				t.Errorf("Expected an error for index %d", d.idx)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("Did not expect an error, %v", err)
+		}
+		if len(out) != len(d.expected) {
+			t.Errorf("Expected %v, but got %v for index %d", d.expected, out, d.idx)
+			continue
+		}
+		for i := range out {
+			if out[i] < d.expected[i]-1 || out[i] > d.expected[i]+1 {
+				t.Errorf("Expected %v, but got %v for index %d", d.expected, out, d.idx)
+				break
+			}
+		}
+	}
+}
+
+func TestDensityProfile(t *testing.T) {
+	w := 20
+	sig := siggen.Noise(0.1, 400)
+	occurrences := []int{40, 160, 300}
+	for _, start := range occurrences {
+		for i := 0; i < w; i++ {
+			sig[start+i] += math.Sin(2 * math.Pi * float64(i) / float64(w))
+		}
+	}
+	// a one-off event
+	for i := 220; i < 240; i++ {
+		sig[i] += 2 * math.Sin(math.Pi*float64(i-220)/float64(w))
+	}
+
+	testdata := []struct {
+		b           []float64
+		njobs       int
+		expectedErr bool
+	}{
+		{sig, 1, true},
+		{nil, 1, false},
+		{nil, 4, false},
+	}
+
+	for _, d := range testdata {
+		mp, err := New(sig, d.b, w)
+		if err != nil {
+			t.Fatal(err)
+		}
+		o := NewMPOpts()
+		o.NJobs = d.njobs
+		if err = mp.Compute(o); err != nil {
+			t.Fatal(err)
+		}
+
+		density, err := mp.DensityProfile(1)
+		if d.expectedErr {
+			if err == nil {
+				t.Errorf("Expected an error for an AB join")
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("Did not expect an error, %v", err)
+		}
+
+		// compares against a direct distance profile of each subsequence
+		if err = mp.initCaches(); err != nil {
+			t.Fatal(err)
+		}
+		prof := make([]float64, len(density))
+		fft := mp.newFFT()
+		for i := range density {
+			if err = mp.distanceProfile(i, prof, fft); err != nil {
+				t.Fatal(err)
+			}
+			expected := 0
+			last := -w
+			for j, dist := range prof {
+				if dist <= 1 && j-last >= w/2 {
+					expected++
+					last = j
+				}
+			}
+			if density[i] != expected {
+				t.Errorf("Expected a density of %d at %d, but got %d", expected, i, density[i])
+				break
+			}
+		}
+
+		for _, idx := range occurrences {
+			if density[idx] != len(occurrences)-1 {
+				t.Errorf("Expected a density of %d at %d, but got %d", len(occurrences)-1, idx, density[idx])
+			}
+		}
+		if density[220] != 0 {
+			t.Errorf("Expected a density of 0 for the one-off event, but got %d", density[220])
+		}
+	}
+}
+
+func TestFlatThreshold(t *testing.T) {
+	w := 20
+	sig := siggen.Add(siggen.Sin(1, 5, 0, 0, 100, 3), seededNoise(1, 0.1, 300))
+	// a slowly drifting flat region z-normalizes into nearly identical ramps
+	for i := 100; i < 200; i++ {
+		sig[i] = 1 + 1e-6*float64(i)
+	}
+
+	inFlat := func(idx int) bool {
+		return idx+w > 100 && idx < 200
+	}
+
+	testdata := []struct {
+		threshold    float64
+		expectedFlat bool
+	}{
+		{0, true},
+		{0.05, false},
+	}
+
+	for _, d := range testdata {
+		mp, err := New(sig, nil, w)
+		if err != nil {
+			t.Fatal(err)
+		}
+		o := NewMPOpts()
+		o.FlatThreshold = d.threshold
+		if err = mp.Compute(o); err != nil {
+			t.Fatal(err)
+		}
+
+		if d.threshold == 0 && mp.Mask != nil {
+			t.Errorf("Expected no mask without a threshold, but got %v", mp.Mask)
+		}
+		if d.threshold > 0 {
+			for i, masked := range mp.Mask {
+				if i >= 100 && i+w <= 200 && !masked {
+					t.Errorf("Expected subsequence %d in the flat region to be masked", i)
+					break
+				}
+			}
+		}
+
+		motifs, err := mp.DiscoverMotifs(2, 2, 10, w/2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		discords, err := mp.DiscoverDiscords(3, w/2)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		foundFlat := false
+		for _, m := range motifs {
+			for _, idx := range m.Idx {
+				if inFlat(idx) {
+					foundFlat = true
+				}
+			}
+		}
+		if foundFlat != d.expectedFlat {
+			t.Errorf("Expected motifs in the flat region to be %v, but got %+v with threshold %.2f", d.expectedFlat, motifs, d.threshold)
+		}
+		for _, idx := range discords {
+			if d.threshold > 0 && mp.Mask[idx] {
+				t.Errorf("Expected no discords in the masked region, but got %v", discords)
+			}
+		}
+	}
+}
+
+func TestDiscoverDiscords(t *testing.T) {
+	mprof := []float64{1, 2, 3, 4}
+	a := []float64{1, 2, 3, 4, 5, 6}
+	w := 3
+
+	testdata := []struct {
+		mp               []float64
+		k                int
+		exzone           int
+		expectedDiscords []int
+	}{
+		{mprof, 4, 0, []int{3, 3, 3, 3}},
+		{mprof, 4, 1, []int{3, 1}},
+		{mprof, 10, 1, []int{3, 1}},
+		{mprof, 0, 1, []int{}},
+	}
+
+	for _, d := range testdata {
+		mp := MatrixProfile{A: a, B: a, W: w, MP: d.mp, AV: av.Default, Opts: NewMPOpts()}
+		discords, err := mp.DiscoverDiscords(d.k, d.exzone)
+		if err != nil {
+			t.Errorf("Got error %v on %v", err, d)
+			return
+		}
+		if len(discords) != len(d.expectedDiscords) {
+			t.Errorf("Got a length of %d discords, but expected %d, for %v", len(discords), len(d.expectedDiscords), d)
+			return
+		}
+		for i, idx := range discords {
+			if idx != d.expectedDiscords[i] {
+				t.Errorf("expected index, %d, but got %d, for %v", d.expectedDiscords[i], idx, d)
+				return
+			}
+		}
+	}
+}
+
+func TestDiscoverDiscordDetails(t *testing.T) {
+	a := []float64{1, 2, 3, 4, 5, 6, 7, 8}
+	mprof := []float64{1, 2, 3, 4, 1.5, 0.5}
+	idx := []int{3, 4, 5, 0, 1, 2}
+	w := 3
+
+	testdata := []struct {
+		customAV []float64
+		expected []Discord
+	}{
+		{nil, []Discord{
+			{Rank: 0, Idx: 3, NN: 0, Dist: 4, Score: 4},
+			{Rank: 1, Idx: 1, NN: 4, Dist: 2, Score: 2},
+			{Rank: 2, Idx: 4, NN: 1, Dist: 1.5, Score: 1.5},
+		}},
+		{[]float64{1, 1, 1, 0.5, 1, 1}, []Discord{
+			{Rank: 0, Idx: 2, NN: 5, Dist: 3, Score: 3},
+			{Rank: 1, Idx: 3, NN: 0, Dist: 4, Score: 2},
+			{Rank: 2, Idx: 4, NN: 1, Dist: 1.5, Score: 1.5},
+		}},
+	}
+
+	for i, d := range testdata {
+		mp := MatrixProfile{A: a, B: a, W: w, MP: mprof, Idx: idx, AV: av.Default, CustomAV: d.customAV, Opts: NewMPOpts()}
+		discords, err := mp.DiscoverDiscordDetails(3, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(discords, d.expected) {
+			t.Errorf("Expected %v, but got %v for case %d", d.expected, discords, i)
+		}
+		indices := make([]int, len(discords))
+		for r, disc := range discords {
+			indices[r] = disc.Idx
+		}
+		if !reflect.DeepEqual(mp.Discords, indices) {
+			t.Errorf("Expected the discords %v to be kept, but got %v for case %d", indices, mp.Discords, i)
+		}
+	}
+}
+
+func TestDiscoverMotifs(t *testing.T) {
+	a := []float64{0, 0, 0.56, 0.99, 0.97, 0.75, 0, 0, 0, 0.43, 0.98, 0.99, 0.65, 0, 0, 0, 0.6, 0.97, 0.965, 0.8, 0, 0, 0}
+
+	testdata := []struct {
+		a               []float64
+		b               []float64
+		m               int
 		k               int
 		expectedMotifs  [][]int
 		expectedMinDist []float64
@@ -893,12 +2803,13 @@ func TestDiscoverSegments(t *testing.T) {
 		{[]int{2, 3, 0, 0, 6, 3, 4}, 3, 0, []float64{1, 1, 0.7, 0, 0.29166666, 0.7, 1}},
 	}
 
-	var minIdx int
-	var minVal float64
-	var histo []float64
 	for _, d := range testdata {
 		mp := MatrixProfile{Idx: d.mpIdx}
-		minIdx, minVal, histo = mp.DiscoverSegments()
+		minIdx, minVal, histo, err := mp.DiscoverSegments()
+		if err != nil {
+			t.Errorf("Did not expect an error, %v, %+v", err, d)
+			continue
+		}
 		if histo != nil && d.expectedHisto == nil {
 			// Failed to compute histogram
 			continue
@@ -920,3 +2831,291 @@ func TestDiscoverSegments(t *testing.T) {
 		}
 	}
 }
+
+func TestComputeTransform(t *testing.T) {
+	pattern := []float64{0, 2, 5, 1, -3, 0, 4, -1}
+	sig := siggen.Noise(0.1, 200)
+	sig = siggen.Append(sig[:40], pattern, sig[40:130], pattern, sig[130:])
+	trend := siggen.Add(sig, siggen.Line(0.5, 0, len(sig)))
+
+	testdata := []struct {
+		transform Transform
+		n         int
+		span      int
+	}{
+		{TransformDiff, len(trend) - 1, 9},
+		{TransformDetrend, len(trend), 8},
+	}
+
+	for _, d := range testdata {
+		mp, err := New(trend, nil, 8)
+		if err != nil {
+			t.Fatal(err)
+		}
+		o := NewMPOpts()
+		o.Transform = d.transform
+		if err = mp.Compute(o); err != nil {
+			t.Fatal(err)
+		}
+		if len(mp.RawA) != len(trend) || len(mp.A) != d.n || mp.N != d.n || len(mp.MP) != d.n-mp.W+1 {
+			t.Errorf("Expected a transformed length of %d, but got %d for %s", d.n, len(mp.A), d.transform)
+		}
+		if err = mp.Update([]float64{1}); err == nil {
+			t.Errorf("Expected an error streaming a transformed timeseries for %s", d.transform)
+		}
+
+		motifs, err := mp.DiscoverMotifs(1, 2, 0, mp.W/2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		start, end := mp.RawSpan(motifs[0].Idx[0])
+		if end-start != d.span {
+			t.Errorf("Expected a raw span of %d, but got %d for %s", d.span, end-start, d.transform)
+		}
+		// a differenced subsequence covers one raw point before the pattern
+		for _, idx := range motifs[0].Idx {
+			if start, end := mp.RawSpan(idx); !(start <= 40 && end >= 48) && !(start <= 138 && end >= 146) {
+				t.Errorf("Expected motifs covering 40 and 138 of the raw timeseries, but got %v for %s", motifs[0].Idx, d.transform)
+				break
+			}
+		}
+
+		if err = mp.Compute(NewMPOpts()); err != nil {
+			t.Fatal(err)
+		}
+		if mp.RawA != nil || len(mp.A) != len(trend) || mp.A[len(trend)-1] != trend[len(trend)-1] {
+			t.Errorf("Expected the raw timeseries to be restored without a transform for %s", d.transform)
+		}
+	}
+}
+
+func TestTransformTimeIndex(t *testing.T) {
+	a := seededNoise(1, 1, 50)
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	times := make([]time.Time, len(a))
+	for i := range times {
+		times[i] = start.Add(time.Duration(i) * time.Minute)
+	}
+
+	// wrapped points are stamped one period later, the span of the profiled timeseries
+	// and one more minute
+	testdata := []struct {
+		transform Transform
+		circular  bool
+		first     int
+		last      int
+		period    time.Duration
+	}{
+		{TransformDiff, false, 1, 49, 0},
+		{TransformDetrend, false, 0, 49, 0},
+		{TransformDiff, true, 1, 4, 49 * time.Minute},
+		{TransformNone, true, 0, 3, 50 * time.Minute},
+	}
+
+	w := 5
+	for _, d := range testdata {
+		mp, err := NewWithOpts(a, WithWindow(w), WithTimeIndex(times))
+		if err != nil {
+			t.Fatal(err)
+		}
+		o := NewMPOpts()
+		o.Transform = d.transform
+		o.Circular = d.circular
+		if err = mp.Compute(o); err != nil {
+			t.Fatal(err)
+		}
+		if len(mp.TimeIndex) != len(mp.A) {
+			t.Fatalf("Expected a time index of length %d, but got %d for %+v", len(mp.A), len(mp.TimeIndex), d)
+		}
+		last := times[d.last].Add(d.period)
+		if !mp.TimeIndex[0].Equal(times[d.first]) || !mp.TimeIndex[len(mp.A)-1].Equal(last) {
+			t.Errorf("Expected the time index to run from %v to %v, but got %v to %v for %+v", times[d.first], last, mp.TimeIndex[0], mp.TimeIndex[len(mp.A)-1], d)
+		}
+		for i := 1; i < len(mp.TimeIndex); i++ {
+			if !mp.TimeIndex[i].After(mp.TimeIndex[i-1]) {
+				t.Errorf("Expected an increasing time index, but got %v after %v at %d for %+v", mp.TimeIndex[i], mp.TimeIndex[i-1], i, d)
+				break
+			}
+		}
+
+		if err = mp.Compute(NewMPOpts()); err != nil {
+			t.Fatal(err)
+		}
+		if mp.RawTimeIndex != nil || len(mp.TimeIndex) != len(times) || !mp.TimeIndex[0].Equal(times[0]) {
+			t.Errorf("Expected the original time index to be restored without a transform for %+v", d)
+		}
+	}
+}
+
+func TestSTOMPSelfJoinSymmetry(t *testing.T) {
+	sig := siggen.Add(siggen.Sin(1, 4, 0, 0, 100, 2), siggen.Noise(0.2, 200))
+
+	expected, err := New(sig, nil, 16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	o := NewMPOpts()
+	o.Algorithm = AlgoSTMP
+	if err = expected.Compute(o); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, njobs := range []int{1, 3, 8, 400} {
+		mp, err := New(sig, nil, 16)
+		if err != nil {
+			t.Fatal(err)
+		}
+		o := NewMPOpts()
+		o.Algorithm = AlgoSTOMP
+		o.NJobs = njobs
+		if err = mp.Compute(o); err != nil {
+			t.Fatal(err)
+		}
+		for i := range mp.MP {
+			if math.Abs(mp.MP[i]-expected.MP[i]) > 1e-7 {
+				t.Errorf("Expected %.6f at %d, but got %.6f for %d jobs", expected.MP[i], i, mp.MP[i], njobs)
+				break
+			}
+		}
+	}
+}
+
+func TestComputeCircular(t *testing.T) {
+	// a pattern split across the end of the timeseries also occurs whole at 100
+	pattern := siggen.Sin(3, 5, 0, 0, 100, 0.2)
+	sig := siggen.Noise(0.5, 200)
+	copy(sig[100:], pattern)
+	copy(sig[190:], pattern[:10])
+	copy(sig, pattern[10:])
+
+	mp, err := New(sig, sig, 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	o := NewMPOpts()
+	o.Circular = true
+	if err = mp.Compute(o); err == nil {
+		t.Errorf("Expected an error for a circular AB join")
+	}
+
+	var expected []float64
+	for _, algo := range []Algo{AlgoSTMP, AlgoSTAMP, AlgoSTOMP, AlgoMPX} {
+		mp, err := New(sig, nil, 20)
+		if err != nil {
+			t.Fatal(err)
+		}
+		mp.ExclusionZone = 5
+		o := NewMPOpts()
+		o.Algorithm = algo
+		o.Circular = true
+		if err = mp.Compute(o); err != nil {
+			t.Fatal(err)
+		}
+
+		if len(mp.MP) != len(sig) || len(mp.RawA) != len(sig) {
+			t.Fatalf("Expected a subsequence at every point of the timeseries, but got %d for %s", len(mp.MP), algo)
+		}
+		if mp.Idx[190] != 100 || mp.MP[190] > 1e-6 {
+			t.Errorf("Expected the wrapped pattern at 190 to match 100, but got %d at %.3f for %s", mp.Idx[190], mp.MP[190], algo)
+		}
+		for i, idx := range mp.Idx {
+			lag := idx - i
+			if lag < 0 {
+				lag = -lag
+			}
+			if lag > len(sig)/2 {
+				lag = len(sig) - lag
+			}
+			if lag < mp.ExclusionZone {
+				t.Errorf("Expected the neighbor of %d to be outside the wrapped exclusion zone, but got %d for %s", i, idx, algo)
+				break
+			}
+		}
+
+		// mpx compares the pairs exactly at the edge of the exclusion zone on both
+		// sides, so only the distance profile based algorithms match exactly
+		if algo == AlgoMPX {
+			continue
+		}
+		if expected == nil {
+			expected = mp.MP
+			continue
+		}
+		for i := range mp.MP {
+			if math.Abs(mp.MP[i]-expected[i]) > 1e-6 {
+				t.Errorf("Expected %.6f at %d, but got %.6f for %s", expected[i], i, mp.MP[i], algo)
+				break
+			}
+		}
+	}
+}
+
+func TestVisualizeOverlay(t *testing.T) {
+	sig := siggen.Add(siggen.Sin(1, 4, 0, 0, 100, 4), siggen.Noise(0.1, 400))
+	mp, err := New(sig, nil, 25)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = mp.Compute(nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = mp.DiscoverMotifs(2, 2, 10, mp.W/2); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = mp.DiscoverDiscords(2, mp.W/2); err != nil {
+		t.Fatal(err)
+	}
+	events, err := mp.AnomalyEvents(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir, err := ioutil.TempDir("", "overlay")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	fn := filepath.Join(dir, "overlay.png")
+	if err = mp.VisualizeOverlay(fn, events); err != nil {
+		t.Fatal(err)
+	}
+	if info, err := os.Stat(fn); err != nil || info.Size() == 0 {
+		t.Errorf("Expected a png to be written, but got %v", err)
+	}
+}
+
+func TestComputeDeterministicTies(t *testing.T) {
+	// an exactly repeating pattern gives every subsequence several equally close matches
+	pattern := []float64{0, 1, 3, 2, 5, 4, 1, 0}
+	var a []float64
+	for i := 0; i < 12; i++ {
+		a = append(a, pattern...)
+	}
+
+	for _, algo := range []Algo{AlgoMPX, AlgoSTAMP, AlgoSTOMP} {
+		var first []int
+		for _, njobs := range []int{1, 2, 3, 5, 8} {
+			mp, err := New(a, nil, 8)
+			if err != nil {
+				t.Fatal(err)
+			}
+			o := NewMPOpts()
+			o.Algorithm = algo
+			o.NJobs = njobs
+			if err = mp.Compute(o); err != nil {
+				t.Fatal(err)
+			}
+			if first == nil {
+				first = mp.Idx
+				continue
+			}
+			for i := range first {
+				if mp.Idx[i] != first[i] {
+					t.Errorf("Expected index %d at %d with %d jobs like with 1 job, but got %d for %s", first[i], i, njobs, mp.Idx[i], algo)
+					break
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package matrixprofile
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+
+	"gonum.org/v1/gonum/stat"
+)
+
+// ThresholdRule decides when the discord score of a new subsequence is anomalous. A
+// rule either has a static threshold or follows a quantile of the recent scores.
+type ThresholdRule struct {
+	Name     string  `json:"name"`     // identifies the rule in alerts
+	Static   float64 `json:"static"`   // euclidean distance at or above which a score is anomalous. Defaults to 0 which uses the quantile instead
+	Quantile float64 `json:"quantile"` // quantile of the recent scores, between 0 and 1, at or above which a score is anomalous when no static threshold is set
+	Window   int     `json:"window"`   // number of recent scores the quantile is taken over. The rule only fires once that many scores are seen
+}
+
+// validate checks that the rule sets exactly one kind of threshold.
+func (r ThresholdRule) validate() error {
+	switch {
+	case r.Static < 0:
+		return fmt.Errorf("static threshold of rule %s, %.3f, must not be negative", r.Name, r.Static)
+	case r.Static > 0 && r.Quantile != 0:
+		return fmt.Errorf("rule %s must have either a static threshold or a quantile", r.Name)
+	case r.Static == 0 && (r.Quantile <= 0 || r.Quantile >= 1):
+		return fmt.Errorf("quantile of rule %s, %.3f, must be between 0 and 1", r.Name, r.Quantile)
+	case r.Static == 0 && r.Window < 1:
+		return fmt.Errorf("quantile window of rule %s, %d, must be at least 1", r.Name, r.Window)
+	}
+	return nil
+}
+
+// Alert is emitted by a Monitor when the score of a new subsequence crosses a rule's
+// threshold.
+type Alert struct {
+	Rule      string  // name of the rule crossed
+	Idx       int     // absolute start index of the subsequence in the stream, including any retired points
+	Score     float64 // euclidean distance of the subsequence to its nearest neighbor
+	Threshold float64 // threshold of the rule when the score crossed it
+}
+
+// Monitor watches a streaming self join for anomalies. Each new point completes a
+// subsequence whose matrix profile value, the distance to its nearest neighbor in the
+// past, is its discord score. OnAlert is called when a score crosses a rule's
+// threshold from below, so the overlapping subsequences of a single incident raise one
+// alert per rule rather than one per point.
+type Monitor struct {
+	MP      *MatrixProfile  // streaming self join updated by the monitor
+	Rules   []ThresholdRule // rules checked against each new score
+	OnAlert func(Alert)     // called for each crossing
+
+	scores []float64 // most recent finite scores, as many as the longest quantile window
+	above  []bool    // whether the last score of each rule was at or above its threshold
+}
+
+// NewMonitor creates a monitor of a computed self join matrix profile, such as one set
+// up with StreamOpts to cap its length.
+func NewMonitor(mp *MatrixProfile, onAlert func(Alert), rules ...ThresholdRule) (*Monitor, error) {
+	if mp == nil || mp.MP == nil {
+		return nil, errors.New("matrix profile has not been computed")
+	}
+	if !mp.SelfJoin {
+		return nil, errors.New("can only monitor a self join")
+	}
+	if onAlert == nil {
+		return nil, errors.New("must provide an alert callback")
+	}
+	if len(rules) == 0 {
+		return nil, errors.New("must provide at least one threshold rule")
+	}
+	for _, r := range rules {
+		if err := r.validate(); err != nil {
+			return nil, err
+		}
+	}
+	return &Monitor{
+		MP:      mp,
+		Rules:   rules,
+		OnAlert: onAlert,
+		above:   make([]bool, len(rules)),
+	}, nil
+}
+
+// Update appends the values to the matrix profile one at a time and checks the score
+// of each new subsequence against the rules. Quantile thresholds are taken over the
+// scores before the new one, and flat subsequences, without a defined score, are
+// skipped.
+func (m *Monitor) Update(newValues []float64) error {
+	window := 0
+	for _, r := range m.Rules {
+		if r.Static == 0 && r.Window > window {
+			window = r.Window
+		}
+	}
+
+	for _, v := range newValues {
+		if err := m.MP.Update([]float64{v}); err != nil {
+			return err
+		}
+		last := len(m.MP.MP) - 1
+		score := MatrixProfile{MP: m.MP.MP[last:], W: m.MP.W, Opts: m.MP.Opts}.euclideanMP()[0]
+		if math.IsInf(score, 0) || math.IsNaN(score) {
+			continue
+		}
+
+		for i, r := range m.Rules {
+			threshold, ok := r.Static, true
+			if r.Static == 0 {
+				threshold, ok = recentQuantile(m.scores, r.Quantile, r.Window)
+			}
+			above := ok && score >= threshold
+			if above && !m.above[i] {
+				m.OnAlert(Alert{
+					Rule:      r.Name,
+					Idx:       last + m.MP.Offset,
+					Score:     score,
+					Threshold: threshold,
+				})
+			}
+			m.above[i] = above
+		}
+
+		if window > 0 {
+			m.scores = append(m.scores, score)
+			if len(m.scores) > window {
+				m.scores = m.scores[len(m.scores)-window:]
+			}
+		}
+	}
+	return nil
+}
+
+// recentQuantile returns the quantile of the last window scores, or false if fewer
+// scores were seen.
+func recentQuantile(scores []float64, q float64, window int) (float64, bool) {
+	if len(scores) < window {
+		return 0, false
+	}
+	sorted := make([]float64, window)
+	copy(sorted, scores[len(scores)-window:])
+	sort.Float64s(sorted)
+	return stat.Quantile(q, stat.Empirical, sorted, nil), true
+}
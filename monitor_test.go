@@ -0,0 +1,103 @@
+package matrixprofile
+
+import (
+	"testing"
+
+	"github.com/matrix-profile-foundation/go-matrixprofile/siggen"
+)
+
+func TestNewMonitor(t *testing.T) {
+	sig := siggen.Add(siggen.Sin(1, 4, 0, 0, 100, 2), siggen.Noise(0.05, 200))
+	mp, err := New(sig, nil, 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	onAlert := func(Alert) {}
+	if _, err = NewMonitor(mp, onAlert, ThresholdRule{Static: 1}); err == nil {
+		t.Errorf("Expected an error before the matrix profile is computed")
+	}
+	if err = mp.Compute(NewMPOpts()); err != nil {
+		t.Fatal(err)
+	}
+
+	testdata := []struct {
+		onAlert     func(Alert)
+		rules       []ThresholdRule
+		expectedErr bool
+	}{
+		{onAlert, []ThresholdRule{{Static: 1}}, false},
+		{onAlert, []ThresholdRule{{Quantile: 0.99, Window: 50}, {Static: 2}}, false},
+		{nil, []ThresholdRule{{Static: 1}}, true},
+		{onAlert, nil, true},
+		{onAlert, []ThresholdRule{{Static: -1}}, true},
+		{onAlert, []ThresholdRule{{Static: 1, Quantile: 0.5, Window: 10}}, true},
+		{onAlert, []ThresholdRule{{Quantile: 1, Window: 10}}, true},
+		{onAlert, []ThresholdRule{{Quantile: 0.5}}, true},
+	}
+	for _, d := range testdata {
+		_, err := NewMonitor(mp, d.onAlert, d.rules...)
+		if d.expectedErr && err == nil {
+			t.Errorf("Expected an error for rules %+v", d.rules)
+		}
+		if !d.expectedErr && err != nil {
+			t.Errorf("Did not expect an error, %v, for rules %+v", err, d.rules)
+		}
+	}
+}
+
+func TestMonitor(t *testing.T) {
+	w := 20
+	sig := siggen.Add(siggen.Sin(1, 4, 0, 0, 100, 10), siggen.Noise(0.05, 1000))
+	anomaly := 800
+	for i := 0; i < 10; i++ {
+		sig[anomaly+i] += 3 * float64(i%2)
+	}
+	history := 400
+
+	mp, err := New(sig[:history], nil, w)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mp.Stream = &StreamOpts{MaxLen: 300}
+	if err = mp.Compute(NewMPOpts()); err != nil {
+		t.Fatal(err)
+	}
+
+	alerts := make(map[string][]Alert)
+	m, err := NewMonitor(mp, func(a Alert) {
+		alerts[a.Rule] = append(alerts[a.Rule], a)
+	}, ThresholdRule{Name: "static", Static: 2}, ThresholdRule{Name: "quantile", Quantile: 0.99, Window: 200})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := history; i < len(sig); i += 50 {
+		end := i + 50
+		if end > len(sig) {
+			end = len(sig)
+		}
+		if err = m.Update(sig[i:end]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if len(alerts["static"]) != 1 {
+		t.Fatalf("Expected a single static alert for the anomaly, but got %+v", alerts["static"])
+	}
+	for name, list := range alerts {
+		for _, a := range list {
+			if a.Score < a.Threshold || a.Idx < history-w+1 || a.Idx+w > len(sig) {
+				t.Errorf("Expected a new subsequence scoring above its threshold for rule %s, but got %+v", name, a)
+			}
+			if name == "quantile" && a.Idx < history-w+1+200 {
+				t.Errorf("Expected no quantile alert before the window is full, but got %+v", a)
+			}
+		}
+	}
+	found := false
+	for _, a := range alerts["quantile"] {
+		found = found || a.Idx+w > anomaly && a.Idx < anomaly+10
+	}
+	if s := alerts["static"][0]; s.Idx+w <= anomaly || s.Idx >= anomaly+10 || !found {
+		t.Errorf("Expected both rules to alert on the anomaly at %d, but got %+v", anomaly, alerts)
+	}
+}
@@ -0,0 +1,317 @@
+// Package mpdist provides similarity search over collections of timeseries using the
+// matrix profile distance measure, MPdist.
+package mpdist
+
+import (
+	"container/heap"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+
+	mp "github.com/matrix-profile-foundation/go-matrixprofile"
+	"github.com/matrix-profile-foundation/go-matrixprofile/util"
+)
+
+// mpdistThresh is the fraction of the combined length of both series used by
+// MPdist to pick the reported distance out of the joined matrix profiles.
+const mpdistThresh = 0.05
+
+// Neighbor is a single result of a nearest neighbor search with the id of the
+// series returned by Add and its MPdist to the query.
+type Neighbor struct {
+	ID   int
+	Dist float64
+}
+
+// Index stores a collection of timeseries and answers k nearest neighbor queries
+// under MPdist. Each stored series keeps a kd-tree over piecewise aggregate
+// sketches of its z-normalized subsequences, which provides a cheap lower bound on
+// MPdist used to skip exact computations.
+type Index struct {
+	W        int // subsequence length used for MPdist
+	Segments int // number of piecewise aggregate segments per subsequence sketch
+	LeafSize int // maximum number of sketches held by a kd-tree leaf
+
+	series []indexEntry
+	exact  int // number of exact MPdist computations performed by the last query
+}
+
+type indexEntry struct {
+	ts       []float64
+	sketches [][]float64
+	tree     *kdNode
+}
+
+// kdNode is a node of a kd-tree over sketches. Leaves hold the sketches directly
+// and every node holds the bounding box of the sketches below it.
+type kdNode struct {
+	lo, hi      []float64
+	left, right *kdNode
+	points      [][]float64
+}
+
+// NewIndex creates an empty index computing MPdist with a subsequence length of w.
+func NewIndex(w int) (*Index, error) {
+	if w < 2 {
+		return nil, errors.New("subsequence length must be at least 2")
+	}
+	s := 8
+	if w < s {
+		s = w
+	}
+	return &Index{W: w, Segments: s, LeafSize: 8}, nil
+}
+
+// Add stores a timeseries in the index and returns its id.
+func (idx *Index) Add(ts []float64) (int, error) {
+	if len(ts) < idx.W {
+		return 0, fmt.Errorf("timeseries length, %d, must be at least the subsequence length, %d", len(ts), idx.W)
+	}
+	sk, err := idx.sketches(ts)
+	if err != nil {
+		return 0, err
+	}
+	idx.series = append(idx.series, indexEntry{ts: ts, sketches: sk, tree: idx.buildTree(sk)})
+	return len(idx.series) - 1, nil
+}
+
+// Len returns the number of timeseries stored in the index.
+func (idx Index) Len() int {
+	return len(idx.series)
+}
+
+// Series returns the timeseries stored under an id.
+func (idx Index) Series(id int) []float64 {
+	if id < 0 || id >= len(idx.series) {
+		return nil
+	}
+	return idx.series[id].ts
+}
+
+// NearestK returns the k stored timeseries with the lowest MPdist to the query
+// sorted by ascending distance. Series are visited in order of their lower bound
+// and the search stops once no remaining lower bound can beat the current k-th
+// best distance.
+func (idx *Index) NearestK(query []float64, k int) ([]Neighbor, error) {
+	if k < 1 {
+		return nil, fmt.Errorf("must request at least 1 neighbor, got %d", k)
+	}
+	if len(query) < idx.W {
+		return nil, fmt.Errorf("query length, %d, must be at least the subsequence length, %d", len(query), idx.W)
+	}
+	if k > len(idx.series) {
+		k = len(idx.series)
+	}
+	idx.exact = 0
+
+	qsk, err := idx.sketches(query)
+	if err != nil {
+		return nil, err
+	}
+	qtree := idx.buildTree(qsk)
+
+	order := make([]Neighbor, len(idx.series))
+	for i, e := range idx.series {
+		order[i] = Neighbor{ID: i, Dist: idx.lowerBound(query, qsk, qtree, e)}
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return order[i].Dist < order[j].Dist
+	})
+
+	var best neighborHeap
+	heap.Init(&best)
+	o := mp.NewMPDistOpts()
+	for _, cand := range order {
+		if len(best) == k && cand.Dist >= best[0].Dist {
+			break
+		}
+		d, err := mp.MPDist(query, idx.series[cand.ID].ts, idx.W, o)
+		if err != nil {
+			return nil, err
+		}
+		idx.exact++
+		if len(best) < k {
+			heap.Push(&best, Neighbor{ID: cand.ID, Dist: d})
+		} else if d < best[0].Dist {
+			best[0] = Neighbor{ID: cand.ID, Dist: d}
+			heap.Fix(&best, 0)
+		}
+	}
+
+	out := make([]Neighbor, len(best))
+	for i := len(out) - 1; i >= 0; i-- {
+		out[i] = heap.Pop(&best).(Neighbor)
+	}
+	return out, nil
+}
+
+// lowerBound computes a lower bound of the MPdist between the query and a stored
+// series. Each query subsequence's nearest neighbor distance is bounded by the
+// distance of its sketch to the nearest sketch of the series, and likewise each
+// subsequence of the series is bounded by the nearest sketch of the query. The
+// same order statistic MPdist uses is then taken over these bounds.
+func (idx Index) lowerBound(query []float64, qsk [][]float64, qtree *kdNode, e indexEntry) float64 {
+	bounds := make([]float64, 0, len(qsk)+len(e.sketches))
+	for _, sk := range qsk {
+		bounds = append(bounds, e.tree.nearest(sk, math.Inf(1)))
+	}
+	for _, sk := range e.sketches {
+		bounds = append(bounds, qtree.nearest(sk, math.Inf(1)))
+	}
+
+	sort.Float64s(bounds)
+	k := int(mpdistThresh * float64(len(query)+len(e.ts)))
+	// guard against floating point error making the bound slightly too tight
+	if k < len(bounds) {
+		return bounds[k] * (1 - 1e-9)
+	}
+	return bounds[len(bounds)-1] * (1 - 1e-9)
+}
+
+// sketches computes the piecewise aggregate approximation of every z-normalized
+// subsequence of ts. Each segment mean is scaled by the square root of the segment
+// length so the euclidean distance between two sketches never exceeds the
+// euclidean distance between the z-normalized subsequences.
+func (idx Index) sketches(ts []float64) ([][]float64, error) {
+	mean, std, err := util.MovMeanStd(ts, idx.W)
+	if err != nil {
+		return nil, err
+	}
+
+	csum := make([]float64, len(ts)+1)
+	for i, v := range ts {
+		csum[i+1] = csum[i] + v
+	}
+
+	bounds := make([]int, idx.Segments+1)
+	for s := 0; s <= idx.Segments; s++ {
+		bounds[s] = s * idx.W / idx.Segments
+	}
+
+	out := make([][]float64, len(mean))
+	for i := range mean {
+		out[i] = make([]float64, idx.Segments)
+		if std[i] == 0 || math.IsNaN(std[i]) {
+			continue
+		}
+		for s := 0; s < idx.Segments; s++ {
+			l := float64(bounds[s+1] - bounds[s])
+			segMean := (csum[i+bounds[s+1]] - csum[i+bounds[s]]) / l
+			out[i][s] = math.Sqrt(l) * (segMean - mean[i]) / std[i]
+		}
+	}
+	return out, nil
+}
+
+// buildTree builds a kd-tree over a set of sketches by recursively splitting on
+// the median of the dimension with the widest spread.
+func (idx Index) buildTree(points [][]float64) *kdNode {
+	if len(points) == 0 {
+		return nil
+	}
+	n := &kdNode{
+		lo: make([]float64, idx.Segments),
+		hi: make([]float64, idx.Segments),
+	}
+	copy(n.lo, points[0])
+	copy(n.hi, points[0])
+	for _, p := range points[1:] {
+		for s, v := range p {
+			n.lo[s] = math.Min(n.lo[s], v)
+			n.hi[s] = math.Max(n.hi[s], v)
+		}
+	}
+
+	if len(points) <= idx.LeafSize {
+		n.points = points
+		return n
+	}
+
+	dim := 0
+	for s := range n.lo {
+		if n.hi[s]-n.lo[s] > n.hi[dim]-n.lo[dim] {
+			dim = s
+		}
+	}
+	if n.hi[dim] == n.lo[dim] {
+		// all sketches are identical so there is nothing to split on
+		n.points = points
+		return n
+	}
+
+	sorted := make([][]float64, len(points))
+	copy(sorted, points)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i][dim] < sorted[j][dim]
+	})
+	mid := len(sorted) / 2
+	n.left = idx.buildTree(sorted[:mid])
+	n.right = idx.buildTree(sorted[mid:])
+	return n
+}
+
+// nearest returns the euclidean distance from p to the closest sketch in the
+// tree, or best if no sketch is closer than best.
+func (n *kdNode) nearest(p []float64, best float64) float64 {
+	if n == nil || pointBoxDist(p, n.lo, n.hi) >= best {
+		return best
+	}
+	if n.points != nil {
+		for _, q := range n.points {
+			var sum float64
+			for s, v := range p {
+				sum += (v - q[s]) * (v - q[s])
+			}
+			if d := math.Sqrt(sum); d < best {
+				best = d
+			}
+		}
+		return best
+	}
+
+	first, second := n.left, n.right
+	if pointBoxDist(p, second.lo, second.hi) < pointBoxDist(p, first.lo, first.hi) {
+		first, second = second, first
+	}
+	best = first.nearest(p, best)
+	return second.nearest(p, best)
+}
+
+// pointBoxDist is the minimum euclidean distance between a point and any point
+// inside a box.
+func pointBoxDist(p, lo, hi []float64) float64 {
+	var sum, d float64
+	for s, v := range p {
+		switch {
+		case v < lo[s]:
+			d = lo[s] - v
+		case v > hi[s]:
+			d = v - hi[s]
+		default:
+			continue
+		}
+		sum += d * d
+	}
+	return math.Sqrt(sum)
+}
+
+// neighborHeap is a max heap of neighbors on distance so the worst of the
+// current best k sits at the root.
+type neighborHeap []Neighbor
+
+func (h neighborHeap) Len() int           { return len(h) }
+func (h neighborHeap) Less(i, j int) bool { return h[i].Dist > h[j].Dist }
+func (h neighborHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+
+// Push implements the function in the heap interface
+func (h *neighborHeap) Push(x interface{}) {
+	*h = append(*h, x.(Neighbor))
+}
+
+// Pop implements the function in the heap interface
+func (h *neighborHeap) Pop() interface{} {
+	x := (*h)[len(*h)-1]
+	*h = (*h)[:len(*h)-1]
+	return x
+}
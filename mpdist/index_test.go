@@ -0,0 +1,154 @@
+package mpdist
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"testing"
+
+	mp "github.com/matrix-profile-foundation/go-matrixprofile"
+	"github.com/matrix-profile-foundation/go-matrixprofile/siggen"
+)
+
+func TestNewIndex(t *testing.T) {
+	testdata := []struct {
+		w           int
+		expectedErr bool
+	}{
+		{1, true},
+		{2, false},
+		{32, false},
+	}
+
+	for _, d := range testdata {
+		idx, err := NewIndex(d.w)
+		if d.expectedErr && err == nil {
+			t.Errorf("Expected an error, but got none for %v", d)
+		}
+		if !d.expectedErr && err != nil {
+			t.Errorf("Expected no error, but got %v for %v", err, d)
+		}
+		if err == nil && idx.Segments > d.w {
+			t.Errorf("Expected at most %d segments, but got %d", d.w, idx.Segments)
+		}
+	}
+}
+
+func setupCollection() [][]float64 {
+	rand.Seed(4)
+	var out [][]float64
+	for i := 0; i < 12; i++ {
+		freq := 1 + float64(i%4)
+		sig := siggen.Add(siggen.Sin(1, freq, 0, 0, 100, 2), siggen.Noise(0.2*float64(1+i/4), 200))
+		if i%3 == 0 {
+			sig = siggen.Add(sig, siggen.Sawtooth(0.5, 3, 0, 0, 100, 2))
+		}
+		out = append(out, sig)
+	}
+	return out
+}
+
+func TestNearestK(t *testing.T) {
+	w := 16
+	collection := setupCollection()
+
+	idx, err := NewIndex(w)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, ts := range collection {
+		id, err := idx.Add(ts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if id != i {
+			t.Errorf("Expected id %d, but got %d", i, id)
+		}
+	}
+	if idx.Len() != len(collection) {
+		t.Errorf("Expected %d series, but got %d", len(collection), idx.Len())
+	}
+
+	query := siggen.Add(siggen.Sin(1, 2, 0.3, 0, 100, 1), siggen.Noise(0.1, 100))
+
+	expected := make([]Neighbor, len(collection))
+	for i, ts := range collection {
+		d, err := mp.MPDist(query, ts, w, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected[i] = Neighbor{ID: i, Dist: d}
+	}
+	sort.SliceStable(expected, func(i, j int) bool {
+		return expected[i].Dist < expected[j].Dist
+	})
+
+	for _, k := range []int{1, 3, 5, 20} {
+		out, err := idx.NearestK(query, k)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if k > len(collection) {
+			k = len(collection)
+		}
+		if len(out) != k {
+			t.Errorf("Expected %d neighbors, but got %d", k, len(out))
+			continue
+		}
+		for i := range out {
+			if math.Abs(out[i].Dist-expected[i].Dist) > 1e-7 {
+				t.Errorf("Expected %v, but got %v for k=%d", expected[:k], out, k)
+				break
+			}
+		}
+		if idx.exact > len(collection) {
+			t.Errorf("Expected at most %d exact computations, but got %d", len(collection), idx.exact)
+		}
+		if k == 1 && idx.exact == len(collection) {
+			t.Errorf("Expected the lower bound to prune some exact computations")
+		}
+	}
+
+	if _, err = idx.NearestK(query, 0); err == nil {
+		t.Errorf("Expected an error for k of 0")
+	}
+	if _, err = idx.NearestK(query[:w-1], 1); err == nil {
+		t.Errorf("Expected an error for a short query")
+	}
+	if _, err = idx.Add(query[:w-1]); err == nil {
+		t.Errorf("Expected an error for a short series")
+	}
+}
+
+func TestLowerBound(t *testing.T) {
+	w := 16
+	collection := setupCollection()
+	idx, err := NewIndex(w)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, ts := range collection {
+		if _, err = idx.Add(ts); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for q := range collection {
+		query := collection[q][:120]
+		qsk, err := idx.sketches(query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		qtree := idx.buildTree(qsk)
+		for i, e := range idx.series {
+			lb := idx.lowerBound(query, qsk, qtree, e)
+			d, err := mp.MPDist(query, e.ts, w, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if lb > d+1e-9 {
+				t.Errorf("Expected lower bound %.6f to be at most the MPdist %.6f for query %d and series %d", lb, d, q, i)
+			}
+		}
+	}
+}
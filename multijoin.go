@@ -0,0 +1,104 @@
+package matrixprofile
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/matrix-profile-foundation/go-matrixprofile/util"
+)
+
+// MultiJoin is the result of joining a single timeseries, a, against a set of
+// timeseries. Each entry of the combined profile tracks the closest match for a
+// subsequence of a across every b along with the b it came from.
+type MultiJoin struct {
+	Profiles []*MatrixProfile // AB join of a against each b in the order given
+	MP       []float64        // best matrix profile value for each subsequence of a across all b
+	Idx      []int            // index of the nearest neighbor within the source b
+	Src      []int            // index of the b timeseries containing the nearest neighbor
+}
+
+// JoinMany computes the AB join of a against each timeseries in bs with a
+// subsequence length of w and combines them into a single profile of the best match
+// across all of bs. The sliding statistics of a are computed once and shared across
+// every join. Only the MPX algorithm is supported since it indexes the AB join profile
+// by the subsequences of a. When the options mask flat subsequences, the mask is set on
+// each profile and masked subsequences of a are left without a match in the combined
+// profile.
+func JoinMany(a []float64, bs [][]float64, w int, o *MPOpts) (*MultiJoin, error) {
+	if o == nil {
+		o = NewMPOpts()
+	}
+	if err := o.Validate(); err != nil {
+		return nil, err
+	}
+	if o.Algorithm != AlgoMPX {
+		return nil, fmt.Errorf("JoinMany only supports the %s algorithm, got %s", AlgoMPX, o.Algorithm)
+	}
+	if len(bs) == 0 {
+		return nil, fmt.Errorf("at least one timeseries is required to join against")
+	}
+	if o.recomputesFlat() {
+		return nil, fmt.Errorf("JoinMany doesn't support flat mode %s", o.Flat)
+	}
+	if o.Period > 0 {
+		return nil, fmt.Errorf("JoinMany doesn't support periodic exclusion zones")
+	}
+
+	var mask []bool
+	var sa *mpxStats
+	mj := &MultiJoin{Profiles: make([]*MatrixProfile, len(bs))}
+	for i, b := range bs {
+		mp, err := New(a, b, w)
+		if err != nil {
+			return nil, fmt.Errorf("timeseries %d: %v", i, err)
+		}
+		mp.Opts = o
+
+		if sa == nil {
+			sa = newMPXStats(a, w)
+			if o.FlatThreshold > 0 || o.Flat == FlatSkip {
+				if mask, err = util.FlatMask(a, w, o.flatThreshold()); err != nil {
+					return nil, err
+				}
+			}
+
+			mj.MP = make([]float64, len(a)-w+1)
+			mj.Idx = make([]int, len(mj.MP))
+			mj.Src = make([]int, len(mj.MP))
+			for j := range mj.MP {
+				mj.MP[j] = math.Inf(1)
+				if !o.Euclidean {
+					mj.MP[j] = math.Inf(-1)
+				}
+				mj.Idx[j] = math.MaxInt64
+				mj.Src[j] = -1
+			}
+		}
+
+		if err = mp.mpxWithStats(sa, newMPXStats(b, w)); err != nil {
+			return nil, err
+		}
+		mp.Mask = mask
+		mj.Profiles[i] = mp
+
+		for j, d := range mp.MP {
+			if isBetter(d, mj.MP[j], o.Euclidean) {
+				mj.MP[j] = d
+				mj.Idx[j] = mp.Idx[j]
+				mj.Src[j] = i
+			}
+		}
+	}
+
+	for j, masked := range mask {
+		if masked {
+			mj.MP[j] = math.Inf(1)
+			if !o.Euclidean {
+				mj.MP[j] = math.Inf(-1)
+			}
+			mj.Idx[j] = math.MaxInt64
+			mj.Src[j] = -1
+		}
+	}
+	return mj, nil
+}
@@ -0,0 +1,115 @@
+package matrixprofile
+
+import (
+	"math"
+	"testing"
+
+	"github.com/matrix-profile-foundation/go-matrixprofile/siggen"
+)
+
+func TestJoinMany(t *testing.T) {
+	w := 16
+	a := siggen.Add(siggen.Sin(1, 4, 0, 0, 100, 2), siggen.Noise(0.1, 200))
+	bs := [][]float64{
+		siggen.Noise(1, 150),
+		siggen.Add(siggen.Sin(1, 4, 0, 0, 100, 1), siggen.Noise(0.2, 100)),
+		siggen.Add(siggen.Sawtooth(1, 4, 0, 0, 100, 1.5), siggen.Noise(0.1, 150)),
+	}
+
+	testdata := []struct {
+		bs          [][]float64
+		algo        Algo
+		expectedErr bool
+	}{
+		{bs, AlgoMPX, false},
+		{bs[:1], AlgoMPX, false},
+		{nil, AlgoMPX, true},
+		{bs, AlgoSTOMP, true},
+		{[][]float64{{1, 2, 3}}, AlgoMPX, true},
+	}
+
+	for _, d := range testdata {
+		o := NewMPOpts()
+		o.Algorithm = d.algo
+		mj, err := JoinMany(a, d.bs, w, o)
+		if d.expectedErr {
+			if err == nil {
+				t.Errorf("Expected an error for %d timeseries with %s", len(d.bs), d.algo)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("Did not expect an error, %v", err)
+		}
+		if len(mj.Profiles) != len(d.bs) || len(mj.MP) != len(a)-w+1 {
+			t.Fatalf("Expected %d profiles of length %d, but got %d of length %d", len(d.bs), len(a)-w+1, len(mj.Profiles), len(mj.MP))
+		}
+
+		for i, b := range d.bs {
+			mp, err := New(a, b, w)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err = mp.Compute(o); err != nil {
+				t.Fatal(err)
+			}
+			for j := range mp.MP {
+				if math.Abs(mp.MP[j]-mj.Profiles[i].MP[j]) > 1e-7 {
+					t.Errorf("Expected %.6f at %d for timeseries %d, but got %.6f", mp.MP[j], j, i, mj.Profiles[i].MP[j])
+					break
+				}
+			}
+		}
+
+		for j := range mj.MP {
+			src := mj.Profiles[mj.Src[j]]
+			if mj.MP[j] != src.MP[j] || mj.Idx[j] != src.Idx[j] {
+				t.Errorf("Expected the combined profile to match source %d at %d", mj.Src[j], j)
+				break
+			}
+			for _, p := range mj.Profiles {
+				if p.MP[j] < mj.MP[j] {
+					t.Errorf("Expected the best value at %d, but %.6f is lower than %.6f", j, p.MP[j], mj.MP[j])
+					break
+				}
+			}
+		}
+	}
+}
+
+func TestJoinManyOpts(t *testing.T) {
+	w := 16
+	a := siggen.Add(siggen.Sin(1, 4, 0, 0, 100, 2), seededNoise(1, 0.1, 200))
+	for i := 80; i < 120; i++ {
+		a[i] = 1
+	}
+	bs := [][]float64{seededNoise(2, 1, 150), seededNoise(3, 1, 100)}
+
+	o := NewMPOpts()
+	o.STAMP = &STAMPOpts{SamplePct: 0.5}
+	if _, err := JoinMany(a, bs, w, o); err == nil {
+		t.Errorf("Expected an error when sampling")
+	}
+
+	o = NewMPOpts()
+	o.FlatThreshold = 0.05
+	mj, err := JoinMany(a, bs, w, o)
+	if err != nil {
+		t.Fatalf("Did not expect an error, %v", err)
+	}
+	for j := range mj.MP {
+		flat := mj.Profiles[0].Mask[j]
+		if !flat && j >= 80 && j+w <= 120 {
+			t.Errorf("Expected subsequence %d in the flat region to be masked", j)
+			break
+		}
+		if flat && (mj.Src[j] != -1 || mj.Idx[j] != math.MaxInt64 || !math.IsInf(mj.MP[j], 1)) {
+			t.Errorf("Expected no combined match for the masked subsequence %d, but got %.4f from %d", j, mj.MP[j], mj.Src[j])
+			break
+		}
+		if !flat && mj.Src[j] == -1 {
+			t.Errorf("Expected a combined match for subsequence %d", j)
+			break
+		}
+	}
+}
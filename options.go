@@ -0,0 +1,100 @@
+package matrixprofile
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Normalization describes how subsequences are normalized before being compared.
+type Normalization string
+
+const (
+	NormZ    Normalization = "z"    // subsequences are z-normalized to zero mean and unit variance
+	NormMean Normalization = "mean" // subsequences are mean centered but keep their amplitude, so matches must agree in scale but not in level. Only supported by the non-normalized algorithms AAMP and ACAMP
+)
+
+// Option configures a matrix profile created with NewWithOpts.
+type Option func(*MatrixProfile) error
+
+// WithWindow sets the subsequence length of the matrix profile.
+func WithWindow(w int) Option {
+	return func(mp *MatrixProfile) error {
+		mp.W = w
+		return nil
+	}
+}
+
+// WithBSeries sets the timeseries b to join a against. Without it a self join on a
+// is performed.
+func WithBSeries(b []float64) Option {
+	return func(mp *MatrixProfile) error {
+		if len(b) == 0 {
+			return errors.New("second slice must have a length greater than 0")
+		}
+		mp.B = b
+		return nil
+	}
+}
+
+// WithExclusionZone sets the trivial match exclusion zone used by self joins.
+func WithExclusionZone(e int) Option {
+	return func(mp *MatrixProfile) error {
+		if e < 1 {
+			return fmt.Errorf("exclusion zone, %d, must be at least 1", e)
+		}
+		mp.ExclusionZone = e
+		return nil
+	}
+}
+
+// WithTimeIndex sets the timestamp of each point in a. It must have the same length
+// as a.
+func WithTimeIndex(t []time.Time) Option {
+	return func(mp *MatrixProfile) error {
+		if len(t) != len(mp.A) {
+			return fmt.Errorf("time index length, %d, does not match the timeseries length, %d", len(t), len(mp.A))
+		}
+		mp.TimeIndex = t
+		return nil
+	}
+}
+
+// WithNormalization sets how subsequences are normalized before being compared.
+func WithNormalization(mode Normalization) Option {
+	return func(mp *MatrixProfile) error {
+		switch mode {
+		case NormZ, NormMean:
+		default:
+			return fmt.Errorf("unsupported normalization, %s", mode)
+		}
+		mp.Normalization = mode
+		return nil
+	}
+}
+
+// NewWithOpts creates a matrix profile struct for the timeseries a configured by a set
+// of options. WithWindow is required. This is equivalent to New, but leaves room for
+// settings that don't fit positional arguments.
+func NewWithOpts(a []float64, opts ...Option) (*MatrixProfile, error) {
+	cfg := MatrixProfile{A: a}
+	for _, opt := range opts {
+		if err := opt(&cfg); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.W == 0 {
+		return nil, errors.New("subsequence length must be set with WithWindow")
+	}
+
+	mp, err := New(a, cfg.B, cfg.W)
+	if err != nil {
+		return nil, err
+	}
+	mp.ExclusionZone = cfg.ExclusionZone
+	mp.TimeIndex = cfg.TimeIndex
+	if cfg.Normalization != "" {
+		mp.Normalization = cfg.Normalization
+	}
+	return mp, nil
+}
@@ -0,0 +1,72 @@
+package matrixprofile
+
+import (
+	"testing"
+	"time"
+
+	"github.com/matrix-profile-foundation/go-matrixprofile/siggen"
+)
+
+func TestNewWithOpts(t *testing.T) {
+	a := []float64{0, 0.99, 1, 0, 0, 0.98, 1, 0, 0, 0.96, 1, 0}
+	b := []float64{0, 1, 1, 0}
+	times := make([]time.Time, len(a))
+
+	testdata := []struct {
+		opts        []Option
+		selfJoin    bool
+		expectedErr bool
+	}{
+		{nil, false, true},
+		{[]Option{WithWindow(4)}, true, false},
+		{[]Option{WithWindow(20)}, false, true},
+		{[]Option{WithWindow(4), WithBSeries(b)}, false, false},
+		{[]Option{WithWindow(4), WithBSeries([]float64{})}, false, true},
+		{[]Option{WithWindow(4), WithExclusionZone(3)}, true, false},
+		{[]Option{WithWindow(4), WithExclusionZone(0)}, false, true},
+		{[]Option{WithWindow(4), WithTimeIndex(times)}, true, false},
+		{[]Option{WithWindow(4), WithTimeIndex(times[:3])}, false, true},
+		{[]Option{WithWindow(4), WithNormalization(NormZ)}, true, false},
+		{[]Option{WithWindow(4), WithNormalization("rank")}, false, true},
+	}
+
+	for i, d := range testdata {
+		mp, err := NewWithOpts(a, d.opts...)
+		if d.expectedErr {
+			if err == nil {
+				t.Errorf("Expected an error for case %d", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Did not expect an error, %v, for case %d", err, i)
+			continue
+		}
+		if mp.SelfJoin != d.selfJoin || mp.W != 4 || mp.Normalization != NormZ {
+			t.Errorf("Expected self join %t with window 4 and z normalization, but got %t, %d and %s for case %d", d.selfJoin, mp.SelfJoin, mp.W, mp.Normalization, i)
+		}
+	}
+}
+
+func TestExclusionZoneOption(t *testing.T) {
+	sig := siggen.Add(siggen.Sin(1, 4, 0, 0, 100, 2), siggen.Noise(0.1, 200))
+	exclZone := 30
+
+	for _, algo := range []Algo{AlgoSTMP, AlgoSTAMP, AlgoSTOMP, AlgoMPX} {
+		mp, err := NewWithOpts(sig, WithWindow(20), WithExclusionZone(exclZone))
+		if err != nil {
+			t.Fatal(err)
+		}
+		o := NewMPOpts()
+		o.Algorithm = algo
+		if err = mp.Compute(o); err != nil {
+			t.Fatal(err)
+		}
+		for i, idx := range mp.Idx {
+			if idx-i < exclZone && i-idx < exclZone {
+				t.Errorf("Expected the neighbor of %d to be outside the exclusion zone, but got %d for %s", i, idx, algo)
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package matrixprofile
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/matrix-profile-foundation/go-matrixprofile/util"
+)
+
+// RowRange is the range of subsequences of a from Start up to End, the rows of the
+// distance matrix computed by one shard of a distributed matrix profile.
+type RowRange struct {
+	Start int `json:"start"`
+	End   int `json:"end"`
+}
+
+// RowRanges splits the rows of the distance matrix into ranges for the given number of
+// shards, in the manner of STUMPED. A self join only computes the distance matrix on
+// and above the diagonal, so later rows are cheaper and their ranges are made longer to
+// even out the work of each shard. Fewer ranges than shards are returned if there
+// aren't enough rows. The options must be the ones the shards compute with, as a
+// transform can change the number of rows.
+func (mp *MatrixProfile) RowRanges(o *MPOpts, shards int) ([]RowRange, error) {
+	if shards < 1 {
+		return nil, fmt.Errorf("number of shards, %d, must be at least 1", shards)
+	}
+	if err := mp.preparePartial(o); err != nil {
+		return nil, err
+	}
+
+	n := len(mp.A) - mp.W + 1
+	var ranges []RowRange
+	if mp.SelfJoin {
+		for _, b := range util.DiagBatchingScheme(n, shards) {
+			end := b.Idx + b.Size
+			if end > n {
+				end = n
+			}
+			if b.Idx < end {
+				ranges = append(ranges, RowRange{b.Idx, end})
+			}
+		}
+		return ranges, nil
+	}
+
+	size := (n + shards - 1) / shards
+	for start := 0; start < n; start += size {
+		end := start + size
+		if end > n {
+			end = n
+		}
+		ranges = append(ranges, RowRange{start, end})
+	}
+	return ranges, nil
+}
+
+// ComputePartial computes the partial matrix profile of a range of rows of the
+// distance matrix so an O(n^2) join can be sharded across machines. Each shard creates
+// the same matrix profile, computes its rows from RowRanges, and sends the result to
+// be combined with MergePartials. The rows are computed as a single tile of SCAMP, so
+// only z-normalized euclidean distances are supported and the algorithm of the
+// options doesn't matter.
+func (mp *MatrixProfile) ComputePartial(o *MPOpts, rows RowRange) (*TileResult, error) {
+	if err := mp.preparePartial(o); err != nil {
+		return nil, err
+	}
+
+	t := Tile{Row: rows.Start, Rows: rows.End - rows.Start}
+	if mp.SelfJoin {
+		// the rows are only compared to the columns on and above the diagonal
+		t.Col = rows.Start
+	}
+	t.Cols = len(mp.B) - mp.W + 1 - t.Col
+	return mp.ComputeTile(t)
+}
+
+// MergePartials computes the matrix profile from the partial matrix profiles of every
+// range of rows, which can be merged in any order. The options must be the ones the
+// partial matrix profiles were computed with.
+func (mp *MatrixProfile) MergePartials(o *MPOpts, partials []*TileResult) error {
+	if err := mp.preparePartial(o); err != nil {
+		return err
+	}
+
+	// the matrix profile is reset so partials from an earlier computation don't linger
+	mp.MP, mp.Idx = nil, nil
+	for _, p := range partials {
+		if err := mp.MergeTile(p); err != nil {
+			return err
+		}
+	}
+	if len(partials) == 0 {
+		init := newBatchResult(len(mp.B) - mp.W + 1)
+		mp.MP, mp.Idx = init.MP, init.Idx
+	}
+	mp.applyMaxDistance()
+
+	return mp.maskFlat(mp.Opts)
+}
+
+// preparePartial validates the options of a distributed matrix profile and transforms
+// the timeseries like Compute does.
+func (mp *MatrixProfile) preparePartial(o *MPOpts) error {
+	if o == nil {
+		o = NewMPOpts()
+	}
+	mp.Opts = o
+	mp.mpxStream = nil
+	mp.stampi = nil
+
+	if err := o.Validate(); err != nil {
+		return err
+	}
+	if err := mp.validateNormalization(o); err != nil {
+		return err
+	}
+	if mp.nonNormalized() || o.Manhattan {
+		return errors.New("partial matrix profiles only support z-normalized euclidean distances")
+	}
+	if o.samplePct() < 1 {
+		return errors.New("partial matrix profiles don't support sampling")
+	}
+	if o.recomputesFlat() {
+		return fmt.Errorf("partial matrix profiles don't support flat mode %s", o.Flat)
+	}
+	if o.Period > 0 {
+		return errors.New("partial matrix profiles don't support periodic exclusion zones")
+	}
+	return mp.applyTransform(o)
+}
@@ -0,0 +1,101 @@
+package matrixprofile
+
+import (
+	"math"
+	"testing"
+
+	"github.com/matrix-profile-foundation/go-matrixprofile/siggen"
+)
+
+func TestMergePartials(t *testing.T) {
+	sig := siggen.Add(siggen.Sin(1, 4, 0, 0, 100, 4), siggen.Noise(0.1, 400))
+	b := siggen.Add(siggen.Sin(1, 4, 0, 0, 100, 2), siggen.Noise(0.2, 200))
+
+	testdata := []struct {
+		b        []float64
+		shards   int
+		circular bool
+		squared  bool
+	}{
+		{nil, 1, false, false},
+		{nil, 4, false, false},
+		{nil, 7, true, false},
+		{nil, 500, false, true},
+		{b, 3, false, false},
+		{b, 1000, false, false},
+	}
+
+	for _, d := range testdata {
+		o := NewMPOpts()
+		o.Algorithm = AlgoSTOMP
+		o.Circular = d.circular
+		o.Squared = d.squared
+		exact, err := New(sig, d.b, 20)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = exact.Compute(o); err != nil {
+			t.Fatal(err)
+		}
+
+		// each shard works on its own copy of the matrix profile
+		coordinator, err := New(sig, d.b, 20)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ranges, err := coordinator.RowRanges(o, d.shards)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var partials []*TileResult
+		next := 0
+		for _, r := range ranges {
+			if r.Start != next || r.End <= r.Start {
+				t.Fatalf("Expected contiguous row ranges, but got %+v after %d for %+v", r, next, d)
+			}
+			next = r.End
+
+			shard, err := New(sig, d.b, 20)
+			if err != nil {
+				t.Fatal(err)
+			}
+			p, err := shard.ComputePartial(o, r)
+			if err != nil {
+				t.Fatal(err)
+			}
+			partials = append(partials, p)
+		}
+		if next != len(exact.A)-exact.W+1 {
+			t.Fatalf("Expected the row ranges to end at %d, but got %d for %+v", len(exact.A)-exact.W+1, next, d)
+		}
+
+		if err = coordinator.MergePartials(o, partials); err != nil {
+			t.Fatal(err)
+		}
+		if len(coordinator.MP) != len(exact.MP) {
+			t.Fatalf("Expected %d elements, but got %d for %+v", len(exact.MP), len(coordinator.MP), d)
+		}
+		for i := range exact.MP {
+			if math.Abs(coordinator.MP[i]-exact.MP[i]) > 1e-6 || coordinator.Idx[i] != exact.Idx[i] {
+				t.Errorf("Expected (%.6f, %d) at %d, but got (%.6f, %d) for %+v", exact.MP[i], exact.Idx[i], i, coordinator.MP[i], coordinator.Idx[i], d)
+				break
+			}
+		}
+	}
+
+	mp, err := New(sig, nil, 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = mp.RowRanges(nil, 0); err == nil {
+		t.Errorf("Expected an error for no shards")
+	}
+	o := NewMPOpts()
+	o.Algorithm = AlgoAAMP
+	if _, err = mp.ComputePartial(o, RowRange{0, 10}); err == nil {
+		t.Errorf("Expected an error for non-normalized distances")
+	}
+	if _, err = mp.ComputePartial(nil, RowRange{10, 10}); err == nil {
+		t.Errorf("Expected an error for an empty row range")
+	}
+}
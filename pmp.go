@@ -7,6 +7,8 @@ import (
 	"io/ioutil"
 	"math"
 	"os"
+	"sort"
+	"sync"
 
 	"github.com/matrix-profile-foundation/go-matrixprofile/util"
 )
@@ -90,9 +92,10 @@ func (p *PMP) Load(filepath, format string) error {
 
 // PMPOpts are parameters to vary the algorithm to compute the pan matrix profile.
 type PMPOpts struct {
-	LowerM int     `json:"lower_m"` // used for pan matrix profile
-	UpperM int     `json:"upper_m"` // used for pan matrix profile
-	MPOpts *MPOpts `json:"mp_options"`
+	LowerM    int     `json:"lower_m"`    // used for pan matrix profile
+	UpperM    int     `json:"upper_m"`    // used for pan matrix profile
+	SamplePct float64 `json:"sample_pct"` // fraction of the subsequence lengths computed before stopping early
+	MPOpts    *MPOpts `json:"mp_options"`
 }
 
 // NewPMPOpts returns a default PMPOpts
@@ -101,9 +104,10 @@ func NewPMPOpts(l, u int) *PMPOpts {
 		u = l
 	}
 	return &PMPOpts{
-		LowerM: l,
-		UpperM: u,
-		MPOpts: NewMPOpts(),
+		LowerM:    l,
+		UpperM:    u,
+		SamplePct: 1.0,
+		MPOpts:    NewMPOpts(),
 	}
 }
 
@@ -112,21 +116,37 @@ func (p *PMP) Compute(o *PMPOpts) error {
 	if o == nil {
 		return errors.New("Must provide PMP compute options")
 	}
+	if o.LowerM < 2 {
+		return fmt.Errorf("lower subsequence length, %d, must be at least 2", o.LowerM)
+	}
+	if o.UpperM > len(p.A) || o.UpperM > len(p.B) {
+		return fmt.Errorf("upper subsequence length, %d, must be at most the length of the timeseries", o.UpperM)
+	}
+	if o.SamplePct <= 0 || o.SamplePct > 1 {
+		return fmt.Errorf("must provide a sampling greater than 0 and at most 1, sample: %.3f", o.SamplePct)
+	}
 	p.Opts = o
 	return p.pmp()
 }
 
+// pmp computes the matrix profile at each subsequence length from LowerM to UpperM in
+// the breadth first order of SKIMP, starting with the lower bound followed by the
+// midpoints of successive binary splits of the range. A sample percentage below 1 stops
+// early with lengths spread evenly over the range, leaving the rows of the remaining
+// lengths at +Inf.
 func (p *PMP) pmp() error {
 	windows := util.BinarySplit(p.Opts.LowerM, p.Opts.UpperM)
-	windows = windows[:int(float64(len(windows))*p.Opts.MPOpts.SamplePct)]
+	windows = windows[:int(float64(len(windows))*p.Opts.SamplePct)]
 	if len(windows) < 1 {
 		return errors.New("Need more than one subsequence window for pmp")
 	}
 	p.PWindows = windows
 
-	p.PMP = make([][]float64, len(windows))
-	p.PIdx = make([][]int, len(windows))
-	for i := 0; i < len(windows); i++ {
+	// rows are indexed by subsequence length rather than by the order they are computed
+	rows := p.Opts.UpperM - p.Opts.LowerM + 1
+	p.PMP = make([][]float64, rows)
+	p.PIdx = make([][]int, rows)
+	for i := 0; i < rows; i++ {
 		lenA := len(p.A) - (i + p.Opts.LowerM) + 1
 		p.PMP[i] = make([]float64, lenA)
 		p.PIdx[i] = make([]int, lenA)
@@ -160,6 +180,23 @@ func (p *PMP) pmp() error {
 	return nil
 }
 
+// Profile returns the matrix profile and matrix profile index at subsequence length w
+// from the pan matrix profile.
+func (p PMP) Profile(w int) ([]float64, []int, error) {
+	if p.Opts == nil || p.PMP == nil {
+		return nil, nil, errors.New("pan matrix profile has not been computed")
+	}
+	if w < p.Opts.LowerM || w > p.Opts.UpperM {
+		return nil, nil, fmt.Errorf("subsequence length, %d, is outside of the computed range of %d to %d", w, p.Opts.LowerM, p.Opts.UpperM)
+	}
+	for _, pw := range p.PWindows {
+		if pw == w {
+			return p.PMP[w-p.Opts.LowerM], p.PIdx[w-p.Opts.LowerM], nil
+		}
+	}
+	return nil, nil, fmt.Errorf("subsequence length, %d, was not sampled", w)
+}
+
 // Analyze has not been implemented yet
 func (p PMP) Analyze(co *MPOpts, ao *AnalyzeOpts) error {
 	return errors.New("Analyze for PMP has not been implemented yet.")
@@ -184,3 +221,264 @@ func (p PMP) DiscoverSegments() (int, float64, []float64) {
 func (p PMP) Visualize(fn string, motifs []MotifGroup, discords []int, cac []float64) error {
 	return errors.New("Visualize for PMP has not been implemented yet.")
 }
+
+// StreamPMP maintains the matrix profiles of a streaming self join at several
+// subsequence lengths concurrently, so anomalies are found at scales other than a
+// single guessed subsequence length.
+type StreamPMP struct {
+	Windows  []int            `json:"windows"`  // subsequence lengths in ascending order
+	Profiles []*MatrixProfile `json:"profiles"` // matrix profile of each subsequence length, aligned with Windows
+}
+
+// PanDiscord is a discord found at one of the subsequence lengths of a pan matrix
+// profile.
+type PanDiscord struct {
+	Idx  int     // absolute start index in the stream, including any retired points
+	W    int     // subsequence length the discord was found at
+	Dist float64 // nearest neighbor distance divided by 2*sqrt(W), the largest z-normalized distance, so lengths are comparable
+}
+
+// NewStreamPMP computes the self join matrix profile of a at each of the subsequence
+// lengths in windows. Each profile keeps its own copy of the timeseries so it can be
+// extended and retired independently. A maxLen greater than 0 caps the length of the
+// timeseries kept by every profile as in StreamOpts. The MPX algorithm extends each
+// profile incrementally on Update.
+func NewStreamPMP(a []float64, windows []int, o *MPOpts, maxLen int) (*StreamPMP, error) {
+	if len(windows) == 0 {
+		return nil, errors.New("must provide at least one subsequence length")
+	}
+	if o == nil {
+		o = NewMPOpts()
+	}
+
+	ws := make([]int, len(windows))
+	copy(ws, windows)
+	sort.Ints(ws)
+	for i := 1; i < len(ws); i++ {
+		if ws[i] == ws[i-1] {
+			return nil, fmt.Errorf("subsequence length, %d, is repeated", ws[i])
+		}
+	}
+
+	s := &StreamPMP{
+		Windows:  ws,
+		Profiles: make([]*MatrixProfile, len(ws)),
+	}
+	for i, w := range ws {
+		ts := make([]float64, len(a))
+		copy(ts, a)
+		mp, err := New(ts, nil, w)
+		if err != nil {
+			return nil, err
+		}
+		if maxLen > 0 {
+			mp.Stream = &StreamOpts{MaxLen: maxLen}
+		}
+		if err = mp.Compute(o); err != nil {
+			return nil, err
+		}
+		s.Profiles[i] = mp
+	}
+	return s, nil
+}
+
+// Update appends new values to the timeseries of every subsequence length, updating
+// the profiles concurrently.
+func (s *StreamPMP) Update(newValues []float64) error {
+	errs := make([]error, len(s.Profiles))
+
+	var wg sync.WaitGroup
+	wg.Add(len(s.Profiles))
+	for i, mp := range s.Profiles {
+		go func(i int, mp *MatrixProfile) {
+			defer wg.Done()
+			errs[i] = mp.Update(newValues)
+		}(i, mp)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Discords finds the top k discords across all subsequence lengths. Distances are
+// normalized by the largest possible distance at each length before being ranked, and
+// a discord overlapping a higher ranked one at any length is skipped.
+func (s StreamPMP) Discords(k int) ([]PanDiscord, error) {
+	if k < 1 {
+		return nil, fmt.Errorf("number of discords, %d, must be at least 1", k)
+	}
+
+	var candidates []PanDiscord
+	for _, mp := range s.Profiles {
+		profile := mp.euclideanMP()
+		mp.applyMask(profile)
+		norm := 2 * math.Sqrt(float64(mp.W))
+
+		dists := make([]float64, len(profile))
+		copy(dists, profile)
+		for _, idx := range topDiscords(profile, k, mp.exclusionZone(mp.W/2)) {
+			candidates = append(candidates, PanDiscord{
+				Idx:  idx + mp.Offset,
+				W:    mp.W,
+				Dist: dists[idx] / norm,
+			})
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].Dist > candidates[j].Dist
+	})
+
+	var discords []PanDiscord
+	for _, c := range candidates {
+		if len(discords) == k {
+			break
+		}
+		overlaps := false
+		for _, d := range discords {
+			if c.Idx < d.Idx+d.W && d.Idx < c.Idx+c.W {
+				overlaps = true
+				break
+			}
+		}
+		if !overlaps {
+			discords = append(discords, c)
+		}
+	}
+	return discords, nil
+}
+
+// PanMotif is a motif pair found at one of the subsequence lengths of a pan matrix
+// profile.
+type PanMotif struct {
+	Idx  int     // absolute start index of the first subsequence of the pair, including any retired points
+	NN   int     // absolute start index of its nearest neighbor
+	W    int     // subsequence length the motif was found at
+	Dist float64 // nearest neighbor distance divided by 2*sqrt(W), the largest z-normalized distance, so lengths are comparable
+}
+
+// panProfile is the euclidean self join matrix profile at one subsequence length.
+type panProfile struct {
+	w      int
+	mp     []float64
+	idx    []int
+	offset int
+}
+
+// RankMotifs finds the top k motifs across self join matrix profiles computed at
+// different subsequence lengths. Distances grow with the subsequence length, so they
+// are normalized by the largest possible distance at each length before the motifs are
+// ranked together, and a motif overlapping a higher ranked one at any length is
+// skipped. Motifs are returned in ascending order of their normalized distance.
+func RankMotifs(profiles []*MatrixProfile, k int) ([]PanMotif, error) {
+	rows := make([]panProfile, len(profiles))
+	for i, mp := range profiles {
+		if mp == nil || mp.MP == nil || mp.Idx == nil {
+			return nil, fmt.Errorf("matrix profile %d has not been computed", i)
+		}
+		if !mp.SelfJoin {
+			return nil, fmt.Errorf("matrix profile %d is not a self join", i)
+		}
+		profile := mp.euclideanMP()
+		mp.applyMask(profile)
+		rows[i] = panProfile{
+			w:      mp.W,
+			mp:     profile,
+			idx:    mp.Idx,
+			offset: mp.Offset,
+		}
+	}
+	return rankMotifs(rows, k)
+}
+
+// Motifs finds the top k motifs across all subsequence lengths as in RankMotifs.
+func (s StreamPMP) Motifs(k int) ([]PanMotif, error) {
+	return RankMotifs(s.Profiles, k)
+}
+
+// Motifs finds the top k motifs across the computed subsequence lengths of a self
+// join pan matrix profile as in RankMotifs.
+func (p PMP) Motifs(k int) ([]PanMotif, error) {
+	if p.Opts == nil || p.PMP == nil {
+		return nil, errors.New("pan matrix profile has not been computed")
+	}
+	if !p.SelfJoin {
+		return nil, errors.New("can only rank motifs if a self join is performed")
+	}
+
+	o := p.Opts.MPOpts
+	rows := make([]panProfile, len(p.PWindows))
+	for i, w := range p.PWindows {
+		profile := make([]float64, len(p.PMP[w-p.Opts.LowerM]))
+		copy(profile, p.PMP[w-p.Opts.LowerM])
+		if o != nil && !o.Euclidean {
+			util.P2E(profile, w)
+		}
+		if o != nil && o.Squared {
+			for j, d := range profile {
+				profile[j] = math.Sqrt(d)
+			}
+		}
+		rows[i] = panProfile{w: w, mp: profile, idx: p.PIdx[w-p.Opts.LowerM]}
+	}
+	return rankMotifs(rows, k)
+}
+
+// rankMotifs ranks every nearest neighbor pair of the profiles by its normalized
+// distance and keeps the k best not overlapping a higher ranked motif. The best pairs
+// of every length tend to cover the same pattern, so no length is cut short before the
+// overlaps are known.
+func rankMotifs(rows []panProfile, k int) ([]PanMotif, error) {
+	if k < 1 {
+		return nil, fmt.Errorf("number of motifs, %d, must be at least 1", k)
+	}
+
+	var candidates []PanMotif
+	for _, r := range rows {
+		norm := 2 * math.Sqrt(float64(r.w))
+		for i, d := range r.mp {
+			nn := r.idx[i]
+			if math.IsInf(d, 0) || math.IsNaN(d) || nn < 0 || nn >= len(r.mp) {
+				continue
+			}
+			candidates = append(candidates, PanMotif{
+				Idx:  i + r.offset,
+				NN:   nn + r.offset,
+				W:    r.w,
+				Dist: d / norm,
+			})
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if candidates[i].Dist != candidates[j].Dist {
+			return candidates[i].Dist < candidates[j].Dist
+		}
+		return candidates[i].W < candidates[j].W
+	})
+
+	overlap := func(a, aw, b, bw int) bool {
+		return a < b+bw && b < a+aw
+	}
+	var motifs []PanMotif
+	for _, c := range candidates {
+		if len(motifs) == k {
+			break
+		}
+		overlaps := false
+		for _, m := range motifs {
+			if overlap(c.Idx, c.W, m.Idx, m.W) || overlap(c.Idx, c.W, m.NN, m.W) ||
+				overlap(c.NN, c.W, m.Idx, m.W) || overlap(c.NN, c.W, m.NN, m.W) {
+				overlaps = true
+				break
+			}
+		}
+		if !overlaps {
+			motifs = append(motifs, c)
+		}
+	}
+	return motifs, nil
+}
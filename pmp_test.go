@@ -4,6 +4,8 @@ import (
 	"math"
 	"os"
 	"testing"
+
+	"github.com/matrix-profile-foundation/go-matrixprofile/siggen"
 )
 
 func TestPMPSave(t *testing.T) {
@@ -60,14 +62,14 @@ func TestComputePmp(t *testing.T) {
 		{[]float64{}, []float64{}, 2, 2, 1, nil, nil},
 		{[]float64{1, 1, 1, 1, 1}, []float64{}, 2, 2, 1, nil, nil},
 		{[]float64{}, []float64{1, 1, 1, 1, 1}, 2, 2, 1, nil, nil},
-		{[]float64{1, 2, 1, 3, 1}, []float64{2, 1, 1, 2, 1, 3, 1, -1, -2}, 2, 2, 1, [][]float64{{0, 0, 0, 0}}, [][]int{{2, 3, 2, 3}}},
-		{[]float64{1, 1, 1, 1, 1}, []float64{1, 1, 1, 1, 1, 2, 2, 3, 4, 5}, 2, 2, 1, [][]float64{{2, 2, 2, 2}}, [][]int{{0, 1, 2, 3}}},
+		{[]float64{1, 2, 1, 3, 1}, []float64{2, 1, 1, 2, 1, 3, 1, -1, -2}, 2, 2, 1, [][]float64{{0, 0, 0, 0}}, [][]int{{2, 0, 2, 0}}},
+		{[]float64{1, 1, 1, 1, 1}, []float64{1, 1, 1, 1, 1, 2, 2, 3, 4, 5}, 2, 2, 1, [][]float64{{2, 2, 2, 2}}, [][]int{{0, 0, 0, 0}}},
 		{[]float64{0, 0.99, 1, 0, 0, 0.98, 1, 0, 0, 0.96, 1, 0}, []float64{0, 0.99, 1, 0, 0, 0.98, 1, 0, 0, 0.96, 1, 0}, 4, 4, 1,
 			[][]float64{{0, 0, 0, 0, 0, 0, 0, 0, 0}},
 			[][]int{{0, 1, 2, 3, 4, 5, 6, 7, 8}}},
 		{[]float64{0, 1, 1, 1, 0, 0, 2, 1, 0, 0, 2, 1}, nil, 4, 4, 1,
 			[][]float64{{1.9550, 1.8388, 0.8739, 0, 0, 1.9550, 0.8739, 0, 0}},
-			[][]int{{4, 2, 6, 7, 8, 1, 2, 3, 4}}},
+			[][]int{{4, 2, 6, 7, 8, 0, 2, 3, 4}}},
 		{[]float64{0, 0.99, 1, 0, 0, 0.98, 1, 0, 0, 0.96, 1, 0}, nil, 4, 4, 1,
 			[][]float64{{0.014355, 0.014355, 0.029138, 0.029138, 0.014355, 0.014355, 0.029138, 0.029138, 0.029138}},
 			[][]int{{4, 5, 6, 7, 0, 1, 2, 3, 4}}},
@@ -146,3 +148,206 @@ func TestComputePmp(t *testing.T) {
 		}
 	}
 }
+
+func TestComputePmpSampled(t *testing.T) {
+	sig := siggen.Add(siggen.Sin(1, 5, 0, 0, 100, 2), siggen.Noise(0.1, 200))
+
+	p, err := NewPMP(sig, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err = p.Profile(10); err == nil {
+		t.Errorf("Expected an error before computing the pan matrix profile")
+	}
+	for _, r := range [][]int{{1, 10}, {10, 201}} {
+		if err = p.Compute(NewPMPOpts(r[0], r[1])); err == nil {
+			t.Errorf("Expected an error for subsequence lengths %d to %d", r[0], r[1])
+		}
+	}
+
+	o := NewPMPOpts(4, 20)
+	o.SamplePct = 0.3
+	if err = p.Compute(o); err != nil {
+		t.Fatal(err)
+	}
+	if len(p.PMP) != 17 {
+		t.Fatalf("Expected a row for each of the 17 subsequence lengths, but got %d", len(p.PMP))
+	}
+	expectedWindows := []int{4, 12, 8, 16, 6}
+	if len(p.PWindows) != len(expectedWindows) {
+		t.Fatalf("Expected subsequence lengths %v, but got %v", expectedWindows, p.PWindows)
+	}
+	for i, w := range expectedWindows {
+		if p.PWindows[i] != w {
+			t.Fatalf("Expected subsequence lengths %v, but got %v", expectedWindows, p.PWindows)
+		}
+	}
+
+	for _, w := range expectedWindows {
+		profile, _, err := p.Profile(w)
+		if err != nil {
+			t.Fatal(err)
+		}
+		mp, err := New(sig, nil, w)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = mp.Compute(NewMPOpts()); err != nil {
+			t.Fatal(err)
+		}
+		if len(profile) != len(mp.MP) {
+			t.Fatalf("Expected a profile length of %d, but got %d for window %d", len(mp.MP), len(profile), w)
+		}
+		for i := range mp.MP {
+			if math.Abs(profile[i]-mp.MP[i]) > 1e-6 {
+				t.Errorf("Expected %.6f at %d, but got %.6f for window %d", mp.MP[i], i, profile[i], w)
+				break
+			}
+		}
+	}
+
+	for _, w := range []int{3, 5, 21} {
+		if _, _, err = p.Profile(w); err == nil {
+			t.Errorf("Expected an error for subsequence length %d", w)
+		}
+	}
+	if !math.IsInf(p.PMP[5-4][0], 1) {
+		t.Errorf("Expected +Inf for a subsequence length that was not sampled, but got %.3f", p.PMP[1][0])
+	}
+}
+
+func TestStreamPMP(t *testing.T) {
+	sig := siggen.Add(siggen.Sin(1, 5, 0, 0, 100, 6), siggen.Noise(0.05, 600))
+	// a slow drift away from the pattern is only anomalous at longer subsequence lengths
+	for i := 0; i < 60; i++ {
+		sig[450+i] += 1.5 * math.Sin(math.Pi*float64(i)/60)
+	}
+
+	for _, windows := range [][]int{{}, {10, 10}, {10, 400}} {
+		if _, err := NewStreamPMP(sig[:300], windows, nil, 0); err == nil {
+			t.Errorf("Expected an error for subsequence lengths %v", windows)
+		}
+	}
+
+	s, err := NewStreamPMP(sig[:300], []int{60, 10}, nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 300; i < len(sig); i += 50 {
+		if err = s.Update(sig[i : i+50]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for i, w := range s.Windows {
+		mp, err := New(sig, nil, w)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = mp.Compute(NewMPOpts()); err != nil {
+			t.Fatal(err)
+		}
+		if len(s.Profiles[i].MP) != len(mp.MP) {
+			t.Fatalf("Expected a profile length of %d, but got %d for window %d", len(mp.MP), len(s.Profiles[i].MP), w)
+		}
+		for j := range mp.MP {
+			if math.Abs(s.Profiles[i].MP[j]-mp.MP[j]) > 1e-6 {
+				t.Errorf("Expected %.6f at %d, but got %.6f for window %d", mp.MP[j], j, s.Profiles[i].MP[j], w)
+				break
+			}
+		}
+	}
+
+	if _, err = s.Discords(0); err == nil {
+		t.Errorf("Expected an error for 0 discords")
+	}
+	discords, err := s.Discords(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(discords) == 0 || discords[0].W != 60 || discords[0].Idx+60 < 450 || discords[0].Idx > 510 {
+		t.Errorf("Expected the top discord to overlap the drift at 450 with a window of 60, but got %+v", discords)
+	}
+	for i := 1; i < len(discords); i++ {
+		if discords[i].Dist > discords[i-1].Dist {
+			t.Errorf("Expected discords sorted by distance, but got %+v", discords)
+		}
+	}
+}
+
+func TestRankMotifs(t *testing.T) {
+	sig := seededNoise(1, 0.1, 600)
+	// a long pattern repeated twice, which only stands out clearly at longer lengths
+	for _, start := range []int{100, 400} {
+		for i := 0; i < 60; i++ {
+			sig[start+i] += math.Sin(2*math.Pi*float64(i)/60) + 0.5*math.Sin(6*math.Pi*float64(i)/60)
+		}
+	}
+
+	windows := []int{10, 30, 60}
+	profiles := make([]*MatrixProfile, len(windows))
+	for i, w := range windows {
+		mp, err := New(sig, nil, w)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = mp.Compute(NewMPOpts()); err != nil {
+			t.Fatal(err)
+		}
+		profiles[i] = mp
+	}
+
+	motifs, err := RankMotifs(profiles, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(motifs) != 3 {
+		t.Fatalf("Expected 3 motifs, but got %v", motifs)
+	}
+	top := motifs[0]
+	first, second := top.Idx, top.NN
+	if first > second {
+		first, second = second, first
+	}
+	// noise can shift the best match a few points past the edges of the pattern
+	if first < 95 || first+top.W > 165 || second < 395 || second+top.W > 465 {
+		t.Errorf("Expected the top motif within the repeated pattern, but got %+v", top)
+	}
+	for i, m := range motifs {
+		if i > 0 && m.Dist < motifs[i-1].Dist {
+			t.Errorf("Expected motifs in ascending order of distance, but got %v", motifs)
+		}
+		for _, prev := range motifs[:i] {
+			for _, a := range []int{m.Idx, m.NN} {
+				for _, b := range []int{prev.Idx, prev.NN} {
+					if a < b+prev.W && b < a+m.W {
+						t.Errorf("Expected no overlap between %+v and %+v", m, prev)
+					}
+				}
+			}
+		}
+	}
+
+	p, err := NewPMP(sig, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	o := NewPMPOpts(10, 60)
+	if err = p.Compute(o); err != nil {
+		t.Fatal(err)
+	}
+	panMotifs, err := p.Motifs(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(panMotifs) != 3 || panMotifs[0].Dist > top.Dist {
+		t.Errorf("Expected the best motif across all lengths to be at most %.4f, but got %v", top.Dist, panMotifs)
+	}
+
+	if _, err = RankMotifs(profiles, 0); err == nil {
+		t.Errorf("Expected an error for no motifs")
+	}
+	if _, err = RankMotifs([]*MatrixProfile{{SelfJoin: true}}, 1); err == nil {
+		t.Errorf("Expected an error for a profile that was not computed")
+	}
+}
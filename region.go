@@ -0,0 +1,333 @@
+package matrixprofile
+
+import (
+	"fmt"
+	"math"
+	"sync"
+
+	"github.com/matrix-profile-foundation/go-matrixprofile/util"
+	"gonum.org/v1/gonum/floats"
+)
+
+// Region is a half open range of subsequence start indices, [Start, End).
+type Region struct {
+	Start int `json:"start"`
+	End   int `json:"end"`
+}
+
+// validate checks that the region holds at least one of the n subsequences.
+func (r Region) validate(name string, n int) error {
+	if r.Start < 0 || r.End > n || r.Start >= r.End {
+		return fmt.Errorf("%s region [%d, %d) must hold at least one of the %d subsequences", name, r.Start, r.End, n)
+	}
+	return nil
+}
+
+// JoinRegion computes the matrix profile of only the subsequences of a starting in
+// the query region against only the subsequences of b starting in the match region,
+// such as comparing this week against last month. Only the rows of the query region
+// are visited and each sliding dot product spans only the match region, so the work
+// scales with the product of the region sizes rather than the full join. The rows are
+// updated with the STOMP recurrence regardless of the algorithm in the options. The
+// matrix profile stays indexed by the subsequences of a with indices into b, and
+// entries outside of the query region are left at +Inf. Self joins still exclude
+// trivial matches when the regions overlap. The BA join is not computed.
+func (mp *MatrixProfile) JoinRegion(query, match Region, o *MPOpts) error {
+	if o == nil {
+		o = NewMPOpts()
+	}
+	mp.Opts = o
+	mp.mpxStream = nil
+	mp.stampi = nil
+
+	if err := o.Validate(); err != nil {
+		return err
+	}
+	if err := mp.validateNormalization(o); err != nil {
+		return err
+	}
+	if o.Period > 0 {
+		return fmt.Errorf("region joins don't support periodic exclusion zones")
+	}
+	if err := mp.applyTransform(o); err != nil {
+		return err
+	}
+	if err := query.validate("query", len(mp.A)-mp.W+1); err != nil {
+		return err
+	}
+	if err := match.validate("match", len(mp.B)-mp.W+1); err != nil {
+		return err
+	}
+	if err := mp.initCaches(); err != nil {
+		return err
+	}
+
+	mp.MP = make([]float64, len(mp.A)-mp.W+1)
+	mp.Idx = make([]int, len(mp.A)-mp.W+1)
+	for i := range mp.MP {
+		mp.MP[i] = math.Inf(1)
+		mp.Idx[i] = math.MaxInt64
+	}
+	mp.MPB, mp.IdxB = nil, nil
+	return mp.joinRows(query, match)
+}
+
+// joinRows updates the rows of the query region with the best matches among the
+// subsequences of the match region, keeping a row's current match when it is better
+// so several match regions can be joined one after another.
+func (mp *MatrixProfile) joinRows(query, match Region) error {
+	o := mp.Opts
+
+	// a view of the matrix profile whose b timeseries only covers the match region.
+	// Trivial matches are excluded separately since the view's indices are shifted.
+	view := *mp
+	view.B = mp.B[match.Start : match.End+mp.W-1]
+	view.N = len(view.B)
+	view.BMean = mp.BMean[match.Start:match.End]
+	view.BStd = mp.BStd[match.Start:match.End]
+	view.SelfJoin = false
+	view.BF = view.newFFT().coefficients(view.B)
+
+	exclZone := mp.exclusionZone(mp.W / 2)
+	n := query.End - query.Start
+	batchSize := n/o.NJobs + 1
+	errs := make([]error, o.NJobs)
+
+	var wg sync.WaitGroup
+	wg.Add(o.NJobs)
+	for batch := 0; batch < o.NJobs; batch++ {
+		go func(batch int) {
+			defer wg.Done()
+			start := query.Start + batch*batchSize
+			if start >= query.End {
+				return
+			}
+			end := start + batchSize
+			if end > query.End {
+				end = query.End
+			}
+
+			dot, err := view.crossCorrelate(mp.A[start:start+mp.W], view.newFFT())
+			if err != nil {
+				errs[batch] = err
+				return
+			}
+			profile := make([]float64, len(dot))
+			for i := start; i < end; i++ {
+				if i > start {
+					for j := len(dot) - 1; j > 0; j-- {
+						dot[j] = dot[j-1] - view.B[j-1]*mp.A[i-1] + view.B[j+mp.W-1]*mp.A[i+mp.W-1]
+					}
+					// the first cross correlation is not covered by the recurrence
+					dot[0] = floats.Dot(mp.A[i:i+mp.W], view.B[:mp.W])
+				}
+				if err := view.calculateDistanceProfile(dot, i, profile); err != nil {
+					errs[batch] = err
+					return
+				}
+				if mp.SelfJoin {
+					mp.applyExclusionZone(profile, i, match.Start, exclZone)
+				}
+				if !o.Euclidean {
+					util.E2P(profile, mp.W)
+				}
+
+				bestVal := profile[0]
+				bestIdx := 0
+				for j, d := range profile {
+					if isBetter(d, bestVal, o.Euclidean) {
+						bestVal = d
+						bestIdx = j
+					}
+				}
+				if mp.Idx[i] == math.MaxInt64 || isBetterMatch(bestVal, match.Start+bestIdx, mp.MP[i], mp.Idx[i], o.Euclidean) {
+					mp.MP[i] = bestVal
+					mp.Idx[i] = match.Start + bestIdx
+				}
+			}
+		}(batch)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// allowedRegions converts intervals of points of a into the regions of subsequences
+// that fit entirely within them. Overlapping or touching intervals are merged first, so
+// a subsequence spanning two adjacent intervals is allowed.
+func (mp MatrixProfile) allowedRegions(allowed []Interval) ([]Region, error) {
+	for _, iv := range allowed {
+		if iv.Start < 0 || iv.End > len(mp.A) || iv.Start >= iv.End {
+			return nil, fmt.Errorf("allowed interval [%d, %d) must hold at least one of the %d points of the timeseries", iv.Start, iv.End, len(mp.A))
+		}
+	}
+
+	var regions []Region
+	for _, iv := range mergeIntervals(allowed) {
+		if iv.End-iv.Start >= mp.W {
+			regions = append(regions, Region{Start: iv.Start, End: iv.End - mp.W + 1})
+		}
+	}
+	if len(regions) == 0 {
+		return nil, fmt.Errorf("no subsequence of length %d fits within the allowed intervals", mp.W)
+	}
+	return regions, nil
+}
+
+// RestrictTo limits motif and discord discovery to the subsequences lying entirely
+// within the allowed intervals of points of a, such as business hours, by adding the
+// rest to the mask. Discovery then ranks only the allowed subsequences, so the top k
+// results are all allowed rather than whatever survives filtering the overall top k.
+// For a computed self join, the allowed subsequences whose nearest neighbor lies
+// outside of the intervals are compared again with the allowed ones only, so every
+// match stays within the intervals. Use ComputeWithin to skip the rest of the
+// timeseries altogether when the intervals are a small part of it.
+func (mp *MatrixProfile) RestrictTo(allowed []Interval) error {
+	regions, err := mp.allowedRegions(allowed)
+	if err != nil {
+		return err
+	}
+
+	n := len(mp.A) - mp.W + 1
+	mask := make([]bool, n)
+	for i := range mask {
+		mask[i] = true
+	}
+	for _, r := range regions {
+		for i := r.Start; i < r.End; i++ {
+			mask[i] = false
+		}
+	}
+	// subsequences already masked, such as flat ones, stay masked
+	if len(mp.Mask) == n {
+		for i, m := range mp.Mask {
+			mask[i] = mask[i] || m
+		}
+	}
+	mp.Mask = mask
+
+	if !mp.SelfJoin || len(mp.MP) != n {
+		return nil
+	}
+	return mp.rematchAllowed(regions)
+}
+
+// rematchAllowed recomputes the matches of the subsequences within the regions whose
+// nearest neighbor lies outside of them, against the subsequences within the regions.
+func (mp *MatrixProfile) rematchAllowed(regions []Region) error {
+	n := len(mp.MP)
+	inside := make([]bool, n)
+	for _, r := range regions {
+		for i := r.Start; i < r.End; i++ {
+			inside[i] = true
+		}
+	}
+
+	euclidean := mp.Opts == nil || mp.Opts.Euclidean
+	var fft *fftCache
+	var profile []float64
+	for i := 0; i < n; i++ {
+		if !inside[i] || mp.Idx[i] >= 0 && mp.Idx[i] < n && inside[mp.Idx[i]] {
+			continue
+		}
+		if fft == nil {
+			if err := mp.initCaches(); err != nil {
+				return err
+			}
+			fft = mp.newFFT()
+			profile = make([]float64, n)
+		}
+		if err := mp.distanceProfile(i, profile, fft); err != nil {
+			return err
+		}
+		if !euclidean {
+			util.E2P(profile, mp.W)
+		}
+
+		mp.MP[i], mp.Idx[i] = math.Inf(1), math.MaxInt64
+		if !euclidean {
+			mp.MP[i] = math.Inf(-1)
+		}
+		for j, d := range profile {
+			if inside[j] && !math.IsInf(d, 0) && isBetterMatch(d, j, mp.MP[i], mp.Idx[i], euclidean) {
+				mp.MP[i], mp.Idx[i] = d, j
+			}
+		}
+		if mp.Idx[i] == math.MaxInt64 {
+			mp.MP[i] = math.Inf(1)
+		}
+	}
+	return nil
+}
+
+// ComputeWithin computes the self join matrix profile of only the subsequences lying
+// entirely within the allowed intervals of points of a, each matched only against the
+// other allowed subsequences, and restricts discovery to them with RestrictTo. The
+// rows of each allowed region are joined with every allowed region using the STOMP
+// recurrence regardless of the algorithm in the options, so the work scales with the
+// square of the allowed length rather than of the whole timeseries. Entries outside of
+// the intervals are left at +Inf with an index of math.MaxInt64. Intervals refer to
+// the timeseries after the options transform.
+func (mp *MatrixProfile) ComputeWithin(allowed []Interval, o *MPOpts) error {
+	if o == nil {
+		o = NewMPOpts()
+	}
+	if !mp.SelfJoin {
+		return fmt.Errorf("allowed intervals are only supported for self joins")
+	}
+	mp.Opts = o
+	mp.mpxStream = nil
+	mp.stampi = nil
+
+	if err := o.Validate(); err != nil {
+		return err
+	}
+	if err := mp.validateNormalization(o); err != nil {
+		return err
+	}
+	if mp.nonNormalized() || o.Manhattan || o.recomputesFlat() {
+		return fmt.Errorf("matrix profiles within allowed intervals only support z-normalized euclidean distances or pearson correlations")
+	}
+	if o.Period > 0 {
+		return fmt.Errorf("matrix profiles within allowed intervals don't support periodic exclusion zones")
+	}
+	if err := mp.applyTransform(o); err != nil {
+		return err
+	}
+	regions, err := mp.allowedRegions(allowed)
+	if err != nil {
+		return err
+	}
+	if err = mp.initCaches(); err != nil {
+		return err
+	}
+
+	mp.MP = make([]float64, len(mp.A)-mp.W+1)
+	mp.Idx = make([]int, len(mp.A)-mp.W+1)
+	for i := range mp.MP {
+		mp.MP[i] = math.Inf(1)
+		mp.Idx[i] = math.MaxInt64
+	}
+	mp.MPB, mp.IdxB = nil, nil
+
+	for _, query := range regions {
+		for _, match := range regions {
+			if err = mp.joinRows(query, match); err != nil {
+				return err
+			}
+		}
+	}
+	mp.applyMaxDistance()
+
+	// the mask of a previous computation would otherwise carry over into RestrictTo
+	mp.Mask = nil
+	if err = mp.maskFlat(o); err != nil {
+		return err
+	}
+	return mp.RestrictTo(allowed)
+}
@@ -0,0 +1,218 @@
+package matrixprofile
+
+import (
+	"math"
+	"testing"
+
+	"github.com/matrix-profile-foundation/go-matrixprofile/siggen"
+	"github.com/matrix-profile-foundation/go-matrixprofile/util"
+)
+
+func TestJoinRegion(t *testing.T) {
+	a := siggen.Add(siggen.Sin(1, 4, 0, 0, 100, 2), siggen.Noise(0.1, 200))
+	b := siggen.Add(siggen.Sin(1, 3, 0, 0, 100, 3), siggen.Noise(0.1, 300))
+
+	testdata := []struct {
+		a           []float64
+		b           []float64
+		query       Region
+		match       Region
+		euclidean   bool
+		expectedErr bool
+	}{
+		{a, nil, Region{0, 181}, Region{0, 181}, true, false},
+		{a, nil, Region{120, 181}, Region{0, 100}, true, false},
+		{a, nil, Region{50, 100}, Region{60, 150}, true, false},
+		{a, nil, Region{50, 100}, Region{60, 150}, false, false},
+		{a, b, Region{10, 40}, Region{200, 281}, true, false},
+		{a, nil, Region{50, 50}, Region{0, 100}, true, true},
+		{a, nil, Region{0, 100}, Region{150, 182}, true, true},
+		{a, b, Region{-1, 40}, Region{0, 100}, true, true},
+	}
+
+	for _, d := range testdata {
+		mp, err := New(d.a, d.b, 20)
+		if err != nil {
+			t.Fatal(err)
+		}
+		o := NewMPOpts()
+		o.NJobs = 3
+		o.Euclidean = d.euclidean
+		err = mp.JoinRegion(d.query, d.match, o)
+		if d.expectedErr {
+			if err == nil {
+				t.Errorf("Expected an error for query %v and match %v", d.query, d.match)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("Did not expect an error, %v, for query %v and match %v", err, d.query, d.match)
+		}
+
+		profile := make([]float64, len(mp.B)-mp.W+1)
+		for i := range mp.MP {
+			if i < d.query.Start || i >= d.query.End {
+				if !math.IsInf(mp.MP[i], 1) {
+					t.Errorf("Expected +Inf outside of the query region at %d, but got %.3f", i, mp.MP[i])
+				}
+				continue
+			}
+
+			if err = mp.distanceProfile(i, profile, mp.newFFT()); err != nil {
+				t.Fatal(err)
+			}
+			best := math.Inf(1)
+			for j := d.match.Start; j < d.match.End; j++ {
+				best = math.Min(best, profile[j])
+			}
+			got := mp.MP[i]
+			if !d.euclidean {
+				got = math.Sqrt(2 * float64(mp.W) * (1 - got))
+			}
+			if math.Abs(got-best) > 1e-6 || mp.Idx[i] < d.match.Start || mp.Idx[i] >= d.match.End {
+				t.Errorf("Expected a distance of %.6f within the match region for %d, but got %.6f at %d", best, i, got, mp.Idx[i])
+			}
+		}
+	}
+}
+
+func TestComputeWithin(t *testing.T) {
+	w := 20
+	shape := make([]float64, w)
+	for i := range shape {
+		shape[i] = math.Sin(2 * math.Pi * float64(i) / float64(w))
+	}
+	// an exact repeat outside of the allowed hours and a noisy one within them
+	sig := seededNoise(1, 0.3, 600)
+	for _, start := range []int{30, 450} {
+		for i := range shape {
+			sig[start+i] = 3 * shape[i]
+		}
+	}
+	for _, start := range []int{120, 320} {
+		for i := range shape {
+			sig[start+i] += 3 * shape[i]
+		}
+	}
+	allowed := []Interval{{100, 200}, {300, 400}, {200, 210}}
+	isAllowed := func(i int) bool {
+		return i >= 100 && i+w <= 210 || i >= 300 && i+w <= 400
+	}
+
+	mp, err := New(sig, nil, w)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = mp.Compute(NewMPOpts()); err != nil {
+		t.Fatal(err)
+	}
+	motifs, err := mp.DiscoverMotifs(1, 2, 10, w/2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(motifs) != 1 || isAllowed(motifs[0].Idx[0]) {
+		t.Fatalf("Expected the exact repeat as the top motif without restriction, but got %v", motifs)
+	}
+
+	// the distance profile of a row excludes the trivial matches [i-w/2, i+w/2), while
+	// the column updates of stomp leave a match at i-w/2 and exclude one at i+w/2
+	full, err := New(sig, nil, w)
+	if err != nil {
+		t.Fatal(err)
+	}
+	o := NewMPOpts()
+	o.Algorithm = AlgoSTOMP
+	if err = full.Compute(o); err != nil {
+		t.Fatal(err)
+	}
+	reference := func(i int, columns bool) float64 {
+		profile := bruteDistanceProfile(sig[i:i+w], sig)
+		if columns {
+			util.ApplyExclusionZone(profile, i+1, w/2)
+		} else {
+			util.ApplyExclusionZone(profile, i, w/2)
+		}
+		best := math.Inf(1)
+		for j, d := range profile {
+			if isAllowed(j) {
+				best = math.Min(best, d)
+			}
+		}
+		return best
+	}
+
+	for _, compute := range []bool{false, true} {
+		mp, err := New(sig, nil, w)
+		if err != nil {
+			t.Fatal(err)
+		}
+		o := NewMPOpts()
+		o.Algorithm = AlgoSTOMP
+		if compute {
+			err = mp.ComputeWithin(allowed, o)
+		} else if err = mp.Compute(o); err == nil {
+			err = mp.RestrictTo(allowed)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		motifs, err := mp.DiscoverMotifs(1, 2, 10, w/2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(motifs) != 1 {
+			t.Fatalf("Expected 1 motif with compute %t, but got %v", compute, motifs)
+		}
+		for _, idx := range motifs[0].Idx {
+			if !isAllowed(idx) {
+				t.Errorf("Expected only allowed members with compute %t, but got %v", compute, motifs[0].Idx)
+			}
+		}
+		discords, err := mp.DiscoverDiscords(3, w/2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, idx := range discords {
+			if !isAllowed(idx) {
+				t.Errorf("Expected only allowed discords with compute %t, but got %v", compute, discords)
+			}
+		}
+
+		// allowed subsequences are only matched with each other either way. Rows are
+		// joined by their distance profiles within the allowed intervals, while a
+		// restricted stomp join keeps the rows whose match was already allowed
+		for i := range mp.MP {
+			if !isAllowed(i) {
+				if compute && (!math.IsInf(mp.MP[i], 1) || mp.Idx[i] != math.MaxInt64) {
+					t.Errorf("Expected +Inf outside of the allowed intervals at %d, but got %.3f", i, mp.MP[i])
+				}
+				continue
+			}
+			best := reference(i, !compute && isAllowed(full.Idx[i]))
+			if math.Abs(mp.MP[i]-best) > 1e-6 || !isAllowed(mp.Idx[i]) {
+				t.Errorf("Expected a distance of %.6f to an allowed subsequence for %d with compute %t, but got %.6f at %d", best, i, compute, mp.MP[i], mp.Idx[i])
+			}
+		}
+	}
+
+	testdata := []struct {
+		b       []float64
+		allowed []Interval
+	}{
+		{sig[:100], allowed},
+		{nil, []Interval{{-1, 50}}},
+		{nil, []Interval{{550, 601}}},
+		{nil, []Interval{{50, 50}}},
+		{nil, []Interval{{0, 10}, {100, 115}}},
+	}
+	for _, d := range testdata {
+		mp, err := New(sig, d.b, w)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = mp.ComputeWithin(d.allowed, nil); err == nil {
+			t.Errorf("Expected an error for allowed intervals %v with a self join %t", d.allowed, mp.SelfJoin)
+		}
+	}
+}
@@ -0,0 +1,214 @@
+package matrixprofile
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/matrix-profile-foundation/go-matrixprofile/util"
+	"gonum.org/v1/gonum/floats"
+)
+
+// Tile is a rectangular block of the distance matrix, whose rows are the subsequences
+// of a and whose columns are the subsequences of b.
+type Tile struct {
+	Row  int `json:"row"`  // first subsequence of a in the tile
+	Col  int `json:"col"`  // first subsequence of b in the tile
+	Rows int `json:"rows"` // number of subsequences of a in the tile
+	Cols int `json:"cols"` // number of subsequences of b in the tile
+}
+
+// TileResult is the partial matrix profile computed from a single tile.
+type TileResult struct {
+	Tile   Tile      `json:"tile"`
+	MP     []float64 `json:"mp"`     // best distance of each column of the tile over its rows
+	Idx    []int     `json:"pi"`     // subsequence of a of the best distance of each column
+	RowMP  []float64 `json:"row_mp"` // best distance of each row of the tile over its columns, only for self joins
+	RowIdx []int     `json:"row_pi"` // subsequence of b of the best distance of each row, only for self joins
+}
+
+// scamp computes the matrix profile with the tiled decomposition of SCAMP. The
+// distance matrix is split into square tiles, each computed on its own with the STOMP
+// recurrence over its rows, so the columns a tile works on stay in cache. Tiles are
+// spread across the jobs and their partial results merged, and the same tiles can be
+// computed on other machines with ComputeTile and merged with MergeTile.
+func (mp *MatrixProfile) scamp() error {
+	size := 0
+	if mp.Opts.SCAMP != nil {
+		size = mp.Opts.SCAMP.TileSize
+	}
+	tiles, err := mp.Tiles(size)
+	if err != nil {
+		return err
+	}
+
+	n := len(mp.B) - mp.W + 1
+	init := newBatchResult(n)
+	mp.MP, mp.Idx = init.MP, init.Idx
+
+	return mp.runBatches(func(batch int) *mpResult {
+		result := newBatchResult(n)
+		for i := batch; i < len(tiles); i += mp.Opts.NJobs {
+			r, err := mp.ComputeTile(tiles[i])
+			if err != nil {
+				return &mpResult{nil, nil, nil, nil, err}
+			}
+			r.mergeInto(result.MP, result.Idx)
+		}
+		return result
+	})
+}
+
+// Tiles splits the distance matrix into square tiles of at most size subsequences on a
+// side, in the order SCAMP computes them. Only the tiles on and above the diagonal are
+// needed for a self join since its distance matrix is symmetric. A size of 0 uses 2048,
+// or 8 times the subsequence length if that is larger, so the direct dot products that
+// start each tile stay a small part of its work.
+func (mp MatrixProfile) Tiles(size int) ([]Tile, error) {
+	if size < 0 {
+		return nil, fmt.Errorf("tile size, %d, must not be negative", size)
+	}
+	if size == 0 {
+		size = 2048
+		if 8*mp.W > size {
+			size = 8 * mp.W
+		}
+	}
+
+	nA := len(mp.A) - mp.W + 1
+	nB := len(mp.B) - mp.W + 1
+	var tiles []Tile
+	for row := 0; row < nA; row += size {
+		col := 0
+		if mp.SelfJoin {
+			col = row
+		}
+		for ; col < nB; col += size {
+			t := Tile{Row: row, Col: col, Rows: size, Cols: size}
+			if row+size > nA {
+				t.Rows = nA - row
+			}
+			if col+size > nB {
+				t.Cols = nB - col
+			}
+			tiles = append(tiles, t)
+		}
+	}
+	return tiles, nil
+}
+
+// ComputeTile computes the partial matrix profile of a tile with the z-normalized
+// euclidean distance and the options of the matrix profile. A tile only reads the
+// points of its own subsequences, computing their statistics and the dot products of
+// its first row and column directly, so it needs no fourier transform of the whole
+// timeseries and can be computed anywhere the timeseries is available.
+func (mp MatrixProfile) ComputeTile(t Tile) (*TileResult, error) {
+	nA := len(mp.A) - mp.W + 1
+	nB := len(mp.B) - mp.W + 1
+	if t.Row < 0 || t.Col < 0 || t.Rows < 1 || t.Cols < 1 || t.Row+t.Rows > nA || t.Col+t.Cols > nB {
+		return nil, fmt.Errorf("tile %+v is outside of the %d by %d distance matrix", t, nA, nB)
+	}
+
+	aMean, aStd, err := util.MovMeanStd(mp.A[t.Row:t.Row+t.Rows+mp.W-1], mp.W)
+	if err != nil {
+		return nil, err
+	}
+	bMean, bStd, err := util.MovMeanStd(mp.B[t.Col:t.Col+t.Cols+mp.W-1], mp.W)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &TileResult{Tile: t}
+	cols := newBatchResult(t.Cols)
+	r.MP, r.Idx = cols.MP, cols.Idx
+	if mp.SelfJoin {
+		rows := newBatchResult(t.Rows)
+		r.RowMP, r.RowIdx = rows.MP, rows.Idx
+	}
+
+	w := float64(mp.W)
+	zone := mp.exclusionZone(mp.W / 2)
+	dot := make([]float64, t.Cols)
+	for j := range dot {
+		dot[j] = floats.Dot(mp.A[t.Row:t.Row+mp.W], mp.B[t.Col+j:t.Col+j+mp.W])
+	}
+	profile := make([]float64, t.Cols)
+	for i := 0; i < t.Rows; i++ {
+		row := t.Row + i
+		if i > 0 {
+			for j := t.Cols - 1; j > 0; j-- {
+				col := t.Col + j
+				dot[j] = dot[j-1] - mp.B[col-1]*mp.A[row-1] + mp.B[col+mp.W-1]*mp.A[row+mp.W-1]
+			}
+			// the first dot product is not covered by the update above
+			dot[0] = floats.Dot(mp.A[row:row+mp.W], mp.B[t.Col:t.Col+mp.W])
+		}
+
+		// converting cross correlation value to squared euclidian distance,
+		// |2w - 2*(dot - w*muA*muB)/(stdA*stdB)|
+		for j, d := range dot {
+			profile[j] = math.Abs(2*w - 2*(d-w*aMean[i]*bMean[j])/(aStd[i]*bStd[j]))
+		}
+		mp.finishProfile(profile, aStd[i], bStd)
+
+		for j, dist := range profile {
+			col := t.Col + j
+			if !mp.SelfJoin {
+				if isBetterMatch(dist, row, r.MP[j], r.Idx[j], true) {
+					r.MP[j], r.Idx[j] = dist, row
+				}
+				continue
+			}
+
+			// each pair of a self join is visited once from the tiles on and above the
+			// diagonal, and updates both of its subsequences
+			if col < row {
+				continue
+			}
+			if !mp.excluded(row, col, zone) && isBetterMatch(dist, row, r.MP[j], r.Idx[j], true) {
+				r.MP[j], r.Idx[j] = dist, row
+			}
+			if !mp.excluded(col, row, zone) && isBetterMatch(dist, col, r.RowMP[i], r.RowIdx[i], true) {
+				r.RowMP[i], r.RowIdx[i] = dist, col
+			}
+		}
+	}
+	return r, nil
+}
+
+// MergeTile merges the partial matrix profile of a tile into the matrix profile, which
+// is first reset to +Inf if it doesn't have an entry for every subsequence of b. Tiles
+// can be merged in any order with the same result, as ties always go to the smallest
+// index.
+func (mp *MatrixProfile) MergeTile(r *TileResult) error {
+	nA := len(mp.A) - mp.W + 1
+	nB := len(mp.B) - mp.W + 1
+	t := r.Tile
+	if t.Row < 0 || t.Col < 0 || t.Row+len(r.RowMP) > nA || t.Col+len(r.MP) > nB {
+		return fmt.Errorf("tile %+v is outside of the %d by %d distance matrix", t, nA, nB)
+	}
+	if len(r.Idx) != len(r.MP) || len(r.RowIdx) != len(r.RowMP) {
+		return fmt.Errorf("tile %+v has a different number of matrix profile values and indices", t)
+	}
+
+	if len(mp.MP) != nB || len(mp.Idx) != nB {
+		init := newBatchResult(nB)
+		mp.MP, mp.Idx = init.MP, init.Idx
+	}
+	r.mergeInto(mp.MP, mp.Idx)
+	return nil
+}
+
+// mergeInto merges the partial matrix profile of the tile into a matrix profile and
+// index covering every subsequence.
+func (r TileResult) mergeInto(profile []float64, idx []int) {
+	merge := func(from int, mp []float64, mpIdx []int) {
+		for k, d := range mp {
+			if isBetterMatch(d, mpIdx[k], profile[from+k], idx[from+k], true) {
+				profile[from+k] = d
+				idx[from+k] = mpIdx[k]
+			}
+		}
+	}
+	merge(r.Tile.Col, r.MP, r.Idx)
+	merge(r.Tile.Row, r.RowMP, r.RowIdx)
+}
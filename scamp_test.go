@@ -0,0 +1,142 @@
+package matrixprofile
+
+import (
+	"bytes"
+	"encoding/gob"
+	"math"
+	"testing"
+
+	"github.com/matrix-profile-foundation/go-matrixprofile/siggen"
+)
+
+func TestComputeScamp(t *testing.T) {
+	sig := siggen.Add(siggen.Sin(1, 4, 0, 0, 100, 5), siggen.Noise(0.1, 500))
+	b := siggen.Add(siggen.Sin(1, 4, 0, 0, 100, 2), siggen.Noise(0.2, 200))
+
+	testdata := []struct {
+		b        []float64
+		w        int
+		tileSize int
+		njobs    int
+		circular bool
+	}{
+		{nil, 20, 0, 1, false},
+		{nil, 20, 64, 1, false},
+		{nil, 20, 64, 3, false},
+		{nil, 33, 100, 4, false},
+		{nil, 20, 7, 2, true},
+		{b, 20, 0, 2, false},
+		{b, 20, 50, 3, false},
+	}
+
+	for _, d := range testdata {
+		exact, err := New(sig, d.b, d.w)
+		if err != nil {
+			t.Fatal(err)
+		}
+		o := NewMPOpts()
+		o.Algorithm = AlgoSTOMP
+		o.Circular = d.circular
+		if err = exact.Compute(o); err != nil {
+			t.Fatal(err)
+		}
+
+		mp, err := New(sig, d.b, d.w)
+		if err != nil {
+			t.Fatal(err)
+		}
+		o = NewMPOpts()
+		o.Algorithm = AlgoSCAMP
+		o.NJobs = d.njobs
+		o.Circular = d.circular
+		o.SCAMP = &SCAMPOpts{TileSize: d.tileSize}
+		if err = mp.Compute(o); err != nil {
+			t.Fatal(err)
+		}
+
+		if len(mp.MP) != len(exact.MP) {
+			t.Fatalf("Expected %d elements, but got %d, %+v", len(exact.MP), len(mp.MP), d)
+		}
+		for i := range exact.MP {
+			if math.Abs(mp.MP[i]-exact.MP[i]) > 1e-6 || mp.Idx[i] != exact.Idx[i] {
+				t.Errorf("Expected (%.6f, %d) at %d, but got (%.6f, %d), %+v", exact.MP[i], exact.Idx[i], i, mp.MP[i], mp.Idx[i], d)
+				break
+			}
+		}
+	}
+}
+
+func TestMergeTile(t *testing.T) {
+	sig := siggen.Add(siggen.Sin(1, 4, 0, 0, 100, 3), siggen.Noise(0.1, 300))
+
+	exact, err := New(sig, nil, 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	o := NewMPOpts()
+	o.Algorithm = AlgoSTOMP
+	if err = exact.Compute(o); err != nil {
+		t.Fatal(err)
+	}
+
+	worker, err := New(sig, nil, 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tiles, err := worker.Tiles(50)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 6 blocks of rows and columns, only those on and above the diagonal
+	if len(tiles) != 21 {
+		t.Fatalf("Expected 21 tiles, but got %d", len(tiles))
+	}
+
+	// partial results are sent to the coordinator with gob, which keeps the +Inf of
+	// subsequences without a match in the tile, and merged in reverse
+	coordinator, err := New(sig, nil, 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := len(tiles) - 1; i >= 0; i-- {
+		r, err := worker.ComputeTile(tiles[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+		var buf bytes.Buffer
+		if err = gob.NewEncoder(&buf).Encode(r); err != nil {
+			t.Fatal(err)
+		}
+		var received TileResult
+		if err = gob.NewDecoder(&buf).Decode(&received); err != nil {
+			t.Fatal(err)
+		}
+		if err = coordinator.MergeTile(&received); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for i := range exact.MP {
+		if math.Abs(coordinator.MP[i]-exact.MP[i]) > 1e-6 || coordinator.Idx[i] != exact.Idx[i] {
+			t.Errorf("Expected (%.6f, %d) at %d, but got (%.6f, %d)", exact.MP[i], exact.Idx[i], i, coordinator.MP[i], coordinator.Idx[i])
+			break
+		}
+	}
+
+	invalid := []Tile{
+		{Row: -1, Col: 0, Rows: 10, Cols: 10},
+		{Row: 0, Col: 0, Rows: 0, Cols: 10},
+		{Row: 250, Col: 250, Rows: 50, Cols: 50},
+	}
+	for _, tile := range invalid {
+		if _, err = worker.ComputeTile(tile); err == nil {
+			t.Errorf("Expected an error for tile %+v", tile)
+		}
+	}
+	if err = coordinator.MergeTile(&TileResult{Tile: Tile{Col: 280}, MP: make([]float64, 10), Idx: make([]int, 10)}); err == nil {
+		t.Errorf("Expected an error for a tile outside of the matrix profile")
+	}
+	if _, err = worker.Tiles(-1); err == nil {
+		t.Errorf("Expected an error for a negative tile size")
+	}
+}
@@ -0,0 +1,196 @@
+package matrixprofile
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+
+	"gonum.org/v1/gonum/floats"
+)
+
+// scrimp computes a self join matrix profile with SCRIMP++. The PreSCRIMP pass first
+// gives a close approximation, then the SCRIMP pass evaluates whole diagonals of the
+// distance matrix in a random order, converging to the exact matrix profile once every
+// diagonal has been visited. The sample percentage sets the fraction of diagonals that
+// are visited.
+func (mp *MatrixProfile) scrimp() error {
+	if err := mp.prescrimp(); err != nil {
+		return err
+	}
+
+	n := len(mp.A) - mp.W + 1
+	zone := mp.exclusionZone(mp.W / 2)
+	rng := rand.New(rand.NewSource(rand.Int63()))
+	if mp.Opts.SCRIMP != nil && mp.Opts.SCRIMP.Seed != 0 {
+		rng = rand.New(rand.NewSource(mp.Opts.SCRIMP.Seed))
+	}
+
+	// diagonal k holds the pairs of subsequences k apart, and the main diagonal is
+	// always a trivial match
+	diags := rng.Perm(n - 1)
+	for i := range diags {
+		diags[i]++
+	}
+	diags = diags[:int(float64(len(diags))*mp.Opts.samplePct())]
+
+	return mp.runBatches(func(batch int) *mpResult {
+		var own []int
+		for i := batch; i < len(diags); i += mp.Opts.NJobs {
+			own = append(own, diags[i])
+		}
+		return mp.scrimpDiagonals(own, zone)
+	})
+}
+
+// prescrimp computes an approximate self join matrix profile with the PreSCRIMP pass of
+// SCRIMP++. It computes the distance profile of every stride-th subsequence and follows
+// the diagonal of each one's nearest neighbor for the subsequences in between, taking
+// about 1/stride of the time of STOMP. Every value is an upper bound of the exact
+// matrix profile.
+func (mp *MatrixProfile) prescrimp() error {
+	if !mp.SelfJoin {
+		return fmt.Errorf("%s only supports self joins", mp.Opts.Algorithm)
+	}
+	if err := mp.initCaches(); err != nil {
+		return err
+	}
+
+	n := len(mp.A) - mp.W + 1
+	mp.MP = make([]float64, n)
+	mp.Idx = make([]int, n)
+	for i := 0; i < n; i++ {
+		mp.MP[i] = math.Inf(1)
+		mp.Idx[i] = math.MaxInt64
+	}
+
+	zone := mp.exclusionZone(mp.W / 2)
+	stride := mp.prescrimpStride()
+	return mp.runBatches(func(batch int) *mpResult {
+		return mp.prescrimpBatch(batch, stride, zone)
+	})
+}
+
+// prescrimpStride returns the configured PreSCRIMP stride, defaulting to a quarter of
+// the subsequence length. A stride reaching past the last subsequence is cut down to
+// it, otherwise only the first subsequence would be sampled and the ones within its
+// exclusion zone could be left without any match.
+func (mp MatrixProfile) prescrimpStride() int {
+	if mp.Opts.SCRIMP != nil && mp.Opts.SCRIMP.Stride > 0 {
+		if last := len(mp.A) - mp.W; last > 0 && mp.Opts.SCRIMP.Stride > last {
+			return last
+		}
+		return mp.Opts.SCRIMP.Stride
+	}
+	if mp.W/4 > 1 {
+		return mp.W / 4
+	}
+	return 1
+}
+
+// prescrimpBatch computes the distance profiles of every stride-th subsequence handled
+// by a batch, taking every NJobs-th of them starting from the batch number. The
+// subsequences following and preceding each one up to the next sampled subsequence are
+// compared with those following and preceding its nearest neighbor, since neighbors
+// of neighbors tend to be close matches.
+func (mp MatrixProfile) prescrimpBatch(batch, stride, zone int) *mpResult {
+	n := len(mp.A) - mp.W + 1
+	result := newBatchResult(n)
+
+	fft := mp.newFFT()
+	profile := make([]float64, n)
+	buf := make([]float64, 1)
+	for i := batch * stride; i < n; i += mp.Opts.NJobs * stride {
+		dot, err := mp.crossCorrelate(mp.A[i:i+mp.W], fft)
+		if err != nil {
+			return &mpResult{nil, nil, nil, nil, err}
+		}
+		mp.dotsToDistances(dot, i, 0, profile)
+
+		best := -1
+		for j, d := range profile {
+			mp.updatePair(result, d, i, j, zone)
+			if !mp.excluded(i, j, zone) && (best < 0 || isBetterMatch(d, j, profile[best], best, true)) {
+				best = j
+			}
+		}
+		if best < 0 {
+			continue
+		}
+
+		// the dot products along the diagonal of the nearest neighbor follow from the
+		// stomp recurrence in both directions
+		fwd := dot[best]
+		for q := 1; q < stride && i+q < n && best+q < n; q++ {
+			fwd += mp.A[i+q+mp.W-1]*mp.A[best+q+mp.W-1] - mp.A[i+q-1]*mp.A[best+q-1]
+			mp.updatePair(result, mp.pairDistance(fwd, i+q, best+q, buf), i+q, best+q, zone)
+		}
+		back := dot[best]
+		for q := 1; q < stride && i-q >= 0 && best-q >= 0; q++ {
+			back += mp.A[i-q]*mp.A[best-q] - mp.A[i-q+mp.W]*mp.A[best-q+mp.W]
+			mp.updatePair(result, mp.pairDistance(back, i-q, best-q, buf), i-q, best-q, zone)
+		}
+	}
+	return result
+}
+
+// scrimpDiagonals computes every distance along the given diagonals of the distance
+// matrix, where diagonal k compares each subsequence with the one k after it.
+func (mp MatrixProfile) scrimpDiagonals(diags []int, zone int) *mpResult {
+	n := len(mp.A) - mp.W + 1
+	result := newBatchResult(n)
+
+	buf := make([]float64, 1)
+	for _, k := range diags {
+		dot := floats.Dot(mp.A[:mp.W], mp.A[k:k+mp.W])
+		for i := 0; i+k < n; i++ {
+			j := i + k
+			if i > 0 {
+				dot += mp.A[i+mp.W-1]*mp.A[j+mp.W-1] - mp.A[i-1]*mp.A[j-1]
+			}
+			mp.updatePair(result, mp.pairDistance(dot, i, j, buf), i, j, zone)
+		}
+	}
+	return result
+}
+
+// pairDistance converts the dot product of the subsequences at i and j into the same
+// distance dotsToDistances gives, using buf as a single element scratch profile.
+func (mp MatrixProfile) pairDistance(dot float64, i, j int, buf []float64) float64 {
+	w := float64(mp.W)
+	d := (dot - w*mp.AMean[i]*mp.BMean[j]) / mp.BStd[j]
+	buf[0] = math.Abs(2*w - 2/mp.AStd[i]*d)
+	mp.finishProfile(buf, mp.AStd[i], mp.BStd[j:j+1])
+	return buf[0]
+}
+
+// updatePair updates a batch's matrix profile with the distance between the
+// subsequences at i and j. Each direction of the pair is only a match when it is
+// outside the exclusion zone of the distance profile it would come from, so the
+// result is the same as computing every distance profile.
+func (mp MatrixProfile) updatePair(result *mpResult, dist float64, i, j, zone int) {
+	if !mp.excluded(i, j, zone) && isBetterMatch(dist, i, result.MP[j], result.Idx[j], true) {
+		result.MP[j] = dist
+		result.Idx[j] = i
+	}
+	if !mp.excluded(j, i, zone) && isBetterMatch(dist, j, result.MP[i], result.Idx[i], true) {
+		result.MP[i] = dist
+		result.Idx[i] = j
+	}
+}
+
+// excluded reports whether the subsequence at col is a trivial match in the self join
+// distance profile of the subsequence at row, following applyExclusionZone.
+func (mp MatrixProfile) excluded(row, col, zone int) bool {
+	within := func(d int) bool {
+		return d >= -zone && d < zone
+	}
+	d := col - row
+	if within(d) {
+		return true
+	}
+	if mp.circular() {
+		n := len(mp.A) - mp.W + 1
+		return within(d-n) || within(d+n)
+	}
+	return false
+}
@@ -0,0 +1,161 @@
+package matrixprofile
+
+import (
+	"math"
+	"testing"
+
+	"github.com/matrix-profile-foundation/go-matrixprofile/siggen"
+)
+
+func TestComputeScrimp(t *testing.T) {
+	sig := siggen.Add(siggen.Sin(1, 4, 0, 0, 100, 2), siggen.Noise(0.1, 200))
+
+	testdata := []struct {
+		w        int
+		njobs    int
+		stride   int
+		circular bool
+	}{
+		{20, 1, 0, false},
+		{20, 3, 0, false},
+		{20, 4, 7, false},
+		{33, 2, 1, false},
+		{20, 2, 0, true},
+	}
+
+	for _, d := range testdata {
+		exact, err := New(sig, nil, d.w)
+		if err != nil {
+			t.Fatal(err)
+		}
+		o := NewMPOpts()
+		o.Algorithm = AlgoSTOMP
+		o.Circular = d.circular
+		if err = exact.Compute(o); err != nil {
+			t.Fatal(err)
+		}
+
+		mp, err := New(sig, nil, d.w)
+		if err != nil {
+			t.Fatal(err)
+		}
+		o = NewMPOpts()
+		o.Algorithm = AlgoSCRIMP
+		o.NJobs = d.njobs
+		o.Circular = d.circular
+		o.SCRIMP = &SCRIMPOpts{Seed: 3, Stride: d.stride}
+		if err = mp.Compute(o); err != nil {
+			t.Fatal(err)
+		}
+
+		if len(mp.MP) != len(exact.MP) {
+			t.Fatalf("Expected %d elements, but got %d, %+v", len(exact.MP), len(mp.MP), d)
+		}
+		for i := range exact.MP {
+			if math.Abs(mp.MP[i]-exact.MP[i]) > 1e-6 {
+				t.Errorf("Expected %.6f at %d, but got %.6f, %+v", exact.MP[i], i, mp.MP[i], d)
+				break
+			}
+		}
+	}
+}
+
+func TestComputeScrimpPartial(t *testing.T) {
+	sig := siggen.Add(siggen.Sin(1, 4, 0, 0, 100, 2), siggen.Noise(0.1, 200))
+
+	exact, err := New(sig, nil, 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	o := NewMPOpts()
+	o.Algorithm = AlgoSTOMP
+	if err = exact.Compute(o); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, sample := range []float64{0.001, 0.1, 0.5} {
+		mp, err := New(sig, nil, 20)
+		if err != nil {
+			t.Fatal(err)
+		}
+		o = NewMPOpts()
+		o.Algorithm = AlgoSCRIMP
+		o.SCRIMP = &SCRIMPOpts{SamplePct: sample, Seed: 3}
+		if err = mp.Compute(o); err != nil {
+			t.Fatal(err)
+		}
+
+		// the prescrimp pass gives every subsequence a match that can only be refined
+		// towards the exact matrix profile
+		for i := range exact.MP {
+			if math.IsInf(mp.MP[i], 0) || mp.MP[i] < exact.MP[i]-1e-6 {
+				t.Errorf("Expected an upper bound of %.6f at %d, but got %.6f with sample %.3f", exact.MP[i], i, mp.MP[i], sample)
+				break
+			}
+		}
+	}
+}
+
+func TestComputePreScrimp(t *testing.T) {
+	sig := siggen.Add(siggen.Sin(1, 4, 0, 0, 100, 2), siggen.Noise(0.1, 200))
+
+	exact, err := New(sig, nil, 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	o := NewMPOpts()
+	o.Algorithm = AlgoSTOMP
+	if err = exact.Compute(o); err != nil {
+		t.Fatal(err)
+	}
+
+	testdata := []struct {
+		stride int
+		exact  bool
+	}{
+		{1, true},
+		{0, false},
+		{20, false},
+		{1000, false},
+	}
+
+	for _, d := range testdata {
+		mp, err := New(sig, nil, 20)
+		if err != nil {
+			t.Fatal(err)
+		}
+		o = NewMPOpts()
+		o.Algorithm = AlgoPreSCRIMP
+		o.NJobs = 3
+		o.SCRIMP = &SCRIMPOpts{Stride: d.stride}
+		if err = mp.Compute(o); err != nil {
+			t.Fatal(err)
+		}
+
+		// a stride of 1 computes every distance profile
+		for i := range exact.MP {
+			if d.exact && math.Abs(mp.MP[i]-exact.MP[i]) > 1e-6 {
+				t.Errorf("Expected %.6f at %d, but got %.6f with stride %d", exact.MP[i], i, mp.MP[i], d.stride)
+				break
+			}
+			if math.IsInf(mp.MP[i], 0) || mp.MP[i] < exact.MP[i]-1e-6 {
+				t.Errorf("Expected an upper bound of %.6f at %d, but got %.6f with stride %d", exact.MP[i], i, mp.MP[i], d.stride)
+				break
+			}
+		}
+	}
+}
+
+func TestComputeScrimpABJoin(t *testing.T) {
+	for _, algo := range []Algo{AlgoSCRIMP, AlgoPreSCRIMP} {
+		mp, err := New(siggen.Noise(1, 100), siggen.Noise(1, 100), 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		o := NewMPOpts()
+		o.Algorithm = algo
+		if err = mp.Compute(o); err == nil {
+			t.Errorf("Expected an error for an AB join with %s", algo)
+		}
+	}
+}
@@ -0,0 +1,420 @@
+package matrixprofile
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+
+	"github.com/matrix-profile-foundation/go-matrixprofile/util"
+)
+
+// Match is a single result of a similarity search holding the starting index of
+// the matched subsequence and its z-normalized euclidean distance to the query.
+type Match struct {
+	Idx  int
+	Dist float64
+}
+
+// SearchIndex holds precomputed data about all subsequences of a timeseries so
+// that many queries can be run against it. The sliding mean and standard deviation
+// and the fourier transform of the timeseries are computed once when the index is
+// created instead of once per query. A SearchIndex is safe for concurrent use.
+type SearchIndex struct {
+	T             []float64 // timeseries to search
+	W             int       // length of a query
+	ExclusionZone int       // minimum distance between the starting indices of two matches
+
+	mean    []float64
+	std     []float64
+	tf      []complex128
+	fftPool sync.Pool
+}
+
+// NewSearchIndex creates a search index over the subsequences of length w of the
+// timeseries t.
+func NewSearchIndex(t []float64, w int) (*SearchIndex, error) {
+	if len(t) == 0 {
+		return nil, fmt.Errorf("slice is nil or has a length of 0")
+	}
+	if w < 2 {
+		return nil, fmt.Errorf("subsequence length must be at least 2")
+	}
+	if w > len(t) {
+		return nil, fmt.Errorf("subsequence length must be less than the timeseries")
+	}
+
+	s := &SearchIndex{
+		T:             t,
+		W:             w,
+		ExclusionZone: w / 2,
+	}
+
+	var err error
+	s.mean, s.std, err = movMeanStd(t, w)
+	if err != nil {
+		return nil, err
+	}
+
+	// the timeseries is zero padded to a length the fft handles quickly
+	n := util.FFTLen(len(t))
+	s.fftPool.New = func() interface{} {
+		return newFFTCache(n)
+	}
+	fft := s.fftPool.Get().(*fftCache)
+	s.tf = fft.coefficients(t)
+	s.fftPool.Put(fft)
+
+	return s, nil
+}
+
+// movMeanStd computes the sliding mean and standard deviation of t with a window of
+// w. The moving standard deviation is computed from cumulative sums, so a flat window
+// can come out as a small non zero value or NaN rather than exactly zero. These are
+// recomputed directly from the window.
+func movMeanStd(t []float64, w int) ([]float64, []float64, error) {
+	mean, std, err := util.MovMeanStd(t, w)
+	if err != nil {
+		return nil, nil, err
+	}
+	for i := range std {
+		if math.IsNaN(std[i]) || std[i] < 1e-6*math.Max(1, math.Abs(mean[i])) {
+			mean[i], std[i] = windowMeanStd(t[i : i+w])
+		}
+	}
+	return mean, std, nil
+}
+
+// windowMeanStd computes the mean and population standard deviation of a window
+// with two passes over the data.
+func windowMeanStd(ts []float64) (float64, float64) {
+	var mean, v float64
+	for _, x := range ts {
+		mean += x
+	}
+	mean /= float64(len(ts))
+	for _, x := range ts {
+		v += (x - mean) * (x - mean)
+	}
+	return mean, math.Sqrt(v / float64(len(ts)))
+}
+
+// DistanceProfile computes the z-normalized euclidean distance between the query
+// and every subsequence of the indexed timeseries. Subsequences with a standard
+// deviation of zero are given a distance of +Inf.
+func (s *SearchIndex) DistanceProfile(q []float64) ([]float64, error) {
+	if len(q) != s.W {
+		return nil, fmt.Errorf("query length, %d, does not match the index subsequence length, %d", len(q), s.W)
+	}
+
+	qnorm, err := util.ZNormalize(q)
+	if err != nil {
+		return nil, err
+	}
+
+	fft := s.fftPool.Get().(*fftCache)
+	defer s.fftPool.Put(fft)
+	dot := fft.correlate(qnorm, s.tf)
+
+	profile := make([]float64, len(s.T)-s.W+1)
+	for i := range profile {
+		if s.std[i] == 0 {
+			profile[i] = math.Inf(1)
+			continue
+		}
+		profile[i] = math.Sqrt(math.Abs(2 * (float64(s.W) - dot[i]/s.std[i])))
+	}
+	return profile, nil
+}
+
+// MASS computes the z-normalized euclidean distance between the query and every
+// subsequence of t as long as the query, following MASS V3. The timeseries is split
+// into overlapping pieces of pieceLen points, each correlated with the query through a
+// fourier transform of the piece length, so the cost grows as n*log(pieceLen) rather
+// than n*log(n) and the buffers don't depend on the length of t. A pieceLen of 0 picks
+// a length several times the query length. Subsequences with a standard deviation of
+// zero are given a distance of +Inf. To run many queries against the same timeseries,
+// a SearchIndex avoids transforming it for every query.
+func MASS(q, t []float64, pieceLen int) ([]float64, error) {
+	m := len(q)
+	if m < 2 {
+		return nil, fmt.Errorf("query length must be at least 2")
+	}
+	if m > len(t) {
+		return nil, fmt.Errorf("query length, %d, must be at most the timeseries length, %d", m, len(t))
+	}
+	if pieceLen == 0 {
+		pieceLen = 8 * m
+		if pieceLen < 1024 {
+			pieceLen = 1024
+		}
+	}
+	if pieceLen < m {
+		return nil, fmt.Errorf("piece length, %d, must be at least the query length, %d", pieceLen, m)
+	}
+
+	qnorm, err := util.ZNormalize(q)
+	if err != nil {
+		return nil, err
+	}
+
+	// pieces are grown to the transform length so none of it is wasted on padding,
+	// but never past the timeseries
+	k := util.FFTLen(pieceLen)
+	if n := util.FFTLen(len(t)); k > n {
+		k = n
+	}
+	fft := newFFTCache(k)
+	qf := fft.querySpectrum(qnorm, nil)
+	pad := make([]float64, k)
+	tf := make([]complex128, k/2+1)
+
+	profile := make([]float64, len(t)-m+1)
+	// consecutive pieces overlap by m-1 points so every subsequence is within one
+	for start := 0; start < len(profile); start += k - m + 1 {
+		end := start + k
+		if end > len(t) {
+			end = len(t)
+		}
+		piece := t[start:end]
+		_, std, err := movMeanStd(piece, m)
+		if err != nil {
+			return nil, err
+		}
+
+		for i := copy(pad, piece); i < k; i++ {
+			pad[i] = 0
+		}
+		dot := fft.correlateSpectrum(qf, m, fft.fft.Coefficients(tf, pad))
+		for i := range std {
+			if std[i] == 0 {
+				profile[start+i] = math.Inf(1)
+				continue
+			}
+			profile[start+i] = math.Sqrt(math.Abs(2 * (float64(m) - dot[i]/std[i])))
+		}
+	}
+	return profile, nil
+}
+
+// GappedDistanceProfile computes the z-normalized euclidean distance between the query
+// and every subsequence of t as long as the query, where NaN points of the query are
+// "don't care" gaps left out of the comparison. Both the query and each subsequence are
+// z-normalized over the points outside of the gaps only, so a middle segment that is
+// irrelevant or varies between occurrences has no effect on the distance. The query
+// must have at least two points outside of the gaps and they must not be constant.
+// Subsequences that are constant outside of the gaps are given a distance of +Inf.
+func GappedDistanceProfile(q, t []float64) ([]float64, error) {
+	m := len(q)
+	if m > len(t) {
+		return nil, fmt.Errorf("query length, %d, must be at most the timeseries length, %d", m, len(t))
+	}
+
+	// mask holds 1 for the points of the query that are compared and 0 for the gaps,
+	// and known the compared points themselves
+	mask := make([]float64, m)
+	known := make([]float64, 0, m)
+	for i, v := range q {
+		if !math.IsNaN(v) {
+			mask[i] = 1
+			known = append(known, v)
+		}
+	}
+	if len(known) < 2 {
+		return nil, fmt.Errorf("query must have at least 2 points outside of the gaps, got %d", len(known))
+	}
+	knownNorm, err := util.ZNormalize(known)
+	if err != nil {
+		return nil, err
+	}
+	qnorm := make([]float64, m)
+	for i, k := 0, 0; i < m; i++ {
+		if mask[i] == 1 {
+			qnorm[i] = knownNorm[k]
+			k++
+		}
+	}
+
+	// the sums and sums of squares of the points of each subsequence outside of the
+	// gaps are sliding dot products of the mask with the timeseries and its square
+	sq := make([]float64, len(t))
+	for i, v := range t {
+		sq[i] = v * v
+	}
+	fft := newFFTCache(util.FFTLen(len(t)))
+	tf := fft.coefficients(t)
+	n := len(t) - m + 1
+	sum := append([]float64(nil), fft.correlate(mask, tf)[:n]...)
+	sumSq := append([]float64(nil), fft.correlate(mask, fft.coefficients(sq))[:n]...)
+	dot := fft.correlate(qnorm, tf)[:n]
+
+	c := float64(len(known))
+	profile := make([]float64, n)
+	for i := range profile {
+		mean := sum[i] / c
+		std := math.Sqrt(math.Max(sumSq[i]/c-mean*mean, 0))
+		// like the moving standard deviation, a subsequence that is constant outside
+		// of the gaps can come out as a small non zero value, so it is recomputed
+		if std < 1e-6*math.Max(1, math.Abs(mean)) {
+			std = gappedStd(t[i:i+m], mask)
+		}
+		if std == 0 {
+			profile[i] = math.Inf(1)
+			continue
+		}
+		// the normalized query sums to 0 outside of the gaps and is 0 in them, so its
+		// dot product with the raw subsequence equals the one with the mean removed
+		profile[i] = math.Sqrt(math.Abs(2 * (c - dot[i]/std)))
+	}
+	return profile, nil
+}
+
+// gappedStd computes the population standard deviation of the points of a subsequence
+// where the mask is 1.
+func gappedStd(sub, mask []float64) float64 {
+	var mean, v, c float64
+	for i, x := range sub {
+		mean += mask[i] * x
+		c += mask[i]
+	}
+	mean /= c
+	for i, x := range sub {
+		v += mask[i] * (x - mean) * (x - mean)
+	}
+	return math.Sqrt(v / c)
+}
+
+// TopKMatches returns the k subsequences of the indexed timeseries closest to the
+// query sorted by ascending distance. An exclusion zone is applied around each
+// match found so that trivially shifted copies of the same match are not returned.
+func (s *SearchIndex) TopKMatches(q []float64, k int) ([]Match, error) {
+	if k < 1 {
+		return nil, fmt.Errorf("must request at least 1 match, got %d", k)
+	}
+
+	profile, err := s.DistanceProfile(q)
+	if err != nil {
+		return nil, err
+	}
+
+	return topKMatches(profile, k, s.ExclusionZone), nil
+}
+
+// topKMatches picks the k lowest finite values out of a distance profile applying
+// an exclusion zone around each pick.
+func topKMatches(profile []float64, k, exclusionZone int) []Match {
+	order := make([]int, len(profile))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return profile[order[i]] < profile[order[j]]
+	})
+
+	excluded := make([]bool, len(profile))
+	matches := make([]Match, 0, k)
+	for _, idx := range order {
+		if len(matches) == k || math.IsInf(profile[idx], 1) || math.IsNaN(profile[idx]) {
+			break
+		}
+		if excluded[idx] {
+			continue
+		}
+		matches = append(matches, Match{Idx: idx, Dist: profile[idx]})
+
+		start, end := idx-exclusionZone, idx+exclusionZone
+		if start < 0 {
+			start = 0
+		}
+		if end > len(profile)-1 {
+			end = len(profile) - 1
+		}
+		for i := start; i <= end; i++ {
+			excluded[i] = true
+		}
+	}
+	return matches
+}
+
+// MPDistProfile computes the matrix profile distance, as in MPDist, between the query
+// and each window of the series as long as the query, starting every step points. The
+// distance profile of each subsequence of length w of the query against the series is
+// computed once, and the two joins of each window follow from sliding minimums over
+// them, so a query made up of several patterns is found regardless of their order.
+// Flat subsequences have a distance of +Inf.
+func MPDistProfile(query, series []float64, w, step int) ([]float64, error) {
+	if step < 1 {
+		return nil, fmt.Errorf("step, %d, must be at least 1", step)
+	}
+	if w > len(query) {
+		return nil, fmt.Errorf("subsequence length must be less than the query")
+	}
+	if len(query) > len(series) {
+		return nil, fmt.Errorf("query length, %d, must be at most the series length, %d", len(query), len(series))
+	}
+	s, err := NewSearchIndex(series, w)
+	if err != nil {
+		return nil, err
+	}
+
+	nq := len(query) - w + 1
+	ns := len(series) - w + 1
+	windows := (len(series)-len(query))/step + 1
+
+	// rowMin holds the nearest neighbor of each query subsequence within each window
+	// and colMin the nearest neighbor of each series subsequence within the query,
+	// which is the same for every window holding it
+	rowMin := make([][]float64, windows)
+	for k := range rowMin {
+		rowMin[k] = make([]float64, nq)
+	}
+	colMin := make([]float64, ns)
+	for j := range colMin {
+		colMin[j] = math.Inf(1)
+	}
+
+	deque := make([]int, 0, nq)
+	for i := 0; i < nq; i++ {
+		profile, err := s.DistanceProfile(query[i : i+w])
+		if err != nil {
+			profile = make([]float64, ns)
+			for j := range profile {
+				profile[j] = math.Inf(1)
+			}
+		}
+		for j, d := range profile {
+			colMin[j] = math.Min(colMin[j], d)
+		}
+
+		// a monotonic deque of indices gives the minimum of each window of nq values
+		deque = deque[:0]
+		for j, d := range profile {
+			for len(deque) > 0 && profile[deque[len(deque)-1]] >= d {
+				deque = deque[:len(deque)-1]
+			}
+			deque = append(deque, j)
+			start := j - nq + 1
+			if deque[0] < start {
+				deque = deque[1:]
+			}
+			if start >= 0 && start%step == 0 && start/step < windows {
+				rowMin[start/step][i] = profile[deque[0]]
+			}
+		}
+	}
+
+	k := int(mpdistThresh * float64(2*len(query)))
+	out := make([]float64, windows)
+	abba := make([]float64, 2*nq)
+	for win := range out {
+		copy(abba, rowMin[win])
+		copy(abba[nq:], colMin[win*step:win*step+nq])
+		sort.Float64s(abba)
+		if k < len(abba) {
+			out[win] = abba[k]
+		} else {
+			out[win] = abba[len(abba)-1]
+		}
+	}
+	return out, nil
+}
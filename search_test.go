@@ -0,0 +1,325 @@
+package matrixprofile
+
+import (
+	"math"
+	"sync"
+	"testing"
+
+	"github.com/matrix-profile-foundation/go-matrixprofile/siggen"
+	"github.com/matrix-profile-foundation/go-matrixprofile/util"
+)
+
+func bruteDistanceProfile(q, t []float64) []float64 {
+	qn, _ := util.ZNormalize(q)
+	out := make([]float64, len(t)-len(q)+1)
+	for i := range out {
+		tn, err := util.ZNormalize(t[i : i+len(q)])
+		if err != nil {
+			out[i] = math.Inf(1)
+			continue
+		}
+		var d float64
+		for j := range qn {
+			d += (qn[j] - tn[j]) * (qn[j] - tn[j])
+		}
+		out[i] = math.Sqrt(d)
+	}
+	return out
+}
+
+func TestNewSearchIndex(t *testing.T) {
+	testdata := []struct {
+		t           []float64
+		w           int
+		expectedErr bool
+	}{
+		{[]float64{}, 2, true},
+		{[]float64{1, 2, 3, 4}, 1, true},
+		{[]float64{1, 2, 3, 4}, 5, true},
+		{[]float64{1, 2, 3, 4}, 4, false},
+	}
+
+	for _, d := range testdata {
+		_, err := NewSearchIndex(d.t, d.w)
+		if d.expectedErr && err == nil {
+			t.Errorf("Expected an error, but got none for %v", d)
+		}
+		if !d.expectedErr && err != nil {
+			t.Errorf("Expected no error, but got %v for %v", err, d)
+		}
+	}
+}
+
+func TestSearchIndexDistanceProfile(t *testing.T) {
+	sig := siggen.Add(siggen.Sin(1, 3, 0, 0, 100, 3), siggen.Noise(0.3, 300))
+	sig = append(sig, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1)
+
+	s, err := NewSearchIndex(sig, 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	queries := [][]float64{
+		sig[10:18],
+		sig[150:158],
+		{1, 2, 3, 4, 5, 6, 7, 8},
+	}
+	for _, q := range queries {
+		profile, err := s.DistanceProfile(q)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := bruteDistanceProfile(q, sig)
+		if len(profile) != len(expected) {
+			t.Fatalf("Expected %d elements, but got %d", len(expected), len(profile))
+		}
+		for i := range profile {
+			if math.IsInf(expected[i], 1) != math.IsInf(profile[i], 1) || (!math.IsInf(expected[i], 1) && math.Abs(profile[i]-expected[i]) > 1e-6) {
+				t.Errorf("Expected %.6f at index %d, but got %.6f", expected[i], i, profile[i])
+				break
+			}
+		}
+	}
+
+	if _, err = s.DistanceProfile(sig[:4]); err == nil {
+		t.Errorf("Expected an error for a query of the wrong length")
+	}
+	if _, err = s.DistanceProfile([]float64{1, 1, 1, 1, 1, 1, 1, 1}); err == nil {
+		t.Errorf("Expected an error for a constant query")
+	}
+}
+
+func TestMASS(t *testing.T) {
+	sig := siggen.Add(siggen.Sin(1, 3, 0, 0, 100, 30), siggen.Noise(0.3, 3000))
+	sig = append(sig, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1)
+	sig = append(sig, siggen.Noise(0.3, 500)...)
+
+	testdata := []struct {
+		q           []float64
+		pieceLen    int
+		expectedErr bool
+	}{
+		{sig[10:18], 0, false},
+		{sig[10:18], 8, false},
+		{sig[10:18], 9, false},
+		{sig[150:250], 100, false},
+		{sig[150:250], 333, false},
+		{sig[150:250], 0, false},
+		{sig[1000:2200], 0, false},
+		{sig, 0, false},
+		{sig[150:250], 99, true},
+		{sig[10:11], 0, true},
+		{[]float64{1, 1, 1, 1}, 0, true},
+		{append(sig, 1), 0, true},
+	}
+
+	for i, d := range testdata {
+		profile, err := MASS(d.q, sig, d.pieceLen)
+		if d.expectedErr {
+			if err == nil {
+				t.Errorf("Expected an error for case %d", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("Did not expect an error, %v, for case %d", err, i)
+		}
+
+		expected := bruteDistanceProfile(d.q, sig)
+		if len(profile) != len(expected) {
+			t.Fatalf("Expected %d elements, but got %d for case %d", len(expected), len(profile), i)
+		}
+		// squared distances are compared since the square root magnifies rounding
+		// errors of exact matches
+		for j := range profile {
+			if math.IsInf(expected[j], 1) != math.IsInf(profile[j], 1) || (!math.IsInf(expected[j], 1) && math.Abs(profile[j]*profile[j]-expected[j]*expected[j]) > 1e-6) {
+				t.Errorf("Expected %.6f at index %d, but got %.6f for case %d", expected[j], j, profile[j], i)
+				break
+			}
+		}
+	}
+}
+
+func TestGappedDistanceProfile(t *testing.T) {
+	// three occurrences of a shape whose middle segment differs each time
+	sig := siggen.Noise(0.5, 600)
+	starts := []int{50, 250, 450}
+	for k, start := range starts {
+		for i := 0; i < 60; i++ {
+			switch {
+			case i < 20:
+				sig[start+i] += 4 * math.Sin(float64(i)*math.Pi/10)
+			case i < 40:
+				sig[start+i] += float64(k*3) * math.Cos(float64(i))
+			default:
+				sig[start+i] += float64(i-40) / 2
+			}
+		}
+	}
+	q := make([]float64, 60)
+	copy(q, sig[250:310])
+	for i := 20; i < 40; i++ {
+		q[i] = math.NaN()
+	}
+
+	profile, err := GappedDistanceProfile(q, sig)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// compare with z-normalizing only the points outside of the gap
+	known := func(sub []float64) []float64 {
+		out := append([]float64{}, sub[:20]...)
+		return append(out, sub[40:]...)
+	}
+	if len(profile) != len(sig)-59 {
+		t.Fatalf("Expected %d elements, but got %d", len(sig)-59, len(profile))
+	}
+	for i := range profile {
+		e := bruteDistanceProfile(known(q), known(sig[i:i+60]))[0]
+		if math.Abs(profile[i]*profile[i]-e*e) > 1e-6 {
+			t.Fatalf("Expected %.6f at index %d, but got %.6f", e, i, profile[i])
+		}
+	}
+	matches := topKMatches(profile, 3, 30)
+	for k, m := range matches {
+		if m.Idx < starts[0]-2 || (m.Idx > starts[0]+2 && m.Idx < starts[1]-2) || (m.Idx > starts[1]+2 && m.Idx < starts[2]-2) || m.Idx > starts[2]+2 {
+			t.Errorf("Expected match %d, %d, to be at one of the occurrences %v", k, m.Idx, starts)
+		}
+	}
+
+	testdata := [][]float64{
+		{math.NaN(), 1, math.NaN()},
+		{1, 1, math.NaN(), 1},
+		make([]float64, 601),
+	}
+	for _, q := range testdata {
+		if _, err = GappedDistanceProfile(q, sig); err == nil {
+			t.Errorf("Expected an error for query %v", q[:3])
+		}
+	}
+
+	// a timeseries that is constant outside of the gaps is never matched
+	flat := []float64{1, 1, 5, 1, 1, 2, 3}
+	profile, err = GappedDistanceProfile([]float64{0, 1, math.NaN(), 3}, flat)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !math.IsInf(profile[0], 1) || math.IsInf(profile[3], 1) {
+		t.Errorf("Expected only a distance of +Inf for the flat subsequence, but got %v", profile)
+	}
+}
+
+func TestTopKMatches(t *testing.T) {
+	pattern := []float64{0, 1, 3, 7, 3, 1, 0, -1}
+	sig := siggen.Noise(0.1, 200)
+	for _, idx := range []int{20, 90, 160} {
+		for i, v := range pattern {
+			sig[idx+i] += v
+		}
+	}
+
+	s, err := NewSearchIndex(sig, len(pattern))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	matches, err := s.TopKMatches(pattern, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(matches) != 3 {
+		t.Fatalf("Expected 3 matches, but got %d", len(matches))
+	}
+	found := map[int]bool{}
+	for i, m := range matches {
+		found[m.Idx] = true
+		if i > 0 && m.Dist < matches[i-1].Dist {
+			t.Errorf("Expected matches sorted by distance, but got %v", matches)
+		}
+	}
+	for _, idx := range []int{20, 90, 160} {
+		if !found[idx] {
+			t.Errorf("Expected a match at index %d, but got %v", idx, matches)
+		}
+	}
+
+	if _, err = s.TopKMatches(pattern, 0); err == nil {
+		t.Errorf("Expected an error for k of 0")
+	}
+
+	// run queries concurrently against the same index
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := s.TopKMatches(pattern, 2); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestMPDistProfile(t *testing.T) {
+	sine := siggen.Sin(1, 10, 0, 0, 100, 0.5)
+	saw := siggen.Sawtooth(1, 10, 0, 0, 100, 0.5)
+	query := append(append([]float64(nil), sine...), saw...)
+
+	// the two patterns of the query appear in the opposite order in the series
+	series := seededNoise(1, 0.5, 600)
+	for i := range sine {
+		series[300+i] += saw[i]
+		series[350+i] += sine[i]
+	}
+	// only a few subsequences this long fit within each pattern, so a window holding just
+	// one of them has fewer close matches than MPdist needs, unlike the aligned window
+	w := 40
+
+	for _, d := range []struct {
+		q      []float64
+		w      int
+		step   int
+		errMsg string
+	}{
+		{query, w, 0, "a step of 0"},
+		{query, 101, 1, "a subsequence longer than the query"},
+		{siggen.Noise(1, 700), w, 1, "a query longer than the series"},
+		{query, 1, 1, "a subsequence length of 1"},
+	} {
+		if _, err := MPDistProfile(d.q, series, d.w, d.step); err == nil {
+			t.Errorf("Expected an error for %s", d.errMsg)
+		}
+	}
+
+	for _, step := range []int{1, 7} {
+		profile, err := MPDistProfile(query, series, w, step)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(profile) != (len(series)-len(query))/step+1 {
+			t.Fatalf("Expected %d windows, but got %d", (len(series)-len(query))/step+1, len(profile))
+		}
+
+		best := 0
+		for i, d := range profile {
+			if d < profile[best] {
+				best = i
+			}
+			if i%10 != 0 {
+				continue
+			}
+			expected, err := MPDist(query, series[i*step:i*step+len(query)], w, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if math.Abs(d-expected) > 1e-6 {
+				t.Errorf("Expected %.6f at window %d, but got %.6f for step %d", expected, i, d, step)
+			}
+		}
+		if math.Abs(float64(best*step-300)) > 10 {
+			t.Errorf("Expected the closest window near 300, but got %d for step %d", best*step, step)
+		}
+	}
+}
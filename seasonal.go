@@ -0,0 +1,113 @@
+package matrixprofile
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/matrix-profile-foundation/go-matrixprofile/util"
+)
+
+// validatePeriod checks that a period set in the options applies to a self join and
+// leaves matches between its periodic exclusion zones.
+func (mp MatrixProfile) validatePeriod(zone int) error {
+	if mp.Opts == nil || mp.Opts.Period <= 0 {
+		return nil
+	}
+	if !mp.SelfJoin {
+		return errors.New("periodic exclusion zones are only supported for self joins")
+	}
+	if mp.Opts.Period <= 2*zone {
+		return fmt.Errorf("period, %d, must be more than twice the exclusion zone, %d", mp.Opts.Period, zone)
+	}
+	return nil
+}
+
+// periodicMatch reports whether the subsequence at j lags the subsequence at i by a
+// nonzero multiple of the period of the options, give or take the exclusion zone. In
+// circular self joins the lag also wraps around the end of the timeseries.
+func (mp MatrixProfile) periodicMatch(i, j, zone int) bool {
+	p := mp.Opts.Period
+	lags := []int{j - i}
+	if mp.circular() {
+		n := len(mp.A) - mp.W + 1
+		lags = append(lags, j-i+n, j-i-n)
+	}
+	for _, lag := range lags {
+		// the nearest multiples of the period below and above the lag
+		off := ((lag % p) + p) % p
+		k := (lag - off) / p
+		if k != 0 && off < zone || k+1 != 0 && p-off <= zone {
+			return true
+		}
+	}
+	return false
+}
+
+// applyPeriodicZone sets the matches of the subsequence at idx lagging it by a nonzero
+// multiple of the period of the options to +Inf, with the same zone around each
+// multiple as the trivial match exclusion zone.
+func (mp MatrixProfile) applyPeriodicZone(profile []float64, idx, zone int) {
+	p := mp.Opts.Period
+	for lag := p; lag-zone < len(profile); lag += p {
+		mp.applyExclusionZone(profile, idx+lag, 0, zone)
+		mp.applyExclusionZone(profile, idx-lag, 0, zone)
+	}
+}
+
+// handlePeriod excludes the matches at multiples of the period of the options from the
+// nearest neighbors of a self join, so a seasonal timeseries is profiled by how each
+// subsequence compares to other phases of the season rather than to the same phase of
+// other cycles. Excluding matches only raises matrix profile values, so just the rows
+// whose nearest neighbor falls in a periodic exclusion zone are recomputed, with zone
+// as both the trivial match and the periodic exclusion zone.
+func (mp *MatrixProfile) handlePeriod(zone int) error {
+	if mp.Opts == nil || mp.Opts.Period <= 0 {
+		return nil
+	}
+
+	n := len(mp.MP)
+	var rows []int
+	for i := 0; i < n; i++ {
+		if mp.Idx[i] >= 0 && mp.Idx[i] < n && mp.periodicMatch(i, mp.Idx[i], zone) {
+			rows = append(rows, i)
+		}
+	}
+	if len(rows) == 0 {
+		return nil
+	}
+
+	if err := mp.initCaches(); err != nil {
+		return err
+	}
+	// the trivial matches are excluded below with the zone of the algorithm applied
+	view := *mp
+	view.SelfJoin = false
+	euclidean := mp.Opts.Euclidean
+	fft := mp.newFFT()
+	profile := make([]float64, n)
+	for _, i := range rows {
+		if err := view.distanceProfile(i, profile, fft); err != nil {
+			return err
+		}
+		mp.applyExclusionZone(profile, i, 0, zone)
+		mp.applyPeriodicZone(profile, i, zone)
+		if !euclidean {
+			util.E2P(profile, mp.W)
+		}
+
+		mp.MP[i], mp.Idx[i] = math.Inf(1), math.MaxInt64
+		if !euclidean {
+			mp.MP[i] = math.Inf(-1)
+		}
+		for j, d := range profile {
+			if !math.IsInf(d, 0) && !math.IsNaN(d) && isBetterMatch(d, j, mp.MP[i], mp.Idx[i], euclidean) {
+				mp.MP[i], mp.Idx[i] = d, j
+			}
+		}
+		if mp.Idx[i] == math.MaxInt64 {
+			mp.MP[i] = math.Inf(1)
+		}
+	}
+	return nil
+}
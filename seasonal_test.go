@@ -0,0 +1,141 @@
+package matrixprofile
+
+import (
+	"math"
+	"testing"
+
+	"github.com/matrix-profile-foundation/go-matrixprofile/siggen"
+	"github.com/matrix-profile-foundation/go-matrixprofile/util"
+)
+
+func TestComputePeriod(t *testing.T) {
+	testdata := []struct {
+		name        string
+		selfJoin    bool
+		w           int
+		period      int
+		flat        FlatMode
+		expectedErr bool
+	}{
+		{"negative period", true, 20, -1, FlatNone, true},
+		{"ab join", false, 20, 60, FlatNone, true},
+		{"period within the exclusion zone", true, 20, 10, FlatNone, true},
+		{"recomputed flat subsequences", true, 20, 60, FlatFloor, true},
+		{"no period", true, 20, 0, FlatNone, false},
+		{"period", true, 20, 60, FlatNone, false},
+	}
+
+	a := siggen.Noise(0.1, 300)
+	for _, d := range testdata {
+		var b []float64
+		if !d.selfJoin {
+			b = a[:200]
+		}
+		mp, err := New(a, b, d.w)
+		if err != nil {
+			t.Fatal(err)
+		}
+		o := NewMPOpts()
+		o.Period = d.period
+		o.Flat = d.flat
+		err = mp.Compute(o)
+		if err != nil && !d.expectedErr {
+			t.Errorf("%s: did not expect an error, %v", d.name, err)
+		}
+		if err == nil && d.expectedErr {
+			t.Errorf("%s: expected an error", d.name)
+		}
+	}
+
+	// a daily cycle whose closest matches are the same phase of other days
+	period, w := 50, 16
+	day := siggen.Sin(1, 1, 0, 0, float64(period), 1)
+	a = siggen.Noise(0.05, 10*period)
+	for i := range a {
+		a[i] += day[i%period]
+	}
+
+	n := len(a) - w + 1
+	brute := func(zone int, euclidean bool) []float64 {
+		out := make([]float64, n)
+		for i := range out {
+			profile := bruteDistanceProfile(a[i:i+w], a)
+			util.ApplyExclusionZone(profile, i, zone)
+			for lag := period; lag-zone < n; lag += period {
+				util.ApplyExclusionZone(profile, i+lag, zone)
+				util.ApplyExclusionZone(profile, i-lag, zone)
+			}
+			out[i] = math.Inf(1)
+			for _, v := range profile {
+				out[i] = math.Min(out[i], v)
+			}
+			if !euclidean {
+				out[i] = 1 - out[i]*out[i]/(2*float64(w))
+			}
+		}
+		return out
+	}
+
+	for _, algo := range []Algo{AlgoSTOMP, AlgoSTAMP, AlgoMPX} {
+		for _, euclidean := range []bool{true, false} {
+			mp, err := New(a, nil, w)
+			if err != nil {
+				t.Fatal(err)
+			}
+			o := NewMPOpts()
+			o.Algorithm = algo
+			o.Euclidean = euclidean
+			o.Period = period
+			if err = mp.Compute(o); err != nil {
+				t.Fatal(err)
+			}
+
+			zone := w / 2
+			if algo == AlgoMPX {
+				zone = w / 4
+			}
+			expected := brute(zone, euclidean)
+			for i := range expected {
+				if math.Abs(mp.MP[i]-expected[i]) > 1e-6 {
+					t.Errorf("%s, euclidean %t: expected %.4f at index %d, but got %.4f", algo, euclidean, expected[i], i, mp.MP[i])
+					break
+				}
+				if mp.periodicMatch(i, mp.Idx[i], zone) || int(math.Abs(float64(mp.Idx[i]-i))) < zone {
+					t.Errorf("%s, euclidean %t: expected the match of index %d, %d, outside the exclusion zones", algo, euclidean, i, mp.Idx[i])
+					break
+				}
+			}
+		}
+	}
+}
+
+func TestPeriodicMatch(t *testing.T) {
+	testdata := []struct {
+		i, j     int
+		circular bool
+		expected bool
+	}{
+		{100, 100, false, false},
+		{100, 105, false, false},
+		{100, 150, false, true},
+		{100, 145, false, true},
+		{100, 144, false, false},
+		{100, 154, false, true},
+		{100, 155, false, false},
+		{100, 50, false, true},
+		{100, 203, false, true},
+		{100, 175, false, false},
+		{10, 170, false, false},
+		{10, 170, true, true},
+	}
+
+	// 210 subsequences with a zone of 5 around multiples of 50
+	mp := MatrixProfile{A: make([]float64, 219), W: 10, SelfJoin: true, Opts: NewMPOpts()}
+	mp.Opts.Period = 50
+	for _, d := range testdata {
+		mp.Opts.Circular = d.circular
+		if out := mp.periodicMatch(d.i, d.j, 5); out != d.expected {
+			t.Errorf("expected %t for %d and %d with circular %t, but got %t", d.expected, d.i, d.j, d.circular, out)
+		}
+	}
+}
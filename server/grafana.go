@@ -0,0 +1,282 @@
+// Package server exposes matrix profiles over HTTP so they can be consumed by
+// dashboards and other services.
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	mp "github.com/matrix-profile-foundation/go-matrixprofile"
+)
+
+// profileSuffix selects the matrix profile of a series rather than its raw values in a
+// Grafana target name.
+const profileSuffix = ":mp"
+
+// series is a snapshot of a timeseries and its matrix profile served to Grafana.
+type series struct {
+	start    time.Time
+	step     time.Duration
+	values   []float64
+	profile  []float64
+	w        int
+	discords []int
+}
+
+// time returns the timestamp of the point at index i.
+func (s series) time(i int) time.Time {
+	return s.start.Add(time.Duration(i) * s.step)
+}
+
+// Grafana serves matrix profiles following the Grafana simple JSON datasource
+// contract. Each named series is offered as two targets, the raw values under its
+// name and the matrix profile under its name with a ":mp" suffix, and its top
+// discords are offered as annotations so they can be drawn next to the raw metric.
+type Grafana struct {
+	DiscordK int // number of discords offered as annotations for each series. Defaults to 3
+
+	mu     sync.RWMutex
+	series map[string]series
+}
+
+// NewGrafana returns a Grafana datasource without any series.
+func NewGrafana() *Grafana {
+	return &Grafana{
+		DiscordK: 3,
+		series:   make(map[string]series),
+	}
+}
+
+// Set publishes a snapshot of a computed matrix profile under a name, where start is
+// the time of the first point of the self join timeseries and step is the time
+// between points. Set must be called again after the matrix profile is updated for the
+// new values to be served.
+func (g *Grafana) Set(name string, p *mp.MatrixProfile, start time.Time, step time.Duration) error {
+	if name == "" || strings.HasSuffix(name, profileSuffix) {
+		return fmt.Errorf("series name, %q, must be non empty and not end with %s", name, profileSuffix)
+	}
+	if p == nil || p.MP == nil {
+		return errors.New("matrix profile has not been computed")
+	}
+	if step <= 0 {
+		return fmt.Errorf("step, %v, must be positive", step)
+	}
+
+	s := series{
+		start:   start.Add(time.Duration(p.Offset) * step),
+		step:    step,
+		values:  append([]float64(nil), p.A...),
+		profile: append([]float64(nil), p.MP...),
+		w:       p.W,
+	}
+
+	// discovering discords overwrites the discords held by the matrix profile
+	view := *p
+	discords, err := view.DiscoverDiscords(g.DiscordK, p.W/2)
+	if err != nil {
+		return err
+	}
+	s.discords = discords
+
+	g.mu.Lock()
+	g.series[name] = s
+	g.mu.Unlock()
+	return nil
+}
+
+// Remove stops serving a series.
+func (g *Grafana) Remove(name string) {
+	g.mu.Lock()
+	delete(g.series, name)
+	g.mu.Unlock()
+}
+
+// ServeHTTP implements the endpoints of the simple JSON datasource contract relative
+// to where the handler is mounted.
+func (g *Grafana) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Headers", "accept, content-type")
+	w.Header().Set("Access-Control-Allow-Methods", "POST")
+	if r.Method == http.MethodOptions {
+		return
+	}
+
+	path := strings.TrimSuffix(r.URL.Path, "/")
+	switch {
+	case path == "":
+		// the connection test expects a 200 response
+		w.WriteHeader(http.StatusOK)
+	case strings.HasSuffix(path, "/search"):
+		g.search(w)
+	case strings.HasSuffix(path, "/query"):
+		g.query(w, r)
+	case strings.HasSuffix(path, "/annotations"):
+		g.annotations(w, r)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// timeRange is the range of a dashboard panel.
+type timeRange struct {
+	From time.Time `json:"from"`
+	To   time.Time `json:"to"`
+}
+
+// contains reports whether t falls within the range.
+func (tr timeRange) contains(t time.Time) bool {
+	return !t.Before(tr.From) && !t.After(tr.To)
+}
+
+// queryRequest is the body of a /query request.
+type queryRequest struct {
+	Range   timeRange `json:"range"`
+	Targets []struct {
+		Target string `json:"target"`
+	} `json:"targets"`
+	MaxDataPoints int `json:"maxDataPoints"`
+}
+
+// timeSeries is a single target in a /query response, with each datapoint holding a
+// value and a unix timestamp in milliseconds.
+type timeSeries struct {
+	Target     string       `json:"target"`
+	Datapoints [][2]float64 `json:"datapoints"`
+}
+
+// annotationRequest is the body of an /annotations request.
+type annotationRequest struct {
+	Range      timeRange `json:"range"`
+	Annotation struct {
+		Name  string `json:"name"`
+		Query string `json:"query"`
+	} `json:"annotation"`
+}
+
+// annotation is a single entry of an /annotations response.
+type annotation struct {
+	Annotation interface{} `json:"annotation"`
+	Time       int64       `json:"time"`
+	TimeEnd    int64       `json:"timeEnd"`
+	IsRegion   bool        `json:"isRegion"`
+	Title      string      `json:"title"`
+	Text       string      `json:"text"`
+	Tags       []string    `json:"tags"`
+}
+
+// search lists every target that can be queried.
+func (g *Grafana) search(w http.ResponseWriter) {
+	g.mu.RLock()
+	targets := make([]string, 0, 2*len(g.series))
+	for name := range g.series {
+		targets = append(targets, name, name+profileSuffix)
+	}
+	g.mu.RUnlock()
+
+	sort.Strings(targets)
+	writeJSON(w, targets)
+}
+
+// query returns the datapoints of each target within the requested range.
+func (g *Grafana) query(w http.ResponseWriter, r *http.Request) {
+	var req queryRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf("failed to decode query, %v", err), http.StatusBadRequest)
+		return
+	}
+
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	resp := make([]timeSeries, 0, len(req.Targets))
+	for _, t := range req.Targets {
+		s, ok := g.series[strings.TrimSuffix(t.Target, profileSuffix)]
+		if !ok {
+			http.Error(w, fmt.Sprintf("unknown target, %s", t.Target), http.StatusNotFound)
+			return
+		}
+		values := s.values
+		if strings.HasSuffix(t.Target, profileSuffix) {
+			values = s.profile
+		}
+
+		ts := timeSeries{Target: t.Target, Datapoints: [][2]float64{}}
+		for i, v := range values {
+			at := s.time(i)
+			if !req.Range.contains(at) || math.IsInf(v, 0) || math.IsNaN(v) {
+				continue
+			}
+			ts.Datapoints = append(ts.Datapoints, [2]float64{v, float64(at.UnixNano() / int64(time.Millisecond))})
+		}
+		ts.Datapoints = downsample(ts.Datapoints, req.MaxDataPoints)
+		resp = append(resp, ts)
+	}
+	writeJSON(w, resp)
+}
+
+// downsample keeps every nth datapoint so at most max datapoints are returned. A max
+// of 0 keeps all datapoints.
+func downsample(points [][2]float64, max int) [][2]float64 {
+	if max <= 0 || len(points) <= max {
+		return points
+	}
+	stride := (len(points) + max - 1) / max
+	out := make([][2]float64, 0, max)
+	for i := 0; i < len(points); i += stride {
+		out = append(out, points[i])
+	}
+	return out
+}
+
+// annotations returns the discords of the series named by the annotation query that
+// start within the requested range, each spanning its subsequence.
+func (g *Grafana) annotations(w http.ResponseWriter, r *http.Request) {
+	var req annotationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf("failed to decode annotation query, %v", err), http.StatusBadRequest)
+		return
+	}
+
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	name := strings.TrimSuffix(strings.TrimSpace(req.Annotation.Query), profileSuffix)
+	s, ok := g.series[name]
+	if !ok {
+		http.Error(w, fmt.Sprintf("unknown series, %s", name), http.StatusNotFound)
+		return
+	}
+
+	resp := []annotation{}
+	for rank, idx := range s.discords {
+		start := s.time(idx)
+		if !req.Range.contains(start) {
+			continue
+		}
+		resp = append(resp, annotation{
+			Annotation: req.Annotation,
+			Time:       start.UnixNano() / int64(time.Millisecond),
+			TimeEnd:    s.time(idx+s.w-1).UnixNano() / int64(time.Millisecond),
+			IsRegion:   true,
+			Title:      fmt.Sprintf("discord %d", rank+1),
+			Text:       fmt.Sprintf("matrix profile value %.3f", s.profile[idx]),
+			Tags:       []string{"discord", name},
+		})
+	}
+	writeJSON(w, resp)
+}
+
+// writeJSON writes a value as a JSON response.
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,121 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	mp "github.com/matrix-profile-foundation/go-matrixprofile"
+	"github.com/matrix-profile-foundation/go-matrixprofile/siggen"
+)
+
+func TestGrafana(t *testing.T) {
+	sig := siggen.Add(siggen.Sin(1, 4, 0, 0, 100, 2), siggen.Noise(0.01, 200))
+	for i := 120; i < 130; i++ {
+		sig[i] += 2
+	}
+	p, err := mp.New(sig, nil, 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = p.Compute(nil); err != nil {
+		t.Fatal(err)
+	}
+
+	g := NewGrafana()
+	g.DiscordK = 1
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	if err = g.Set("cpu:mp", p, start, time.Second); err == nil {
+		t.Errorf("Expected an error for a name with the profile suffix")
+	}
+	if err = g.Set("cpu", p, start, time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	srv := httptest.NewServer(g)
+	defer srv.Close()
+
+	post := func(path, body string, out interface{}) int {
+		resp, err := http.Post(srv.URL+path, "application/json", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode == http.StatusOK && out != nil {
+			if err = json.NewDecoder(resp.Body).Decode(out); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return resp.StatusCode
+	}
+
+	if code := post("/", "", nil); code != http.StatusOK {
+		t.Errorf("Expected the connection test to succeed, but got %d", code)
+	}
+
+	var targets []string
+	post("/search", `{"target": ""}`, &targets)
+	if len(targets) != 2 || targets[0] != "cpu" || targets[1] != "cpu:mp" {
+		t.Errorf("Expected the raw and profile targets, but got %v", targets)
+	}
+
+	var series []timeSeries
+	query := `{"range": {"from": "2020-01-01T00:00:10Z", "to": "2020-01-01T00:00:19Z"}, "targets": [{"target": "cpu"}, {"target": "cpu:mp"}]}`
+	post("/query", query, &series)
+	if len(series) != 2 {
+		t.Fatalf("Expected 2 series, but got %d", len(series))
+	}
+	for _, s := range series {
+		if len(s.Datapoints) != 10 {
+			t.Errorf("Expected 10 datapoints for %s, but got %d", s.Target, len(s.Datapoints))
+			continue
+		}
+		if ms := s.Datapoints[0][1]; ms != float64(start.Add(10*time.Second).Unix()*1000) {
+			t.Errorf("Expected the first datapoint of %s at 10s, but got %.0f", s.Target, ms)
+		}
+	}
+	if series[0].Datapoints[0][0] != sig[10] || series[1].Datapoints[0][0] != p.MP[10] {
+		t.Errorf("Expected the raw and profile values at index 10")
+	}
+
+	if code := post("/query", `{"targets": [{"target": "mem"}]}`, nil); code != http.StatusNotFound {
+		t.Errorf("Expected an unknown target to not be found, but got %d", code)
+	}
+
+	var annotations []annotation
+	query = `{"range": {"from": "2020-01-01T00:00:00Z", "to": "2020-01-01T01:00:00Z"}, "annotation": {"name": "discords", "query": "cpu"}}`
+	post("/annotations", query, &annotations)
+	if len(annotations) != 1 {
+		t.Fatalf("Expected 1 annotation, but got %d", len(annotations))
+	}
+	at := time.Unix(0, annotations[0].Time*int64(time.Millisecond)).Sub(start)
+	if at < 100*time.Second || at > 130*time.Second || !annotations[0].IsRegion {
+		t.Errorf("Expected a discord region near the anomaly at 120s, but got %v", at)
+	}
+}
+
+func TestDownsample(t *testing.T) {
+	points := make([][2]float64, 10)
+	for i := range points {
+		points[i][1] = float64(i)
+	}
+
+	testdata := []struct {
+		max      int
+		expected int
+	}{
+		{0, 10},
+		{20, 10},
+		{5, 5},
+		{3, 3},
+	}
+
+	for _, d := range testdata {
+		if out := downsample(points, d.max); len(out) != d.expected {
+			t.Errorf("Expected %d points for a max of %d, but got %d", d.expected, d.max, len(out))
+		}
+	}
+}
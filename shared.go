@@ -0,0 +1,140 @@
+package matrixprofile
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/matrix-profile-foundation/go-matrixprofile/util"
+)
+
+// SharedMotif is a pattern conserved between two timeseries along with where it
+// occurs in each of them.
+type SharedMotif struct {
+	AIdx    []int   // start indices of the occurrences in a
+	BIdx    []int   // start indices of the occurrences in b
+	MinDist float64 // distance of the closest pair between a and b
+}
+
+// SharedMotifs finds the top k patterns that appear in both a and b using an AB join
+// with a subsequence length of w. Each pattern is seeded by the closest remaining pair
+// between a and b, and then grown to every subsequence of a and of b within radius
+// times that pair's distance of the seed in a. Occurrences within half a subsequence
+// length of each other are reported once. Every occurrence in a is excluded from
+// seeding later patterns. Only the MPX algorithm is supported since it indexes the AB
+// join profile by the subsequences of a.
+func SharedMotifs(a, b []float64, w, k int, radius float64, o *MPOpts) ([]SharedMotif, error) {
+	if o == nil {
+		o = NewMPOpts()
+	}
+	if o.Algorithm != AlgoMPX {
+		return nil, fmt.Errorf("SharedMotifs only supports the %s algorithm, got %s", AlgoMPX, o.Algorithm)
+	}
+	if k < 1 {
+		return nil, fmt.Errorf("number of motifs, %d, must be at least 1", k)
+	}
+	if radius < 1 {
+		return nil, fmt.Errorf("radius, %.3f, must be at least 1", radius)
+	}
+
+	ab, err := New(a, b, w)
+	if err != nil {
+		return nil, err
+	}
+	if err = ab.Compute(o); err != nil {
+		return nil, err
+	}
+	aa, err := New(a, nil, w)
+	if err != nil {
+		return nil, err
+	}
+	aa.Opts = o
+	if err = ab.initCaches(); err != nil {
+		return nil, err
+	}
+	if err = aa.initCaches(); err != nil {
+		return nil, err
+	}
+
+	seeds := ab.euclideanMP()
+	ab.applyMask(seeds)
+
+	exclZone := w / 2
+	profA := make([]float64, len(a)-w+1)
+	profB := make([]float64, len(b)-w+1)
+	fftA := aa.newFFT()
+	fftB := ab.newFFT()
+
+	var motifs []SharedMotif
+	for len(motifs) < k {
+		seed := -1
+		for i, d := range seeds {
+			if !math.IsInf(d, 0) && !math.IsNaN(d) && (seed < 0 || d < seeds[seed]) {
+				seed = i
+			}
+		}
+		if seed < 0 {
+			break
+		}
+		maxDist := radius * seeds[seed]
+
+		if err = aa.distanceProfile(seed, profA, fftA); err != nil {
+			return nil, err
+		}
+		profA[seed] = 0
+		if err = ab.distanceProfile(seed, profB, fftB); err != nil {
+			return nil, err
+		}
+		if ab.squared() {
+			for _, prof := range [][]float64{profA, profB} {
+				for i, d := range prof {
+					prof[i] = math.Sqrt(d)
+				}
+			}
+		}
+
+		m := SharedMotif{
+			AIdx:    occurrences(profA, maxDist, exclZone),
+			BIdx:    occurrences(profB, maxDist, exclZone),
+			MinDist: seeds[seed],
+		}
+		motifs = append(motifs, m)
+
+		for _, i := range m.AIdx {
+			util.ApplyExclusionZone(seeds, i, exclZone)
+		}
+		seeds[seed] = math.Inf(1)
+	}
+	return motifs, nil
+}
+
+// occurrences greedily picks the subsequences of a distance profile within maxDist,
+// closest first, skipping any within the exclusion zone of one already picked. The
+// picked indices are returned in ascending order.
+func occurrences(profile []float64, maxDist float64, exclZone int) []int {
+	var candidates []int
+	for i, d := range profile {
+		if d <= maxDist {
+			candidates = append(candidates, i)
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return profile[candidates[i]] < profile[candidates[j]]
+	})
+
+	var picked []int
+	for _, c := range candidates {
+		trivial := false
+		for _, p := range picked {
+			if c-p < exclZone && p-c < exclZone {
+				trivial = true
+				break
+			}
+		}
+		if !trivial {
+			picked = append(picked, c)
+		}
+	}
+	sort.Ints(picked)
+	return picked
+}
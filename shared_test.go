@@ -0,0 +1,71 @@
+package matrixprofile
+
+import (
+	"math"
+	"testing"
+
+	"github.com/matrix-profile-foundation/go-matrixprofile/siggen"
+)
+
+func TestSharedMotifs(t *testing.T) {
+	pattern := siggen.Add(siggen.Sin(3, 7, 0, 0, 100, 0.3), siggen.Line(0.2, 0, 30))
+	a := siggen.Noise(0.2, 300)
+	b := siggen.Noise(0.2, 400)
+	for _, start := range []int{50, 180} {
+		for i, v := range pattern {
+			a[start+i] += v
+		}
+	}
+	for _, start := range []int{90, 300} {
+		for i, v := range pattern {
+			b[start+i] += v
+		}
+	}
+
+	testdata := []struct {
+		k           int
+		radius      float64
+		algo        Algo
+		expectedErr bool
+	}{
+		{0, 2, AlgoMPX, true},
+		{1, 0.5, AlgoMPX, true},
+		{1, 2, AlgoSTOMP, true},
+		{1, 3, AlgoMPX, false},
+		{3, 3, AlgoMPX, false},
+	}
+
+	for _, d := range testdata {
+		o := NewMPOpts()
+		o.Algorithm = d.algo
+		motifs, err := SharedMotifs(a, b, len(pattern), d.k, d.radius, o)
+		if d.expectedErr {
+			if err == nil {
+				t.Errorf("Expected an error for %+v", d)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("Did not expect an error, %v, for %+v", err, d)
+		}
+		if len(motifs) != d.k {
+			t.Fatalf("Expected %d shared motifs, but got %d for %+v", d.k, len(motifs), d)
+		}
+
+		// the best match may cover the planted pattern with the same partial alignment
+		// in every occurrence, so only the offset between occurrences is exact
+		m := motifs[0]
+		if len(m.AIdx) != 2 || len(m.BIdx) != 2 {
+			t.Fatalf("Expected 2 occurrences in each timeseries, but got %v and %v for %+v", m.AIdx, m.BIdx, d)
+		}
+		shift := m.AIdx[0] - 50
+		if math.Abs(float64(shift)) >= float64(len(pattern)) || m.AIdx[1]-180 != shift || m.BIdx[0]-90 != shift || m.BIdx[1]-300 != shift {
+			t.Errorf("Expected occurrences at 50 and 180 in a and 90 and 300 in b with a common shift, but got %v and %v for %+v", m.AIdx, m.BIdx, d)
+		}
+		for i := 1; i < len(motifs); i++ {
+			if motifs[i].MinDist < motifs[i-1].MinDist {
+				t.Errorf("Expected shared motifs ordered by distance, but got %.3f after %.3f", motifs[i].MinDist, motifs[i-1].MinDist)
+			}
+		}
+	}
+}
@@ -0,0 +1,214 @@
+package matrixprofile
+
+import (
+	"fmt"
+	"math"
+	"runtime"
+	"sync"
+
+	"github.com/matrix-profile-foundation/go-matrixprofile/util"
+	"gonum.org/v1/gonum/floats"
+)
+
+// SiMPle is a similarity matrix profile join of two sequences of feature vectors, such
+// as the chroma or spectrogram frames of two recordings, following SiMPle-Fast. Each
+// row of A and B is the feature vector of one time step. The distance between two
+// subsequences of w time steps is the euclidean distance between all of their
+// features, without z-normalization, so the per dimension distances are summed.
+type SiMPle struct {
+	A        [][]float64 // feature vectors of the first sequence, one row per time step
+	B        [][]float64 // feature vectors of the sequence to join with, the same as A for a self join
+	W        int         // number of time steps in a subsequence
+	SelfJoin bool        // indicates whether a self join is performed with an exclusion zone
+	MP       []float64   // distance of each subsequence of a to its nearest neighbor in b
+	Idx      []int       // index of the nearest neighbor in b of each subsequence of a
+	MPB      []float64   // distance of each subsequence of b to its nearest neighbor in a for an AB join
+	IdxB     []int       // index of the nearest neighbor in a of each subsequence of b for an AB join
+	NJobs    int         // number of batches of rows computed concurrently, defaulting to the number of CPUs when less than 1
+}
+
+// NewSiMPle creates a SiMPle join of the feature vectors of a against those of b, or a
+// self join of a if b is nil. All feature vectors must have the same number of
+// dimensions.
+func NewSiMPle(a, b [][]float64, w int) (*SiMPle, error) {
+	if len(a) == 0 {
+		return nil, fmt.Errorf("first slice is nil or has a length of 0")
+	}
+	if b != nil && len(b) == 0 {
+		return nil, fmt.Errorf("second slice must be nil for self-join operation or have a length greater than 0")
+	}
+
+	s := SiMPle{A: a, W: w}
+	if b == nil {
+		s.B = a
+		s.SelfJoin = true
+	} else {
+		s.B = b
+	}
+
+	dims := len(a[0])
+	if dims == 0 {
+		return nil, fmt.Errorf("feature vectors must have at least 1 dimension")
+	}
+	for name, frames := range map[string][][]float64{"a": s.A, "b": s.B} {
+		for i, f := range frames {
+			if len(f) != dims {
+				return nil, fmt.Errorf("feature vector %d of %s has %d dimensions and doesn't match the %d dimensions of the first", i, name, len(f), dims)
+			}
+		}
+	}
+
+	if s.W > len(s.A) || s.W > len(s.B) {
+		return nil, fmt.Errorf("subsequence length must be less than the number of time steps")
+	}
+	if s.W < 2 {
+		return nil, fmt.Errorf("subsequence length must be at least 2")
+	}
+	return &s, nil
+}
+
+// Compute computes the SiMPle join. The first row of each batch of rows of the
+// distance matrix starts from the sliding dot products of every dimension computed
+// with fourier transforms, and the following rows update their sum with the inner
+// products of the frames entering and leaving the subsequences.
+func (s *SiMPle) Compute() error {
+	jobs := s.NJobs
+	if jobs < 1 {
+		jobs = runtime.NumCPU()
+	}
+
+	nA := len(s.A) - s.W + 1
+	nB := len(s.B) - s.W + 1
+	init := newBatchResult(nA)
+	s.MP, s.Idx = init.MP, init.Idx
+	if !s.SelfJoin {
+		init = newBatchResult(nB)
+		s.MPB, s.IdxB = init.MP, init.Idx
+	}
+
+	// the half spectrum of each dimension of b is shared across all batches
+	n := util.FFTLen(len(s.B))
+	fft := newFFTCache(n)
+	col := make([]float64, len(s.B))
+	bf := make([][]complex128, len(s.B[0]))
+	for d := range bf {
+		for t := range s.B {
+			col[t] = s.B[t][d]
+		}
+		bf[d] = fft.coefficients(col)
+	}
+
+	aSq := frameSumSq(s.A, s.W)
+	bSq := aSq
+	if !s.SelfJoin {
+		bSq = frameSumSq(s.B, s.W)
+	}
+
+	batchSize := nA/jobs + 1
+	results := make([]*mpResult, jobs)
+	var wg sync.WaitGroup
+	for batch := 0; batch < jobs; batch++ {
+		wg.Add(1)
+		go func(batch int) {
+			defer wg.Done()
+			results[batch] = s.batch(batch*batchSize, batchSize, newFFTCache(n), bf, aSq, bSq)
+		}(batch)
+	}
+	wg.Wait()
+
+	if s.SelfJoin {
+		return nil
+	}
+
+	// batches are merged in order of their rows, so ties keep the smallest index as if
+	// the rows were computed one after another
+	for _, r := range results {
+		if r == nil {
+			continue
+		}
+		for j, d := range r.MP {
+			if d < s.MPB[j] {
+				s.MPB[j] = d
+				s.IdxB[j] = r.Idx[j]
+			}
+		}
+	}
+	return nil
+}
+
+// batch computes size rows of the distance matrix starting at row start, writing the
+// best of each row directly since no other batch has those rows. For an AB join it
+// also returns the best of each column.
+func (s *SiMPle) batch(start, size int, fft *fftCache, bf [][]complex128, aSq, bSq []float64) *mpResult {
+	nA := len(s.A) - s.W + 1
+	nB := len(s.B) - s.W + 1
+	if start >= nA {
+		// got an index larger than the number of subsequences so ignore
+		return nil
+	}
+	end := start + size
+	if end > nA {
+		end = nA
+	}
+
+	dot := make([]float64, nB)
+	q := make([]float64, s.W)
+	for d := range bf {
+		for t := range q {
+			q[t] = s.A[start+t][d]
+		}
+		floats.Add(dot, fft.correlate(q, bf[d])[:nB])
+	}
+
+	result := newBatchResult(nB)
+	profile := make([]float64, nB)
+	for i := start; i < end; i++ {
+		if i > start {
+			for j := nB - 1; j > 0; j-- {
+				dot[j] = dot[j-1] - floats.Dot(s.A[i-1], s.B[j-1]) + floats.Dot(s.A[i+s.W-1], s.B[j+s.W-1])
+			}
+			// the first dot product is not covered by the update above
+			dot[0] = 0
+			for t := 0; t < s.W; t++ {
+				dot[0] += floats.Dot(s.A[i+t], s.B[t])
+			}
+		}
+
+		for j := range profile {
+			// rounding can take the distance of identical subsequences below 0
+			profile[j] = math.Sqrt(math.Max(aSq[i]+bSq[j]-2*dot[j], 0))
+		}
+		if s.SelfJoin {
+			util.ApplyExclusionZone(profile, i, s.W/2)
+		}
+
+		for j, d := range profile {
+			if !s.SelfJoin && d < result.MP[j] {
+				result.MP[j] = d
+				result.Idx[j] = i
+			}
+			if d < s.MP[i] {
+				s.MP[i] = d
+				s.Idx[i] = j
+			}
+		}
+	}
+	return result
+}
+
+// frameSumSq returns the sum of squares of all features of each sliding window of w
+// frames.
+func frameSumSq(frames [][]float64, w int) []float64 {
+	out := make([]float64, len(frames)-w+1)
+	var sum float64
+	for i, f := range frames {
+		sum += floats.Dot(f, f)
+		if i >= w {
+			sum -= floats.Dot(frames[i-w], frames[i-w])
+		}
+		if i >= w-1 {
+			out[i-w+1] = sum
+		}
+	}
+	return out
+}
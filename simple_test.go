@@ -0,0 +1,119 @@
+package matrixprofile
+
+import (
+	"math"
+	"testing"
+
+	"github.com/matrix-profile-foundation/go-matrixprofile/siggen"
+)
+
+// noiseFrames returns n random feature vectors of the given dimensions.
+func noiseFrames(n, dims int) [][]float64 {
+	frames := make([][]float64, n)
+	for d := 0; d < dims; d++ {
+		col := siggen.Noise(1, n)
+		for t := range frames {
+			if d == 0 {
+				frames[t] = make([]float64, dims)
+			}
+			frames[t][d] = col[t]
+		}
+	}
+	return frames
+}
+
+// bruteSiMPle computes the nearest neighbor distance and index of each subsequence of a
+// in b.
+func bruteSiMPle(a, b [][]float64, w int, selfJoin bool) ([]float64, []int) {
+	mp := make([]float64, len(a)-w+1)
+	idx := make([]int, len(mp))
+	for i := range mp {
+		mp[i], idx[i] = math.Inf(1), math.MaxInt64
+		for j := 0; j+w <= len(b); j++ {
+			if selfJoin && j >= i-w/2 && j < i+w/2 {
+				continue
+			}
+			var sq float64
+			for t := 0; t < w; t++ {
+				for d := range a[i+t] {
+					sq += (a[i+t][d] - b[j+t][d]) * (a[i+t][d] - b[j+t][d])
+				}
+			}
+			if math.Sqrt(sq) < mp[i] {
+				mp[i], idx[i] = math.Sqrt(sq), j
+			}
+		}
+	}
+	return mp, idx
+}
+
+func TestNewSiMPle(t *testing.T) {
+	testdata := []struct {
+		a           [][]float64
+		b           [][]float64
+		w           int
+		expectedErr bool
+	}{
+		{[][]float64{}, nil, 2, true},
+		{noiseFrames(10, 3), [][]float64{}, 2, true},
+		{[][]float64{{}, {}, {}}, nil, 2, true},
+		{noiseFrames(10, 3), noiseFrames(10, 2), 2, true},
+		{noiseFrames(10, 3), nil, 1, true},
+		{noiseFrames(10, 3), noiseFrames(5, 3), 6, true},
+		{noiseFrames(10, 3), noiseFrames(5, 3), 5, false},
+		{noiseFrames(10, 3), nil, 10, false},
+	}
+
+	for i, d := range testdata {
+		_, err := NewSiMPle(d.a, d.b, d.w)
+		if d.expectedErr && err == nil {
+			t.Errorf("Expected an error, but got none for case %d", i)
+		}
+		if !d.expectedErr && err != nil {
+			t.Errorf("Expected no error, but got %v for case %d", err, i)
+		}
+	}
+}
+
+func TestSiMPle(t *testing.T) {
+	a := noiseFrames(120, 12)
+	b := noiseFrames(90, 12)
+	w := 8
+
+	for _, jobs := range []int{1, 4} {
+		for _, selfJoin := range []bool{true, false} {
+			var s *SiMPle
+			var err error
+			if selfJoin {
+				s, err = NewSiMPle(a, nil, w)
+			} else {
+				s, err = NewSiMPle(a, b, w)
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			s.NJobs = jobs
+			if err = s.Compute(); err != nil {
+				t.Fatal(err)
+			}
+
+			expectedMP, expectedIdx := bruteSiMPle(s.A, s.B, w, selfJoin)
+			for i := range expectedMP {
+				if math.Abs(s.MP[i]-expectedMP[i]) > 1e-6 || s.Idx[i] != expectedIdx[i] {
+					t.Errorf("Expected %.6f with index %d at %d, but got %.6f with index %d for self join %t and %d jobs", expectedMP[i], expectedIdx[i], i, s.MP[i], s.Idx[i], selfJoin, jobs)
+					break
+				}
+			}
+			if selfJoin {
+				continue
+			}
+			expectedMPB, expectedIdxB := bruteSiMPle(s.B, s.A, w, false)
+			for j := range expectedMPB {
+				if math.Abs(s.MPB[j]-expectedMPB[j]) > 1e-6 || s.IdxB[j] != expectedIdxB[j] {
+					t.Errorf("Expected %.6f with index %d at %d of b, but got %.6f with index %d for %d jobs", expectedMPB[j], expectedIdxB[j], j, s.MPB[j], s.IdxB[j], jobs)
+					break
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package matrixprofile
+
+import (
+	"fmt"
+	"math"
+)
+
+// Snippet is one of the most representative subsequences of a timeseries.
+type Snippet struct {
+	Idx      int     // starting index of the snippet in the timeseries
+	Fraction float64 // fraction of the subsequences of the timeseries closer to this snippet than to any other
+}
+
+// Snippets finds the k snippets of length snippetLen that best summarize the typical
+// behavior of ts. The timeseries is split into non-overlapping segments of snippetLen,
+// and the MPDistProfile of each segment against ts, with a subsequence length of w,
+// measures how well it represents every part of the timeseries. Snippets are picked
+// greedily so each one most lowers the total distance from the timeseries to its
+// closest snippet. A w of 0 uses half of the snippet length. Snippets are returned in
+// the order they were picked.
+func Snippets(ts []float64, snippetLen, k, w int) ([]Snippet, error) {
+	if w == 0 {
+		w = snippetLen / 2
+	}
+	if snippetLen < 2 || snippetLen > len(ts)/2 {
+		return nil, fmt.Errorf("snippet length, %d, must be at least 2 and at most half of the timeseries", snippetLen)
+	}
+	segments := len(ts) / snippetLen
+	if k < 1 || k > segments {
+		return nil, fmt.Errorf("number of snippets, %d, must be between 1 and the number of segments, %d", k, segments)
+	}
+
+	profiles := make([][]float64, segments)
+	for i := range profiles {
+		var err error
+		profiles[i], err = MPDistProfile(ts[i*snippetLen:(i+1)*snippetLen], ts, w, 1)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// closest holds the distance of each subsequence to its closest snippet so far
+	closest := make([]float64, len(profiles[0]))
+	for j := range closest {
+		closest[j] = math.Inf(1)
+	}
+	picked := make([]int, 0, k)
+	used := make([]bool, segments)
+	for len(picked) < k {
+		best, bestArea := -1, math.Inf(1)
+		for i, profile := range profiles {
+			if used[i] {
+				continue
+			}
+			var area float64
+			for j, d := range profile {
+				area += math.Min(d, closest[j])
+			}
+			if best < 0 || area < bestArea {
+				best, bestArea = i, area
+			}
+		}
+		picked = append(picked, best)
+		used[best] = true
+		for j, d := range profiles[best] {
+			closest[j] = math.Min(closest[j], d)
+		}
+	}
+
+	// each subsequence counts towards the snippet it is closest to, with ties going to
+	// the snippet picked first
+	counts := make([]int, k)
+	for j := range closest {
+		owner := 0
+		for s := 1; s < k; s++ {
+			if profiles[picked[s]][j] < profiles[picked[owner]][j] {
+				owner = s
+			}
+		}
+		counts[owner]++
+	}
+
+	snippets := make([]Snippet, k)
+	for s, i := range picked {
+		snippets[s] = Snippet{
+			Idx:      i * snippetLen,
+			Fraction: float64(counts[s]) / float64(len(closest)),
+		}
+	}
+	return snippets, nil
+}
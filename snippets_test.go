@@ -0,0 +1,52 @@
+package matrixprofile
+
+import (
+	"math"
+	"testing"
+
+	"github.com/matrix-profile-foundation/go-matrixprofile/siggen"
+)
+
+func TestSnippets(t *testing.T) {
+	// two regimes of sine waves surround a regime of sawtooth waves
+	sine := siggen.Sin(1, 5, 0, 0, 100, 4)
+	saw := siggen.Sawtooth(1, 5, 0, 0, 100, 4)
+	ts := append(append(append([]float64(nil), sine...), saw...), sine...)
+	ts = siggen.Add(ts, siggen.Noise(0.1, len(ts)))
+
+	for _, d := range []struct {
+		snippetLen int
+		k          int
+	}{
+		{1, 2},
+		{700, 2},
+		{100, 0},
+		{100, 13},
+	} {
+		if _, err := Snippets(ts, d.snippetLen, d.k, 0); err == nil {
+			t.Errorf("Expected an error for a snippet length of %d and %d snippets", d.snippetLen, d.k)
+		}
+	}
+
+	snippets, err := Snippets(ts, 100, 2, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(snippets) != 2 {
+		t.Fatalf("Expected 2 snippets, but got %d", len(snippets))
+	}
+
+	// the first snippet represents the more common sine regime
+	if snippets[0].Idx >= 400 && snippets[0].Idx < 800 {
+		t.Errorf("Expected the first snippet in a sine regime, but got %d", snippets[0].Idx)
+	}
+	if snippets[1].Idx < 400 || snippets[1].Idx >= 800 {
+		t.Errorf("Expected the second snippet in the sawtooth regime, but got %d", snippets[1].Idx)
+	}
+	if math.Abs(snippets[0].Fraction-2.0/3) > 0.1 || math.Abs(snippets[1].Fraction-1.0/3) > 0.1 {
+		t.Errorf("Expected fractions near 2/3 and 1/3, but got %+v", snippets)
+	}
+	if math.Abs(snippets[0].Fraction+snippets[1].Fraction-1) > 1e-9 {
+		t.Errorf("Expected fractions summing to 1, but got %+v", snippets)
+	}
+}
@@ -0,0 +1,298 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// S3 is a Store for S3 compatible object stores, including Amazon S3, the Google Cloud
+// Storage XML API with HMAC keys, and MinIO. Requests are signed with AWS signature
+// version 4 and buckets are addressed by path, as in endpoint/bucket/key.
+type S3 struct {
+	Endpoint    string       // base url of the store, such as https://s3.us-east-1.amazonaws.com or https://storage.googleapis.com
+	Region      string       // region the requests are signed for. Google Cloud Storage accepts auto
+	AccessKey   string       // access key id
+	SecretKey   string       // secret access key
+	Client      *http.Client // client used to send requests. Defaults to http.DefaultClient
+	MinPartSize int          // smallest size of every part of a multipart upload but the last, for stores with another limit. Defaults to 0 which uses the 5 MiB of the package MinPartSize
+
+	now func() time.Time // returns the signing time
+}
+
+// NewS3 returns an S3 store for the given endpoint and credentials.
+func NewS3(endpoint, region, accessKey, secretKey string) *S3 {
+	return &S3{
+		Endpoint:  strings.TrimSuffix(endpoint, "/"),
+		Region:    region,
+		AccessKey: accessKey,
+		SecretKey: secretKey,
+		Client:    http.DefaultClient,
+		now:       time.Now,
+	}
+}
+
+// minPartSize returns the smallest part size the store accepts.
+func (s *S3) minPartSize() int {
+	if s.MinPartSize > 0 {
+		return s.MinPartSize
+	}
+	return MinPartSize
+}
+
+// Put writes an object with a single request.
+func (s *S3) Put(ctx context.Context, bucket, key string, data []byte) error {
+	resp, err := s.do(ctx, http.MethodPut, bucket, key, nil, data)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// Get opens an object for reading.
+func (s *S3) Get(ctx context.Context, bucket, key string) (io.ReadCloser, error) {
+	resp, err := s.do(ctx, http.MethodGet, bucket, key, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// CreateMultipart starts a multipart upload.
+func (s *S3) CreateMultipart(ctx context.Context, bucket, key string) (string, error) {
+	resp, err := s.do(ctx, http.MethodPost, bucket, key, url.Values{"uploads": {""}}, nil)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		UploadID string `xml:"UploadId"`
+	}
+	if err = xml.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", fmt.Errorf("failed to decode multipart upload, %v", err)
+	}
+	if result.UploadID == "" {
+		return "", errors.New("store did not return a multipart upload id")
+	}
+	return result.UploadID, nil
+}
+
+// UploadPart uploads a single part of a multipart upload.
+func (s *S3) UploadPart(ctx context.Context, bucket, key, uploadID string, part int, data []byte) (string, error) {
+	query := url.Values{"partNumber": {strconv.Itoa(part)}, "uploadId": {uploadID}}
+	resp, err := s.do(ctx, http.MethodPut, bucket, key, query, data)
+	if err != nil {
+		return "", err
+	}
+	resp.Body.Close()
+
+	etag := resp.Header.Get("ETag")
+	if etag == "" {
+		return "", fmt.Errorf("store did not return an entity tag for part %d", part)
+	}
+	return etag, nil
+}
+
+// completedPart is a part listed in a request to complete a multipart upload.
+type completedPart struct {
+	PartNumber int    `xml:"PartNumber"`
+	ETag       string `xml:"ETag"`
+}
+
+// CompleteMultipart assembles the uploaded parts into the object.
+func (s *S3) CompleteMultipart(ctx context.Context, bucket, key, uploadID string, etags []string) error {
+	body := struct {
+		XMLName xml.Name        `xml:"CompleteMultipartUpload"`
+		Parts   []completedPart `xml:"Part"`
+	}{}
+	for i, etag := range etags {
+		body.Parts = append(body.Parts, completedPart{PartNumber: i + 1, ETag: etag})
+	}
+	data, err := xml.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	resp, err := s.do(ctx, http.MethodPost, bucket, key, url.Values{"uploadId": {uploadID}}, data)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	// a failed assembly may be reported in the body of a successful response
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	var failure struct {
+		XMLName xml.Name
+		Code    string `xml:"Code"`
+		Message string `xml:"Message"`
+	}
+	if xml.Unmarshal(b, &failure) == nil && failure.XMLName.Local == "Error" {
+		return fmt.Errorf("failed to complete multipart upload, %s: %s", failure.Code, failure.Message)
+	}
+	return nil
+}
+
+// AbortMultipart discards a multipart upload.
+func (s *S3) AbortMultipart(ctx context.Context, bucket, key, uploadID string) error {
+	resp, err := s.do(ctx, http.MethodDelete, bucket, key, url.Values{"uploadId": {uploadID}}, nil)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// do sends a signed request and returns the response if it succeeded.
+func (s *S3) do(ctx context.Context, method, bucket, key string, query url.Values, body []byte) (*http.Response, error) {
+	if bucket == "" || key == "" {
+		return nil, errors.New("must provide a bucket and key")
+	}
+
+	u, err := url.Parse(s.Endpoint)
+	if err != nil {
+		return nil, err
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/" + bucket + "/" + key
+	// the path is sent exactly as it is encoded for signing
+	u.RawPath = uriEncode(u.Path, false)
+	u.RawQuery = canonicalQuery(query)
+
+	req, err := http.NewRequest(method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	s.sign(req, body)
+
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode/100 != 2 {
+		defer resp.Body.Close()
+		msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("%s %s/%s failed with status %d, %s", method, bucket, key, resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	return resp, nil
+}
+
+// sign adds AWS signature version 4 headers to a request.
+func (s *S3) sign(req *http.Request, body []byte) {
+	now := time.Now
+	if s.now != nil {
+		now = s.now
+	}
+	t := now().UTC()
+	amzDate := t.Format("20060102T150405Z")
+	date := t.Format("20060102")
+
+	payload := sha256.Sum256(body)
+	payloadHash := hex.EncodeToString(payload[:])
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	// every header set so far is signed along with the host
+	names := []string{"host"}
+	headers := map[string]string{"host": req.URL.Host}
+	for name, vals := range req.Header {
+		lower := strings.ToLower(name)
+		names = append(names, lower)
+		headers[lower] = strings.TrimSpace(strings.Join(vals, ","))
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		uriEncode(req.URL.Path, false),
+		req.URL.RawQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + s.Region + "/s3/aws4_request"
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+
+	key := hmacSHA256([]byte("AWS4"+s.SecretKey), date)
+	key = hmacSHA256(key, s.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.AccessKey, scope, signedHeaders, signature))
+}
+
+// hmacSHA256 returns the HMAC-SHA256 of data with the given key.
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// canonicalQuery encodes query parameters sorted by name as required for signing.
+func canonicalQuery(query url.Values) string {
+	names := make([]string, 0, len(query))
+	for name := range query {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var params []string
+	for _, name := range names {
+		vals := append([]string(nil), query[name]...)
+		sort.Strings(vals)
+		for _, v := range vals {
+			params = append(params, uriEncode(name, true)+"="+uriEncode(v, true))
+		}
+	}
+	return strings.Join(params, "&")
+}
+
+// uriEncode percent encodes every byte except unreserved characters, and slashes
+// unless encodeSlash is set.
+func uriEncode(s string, encodeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '_', c == '.', c == '~':
+			b.WriteByte(c)
+		case c == '/' && !encodeSlash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
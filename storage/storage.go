@@ -0,0 +1,118 @@
+// Package storage persists computed matrix profiles to object stores so fleets of
+// profiles can be archived and lazily retrieved by key.
+package storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// DefaultPartSize is the part size used to upload large objects in multiple parts,
+// above the minimum part size of S3 compatible stores.
+const DefaultPartSize = 8 << 20
+
+// MinPartSize is the smallest part size S3 compatible stores accept for every part of
+// a multipart upload but the last.
+const MinPartSize = 5 << 20
+
+// Store is a set of buckets holding objects addressed by key.
+type Store interface {
+	// Put writes an object, replacing any existing object with the same key.
+	Put(ctx context.Context, bucket, key string, data []byte) error
+	// Get opens an object for reading. The caller must close it.
+	Get(ctx context.Context, bucket, key string) (io.ReadCloser, error)
+}
+
+// Multipart is a Store that can upload an object in parts, which is required for
+// objects larger than a single request allows and lets failed parts be retried alone.
+type Multipart interface {
+	Store
+	// CreateMultipart starts an upload and returns its identifier.
+	CreateMultipart(ctx context.Context, bucket, key string) (string, error)
+	// UploadPart uploads the part with the given number, starting at 1, and returns
+	// its entity tag.
+	UploadPart(ctx context.Context, bucket, key, uploadID string, part int, data []byte) (string, error)
+	// CompleteMultipart assembles the uploaded parts, in order of their entity tags,
+	// into the object.
+	CompleteMultipart(ctx context.Context, bucket, key, uploadID string, etags []string) error
+	// AbortMultipart discards an upload and its uploaded parts.
+	AbortMultipart(ctx context.Context, bucket, key, uploadID string) error
+}
+
+// minPartSizer is a Multipart store that rejects parts, other than the last, below a
+// minimum size when the upload is completed.
+type minPartSizer interface {
+	minPartSize() int
+}
+
+// Upload writes data to a store. When the data is larger than the part size and the
+// store supports multipart uploads, the data is uploaded in parts of the part size and
+// the upload is aborted if any part fails. A part size of 0 uses DefaultPartSize. A
+// part size below the minimum of the store, MinPartSize for S3, is rejected before
+// anything is uploaded.
+func Upload(ctx context.Context, s Store, bucket, key string, data []byte, partSize int) error {
+	if s == nil {
+		return errors.New("must provide a store")
+	}
+	if partSize < 0 {
+		return fmt.Errorf("part size, %d, must not be negative", partSize)
+	}
+	if partSize == 0 {
+		partSize = DefaultPartSize
+	}
+
+	m, ok := s.(Multipart)
+	if !ok || len(data) <= partSize {
+		return s.Put(ctx, bucket, key, data)
+	}
+
+	if p, ok := m.(minPartSizer); ok && partSize < p.minPartSize() {
+		return fmt.Errorf("part size, %d, must be at least %d", partSize, p.minPartSize())
+	}
+
+	uploadID, err := m.CreateMultipart(ctx, bucket, key)
+	if err != nil {
+		return err
+	}
+
+	var etags []string
+	for start, part := 0, 1; start < len(data); start, part = start+partSize, part+1 {
+		end := start + partSize
+		if end > len(data) {
+			end = len(data)
+		}
+		etag, err := m.UploadPart(ctx, bucket, key, uploadID, part, data[start:end])
+		if err != nil {
+			m.AbortMultipart(ctx, bucket, key, uploadID)
+			return fmt.Errorf("failed to upload part %d, %v", part, err)
+		}
+		etags = append(etags, etag)
+	}
+
+	if err = m.CompleteMultipart(ctx, bucket, key, uploadID, etags); err != nil {
+		m.AbortMultipart(ctx, bucket, key, uploadID)
+		return err
+	}
+	return nil
+}
+
+// Download reads a whole object from a store.
+func Download(ctx context.Context, s Store, bucket, key string) ([]byte, error) {
+	if s == nil {
+		return nil, errors.New("must provide a store")
+	}
+	rc, err := s.Get(ctx, bucket, key)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	var buf bytes.Buffer
+	if _, err = buf.ReadFrom(rc); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,369 @@
+package matrixprofile
+
+import (
+	"math"
+	"sort"
+
+	"github.com/matrix-profile-foundation/go-matrixprofile/util"
+	"gonum.org/v1/gonum/floats"
+)
+
+// MotifEventType describes how a tracked motif changed after an Update.
+type MotifEventType string
+
+const (
+	MotifEmerged MotifEventType = "emerged" // a new recurring pattern was found
+	MotifGrew    MotifEventType = "grew"    // an existing motif gained new members
+	MotifExpired MotifEventType = "expired" // a motif has not recurred within the expiry
+)
+
+// MotifEvent is emitted while streaming when a tracked motif changes. Indices are
+// absolute positions in the stream, including any points retired through Offset.
+type MotifEvent struct {
+	Type   MotifEventType
+	ID     int        // stable identifier of the tracked motif
+	Motif  MotifGroup // all retained members of the motif
+	NewIdx []int      // members added by this event for emerged and grown motifs
+}
+
+// trackedMotif is the state of a motif followed across updates.
+type trackedMotif struct {
+	id      int
+	members []int // absolute start indices sorted in ascending order
+	latest  int   // most recent member, kept even after it has been retired
+	minDist float64
+	expired bool
+}
+
+// overlaps returns whether a subsequence of length w starting at idx overlaps any
+// member of the motif.
+func (t trackedMotif) overlaps(idx, w int) bool {
+	i := sort.SearchInts(t.members, idx-w+1)
+	return i < len(t.members) && t.members[i] < idx+w
+}
+
+func (t trackedMotif) event(typ MotifEventType, newIdx []int) MotifEvent {
+	members := make([]int, len(t.members))
+	copy(members, t.members)
+	return MotifEvent{
+		Type:   typ,
+		ID:     t.id,
+		Motif:  MotifGroup{Idx: members, MinDist: t.minDist},
+		NewIdx: newIdx,
+	}
+}
+
+// trackMotifs discovers the current top motifs and matches them against the motifs
+// tracked from previous updates. A discovered motif with a member overlapping a member
+// of a tracked motif adds any members after the latest tracked member to it, otherwise
+// it is tracked as a new motif. Tracked motifs with
+// no new members within the expiry stop recurring, and motifs whose members have all
+// been retired are forgotten.
+func (mp *MatrixProfile) trackMotifs() error {
+	s := mp.Stream
+	if s == nil || s.MotifK <= 0 || s.OnMotifEvent == nil {
+		return nil
+	}
+
+	groups, err := mp.DiscoverMotifs(s.MotifK, s.MotifRadius, 0, mp.exclusionZone(mp.W/2))
+	if err != nil {
+		return err
+	}
+
+	var events []MotifEvent
+	for _, g := range groups {
+		if len(g.Idx) == 0 || (s.MotifMaxDist > 0 && g.MinDist > s.MotifMaxDist) {
+			continue
+		}
+
+		var match *trackedMotif
+		for _, t := range s.motifs {
+			for _, idx := range g.Idx {
+				if t.overlaps(idx+mp.Offset, mp.W) {
+					match = t
+					break
+				}
+			}
+			if match != nil {
+				break
+			}
+		}
+
+		if match == nil {
+			t := &trackedMotif{id: s.nextMotifID, minDist: g.MinDist}
+			s.nextMotifID++
+			for _, idx := range g.Idx {
+				t.members = append(t.members, idx+mp.Offset)
+			}
+			t.latest = t.members[len(t.members)-1]
+			s.motifs = append(s.motifs, t)
+			newIdx := make([]int, len(t.members))
+			copy(newIdx, t.members)
+			events = append(events, t.event(MotifEmerged, newIdx))
+			continue
+		}
+
+		// only occurrences after the latest member count as growth so that shifted
+		// alignments of earlier occurrences are not reported as new members
+		var newIdx []int
+		for _, idx := range g.Idx {
+			if idx+mp.Offset > match.latest && !match.overlaps(idx+mp.Offset, mp.W) {
+				newIdx = append(newIdx, idx+mp.Offset)
+			}
+		}
+		if g.MinDist < match.minDist {
+			match.minDist = g.MinDist
+		}
+		if len(newIdx) == 0 {
+			continue
+		}
+		match.members = append(match.members, newIdx...)
+		match.latest = newIdx[len(newIdx)-1]
+		match.expired = false
+		events = append(events, match.event(MotifGrew, newIdx))
+	}
+
+	// absolute number of points seen so far in the stream
+	end := mp.Offset + len(mp.A)
+	retained := s.motifs[:0]
+	for _, t := range s.motifs {
+		// drops members that have been retired from the timeseries
+		i := sort.SearchInts(t.members, mp.Offset)
+		t.members = t.members[i:]
+		if len(t.members) == 0 {
+			continue
+		}
+		retained = append(retained, t)
+
+		if !t.expired && s.MotifExpiry > 0 && end-(t.latest+mp.W) > s.MotifExpiry {
+			t.expired = true
+			events = append(events, t.event(MotifExpired, nil))
+		}
+	}
+	s.motifs = retained
+
+	for _, e := range events {
+		s.OnMotifEvent(e)
+	}
+	return nil
+}
+
+// mpxStream caches the MPX sliding statistics of a self join along with the mean
+// centered dot products of the last subsequence against every subsequence. Appending a
+// point extends each diagonal by one step instead of re-running a query over the whole
+// timeseries.
+type mpxStream struct {
+	stats   *mpxStats
+	cov     []float64 // mean centered dot product of the last subsequence with each subsequence
+	profile []float64 // reused buffer for the profile of the newest subsequence
+}
+
+// newMPXStream computes the initial MPX streaming state for a self join timeseries.
+func newMPXStream(ts []float64, w int) *mpxStream {
+	s := &mpxStream{stats: newMPXStats(ts, w)}
+	last := len(s.stats.mu) - 1
+	s.cov = make([]float64, last+1)
+
+	q := make([]float64, w)
+	copy(q, ts[last:])
+	floats.AddConst(-s.stats.mu[last], q)
+	sub := make([]float64, w)
+	for i := range s.cov {
+		copy(sub, ts[i:i+w])
+		floats.AddConst(-s.stats.mu[i], sub)
+		s.cov[i] = floats.Dot(sub, q)
+	}
+	return s
+}
+
+// retire drops the statistics of the first k subsequences.
+func (s *mpxStream) retire(k int) {
+	s.stats.mu = shiftOut(s.stats.mu, k)
+	s.stats.sig = shiftOut(s.stats.sig, k)
+	s.stats.df = shiftOut(s.stats.df, k)
+	s.stats.dg = shiftOut(s.stats.dg, k)
+	s.cov = shiftOut(s.cov, k)
+}
+
+// stampiStream caches the sliding dot products of the last subsequence of a self join
+// against every subsequence, so appending a point steps them forward with the STOMP
+// recurrence instead of running a MASS query over the whole timeseries.
+type stampiStream struct {
+	dot     []float64 // dot product of the last subsequence with each subsequence, left at zero for manhattan distances
+	sumSq   []float64 // sum of squares of each subsequence, only kept for non-normalized distances
+	profile []float64 // reused buffer for the profile of the newest subsequence
+}
+
+// newSTAMPIStream computes the initial STAMPI streaming state for a self join from a
+// single query of the last subsequence, refreshing the caches of the timeseries.
+func (mp *MatrixProfile) newSTAMPIStream() (*stampiStream, error) {
+	if err := mp.initCaches(); err != nil {
+		return nil, err
+	}
+	last := len(mp.A) - mp.W
+	s := &stampiStream{dot: make([]float64, last+1)}
+	if mp.manhattan() {
+		// manhattan distances don't use the dot products, which are left at zero
+		return s, nil
+	}
+
+	dot, err := mp.crossCorrelate(mp.A[last:], mp.newFFT())
+	if err != nil {
+		return nil, err
+	}
+	// the cross correlation reuses the buffers of the fft cache so it is copied out
+	copy(s.dot, dot)
+	if mp.nonNormalized() {
+		s.sumSq = movSumSq(mp.A, mp.W)
+	}
+	return s, nil
+}
+
+// retire drops the dot products and sums of squares of the first k subsequences.
+func (s *stampiStream) retire(k int) {
+	s.dot = shiftOut(s.dot, k)
+	if s.sumSq != nil {
+		s.sumSq = shiftOut(s.sumSq, k)
+	}
+}
+
+// shiftOut drops the first k values of a slice by shifting the rest to its front, so
+// the slice keeps its backing array.
+func shiftOut(s []float64, k int) []float64 {
+	if k >= len(s) {
+		return s[:0]
+	}
+	n := copy(s, s[k:])
+	return s[:n]
+}
+
+// appendMPX appends a value to a self join timeseries and updates the matrix profile
+// using the MPX recurrence. The newest subsequence's dot products are derived from the
+// previous ones along each diagonal, so each point costs O(n) rather than a full MASS
+// query over the history. The exclusion zone matches the one used by MPX.
+func (mp *MatrixProfile) appendMPX(val float64) {
+	if mp.mpxStream == nil {
+		mp.mpxStream = newMPXStream(mp.A, mp.W)
+	}
+	s := mp.mpxStream
+	st := s.stats
+
+	mp.A = append(mp.A, val)
+	mp.B = mp.A
+	mp.N++
+
+	// the fft and sliding statistics caches no longer reflect the timeseries and are
+	// rebuilt lazily when needed
+	mp.BF = nil
+	mp.mpxStats = nil
+
+	j := len(mp.A) - mp.W
+	// the sliding statistics of the timeseries are extended alongside the MPX ones when
+	// they are in step, so retiring points only has to shift them
+	if len(mp.AMean) == j && len(mp.BMean) == j {
+		mean, std := windowMeanStd(mp.A[j:])
+		mp.AMean = append(mp.AMean, mean)
+		mp.AStd = append(mp.AStd, std)
+		mp.BMean = append(mp.BMean, mean)
+		mp.BStd = append(mp.BStd, std)
+	}
+	mu, sig := util.MuInvN(mp.A[j:], mp.W)
+	st.mu = append(st.mu, mu[0])
+	st.sig = append(st.sig, sig[0])
+	st.df = append(st.df, 0.5*(mp.A[j+mp.W-1]-mp.A[j-1]))
+	st.dg = append(st.dg, (mp.A[j+mp.W-1]-st.mu[j])+(mp.A[j-1]-st.mu[j-1]))
+
+	// step every diagonal forward by one, then compute the first row directly
+	s.cov = append(s.cov, 0)
+	for i := j; i > 0; i-- {
+		s.cov[i] = s.cov[i-1] + st.df[i]*st.dg[j] + st.df[j]*st.dg[i]
+	}
+	var c float64
+	for k := 0; k < mp.W; k++ {
+		c += (mp.A[k] - st.mu[0]) * (mp.A[j+k] - st.mu[j])
+	}
+	s.cov[0] = c
+
+	exclZone := mp.mpxExclusionZone()
+	noise := mp.noiseVar()
+
+	n := j - exclZone + 1
+	if n < 0 {
+		n = 0
+	}
+	if cap(s.profile) < n {
+		s.profile = make([]float64, n, 2*n)
+	}
+	profile := s.profile[:n]
+	for i := range profile {
+		profile[i] = s.cov[i] * st.sig[i] * st.sig[j]
+		if mp.Opts.RemapNegCorr && profile[i] < 0 {
+			profile[i] = -profile[i]
+		}
+		if noise > 0 {
+			m := math.Min(st.sig[i], st.sig[j])
+			profile[i] += noise * m * m
+		}
+	}
+
+	euclidean := mp.Opts.Euclidean
+	if euclidean {
+		mp.p2e(profile)
+	}
+	mp.fadeProfile(profile, j, euclidean)
+
+	bestVal := math.Inf(1)
+	if !euclidean {
+		bestVal = math.Inf(-1)
+	}
+	bestIdx := math.MaxInt64
+	for i, d := range profile {
+		if isBetter(d, mp.MP[i], euclidean) {
+			mp.MP[i] = d
+			mp.Idx[i] = j
+		}
+		if isBetter(d, bestVal, euclidean) {
+			bestVal = d
+			bestIdx = i
+		}
+	}
+	mp.MP = append(mp.MP, bestVal)
+	mp.Idx = append(mp.Idx, bestIdx)
+	mp.extendMask()
+}
+
+// fadeProfile discounts the matches of the newest subsequence at j of a streaming self
+// join, where profile[i] compares it to the subsequence at i. Matches beyond the stream
+// horizon are excluded, and with a fade factor the euclidean distance of a match lag
+// points apart is divided by the fade factor to the power of lag, so normal behavior
+// adapts to drift instead of being anchored to old data. Pearson correlations are
+// faded through their equivalent squared euclidean distance.
+func (mp MatrixProfile) fadeProfile(profile []float64, j int, euclidean bool) {
+	if mp.Stream == nil {
+		return
+	}
+	worst := math.Inf(1)
+	if !euclidean {
+		worst = math.Inf(-1)
+	}
+
+	if h := mp.Stream.Horizon; h > 0 {
+		for i := 0; i < j-h && i < len(profile); i++ {
+			profile[i] = worst
+		}
+	}
+
+	if f := mp.Stream.FadeFactor; f > 0 && f < 1 {
+		for i, d := range profile {
+			if math.IsInf(d, 0) || i > j {
+				continue
+			}
+			discount := math.Pow(f, float64(j-i))
+			if euclidean {
+				profile[i] = d / discount
+			} else {
+				profile[i] = 1 - (1-d)/(discount*discount)
+			}
+		}
+	}
+}
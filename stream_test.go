@@ -0,0 +1,233 @@
+package matrixprofile
+
+import (
+	"math"
+	"testing"
+
+	"github.com/matrix-profile-foundation/go-matrixprofile/siggen"
+)
+
+func TestTrackMotifs(t *testing.T) {
+	w := 20
+	sig := seededNoise(1, 0.05, 600)
+	// recurring pattern at 100, 250, and 320 that stops after that
+	for _, start := range []int{100, 250, 320} {
+		for i := 0; i < w; i++ {
+			sig[start+i] += math.Sin(2 * math.Pi * float64(i) / float64(w))
+		}
+	}
+
+	testdata := []struct {
+		maxLen int
+		expiry int
+	}{
+		{0, 0},
+		{0, 150},
+		{350, 150},
+	}
+
+	for _, d := range testdata {
+		a := make([]float64, 200)
+		copy(a, sig[:200])
+		mp, err := New(a, nil, w)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = mp.Compute(NewMPOpts()); err != nil {
+			t.Fatal(err)
+		}
+
+		var events []MotifEvent
+		mp.Stream = NewStreamOpts()
+		mp.Stream.MaxLen = d.maxLen
+		mp.Stream.MotifK = 1
+		mp.Stream.MotifMaxDist = 1
+		mp.Stream.MotifExpiry = d.expiry
+		mp.Stream.OnMotifEvent = func(e MotifEvent) {
+			events = append(events, e)
+		}
+
+		for i := 200; i < len(sig); i += 10 {
+			if err = mp.Update(sig[i : i+10]); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		var emerged, grew, expired []MotifEvent
+		for _, e := range events {
+			switch e.Type {
+			case MotifEmerged:
+				emerged = append(emerged, e)
+			case MotifGrew:
+				grew = append(grew, e)
+			case MotifExpired:
+				expired = append(expired, e)
+			}
+		}
+
+		if len(emerged) != 1 {
+			t.Fatalf("Expected 1 emerged motif, but got %d, %+v, for %+v", len(emerged), events, d)
+		}
+		if !containsNear(emerged[0].Motif.Idx, 100, w) || !containsNear(emerged[0].Motif.Idx, 250, w) {
+			t.Errorf("Expected the emerged motif to contain 100 and 250, but got %v for %+v", emerged[0].Motif.Idx, d)
+		}
+		if len(grew) != 1 || grew[0].ID != emerged[0].ID || !containsNear(grew[0].NewIdx, 320, w) {
+			t.Errorf("Expected the motif to grow with a member at 320, but got %+v for %+v", grew, d)
+		}
+
+		expectedExpired := 0
+		if d.expiry > 0 {
+			expectedExpired = 1
+		}
+		if len(expired) != expectedExpired {
+			t.Errorf("Expected %d expired motifs, but got %+v for %+v", expectedExpired, expired, d)
+		}
+	}
+}
+
+func containsNear(idx []int, target, tol int) bool {
+	for _, i := range idx {
+		if i >= target-tol && i <= target+tol {
+			return true
+		}
+	}
+	return false
+}
+
+func TestUpdateFade(t *testing.T) {
+	w := 20
+	sig := siggen.Noise(0.05, 420)
+	bump := make([]float64, w)
+	for i := range bump {
+		bump[i] = math.Sin(2 * math.Pi * float64(i) / float64(w))
+	}
+	// the pattern at 380 recurs equally well at 20 and 250
+	noise := [][]float64{siggen.Noise(0.05, w), siggen.Noise(0.05, w)}
+	for i := 0; i < w; i++ {
+		sig[380+i] = bump[i]
+		sig[20+i] = bump[i] + noise[0][i]
+		sig[250+i] = bump[i] + noise[1][i]
+	}
+
+	testdata := []struct {
+		algo      Algo
+		euclidean bool
+		fade      float64
+		horizon   int
+	}{
+		{AlgoMPX, true, 0.99, 0},
+		{AlgoMPX, false, 0.99, 0},
+		{AlgoSTOMP, true, 0.99, 0},
+		{AlgoMPX, true, 0, 200},
+		{AlgoSTOMP, true, 0, 200},
+	}
+
+	for _, d := range testdata {
+		a := make([]float64, 100)
+		copy(a, sig[:100])
+		mp, err := New(a, nil, w)
+		if err != nil {
+			t.Fatal(err)
+		}
+		o := NewMPOpts()
+		o.Algorithm = d.algo
+		o.Euclidean = d.euclidean
+		if err = mp.Compute(o); err != nil {
+			t.Fatal(err)
+		}
+		mp.Stream = NewStreamOpts()
+		mp.Stream.FadeFactor = d.fade
+		mp.Stream.Horizon = d.horizon
+		if err = mp.Update(sig[100:]); err != nil {
+			t.Fatalf("Did not expect an error, %v, for %+v", err, d)
+		}
+
+		if mp.Idx[380] < 240 || mp.Idx[380] > 260 {
+			t.Errorf("Expected the recent match near 250, but got %d for %+v", mp.Idx[380], d)
+		}
+	}
+
+	mp, err := New(sig[:100], nil, w)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = mp.Compute(nil); err != nil {
+		t.Fatal(err)
+	}
+	mp.Stream = NewStreamOpts()
+	mp.Stream.FadeFactor = 1.5
+	if err = mp.Update(sig[100:110]); err == nil {
+		t.Errorf("Expected an error for a fade factor above 1")
+	}
+}
+
+func TestUpdateMask(t *testing.T) {
+	w := 20
+	sig := seededNoise(1, 1, 300)
+	for i := 200; i < 260; i++ {
+		sig[i] = 1 + 1e-6*float64(i)
+	}
+
+	for _, algo := range []Algo{AlgoMPX, AlgoSTOMP} {
+		a := make([]float64, 150)
+		copy(a, sig[:150])
+		mp, err := New(a, nil, w)
+		if err != nil {
+			t.Fatal(err)
+		}
+		o := NewMPOpts()
+		o.Algorithm = algo
+		o.FlatThreshold = 0.05
+		if err = mp.Compute(o); err != nil {
+			t.Fatal(err)
+		}
+		if err = mp.Update(sig[150:]); err != nil {
+			t.Fatalf("%s: did not expect an error, %v", algo, err)
+		}
+
+		if len(mp.Mask) != len(mp.MP) {
+			t.Fatalf("%s: expected a mask of length %d, but got %d", algo, len(mp.MP), len(mp.Mask))
+		}
+		for i, masked := range mp.Mask {
+			flat := i >= 200 && i+w <= 260
+			if masked != flat {
+				t.Errorf("%s: expected subsequence %d to be masked %t, but got %t", algo, i, flat, masked)
+				break
+			}
+		}
+	}
+}
+
+func TestMPXStreamRetire(t *testing.T) {
+	sig := seededNoise(1, 1, 400)
+	a := make([]float64, 100)
+	copy(a, sig[:100])
+	mp, err := New(a, nil, 16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = mp.Compute(NewMPOpts()); err != nil {
+		t.Fatal(err)
+	}
+	mp.Stream = NewStreamOpts()
+	mp.Stream.MaxLen = 100
+	if err = mp.Update(sig[100:110]); err != nil {
+		t.Fatal(err)
+	}
+
+	// once the timeseries is at its maximum length, retiring points shifts the
+	// statistics in place so appending reuses the same backing arrays
+	s := mp.mpxStream
+	mu, cov := &s.stats.mu[0], &s.cov[0]
+	for i := 110; i < len(sig); i++ {
+		if err = mp.Update(sig[i : i+1]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(s.stats.mu) != 85 || len(s.cov) != 85 {
+		t.Fatalf("Expected statistics of 85 subsequences, but got %d and %d", len(s.stats.mu), len(s.cov))
+	}
+	if &s.stats.mu[0] != mu || &s.cov[0] != cov {
+		t.Errorf("Expected the statistics to keep their backing arrays")
+	}
+}
@@ -0,0 +1,100 @@
+package util
+
+import (
+	"fmt"
+	"math"
+	"math/cmplx"
+	"sort"
+
+	"gonum.org/v1/gonum/dsp/fourier"
+)
+
+// DominantPeriods estimates up to maxPeriods periods of a timeseries ordered from the
+// strongest to the weakest. Candidates are the peaks of a Hann windowed periodogram,
+// and the frequency of each peak is interpolated between its neighboring bins before
+// being converted to a period in samples. Periods are at least 2 and at most half the
+// length of the timeseries so at least two cycles are observed. Candidates within 5%
+// of a stronger period are dropped as spectral leakage of it.
+func DominantPeriods(ts []float64, maxPeriods int) ([]int, error) {
+	n := len(ts)
+	if n < 4 {
+		return nil, fmt.Errorf("timeseries length, %d, must be at least 4", n)
+	}
+	if maxPeriods < 1 {
+		return nil, fmt.Errorf("maximum number of periods, %d, must be at least 1", maxPeriods)
+	}
+
+	var mean float64
+	for _, v := range ts {
+		mean += v
+	}
+	mean /= float64(n)
+
+	centered := make([]float64, n)
+	var variance float64
+	for i, v := range ts {
+		centered[i] = v - mean
+		variance += centered[i] * centered[i]
+	}
+	if variance == 0 {
+		return nil, fmt.Errorf("standard deviation is zero")
+	}
+
+	// periodogram of the hann windowed series, zero padded to a fast length
+	l := FFTLen(n)
+	windowed := make([]float64, l)
+	for i, v := range centered {
+		windowed[i] = v * 0.5 * (1 - math.Cos(2*math.Pi*float64(i)/float64(n-1)))
+	}
+	coeffs := fourier.NewFFT(l).Coefficients(nil, windowed)
+	power := make([]float64, len(coeffs))
+	for k, c := range coeffs {
+		power[k] = real(c * cmplx.Conj(c))
+	}
+
+	type candidate struct {
+		k     int
+		power float64
+	}
+	var candidates []candidate
+	for k := 1; k < len(power)-1; k++ {
+		if power[k] > power[k-1] && power[k] >= power[k+1] {
+			candidates = append(candidates, candidate{k, power[k]})
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].power > candidates[j].power
+	})
+
+	maxPeriod := n / 2
+	var periods []int
+	for _, c := range candidates {
+		if len(periods) == maxPeriods {
+			break
+		}
+
+		// refine the peak frequency by fitting a parabola through the log power of the
+		// peak bin and its neighbors, which is close to exact for a hann window
+		a, b, g := math.Log(power[c.k-1]), math.Log(power[c.k]), math.Log(power[c.k+1])
+		shift := 0.0
+		if den := a - 2*b + g; den != 0 && !math.IsInf(den, 0) && !math.IsNaN(den) {
+			shift = 0.5 * (a - g) / den
+		}
+		best := int(math.Round(float64(l) / (float64(c.k) + shift)))
+		if best < 2 || best > maxPeriod {
+			continue
+		}
+
+		leaked := false
+		for _, p := range periods {
+			if math.Abs(float64(best-p)) <= math.Max(1, 0.05*float64(p)) {
+				leaked = true
+				break
+			}
+		}
+		if !leaked {
+			periods = append(periods, best)
+		}
+	}
+	return periods, nil
+}
@@ -0,0 +1,60 @@
+package util
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func sines(n int, periods []float64, amps []float64, noise float64) []float64 {
+	ts := make([]float64, n)
+	for i := range ts {
+		for j, p := range periods {
+			ts[i] += amps[j] * math.Sin(2*math.Pi*float64(i)/p)
+		}
+		ts[i] += noise * (rand.Float64() - 0.5)
+	}
+	return ts
+}
+
+func TestDominantPeriods(t *testing.T) {
+	testdata := []struct {
+		ts          []float64
+		maxPeriods  int
+		expected    []int
+		expectedErr bool
+	}{
+		{[]float64{1, 2, 3}, 1, nil, true},
+		{[]float64{1, 1, 1, 1, 1}, 1, nil, true},
+		{sines(1000, []float64{25}, []float64{1}, 0), 0, nil, true},
+		{sines(1000, []float64{25}, []float64{1}, 0), 1, []int{25}, false},
+		{sines(1000, []float64{25}, []float64{1}, 0.5), 1, []int{25}, false},
+		{sines(997, []float64{37}, []float64{1}, 0.2), 1, []int{37}, false},
+		{sines(1000, []float64{25, 10}, []float64{1, 0.6}, 0.2), 2, []int{25, 10}, false},
+		{sines(2000, []float64{100, 7}, []float64{0.5, 1}, 0.2), 2, []int{7, 100}, false},
+	}
+
+	for i, d := range testdata {
+		out, err := DominantPeriods(d.ts, d.maxPeriods)
+		if d.expectedErr {
+			if err == nil {
+				t.Errorf("Expected an error for case %d", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Did not expect an error, %v, for case %d", err, i)
+			continue
+		}
+		if len(out) != len(d.expected) {
+			t.Errorf("Expected %v, but got %v for case %d", d.expected, out, i)
+			continue
+		}
+		for j := range out {
+			if out[j] != d.expected[j] {
+				t.Errorf("Expected %v, but got %v for case %d", d.expected, out, i)
+				break
+			}
+		}
+	}
+}
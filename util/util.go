@@ -80,6 +80,99 @@ func MovMeanStd(ts []float64, m int) ([]float64, []float64, error) {
 	return mean, std, nil
 }
 
+// FlatMask flags each sliding window of length m whose standard deviation is below
+// threshold times the standard deviation of the whole timeseries. These near constant
+// windows are trivially similar to each other once z-normalized.
+func FlatMask(ts []float64, m int, threshold float64) ([]bool, error) {
+	_, std, err := MovMeanStd(ts, m)
+	if err != nil {
+		return nil, err
+	}
+	_, total, err := MovMeanStd(ts, len(ts))
+	if err != nil {
+		return nil, err
+	}
+
+	mask := make([]bool, len(std))
+	for i, s := range std {
+		mask[i] = math.IsNaN(s) || s < threshold*total[0]
+	}
+	return mask, nil
+}
+
+// FFTLen returns the smallest length of at least n whose only prime factors are 2, 3
+// and 5. Transforms of these lengths are much faster than ones of awkward lengths, so
+// series are zero padded to it before convolving.
+func FFTLen(n int) int {
+	if n <= 1 {
+		return 1
+	}
+	best := math.MaxInt64
+	for p5 := 1; p5 < best; p5 *= 5 {
+		for p35 := p5; p35 < best; p35 *= 3 {
+			p := p35
+			for p < n {
+				p *= 2
+			}
+			if p < best {
+				best = p
+			}
+		}
+	}
+	return best
+}
+
+// Diff returns the first difference of a timeseries, out[i] = ts[i+1] - ts[i], which
+// removes any trend or level shift. The result is one point shorter than ts.
+func Diff(ts []float64) ([]float64, error) {
+	if len(ts) < 2 {
+		return nil, fmt.Errorf("timeseries length, %d, must be at least 2 to difference", len(ts))
+	}
+	out := make([]float64, len(ts)-1)
+	for i := range out {
+		out[i] = ts[i+1] - ts[i]
+	}
+	return out, nil
+}
+
+// Detrend returns a copy of a timeseries with its least squares linear trend removed.
+func Detrend(ts []float64) ([]float64, error) {
+	if len(ts) < 2 {
+		return nil, fmt.Errorf("timeseries length, %d, must be at least 2 to detrend", len(ts))
+	}
+	x := make([]float64, len(ts))
+	for i := range x {
+		x[i] = float64(i)
+	}
+	alpha, beta := stat.LinearRegression(x, ts, nil, false)
+
+	out := make([]float64, len(ts))
+	for i, v := range ts {
+		out[i] = v - (alpha + beta*x[i])
+	}
+	return out, nil
+}
+
+// PAA returns the piecewise aggregate approximation of a timeseries, the mean of each
+// consecutive block of factor points. Trailing points that don't fill a block are
+// dropped.
+func PAA(ts []float64, factor int) ([]float64, error) {
+	if factor < 1 {
+		return nil, fmt.Errorf("factor, %d, must be at least 1", factor)
+	}
+	if len(ts) < factor {
+		return nil, fmt.Errorf("timeseries length, %d, must be at least the factor, %d", len(ts), factor)
+	}
+	out := make([]float64, len(ts)/factor)
+	for i := range out {
+		for _, v := range ts[i*factor : (i+1)*factor] {
+			out[i] += v
+		}
+		out[i] /= float64(factor)
+	}
+	return out, nil
+}
+
 // ApplyExclusionZone performs an in place operation on a given matrix
 // profile setting distances around an index to +Inf
 func ApplyExclusionZone(profile []float64, idx, zoneSize int) {
@@ -273,6 +366,18 @@ func P2E(mp []float64, w int) {
 	}
 }
 
+// P2ESquared converts a slice of pearson correlation values to squared euclidean
+// distances. This is only valid for z-normalized time series.
+func P2ESquared(mp []float64, w int) {
+	for i := 0; i < len(mp); i++ {
+		// caps pearson correlation to 1 in case there are floating point accumulated errors
+		if mp[i] > 1 {
+			mp[i] = 1
+		}
+		mp[i] = 2 * float64(w) * (1 - mp[i])
+	}
+}
+
 // E2P converts a slice of euclidean distances to pearson correlation values. This
 // is only valid for z-normalized time series. Negative pearson correlation values will not be
 // discovered
@@ -288,3 +393,29 @@ func E2P(mp []float64, w int) {
 		}
 	}
 }
+
+// Float32To64 converts a slice of float32 values into a newly allocated slice
+// of float64 values. A nil input returns nil.
+func Float32To64(ts []float32) []float64 {
+	if ts == nil {
+		return nil
+	}
+	out := make([]float64, len(ts))
+	for i, v := range ts {
+		out[i] = float64(v)
+	}
+	return out
+}
+
+// Float64To32 converts a slice of float64 values into a newly allocated slice
+// of float32 values. A nil input returns nil.
+func Float64To32(ts []float64) []float32 {
+	if ts == nil {
+		return nil
+	}
+	out := make([]float32, len(ts))
+	for i, v := range ts {
+		out[i] = float32(v)
+	}
+	return out
+}
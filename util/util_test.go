@@ -3,6 +3,8 @@ package util
 import (
 	"math"
 	"testing"
+
+	"gonum.org/v1/gonum/floats"
 )
 
 func TestZNormalize(t *testing.T) {
@@ -190,3 +192,218 @@ func TestDiagBatchingScheme(t *testing.T) {
 		}
 	}
 }
+
+func TestFloat32To64(t *testing.T) {
+	testdata := []struct {
+		data     []float32
+		expected []float64
+	}{
+		{nil, nil},
+		{[]float32{}, []float64{}},
+		{[]float32{1, -2.5, 0.25}, []float64{1, -2.5, 0.25}},
+	}
+
+	for _, d := range testdata {
+		out := Float32To64(d.data)
+		if (out == nil) != (d.expected == nil) {
+			t.Errorf("Expected %v, but got %v for %v", d.expected, out, d)
+			continue
+		}
+		if len(out) != len(d.expected) {
+			t.Errorf("Expected %d elements, but got %d, %v", len(d.expected), len(out), d)
+			continue
+		}
+		for i := range out {
+			if out[i] != d.expected[i] {
+				t.Errorf("Expected %v, but got %v for %v", d.expected, out, d)
+				break
+			}
+		}
+		back := Float64To32(out)
+		for i := range back {
+			if back[i] != d.data[i] {
+				t.Errorf("Expected %v, but got %v", d.data, back)
+				break
+			}
+		}
+	}
+}
+
+func TestP2ESquared(t *testing.T) {
+	testdata := []struct {
+		corr     []float64
+		w        int
+		expected []float64
+	}{
+		{[]float64{}, 4, []float64{}},
+		{[]float64{1, 0.5, 0, 1.0000001}, 4, []float64{0, 4, 8, 0}},
+	}
+
+	for _, d := range testdata {
+		out := make([]float64, len(d.corr))
+		copy(out, d.corr)
+		P2ESquared(out, d.w)
+
+		dist := make([]float64, len(d.corr))
+		copy(dist, d.corr)
+		P2E(dist, d.w)
+
+		for i := range out {
+			if math.Abs(out[i]-d.expected[i]) > 1e-7 {
+				t.Errorf("Expected %v, but got %v", d.expected, out)
+				break
+			}
+			if math.Abs(out[i]-dist[i]*dist[i]) > 1e-7 {
+				t.Errorf("Expected the square of %v, but got %v", dist, out)
+				break
+			}
+		}
+	}
+}
+
+func TestFlatMask(t *testing.T) {
+	testdata := []struct {
+		ts        []float64
+		m         int
+		threshold float64
+		expected  []bool
+	}{
+		{[]float64{}, 2, 0.1, nil},
+		{[]float64{1, 2, 3}, 1, 0.1, nil},
+		{[]float64{1, 1, 1, 1, 5, -3, 4, 1}, 3, 0.1, []bool{true, true, false, false, false, false}},
+		{[]float64{1, 1.01, 1, 1.01, 5, -3, 4, 1}, 3, 0.1, []bool{true, true, false, false, false, false}},
+		{[]float64{1, 1.01, 1, 1.01, 5, -3, 4, 1}, 3, 0, []bool{false, false, false, false, false, false}},
+	}
+
+	for _, d := range testdata {
+		out, err := FlatMask(d.ts, d.m, d.threshold)
+		if d.expected == nil {
+			if err == nil {
+				t.Errorf("Expected an error for %+v", d)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Did not expect an error, %v, for %+v", err, d)
+			continue
+		}
+		if len(out) != len(d.expected) {
+			t.Errorf("Expected %v, but got %v", d.expected, out)
+			continue
+		}
+		for i := range out {
+			if out[i] != d.expected[i] {
+				t.Errorf("Expected %v, but got %v", d.expected, out)
+				break
+			}
+		}
+	}
+}
+
+func TestFFTLen(t *testing.T) {
+	testdata := []struct {
+		n        int
+		expected int
+	}{
+		{0, 1},
+		{1, 1},
+		{7, 8},
+		{11, 12},
+		{64, 64},
+		{97, 100},
+		{1001, 1024},
+		{1025, 1080},
+	}
+
+	for _, d := range testdata {
+		if out := FFTLen(d.n); out != d.expected {
+			t.Errorf("Expected %d, but got %d for %d", d.expected, out, d.n)
+		}
+	}
+}
+
+func TestDiff(t *testing.T) {
+	testdata := []struct {
+		ts       []float64
+		expected []float64
+	}{
+		{[]float64{1}, nil},
+		{[]float64{1, 3, 2, 2}, []float64{2, -1, 0}},
+	}
+
+	for _, d := range testdata {
+		out, err := Diff(d.ts)
+		if d.expected == nil {
+			if err == nil {
+				t.Errorf("Expected an error for %v", d.ts)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Did not expect an error, %v, for %v", err, d.ts)
+			continue
+		}
+		if !floats.Equal(out, d.expected) {
+			t.Errorf("Expected %v, but got %v", d.expected, out)
+		}
+	}
+}
+
+func TestPAA(t *testing.T) {
+	testdata := []struct {
+		ts       []float64
+		factor   int
+		expected []float64
+	}{
+		{[]float64{1, 2}, 0, nil},
+		{[]float64{1, 2}, 3, nil},
+		{[]float64{1, 3, 2, 2}, 1, []float64{1, 3, 2, 2}},
+		{[]float64{1, 3, 2, 2, 5}, 2, []float64{2, 2}},
+		{[]float64{1, 3, 2, 4, 6, 8}, 3, []float64{2, 6}},
+	}
+
+	for _, d := range testdata {
+		out, err := PAA(d.ts, d.factor)
+		if d.expected == nil {
+			if err == nil {
+				t.Errorf("Expected an error for %v with factor %d", d.ts, d.factor)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Did not expect an error, %v, for %v with factor %d", err, d.ts, d.factor)
+			continue
+		}
+		if !floats.Equal(out, d.expected) {
+			t.Errorf("Expected %v, but got %v", d.expected, out)
+		}
+	}
+}
+
+func TestDetrend(t *testing.T) {
+	testdata := []struct {
+		ts       []float64
+		expected []float64
+	}{
+		{[]float64{1}, nil},
+		{[]float64{1, 3, 5, 7}, []float64{0, 0, 0, 0}},
+		{[]float64{2, 5, 2, 5}, []float64{-0.6, 1.8, -1.8, 0.6}},
+	}
+
+	for _, d := range testdata {
+		out, err := Detrend(d.ts)
+		if d.expected == nil {
+			if err == nil {
+				t.Errorf("Expected an error for %v", d.ts)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Did not expect an error, %v, for %v", err, d.ts)
+			continue
+		}
+		if !floats.EqualApprox(out, d.expected, 1e-9) {
+			t.Errorf("Expected %v, but got %v", d.expected, out)
+		}
+	}
+}
@@ -2,6 +2,7 @@ package matrixprofile
 
 import (
 	"fmt"
+	"image/color"
 	"os"
 
 	"gonum.org/v1/plot"
@@ -111,6 +112,61 @@ func plotMP(sigPts, mpPts plotter.XYs, motifPts [][]plotter.XYs, discordPts []pl
 	return err
 }
 
+// overlayGroup is a set of spans of the signal shaded in the same color and keyed by
+// a single legend entry.
+type overlayGroup struct {
+	label string
+	spans [][2]float64 // start and end x of each shaded span
+}
+
+func plotOverlay(sigPts plotter.XYs, groups []overlayGroup, filename string) error {
+	p, err := plot.New()
+	if err != nil {
+		return err
+	}
+	p.Title.Text = "signal"
+
+	ymin, ymax := 0.0, 0.0
+	for i, pt := range sigPts {
+		if i == 0 || pt.Y < ymin {
+			ymin = pt.Y
+		}
+		if i == 0 || pt.Y > ymax {
+			ymax = pt.Y
+		}
+	}
+
+	// regions are added first so the signal is drawn on top of them
+	for i, g := range groups {
+		r, gr, b, _ := plotutil.Color(i).RGBA()
+		fill := color.NRGBA{R: uint8(r >> 8), G: uint8(gr >> 8), B: uint8(b >> 8), A: 80}
+		for j, span := range g.spans {
+			region, err := plotter.NewPolygon(plotter.XYs{
+				{X: span[0], Y: ymin}, {X: span[1], Y: ymin},
+				{X: span[1], Y: ymax}, {X: span[0], Y: ymax},
+			})
+			if err != nil {
+				return err
+			}
+			region.Color = fill
+			region.LineStyle.Width = 0
+			p.Add(region)
+			if j == 0 {
+				p.Legend.Add(g.label, region)
+			}
+		}
+	}
+
+	line, err := plotter.NewLine(sigPts)
+	if err != nil {
+		return err
+	}
+	line.Color = color.Black
+	p.Add(line)
+
+	return p.Save(vg.Points(1200), vg.Points(400), filename)
+}
+
 func plotKMP(sigPts, mpPts []plotter.XYs, filename string) error {
 	var err error
 